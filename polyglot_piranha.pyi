@@ -9,7 +9,7 @@
 # express or implied. See the License for the specific language governing permissions and
 # limitations under the License.
 
-from typing import List, Optional
+from typing import List, Optional, Union
 
 
 def execute_piranha(piranha_argument: PiranhaArguments) -> list[PiranhaOutputSummary]:
@@ -25,6 +25,46 @@ def execute_piranha(piranha_argument: PiranhaArguments) -> list[PiranhaOutputSum
     """
     ...
 
+def execute_piranha_with_stats(
+    piranha_argument: PiranhaArguments,
+) -> tuple[list[PiranhaOutputSummary], PiranhaStats]:
+    """
+    Executes piranha for the given `piranha_arguments`, like `execute_piranha`, and also returns the statistics of the run
+    Parameters
+    ------------
+        piranha_arguments: Piranha Arguments
+            Configurations for piranha
+    Returns
+    ------------
+    List of `PiranhaOutPutSummary`, along with the `PiranhaStats` of all the files processed by Piranha (including the ones that were neither matched nor rewritten)
+    """
+    ...
+
+def execute_piranha_on_content(
+    code: str,
+    language: str,
+    rule_graph: RuleGraph,
+    line_range: Optional[tuple[int, int]] = None,
+) -> tuple[str, Optional[PiranhaOutputSummary]]:
+    """
+    Executes piranha on the given `code` in memory (i.e. without reading or writing any file)
+    Parameters
+    ------------
+        code: str
+            The source code to transform
+        language: str
+            The target language
+        rule_graph: RuleGraph
+            The graph constructed via the RuleGraph DSL
+        line_range: Optional[tuple[int, int]]
+            The (1-based and inclusive) range of lines to clean up, e.g. the lines selected in an editor.
+            The matches whose edit is not entirely within these lines are skipped, and listed in the `out_of_range_matches` of the `PiranhaOutputSummary`
+    Returns
+    ------------
+    The rewritten code, along with the `PiranhaOutputSummary` (if Piranha matched, rewrote or skipped the code)
+    """
+    ...
+
 class PiranhaArguments:
     """
     A class to capture Piranha's configurations
@@ -48,7 +88,35 @@ class PiranhaArguments:
         global_tag_prefix: Optional[str] = 'GLOBAL_TAG',
         delete_file_if_empty: Optional[bool] = None,
         path_to_output: Optional[str] = None,
-        allow_dirty_ast: Optional[bool] = None
+        allow_dirty_ast: Optional[bool] = None,
+        aggressive_simplification: Optional[bool] = None,
+        cleanup_imports: Optional[bool] = None,
+        normalize_go_layout: Optional[bool] = None,
+        cleanup_tests: Optional[bool] = None,
+        flatten_else: Optional[bool] = None,
+        no_prefilter: Optional[bool] = None,
+        include_vendor: Optional[bool] = None,
+        include_generated: Optional[bool] = None,
+        thread_count: Optional[int] = None,
+        flags_file: Optional[str] = None,
+        flag_comment_pattern: Optional[str] = None,
+        format_output: Optional[bool] = None,
+        formatter_command: Optional[str] = None,
+        use_default_as_treatment: Optional[bool] = None,
+        delete_unreachable: Optional[bool] = None,
+        delete_empty_functions: Optional[bool] = None,
+        flag_states: Optional[dict] = None,
+        no_ignore: Optional[bool] = None,
+        max_fixpoint_iterations: Optional[int] = None,
+        preserve_leading_comments: Optional[bool] = None,
+        delete_unselected_implementations: Optional[bool] = None,
+        simplify_boolean_return: Optional[bool] = None,
+        treatment: Optional[str] = None,
+        treated_value: Optional[bool] = None,
+        dedupe_statements: Optional[bool] = None,
+        per_file_timeout: Optional[float] = None,
+        rules: Optional[List[Union[Rule, dict]]] = None,
+        edges: Optional[List[Union[OutgoingEdges, dict]]] = None
     ):
         """
         Constructs `PiranhaArguments`
@@ -56,15 +124,19 @@ class PiranhaArguments:
         Parameters
         ------------
             language: str
-                the target language
+                the target language, or the path to the config (`.toml`) of a custom language, whose tree-sitter grammar is loaded at runtime
             path_to_codebase: str
                 Path to source code folder or file
             keyword arguments: _
                  substitutions (dict): Substitutions to instantiate the initial set of rules
+                 include (list[str]): Paths to include (as glob patterns, also matched against the path under the code base), e.g. `**/*.go` or `services/checkout/...` (i.e. a package and all its subpackages). All the paths are included by default
+                 exclude (list[str]): Paths to exclude (as glob patterns, also matched against the path under the code base), e.g. `**/vendor/**`. The exclusion takes precedence over the inclusion, and the usages of the package rules in the excluded files of a package are only reported (with the `skip_reason` of the output summary)
                  path_to_configurations (str): Directory containing the configuration files - `piranha_arguments.toml`, `rules.toml`, and  `edges.toml`
                  rule_graph (RuleGraph): The rule graph constructed via RuleGraph DSL
+                 rules (list[Rule | dict]): Rules added to the ones of `path_to_configurations` and `rule_graph`, either as `Rule` objects or as dicts with the keys of the `rules.toml` entries (e.g. `{"name": "delete_flag", "query": "...", "groups": ["cleanup"]}`, with lists rather than sets)
+                 edges (list[OutgoingEdges | dict]): Edges added to the ones of `path_to_configurations` and `rule_graph`, either as `OutgoingEdges` objects or as dicts with the keys of the `edges.toml` entries (e.g. `{"from": "delete_flag", "to": ["cleanup"], "scope": "Parent"}`)
                  code_snippet (str): The input code snippet to transform
-                 dry_run (bool): Disables in-place rewriting of code
+                 dry_run (bool): Disables in-place rewriting of code. The output summaries contain the unified diff of each file instead
                  cleanup_comments (bool): Enables deletion of associated comments
                  cleanup_comments_buffer (int): The number of lines to consider for cleaning up the comments
                  number_of_ancestors_in_parent_scope (int): The number of ancestors considered when PARENT rules
@@ -73,6 +145,32 @@ class PiranhaArguments:
                  delete_file_if_empty (bool): User option that determines whether an empty file will be deleted
                  path_to_output (str): Path to the output json file
                  allow_dirty_ast (bool): Allows syntax errors in the input source code 
+                 aggressive_simplification (bool): Simplifies boolean expressions even if it drops operands with side effects (e.g. `foo() && false` -> `false`)
+                 cleanup_imports (bool): Deletes the imports that are not referred anymore (i.e. whose package was referred before the rewrite), after all the rules are applied (Go only). Disabled by default.
+                 normalize_go_layout (bool): Normalizes the layout of the rewritten files (i.e. indentation with tabs, blank lines, trailing whitespace and sorted imports), after all the rules are applied (Go only). Unlike `gofmt`, the alignment within the lines is left as is (`gofmt` itself can be run as the `formatter_command`). Enabled by default.
+                 cleanup_tests (bool): Deletes the tests exercising the stale flag, i.e. referring to the `stale_flag_name` substitution (Go only). Disabled by default.
+                 flatten_else (bool): Flattens the `else` branch of the `if` statements whose consequence ends with a terminating statement (e.g. `return`), once a flag guard is collapsed (Go and Java). Disabled by default.
+                 simplify_boolean_return (bool): Reduces the `if` statements returning a boolean literal in both branches to a single `return`, once a flag guard is collapsed (Go, Java and TypeScript), e.g. `if c { return true } else { return false }` becomes `return c`. Disabled by default.
+                 dedupe_statements (bool): Deletes a statement identical to the previous one once a flag guard is collapsed, when it is deemed free of side effects, i.e. a call to one of the `pure_functions` (substitution) without nested call, or the assignment of a literal or a variable (Go and Java). Disabled by default.
+                 no_prefilter (bool): Disables the pre-filter, i.e. the files that do not contain any of the substitutions of the current rules are parsed and analyzed too (for debugging)
+                 no_ignore (bool): Analyzes (and rewrites) the files ignored by the `.gitignore` (and `.piranhaignore`) files too. By default, they are skipped while walking the code base
+                 include_vendor (bool): Rewrites the Go files under `vendor` directories too. By default, only their usages are reported (with the `skip_reason` of the output summary)
+                 include_generated (bool): Rewrites the generated Go files (i.e. with a `// Code generated ... DO NOT EDIT.` comment) too. By default, only their usages are reported (with the `skip_reason` of the output summary)
+                 thread_count (int): The number of threads used to process the files of the code base. Defaults to the number of available cores.
+                 max_fixpoint_iterations (int): The maximum number of times the cleanup rules (e.g. the boolean simplifications, or the deletion of the unused variables) are re-applied to a rewritten function, until none of them changes it anymore (Go only). If the function still changes after that many iterations, the rewrites of its file are rolled back, and the file is reported with the `skip_reason` "cleanup did not reach a fixpoint — rolled back" in the output summary, rather than failing the whole run. Defaults to 10.
+                 per_file_timeout (float): The maximum time (in seconds) spent processing a single file. A file exceeding it is left unmodified, and reported with the `skip_reason` "processing timed out — clean up separately" in the output summary, rather than failing the whole run. No timeout by default.
+                 flags_file (str): Path to a JSON manifest (i.e. an array of substitutions, identified by their `stale_flag_name`) of the stale flags to clean up in a single run. The `substitutions` are shared by all the flags
+                 flag_comment_pattern (str): Deletes the comments matching this regex (e.g. `^// FLAG: new_checkout$`) that are immediately adjacent to a deleted node (i.e. right above it, or on its last line), regardless of `cleanup_comments`
+                 preserve_leading_comments (bool): Re-attaches the comments immediately preceding a deleted node to its next surviving sibling, rather than deleting them (or leaving them orphaned), regardless of `cleanup_comments`. The comments are deleted along with the node when it is the last one of its block. Disabled by default.
+                 format_output (bool): Collapses the consecutive blank lines and deletes the trailing whitespace of the rewritten files, after all the rules are applied (any language). Disabled by default.
+                 formatter_command (str): The command formatting the rewritten files after `format_output` (e.g. `black -q -`), reading the code from stdin and writing it to stdout. The code is left as is if the command fails
+                 use_default_as_treatment (bool): Replaces the flag calls (e.g. `exp.BoolValueCtx(ctx, "new_checkout", false)`) with their default value argument, rather than with `treated` (Go only). Disabled by default.
+                 treatment (str): `cleanup` (the default) cleans up the code, while `flip` only flips the default value of the flag API calls to `treated`, e.g. `exp.BoolValue("new_checkout")` becomes `exp.BoolValueWithDefault("new_checkout", true)` (Go only).
+                 treated_value (bool): The value the stale flags are treated as, which overrides the `treated` substitution.
+                 delete_unreachable (bool): Deletes the unexported functions and methods that are not referred in their package anymore, after all the rules are applied (Go only), until a fixpoint is reached. The deleted functions are listed in the output summaries. Disabled by default.
+                 delete_unselected_implementations (bool): Deletes the struct types that are not selected anymore once the flag is cleaned up, along with their methods and constructors, when nothing else refers to them (Go only). The types referred from another package are retained, and listed in the output summaries along with these references. Disabled by default.
+                 delete_empty_functions (bool): Deletes the unexported functions and methods whose body is emptied by the cleanup, once these are not referred anywhere in the code base (Go only). The functions that were empty before the cleanup are retained. Disabled by default.
+                 flag_states (dict): Whether each stale flag is treated as enabled (`True`) or disabled (`False`), e.g. `{"new_checkout": False}`. It sets the `treated` substitution of the flags of the `flags_file` (the other flags are cleaned up as if they were listed in it), such that the same configuration drives both the cleanup shipping the feature and the one killing it
         """
         ...
 
@@ -86,6 +184,17 @@ class PiranhaOutputSummary:
     content: content of the file after all the rewrites
     matches: All the occurrences of "match-only" rules
     rewrites: All the applied edits
+    diff: Unified diff between the original and the final content of the file (only populated for `dry_run`)
+    skip_reason: The reason why the file was not rewritten (e.g. generated Go code), in which case the matches are the usages of the rules
+    suppressed_matches: The matches of the rewrite rules that were not applied, as they overlap a Go statement or declaration annotated with `//piranha:keep`
+    out_of_range_matches: The matches of the rules that were skipped, as their edit is not entirely within the `line_range` of the code
+    deleted_functions: The Go functions of the file deleted by `delete_unreachable`, as they are not referred in their package anymore
+    unselected_implementations: The Go struct types of the file that are not selected anymore, deleted by `delete_unselected_implementations` unless referred from another package
+    unresolved_usages: The usages of the stale flags left in the file once all the rules have been applied, to be reviewed manually
+    flipped_sites: The number of flag API calls of the file whose default value was flipped (only populated for the `flip` treatment)
+    stats: The statistics of the processing of the file (e.g. the number of matches evaluated, and the time spent parsing)
+    invalid_rewrite: The rewrite that introduced syntax errors in the file, in which case all its rewrites were rolled back (i.e. the file is left unmodified)
+    fixpoint_violation: The rewrite that running Piranha again would still apply to the file (only populated with `--verify-fixpoint`)
     """
 
     path: str
@@ -103,6 +212,193 @@ class PiranhaOutputSummary:
     rewrites: list[Edit]
     "All the applied edits"
 
+    diff: str
+    "Unified diff between the original and the final content of the file (only populated for `dry_run`)"
+
+    skip_reason: Optional[str]
+    "The reason why the file was not rewritten (e.g. generated Go code), in which case the matches are the usages of the rules"
+
+    suppressed_matches: list[tuple[str, Match]]
+    "The matches of the rewrite rules that were not applied, as they overlap a Go statement or declaration annotated with `//piranha:keep`"
+
+    out_of_range_matches: list[tuple[str, Match]]
+    "The matches of the rules that were skipped, as their edit is not entirely within the `line_range` of the code"
+
+    deleted_functions: list[DeletedFunction]
+    "The Go functions of the file deleted by `delete_unreachable`, as they are not referred in their package anymore"
+
+    unselected_implementations: list[UnselectedImplementation]
+    "The Go struct types of the file that are not selected anymore, deleted by `delete_unselected_implementations` unless referred from another package"
+
+    unresolved_usages: list[UnresolvedUsage]
+    "The usages of the stale flags left in the file once all the rules have been applied, to be reviewed manually"
+
+    flipped_sites: int
+    "The number of flag API calls of the file whose default value was flipped (only populated for the `flip` treatment)"
+
+    stats: PiranhaStats
+    "The statistics of the processing of the file (e.g. the number of matches evaluated, and the time spent parsing)"
+
+    invalid_rewrite: Optional[InvalidRewrite]
+    "The rewrite that introduced syntax errors in the file, in which case all its rewrites were rolled back (i.e. the file is left unmodified)"
+
+    fixpoint_violation: Optional[str]
+    "The rewrite that running Piranha again would still apply to the file (only populated with `--verify-fixpoint`)"
+
+class PiranhaStats:
+    """
+    The statistics of the processing of a file, or of a whole run of Piranha
+
+    Attributes
+    ----------
+    files: The number of files processed
+    matches_evaluated: The number of matches of the tree-sitter queries of the rules that were evaluated (i.e. before their filters)
+    edits_applied: The number of edits applied (including the ones of the post-processing, e.g. the import cleanup)
+    fixpoint_iterations: The number of iterations of the `fixpoint_rules` over the rewritten functions (Go only)
+    matches_evaluated_per_rule: The number of matches evaluated for each rule
+    parse_time_ms: The time spent parsing (and re-parsing, after each edit) the files, in milliseconds
+    match_time_ms: The time spent matching the rules (i.e. evaluating their queries and filters), in milliseconds
+    write_time_ms: The time spent writing (or deleting) the files, in milliseconds
+    """
+
+    files: int
+    "The number of files processed"
+
+    matches_evaluated: int
+    "The number of matches of the tree-sitter queries of the rules that were evaluated (i.e. before their filters)"
+
+    edits_applied: int
+    "The number of edits applied (including the ones of the post-processing, e.g. the import cleanup)"
+
+    fixpoint_iterations: int
+    "The number of iterations of the `fixpoint_rules` over the rewritten functions (Go only)"
+
+    matches_evaluated_per_rule: dict[str, int]
+    "The number of matches evaluated for each rule"
+
+    parse_time_ms: float
+    "The time spent parsing (and re-parsing, after each edit) the files, in milliseconds"
+
+    match_time_ms: float
+    "The time spent matching the rules (i.e. evaluating their queries and filters), in milliseconds"
+
+    write_time_ms: float
+    "The time spent writing (or deleting) the files, in milliseconds"
+
+class DeletedFunction:
+    """
+    A Go function (or method) deleted by `delete_unreachable`, along with the cleanup that made it unreachable
+
+    Attributes
+    ----------
+    name: Name of the function
+    path: Path to the file declaring the function
+    rule_chain: The rules whose rewrites deleted the references to the function (in the order they were applied)
+    deleted_callers: The deleted functions that referred to the function, if it is transitively unreachable
+    """
+
+    name: str
+    "Name of the function"
+
+    path: str
+    "Path to the file declaring the function"
+
+    rule_chain: list[str]
+    "The rules whose rewrites deleted the references to the function (in the order they were applied)"
+
+    deleted_callers: list[str]
+    "The deleted functions that referred to the function, if it is transitively unreachable"
+
+class InvalidRewrite:
+    """
+    A rewrite that introduced syntax errors (i.e. `ERROR` or `MISSING` nodes that were not in the original content), because of which all the rewrites of its file were rolled back
+
+    Attributes
+    ----------
+    path: Path to the file
+    rule: The rule whose rewrite introduced the syntax errors (or `format_output`, if these were introduced by the formatting)
+    original_snippet: The lines of the code encompassing the rewrite, before it
+    replacement_snippet: The same lines, once rewritten
+    """
+
+    path: str
+    "Path to the file"
+
+    rule: str
+    "The rule whose rewrite introduced the syntax errors (or `format_output`, if these were introduced by the formatting)"
+
+    original_snippet: str
+    "The lines of the code encompassing the rewrite, before it"
+
+    replacement_snippet: str
+    "The same lines, once rewritten"
+
+class UnselectedImplementation:
+    """
+    A Go struct type that is not selected anymore once the flag is cleaned up, which `delete_unselected_implementations`
+    deletes along with its methods and its constructors, unless it is referred from another package
+
+    Attributes
+    ----------
+    name: Name of the type
+    path: Path to the file declaring the type
+    methods: The methods of the type (deleted along with it)
+    constructors: The constructors of the type, i.e. the functions returning it (deleted along with it)
+    external_references: The references to the type (or to its constructors) from other packages (as `path:line`), which prevent its deletion
+    single_implementations: The interfaces implemented by the type that are left with a single implementation once it is deleted, along with this implementation
+    """
+
+    name: str
+    "Name of the type"
+
+    path: str
+    "Path to the file declaring the type"
+
+    methods: list[str]
+    "The methods of the type (deleted along with it)"
+
+    constructors: list[str]
+    "The constructors of the type, i.e. the functions returning it (deleted along with it)"
+
+    external_references: list[str]
+    "The references to the type (or to its constructors) from other packages (as `path:line`), which prevent its deletion"
+
+    single_implementations: list[tuple[str, str]]
+    "The interfaces implemented by the type that are left with a single implementation once it is deleted, along with this implementation"
+
+class UnresolvedUsage:
+    """
+    A usage of a stale flag left in the code once all the rules have been applied, i.e. a string literal of the flag
+    name, or a reference to a variable seeded from the flag that was not fully propagated
+
+    Attributes
+    ----------
+    flag: Name of the stale flag (i.e. its `stale_flag_name`)
+    path: Path to the file
+    line: The (1-based) line of the usage, in the final content of the file
+    enclosing_function: Name of the function (or method) enclosing the usage, if any
+    snippet: The (trimmed) line of the usage
+    variable: The variable seeded from the flag that the usage refers to, if it is not a string literal of the flag name
+    """
+
+    flag: str
+    "Name of the stale flag (i.e. its `stale_flag_name`)"
+
+    path: str
+    "Path to the file"
+
+    line: int
+    "The (1-based) line of the usage, in the final content of the file"
+
+    enclosing_function: Optional[str]
+    "Name of the function (or method) enclosing the usage, if any"
+
+    snippet: str
+    "The (trimmed) line of the usage"
+
+    variable: Optional[str]
+    "The variable seeded from the flag that the usage refers to, if it is not a string literal of the flag name"
+
 class Edit:
     """
      A class to represent an edit performed by Piranha
@@ -112,6 +408,9 @@ class Edit:
     p_match: The match representing the target site of the edit
     replacement_string: The string to replace the substring encompassed by the match
     matched_rule: The rule used for creating this match-replace
+    original_snippet: The lines of the original code encompassing the match
+    replacement_snippet: The lines encompassing the match, after the edit is applied
+    flag: The stale flag (of the flags manifest) whose cleanup performed this edit, if any
     """
 
     p_match: Match
@@ -123,6 +422,15 @@ class Edit:
     replacement_string: str
     "The string to replace the substring encompassed by the match"
 
+    original_snippet: str
+    "The lines of the original code encompassing the match"
+
+    replacement_snippet: str
+    "The lines encompassing the match, after the edit is applied"
+
+    flag: Optional[str]
+    "The stale flag (of the flags manifest) whose cleanup performed this edit, if any"
+
 class Match:
     """
      A class to represent a match
@@ -169,7 +477,7 @@ class Filter:
     "AST pattern that SHOULD match subtrees of `enclosing_node`. " \
     "Number of matches should be within the range of `at_least` and `at_most`."
     at_least: int
-    "The minimum number of times the contains query should match in the enclosing node"
+    "The minimum number of times the contains query should match in the enclosing node (1 by default, or `at_most` if lower)"
     at_most: int
     "The maximum number of times the contains query should match in the enclosing node"
     child_count: int
@@ -220,6 +528,12 @@ class Rule:
     "Filters to test before applying a rule"
     is_seed_rule: bool
     "Marks a rule as a seed rule"
+    is_rvalue: bool
+    "Only accepts the matches in r-value position (i.e. not the target of an assignment)"
+    defined_name: str
+    "The name defined by the matches of the rule, which are only deleted once the name is not referred anymore"
+    unwraps_block: bool
+    "Only accepts the matches of a block that can be unwrapped without its declarations colliding with the enclosing scope (Go only)"
 
     def __init__(
         self,
@@ -231,6 +545,9 @@ class Rule:
         holes: set[str] = set(),
         filters: set[Filter] = set(),
         is_seed_rule: bool = True,
+        is_rvalue: bool = False,
+        defined_name: str = "",
+        unwraps_block: bool = False,
     ):
         """
         Constructs `Rule`
@@ -253,6 +570,12 @@ class Rule:
                 Filters to test before applying a rule
             is_seed_rule: bool
                 Marks a rule as a seed rule
+            is_rvalue: bool
+                Only accepts the matches in r-value position (i.e. not the target of an assignment)
+            defined_name: str
+                The name defined by the matches of the rule, which are only deleted once the name is not referred anymore
+            unwraps_block: bool
+                Only accepts the matches of a block that can be unwrapped without its declarations colliding with the enclosing scope (Go only)
         """
         ...
 
@@ -309,6 +632,14 @@ class RuleGraph:
         """
         ...
 
+    def to_dot(self) -> str:
+        """
+        Serializes the rule graph to Graphviz DOT (for debugging).
+        The seed rules are filled boxes, the dummy rules are dashed boxes and the groups are ellipses.
+        The edges are labeled with their scope.
+        """
+        ...
+
 class TSQuery:
     "Captures a Tree sitter query"
     def query(self):
@@ -12,9 +12,16 @@
 */
 #![allow(deprecated)] // This prevents cargo clippy throwing warning for deprecated use.
 use models::{
-  edit::Edit, filter::Filter, matches::Match, outgoing_edges::OutgoingEdges,
-  piranha_arguments::PiranhaArguments, piranha_output::PiranhaOutputSummary, rule::Rule,
-  rule_graph::RuleGraph, source_code_unit::SourceCodeUnit,
+  edit::Edit,
+  filter::Filter,
+  language::PiranhaLanguage,
+  matches::Match,
+  outgoing_edges::OutgoingEdges,
+  piranha_arguments::{PiranhaArguments, PiranhaArgumentsBuilder},
+  piranha_output::PiranhaOutputSummary,
+  rule::Rule,
+  rule_graph::RuleGraph,
+  source_code_unit::SourceCodeUnit,
 };
 
 pub mod models;
@@ -22,22 +29,48 @@ pub mod models;
 mod tests;
 pub mod utilities;
 
-use std::{collections::HashMap, fs::File, io::Write, path::PathBuf};
+use std::{
+  collections::{HashMap, HashSet},
+  path::{Path, PathBuf},
+};
 
 use itertools::Itertools;
-use log::{debug, info};
+use log::{debug, info, warn};
 
-use crate::models::rule_store::RuleStore;
+use crate::{
+  models::{
+    default_configs::{DELETE_EMPTY_PACKAGE_FILE, OUT_OF_SCOPE_SKIP_REASON, STALE_FLAG_NAME},
+    language::SupportedLanguage,
+    piranha_output::{
+      DeletedFunction, InvalidRewrite, PiranhaStats, UnresolvedUsage, UnselectedImplementation,
+    },
+    rule_store::RuleStore,
+  },
+  utilities::{
+    go_package_cleanup::get_package, read_file, tree_sitter_utilities::count_references,
+  },
+};
 
 use pyo3::prelude::{pyfunction, pymodule, wrap_pyfunction, PyModule, PyResult, Python};
-use tempdir::TempDir;
+use rayon::{
+  prelude::{IntoParallelIterator, IntoParallelRefMutIterator, ParallelIterator},
+  ThreadPool, ThreadPoolBuilder,
+};
+use tree_sitter::{Parser, Range};
 
 #[pymodule]
 fn polyglot_piranha(_py: Python<'_>, m: &PyModule) -> PyResult<()> {
   pyo3_log::init();
   m.add_function(wrap_pyfunction!(execute_piranha, m)?)?;
+  m.add_function(wrap_pyfunction!(execute_piranha_with_stats, m)?)?;
+  m.add_function(wrap_pyfunction!(execute_piranha_on_content, m)?)?;
   m.add_class::<PiranhaArguments>()?;
   m.add_class::<PiranhaOutputSummary>()?;
+  m.add_class::<DeletedFunction>()?;
+  m.add_class::<UnselectedImplementation>()?;
+  m.add_class::<UnresolvedUsage>()?;
+  m.add_class::<InvalidRewrite>()?;
+  m.add_class::<PiranhaStats>()?;
   m.add_class::<Edit>()?;
   m.add_class::<Match>()?;
   m.add_class::<RuleGraph>()?;
@@ -56,6 +89,18 @@ fn polyglot_piranha(_py: Python<'_>, m: &PyModule) -> PyResult<()> {
 /// For each file, it reports its content after the rewrite, the list of matches and the list of rewrites.
 #[pyfunction]
 pub fn execute_piranha(piranha_arguments: &PiranhaArguments) -> Vec<PiranhaOutputSummary> {
+  execute_piranha_with_stats(piranha_arguments).0
+}
+
+/// Executes piranha for the given `piranha_arguments`, like `execute_piranha`.
+///
+/// Returns Piranha Output Summary for each file touched or analyzed by Piranha (each with the statistics of the file),
+/// along with the statistics of the whole run, i.e. of all the files processed by Piranha (including the ones that
+/// were neither matched nor rewritten, which have no summary).
+#[pyfunction]
+pub fn execute_piranha_with_stats(
+  piranha_arguments: &PiranhaArguments,
+) -> (Vec<PiranhaOutputSummary>, PiranhaStats) {
   info!("Executing Polyglot Piranha !!!");
 
   let mut piranha = Piranha::new(piranha_arguments);
@@ -66,11 +111,38 @@ pub fn execute_piranha(piranha_arguments: &PiranhaArguments) -> Vec<PiranhaOutpu
     .iter()
     .map(PiranhaOutputSummary::new)
     .collect_vec();
-  log_piranha_output_summaries(&summaries);
-  summaries
+  let stats = piranha.get_stats();
+  log_piranha_output_summaries(&summaries, &stats);
+  (summaries, stats)
+}
+
+/// Executes piranha on the given `code`, entirely in memory (i.e. without reading or writing any file).
+///
+/// # Arguments:
+/// * code: The source code to transform
+/// * language: Target language
+/// * rule_graph: the graph constructed via the RuleGraph DSL
+/// * line_range: The (1-based and inclusive) range of lines to clean up, e.g. the lines selected in an editor.
+///   The matches whose edit is not entirely within these lines are skipped, and listed in the `out_of_range_matches`
+///   of the Piranha Output Summary
+///
+/// Returns the rewritten code, along with the Piranha Output Summary (if Piranha matched, rewrote or skipped the code).
+#[pyfunction]
+pub fn execute_piranha_on_content(
+  code: String, language: String, rule_graph: RuleGraph, line_range: Option<(usize, usize)>,
+) -> (String, Option<PiranhaOutputSummary>) {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .code_snippet(code.clone())
+    .language(PiranhaLanguage::from(language.as_str()))
+    .rule_graph(rule_graph)
+    .line_range(line_range)
+    .build();
+  let summary = execute_piranha(&piranha_arguments).pop();
+  let content = summary.as_ref().map_or(code, |s| s.content().to_string());
+  (content, summary)
 }
 
-fn log_piranha_output_summaries(summaries: &Vec<PiranhaOutputSummary>) {
+fn log_piranha_output_summaries(summaries: &Vec<PiranhaOutputSummary>, stats: &PiranhaStats) {
   let mut total_number_of_matches: usize = 0;
   let mut total_number_of_rewrites: usize = 0;
   for summary in summaries {
@@ -79,12 +151,110 @@ fn log_piranha_output_summaries(summaries: &Vec<PiranhaOutputSummary>) {
     info!("File : {:?}", &summary.path());
     info!("  # Rewrites : {}", number_of_rewrites);
     info!("  # Matches : {}", number_of_matches);
+    if let Some(skip_reason) = summary.skip_reason() {
+      info!("  Skipped : {}", skip_reason);
+    }
+    if *summary.flipped_sites() > 0 {
+      info!("  # Flipped flag calls : {}", summary.flipped_sites());
+    }
+    info!("  Stats : {}", summary.stats());
+    // The sites kept with `//piranha:keep` are listed, such that they do not silently rot
+    for (rule, m) in summary.suppressed_matches() {
+      info!(
+        "  Kept (line {}) : {} (rule `{}`)",
+        m.original_range().start_point.row + 1,
+        m.matched_string().trim(),
+        rule
+      );
+    }
+    // The sites out of the line range of the code snippet are listed, such that the user can be informed
+    for (rule, m) in summary.out_of_range_matches() {
+      info!(
+        "  Out of range (line {}) : {} (rule `{}`)",
+        m.original_range().start_point.row + 1,
+        m.matched_string().trim(),
+        rule
+      );
+    }
+    // The functions deleted by `delete_unreachable`, along with the rules that made them unreachable
+    for deleted_function in summary.deleted_functions() {
+      info!(
+        "  Deleted unreachable function : {} (via {})",
+        deleted_function.name(),
+        deleted_function.rule_chain().join(" -> ")
+      );
+    }
+    // The struct types not selected anymore by `delete_unselected_implementations`, and the interfaces left with a single
+    // implementation (which are not devirtualized)
+    for implementation in summary.unselected_implementations() {
+      if !implementation.is_deleted() {
+        info!(
+          "  Kept unselected implementation : {} (referred from {})",
+          implementation.name(),
+          implementation.external_references().join(", ")
+        );
+        continue;
+      }
+      info!(
+        "  Deleted unselected implementation : {} (methods : {}, constructors : {})",
+        implementation.name(),
+        implementation.methods().join(", "),
+        implementation.constructors().join(", ")
+      );
+      for (interface, remaining) in implementation.single_implementations() {
+        info!("  Single implementation of {} : {}", interface, remaining);
+      }
+    }
+    // The usages of the stale flags left in the file, to be reviewed manually
+    for unresolved_usage in summary.unresolved_usages() {
+      info!(
+        "  Unresolved usage of {} (line {}, in {}) : {}",
+        unresolved_usage.flag(),
+        unresolved_usage.line(),
+        unresolved_usage
+          .enclosing_function()
+          .as_deref()
+          .unwrap_or("<top level>"),
+        unresolved_usage.snippet()
+      );
+    }
+    // The rewrites attributed to each flag of the flags manifest
+    for (flag, number_of_flag_rewrites) in summary
+      .rewrites()
+      .iter()
+      .filter_map(|e| e.flag().as_ref())
+      .counts()
+      .into_iter()
+      .sorted()
+    {
+      info!("  # Rewrites for {} : {}", flag, number_of_flag_rewrites);
+    }
     total_number_of_rewrites += number_of_rewrites;
     total_number_of_matches += number_of_matches;
   }
   info!("Total files affected/matched {}", &summaries.len());
   info!("Total number of matches {}", total_number_of_matches);
   info!("Total number of rewrites {}", total_number_of_rewrites);
+  info!("Total stats : {}", stats);
+  // The rules evaluating the most matches, which may be the ones to tune
+  for (rule_name, number_of_matches) in stats.most_evaluated_rules().iter().take(10) {
+    info!(
+      "  # Matches evaluated for {} : {}",
+      rule_name, number_of_matches
+    );
+  }
+}
+
+/// Checks if the file was matched, rewritten, kept (i.e. with suppressed matches) or skipped out of the line range by
+/// Piranha, or if it has unresolved usages (or its processing timed out, or its rewrites were rolled back)
+fn is_updated(source_code_unit: &SourceCodeUnit) -> bool {
+  !source_code_unit.matches().is_empty()
+    || !source_code_unit.rewrites().is_empty()
+    || !source_code_unit.suppressed_matches().is_empty()
+    || !source_code_unit.out_of_range_matches().is_empty()
+    || !source_code_unit.unresolved_usages().is_empty()
+    || !source_code_unit.unselected_implementations().is_empty()
+    || source_code_unit.is_rolled_back()
 }
 
 // Maintains the state of Piranha and the updated content of files in the source code.
@@ -98,85 +268,600 @@ struct Piranha {
 }
 
 impl Piranha {
+  /// Returns the files matched, rewritten, kept (i.e. with suppressed matches) or skipped out of the line range by
+  /// Piranha, as well as the ones with unresolved usages (sorted by path, regardless of the order in which they were
+  /// processed)
   fn get_updated_files(&self) -> Vec<SourceCodeUnit> {
     self
       .relevant_files
       .values()
-      .filter(|r| !r.matches().is_empty() || !r.rewrites().is_empty())
+      .filter(|r| is_updated(r))
+      .sorted_by(|a, b| a.path().cmp(b.path()))
       .cloned()
       .collect_vec()
   }
 
+  /// Returns the statistics of the run, i.e. the ones of all the files processed by Piranha (including the ones
+  /// that were neither matched nor rewritten)
+  fn get_stats(&self) -> PiranhaStats {
+    let mut stats = PiranhaStats::default();
+    for source_code_unit in self.relevant_files.values() {
+      stats.merge(&source_code_unit.stats());
+    }
+    stats
+  }
+
   /// Performs cleanup related to stale flags
   fn perform_cleanup(&mut self) {
-    // Setup the parser for the specific language
     let piranha_args = &self.piranha_arguments;
 
-    let mut parser = piranha_args.language().parser();
+    // The files are processed in parallel, by a pool of `thread_count` threads
+    let thread_pool = ThreadPoolBuilder::new()
+      .num_threads(*piranha_args.thread_count())
+      .build()
+      .expect("Could not create the thread pool");
 
-    let mut path_to_codebase = self.piranha_arguments.path_to_codebase().to_string();
+    // The input code snippet is processed in memory (i.e. it is never written to or read from the disk)
+    let in_memory = !piranha_args.code_snippet().is_empty();
 
-    let temp_dir = if !self.piranha_arguments.code_snippet().is_empty() {
-      let td = self.write_code_snippet_to_temp();
-      path_to_codebase = td.path().to_str().unwrap_or_default().to_string();
-      Some(td)
-    } else {
-      None
-    };
+    // The files that added global (or package) rules to the rule store
+    let mut contributing_files = HashSet::new();
+    loop {
+      self.apply_rules_until_fixpoint(&thread_pool, &mut contributing_files);
+      // Delete the definitions and the functions that are not referred anymore, before the imports they referred to
+      // are cleaned up
+      self.perform_unreferenced_definition_cleanup();
+      self.perform_package_cleanup();
+      self.perform_post_processing(&thread_pool);
+      // The global (or package) rules added by the files rolled back since (e.g. as their rewrites, or their
+      // post-processing, introduced syntax errors) may have rewritten the other files. Hence, the cleanup is restarted
+      // without these rules, i.e. only the rolled back files are retained (as is, such that these add no rule anymore).
+      let rolled_back_files = self
+        .relevant_files
+        .iter()
+        .filter(|(path, scu)| scu.is_rolled_back() && contributing_files.contains(*path))
+        .map(|(path, _)| path.clone())
+        .sorted()
+        .collect_vec();
+      if rolled_back_files.is_empty() {
+        break;
+      }
+      debug!(
+        "Restarting the cleanup without the rules added by the rolled back files {:?}",
+        rolled_back_files
+      );
+      self.rule_store = RuleStore::new(&self.piranha_arguments);
+      self.relevant_files.retain(|_, scu| scu.is_rolled_back());
+      contributing_files.clear();
+    }
+    // Delete the files left in the packages emptied by the cleanup (e.g. their `doc.go`)
+    self.perform_empty_go_package_cleanup();
+    // The files that running Piranha again would still rewrite are reported (with `verify_fixpoint`)
+    self.record_fixpoint_violations();
+    // The usages of the stale flags left in the code are listed, to be reviewed manually
+    self.record_unresolved_usages();
+    // The updated code snippet is only reported (in the output summary)
+    if !in_memory {
+      for scu in self.relevant_files.values().filter(|r| is_updated(r)) {
+        scu.persist();
+      }
+      self.delete_empty_go_package_directories();
+    }
+  }
 
+  /// Applies the global (and package) rules to the relevant files, until no more global (or package) rules are added.
+  /// The files adding such rules are recorded in `contributing_files`.
+  fn apply_rules_until_fixpoint(
+    &mut self, thread_pool: &ThreadPool, contributing_files: &mut HashSet<PathBuf>,
+  ) {
+    let piranha_args = &self.piranha_arguments;
     let mut current_global_substitutions = piranha_args.input_substitutions();
-    // Keep looping until new `global` rules are added.
+    // Keep looping until new `global` (or `package`) rules are added.
     loop {
       let current_rules = self.rule_store.global_rules().clone();
+      let current_package_rules_count = self.rule_store.package_rules().len();
+      // The rules deleting a definition are deferred until no more rules apply
+      // (see `perform_unreferenced_definition_cleanup`)
+      let applied_rules = current_rules
+        .iter()
+        .filter(|r| !r.rule().is_definition_rule())
+        .cloned()
+        .collect_vec();
 
       debug!("\n # Global rules {}", current_rules.len());
-      // Iterate over each file containing the usage of the feature flag API
-
-      for (path, content) in self.rule_store.get_relevant_files(
-        &path_to_codebase,
-        piranha_args.include(),
-        piranha_args.exclude(),
-      ) {
-        // Get the `SourceCodeUnit` for the file `path` from the cache `relevant_files`.
-        // In case of miss, lazily insert a new `SourceCodeUnit`.
-        let source_code_unit = self
-          .relevant_files
-          .entry(path.to_path_buf())
-          .or_insert_with(|| {
-            SourceCodeUnit::new(
-              &mut parser,
-              content,
-              &current_global_substitutions,
-              path.as_path(),
-              piranha_args,
-            )
-          });
-
-        // Apply the rules in this `SourceCodeUnit`
-        source_code_unit.apply_rules(&mut self.rule_store, &current_rules, &mut parser, None);
+      // Get each file containing the usage of the feature flag API, along with its `SourceCodeUnit`
+      // from the cache `relevant_files` (if it was already processed).
+      // The files are sorted, so that the rules they add to the rule store are merged in a deterministic order.
+      let files = self
+        .get_relevant_files()
+        .into_iter()
+        .sorted_by(|(a, _), (b, _)| a.cmp(b))
+        .map(|(path, content)| {
+          let source_code_unit = self.relevant_files.remove(&path);
+          (path, content, source_code_unit)
+        })
+        .collect_vec();
+
+      // Each `SourceCodeUnit` is processed independently, against its own clone of the rule store
+      // (the clones share the compiled queries)
+      let shared_rule_store = &self.rule_store;
+      let applied_rules = &applied_rules;
+      let global_substitutions = &current_global_substitutions;
+      let processed_files = thread_pool.install(|| {
+        files
+          .into_par_iter()
+          .map_init(
+            || piranha_args.language().parser(),
+            |parser, (path, content, source_code_unit)| {
+              let mut rule_store = shared_rule_store.clone();
+              // In case of miss, lazily create a new `SourceCodeUnit`.
+              let mut source_code_unit = match source_code_unit {
+                Some(mut scu) => {
+                  scu.add_to_substitutions(global_substitutions);
+                  scu
+                }
+                None => SourceCodeUnit::new(
+                  parser,
+                  content,
+                  global_substitutions,
+                  path.as_path(),
+                  piranha_args,
+                ),
+              };
+
+              // The files whose processing timed out (or whose rewrites were rolled back) are left as is
+              if source_code_unit.is_rolled_back() {
+                return (path, source_code_unit, rule_store);
+              }
+
+              // The files out of the scope of `include` and `exclude` are only pulled in by the package rules,
+              // whose usages are reported (e.g. the calls of a function whose parameter is deleted)
+              if source_code_unit.skip_reason() == Some(OUT_OF_SCOPE_SKIP_REASON) {
+                for (scope_query, rule) in
+                  rule_store.get_package_rules_for(&path, source_code_unit.build_constraint())
+                {
+                  if source_code_unit.is_in_scope(&scope_query, &mut rule_store) {
+                    source_code_unit.add_to_substitutions(rule.substitutions());
+                    source_code_unit.record_usages(&[rule], &mut rule_store);
+                  }
+                }
+                return (path, source_code_unit, rule_store);
+              }
+
+              // The skipped (i.e. vendored or generated) files are not rewritten, only the usages of the rules are reported
+              if source_code_unit.skip_reason().is_some() {
+                source_code_unit.record_usages(applied_rules, &mut rule_store);
+                return (path, source_code_unit, rule_store);
+              }
 
+              // Apply the rules in this `SourceCodeUnit`
+              source_code_unit.apply_rules(&mut rule_store, applied_rules, parser, None);
+
+              // Apply the package rules, if this `SourceCodeUnit` belongs to the package
+              for (scope_query, rule) in
+                rule_store.get_package_rules_for(&path, source_code_unit.build_constraint())
+              {
+                if source_code_unit.is_in_scope(&scope_query, &mut rule_store) {
+                  // The rules chained to the package rule may refer to its holes
+                  source_code_unit.add_to_substitutions(rule.substitutions());
+                  source_code_unit.apply_rules(&mut rule_store, &[rule], parser, Some(scope_query));
+                }
+              }
+
+              // The rewrites that introduced syntax errors (or that did not reach a fixpoint) are rolled back, along with
+              // the global (or package) rules they added (e.g. the deletion of the references to a deleted declaration
+              // in the other files)
+              if !source_code_unit.validate_rewrites(parser) || source_code_unit.is_rolled_back() {
+                return (path, source_code_unit, shared_rule_store.clone());
+              }
+              (path, source_code_unit, rule_store)
+            },
+          )
+          .collect::<Vec<_>>()
+      });
+
+      for (path, source_code_unit, rule_store) in processed_files {
+        if rule_store.global_rules().len() > current_rules.len()
+          || rule_store.package_rules().len() > current_package_rules_count
+        {
+          contributing_files.insert(path.clone());
+        }
+        // Add the `global` (or `package`) rules found in this `SourceCodeUnit` to the rule store
+        self.rule_store.merge(&rule_store);
         // Add the substitutions for the global tags to the `current_global_substitutions`
         current_global_substitutions.extend(source_code_unit.global_substitutions());
+        self.relevant_files.insert(path, source_code_unit);
+      }
 
-        // Break when a new `global` rule is added
-        if self.rule_store.global_rules().len() > current_rules.len() {
-          debug!("Found a new global rule. Will start scanning all the files again.");
-          break;
+      // If no new `global_rules` (or `package_rules`) were added, break.
+      // Otherwise, scan all the files again.
+      if self.rule_store.global_rules().len() == current_rules.len()
+        && self.rule_store.package_rules().len() == current_package_rules_count
+      {
+        break;
+      }
+      debug!("Found a new global rule. Will start scanning all the files again.");
+    }
+  }
+
+  /// Post-processes the rewritten files, once no more rules apply.
+  fn perform_post_processing(&mut self, thread_pool: &ThreadPool) {
+    let piranha_args = &self.piranha_arguments;
+    // Fix the declarations whose declaring occurrence has changed, delete the redundant parentheses and the imports
+    // that are not referred anymore (and the files that do not declare anything anymore), and format the rewritten
+    // files (their Go layout, and then with `format_output`), now that no more rules apply. These rewrite the whole file,
+    // hence these are skipped when the cleanup is restricted to a line range of the code snippet.
+    let rule_store = &self.rule_store;
+    let is_line_range_restricted = piranha_args.line_range().is_some();
+    thread_pool.install(|| {
+      self.relevant_files.par_iter_mut().for_each_init(
+        || piranha_args.language().parser(),
+        |parser, (_, source_code_unit)| {
+          if !source_code_unit.rewrites().is_empty() && !is_line_range_restricted {
+            source_code_unit.perform_declaration_cleanup(parser);
+            source_code_unit.perform_parentheses_cleanup(parser);
+            source_code_unit.perform_import_cleanup(parser);
+            source_code_unit.perform_empty_go_file_cleanup(rule_store, parser);
+            source_code_unit.perform_go_layout_normalization(parser);
+            source_code_unit.perform_output_formatting(parser);
+            // The post-processing (e.g. a `formatter_command`) may introduce syntax errors too
+            source_code_unit.validate_rewrites(parser);
+          }
+        },
+      )
+    });
+  }
+
+  /// Deletes the Go files left in each package (i.e. directory) whose files were all deleted by the cleanup, when these
+  /// do not declare anything either (e.g. a `doc.go` only documenting the package). The package is retained as is if
+  /// any of these files declares something, or is excluded (or skipped).
+  fn perform_empty_go_package_cleanup(&mut self) {
+    let piranha_args = self.piranha_arguments.clone();
+    if !*piranha_args.delete_file_if_empty()
+      || *piranha_args.language().supported_language() != SupportedLanguage::Go
+      || !piranha_args.code_snippet().is_empty()
+    {
+      return;
+    }
+    let mut parser = piranha_args.language().parser();
+    let packages = self
+      .relevant_files
+      .values()
+      .filter(|scu| scu.code().is_empty() && !scu.rewrites().is_empty())
+      .map(|scu| get_package(scu.path()))
+      .unique()
+      .sorted()
+      .collect_vec();
+    for package in packages {
+      let relevant_paths: HashSet<PathBuf> = self
+        .relevant_files
+        .keys()
+        .filter(|path| get_package(path) == package)
+        .cloned()
+        .collect();
+      if relevant_paths
+        .iter()
+        .any(|path| !self.relevant_files[path].code().is_empty())
+      {
+        continue;
+      }
+      let mut files = self.read_package_files(&package, &relevant_paths, &mut parser);
+      if files.iter().any(|scu| {
+        !piranha_args.is_included(scu.path())
+          || scu.skip_reason().is_some()
+          || !scu.declares_nothing(&self.rule_store)
+      }) {
+        continue;
+      }
+      for scu in files.iter_mut() {
+        scu.delete_contents(DELETE_EMPTY_PACKAGE_FILE, &mut parser);
+      }
+      for scu in files {
+        self.relevant_files.insert(scu.path().to_path_buf(), scu);
+      }
+    }
+  }
+
+  /// Deletes the directories of the Go files deleted by the cleanup, that are left empty (i.e. without any file,
+  /// not only Go files), except the code base itself. The deleted directories are logged.
+  fn delete_empty_go_package_directories(&self) {
+    let piranha_args = &self.piranha_arguments;
+    if *piranha_args.dry_run()
+      || !*piranha_args.delete_file_if_empty()
+      || *piranha_args.language().supported_language() != SupportedLanguage::Go
+    {
+      return;
+    }
+    let path_to_codebase = Path::new(piranha_args.path_to_codebase());
+    let packages = self
+      .relevant_files
+      .values()
+      .filter(|scu| scu.code().is_empty() && !scu.rewrites().is_empty())
+      .map(|scu| get_package(scu.path()))
+      .filter(|package| {
+        package.starts_with(path_to_codebase) && package.as_path() != path_to_codebase
+      })
+      .unique()
+      .sorted();
+    for package in packages {
+      let is_empty = std::fs::read_dir(&package).map_or(false, |mut d| d.next().is_none());
+      if is_empty && std::fs::remove_dir(&package).is_ok() {
+        info!("Deleted the empty package directory {:?}", package);
+      }
+    }
+  }
+
+  /// Records the usages of the stale flags (i.e. of the `stale_flag_name` of each flag of the flags manifest, or of
+  /// the input substitutions) left in the relevant files, once all the rules have been applied
+  /// (see `record_unresolved_usages` of `SourceCodeUnit`).
+  fn record_unresolved_usages(&mut self) {
+    let flags = self
+      .piranha_arguments
+      .flag_substitutions()
+      .into_iter()
+      .filter_map(|(_, substitutions)| substitutions.get(STALE_FLAG_NAME).cloned())
+      .unique()
+      .collect_vec();
+    if flags.is_empty() {
+      return;
+    }
+    // The files out of the scope of `include` and `exclude` are not cleaned up
+    for source_code_unit in self
+      .relevant_files
+      .values_mut()
+      .filter(|scu| scu.skip_reason() != Some(OUT_OF_SCOPE_SKIP_REASON))
+    {
+      source_code_unit.record_unresolved_usages(&flags);
+    }
+  }
+
+  /// Applies the (global) rules deleting a definition (see `defined_name`), which were deferred until no more rules
+  /// apply, to the definitions whose name is not referred anywhere in the code base anymore. The references are
+  /// counted in all the files (including the excluded ones, which may not compile otherwise), except within the
+  /// definitions themselves. This is repeated until a fixpoint is reached, since a deleted definition may refer
+  /// to another one.
+  fn perform_unreferenced_definition_cleanup(&mut self) {
+    let piranha_args = self.piranha_arguments.clone();
+    let definition_rules = self
+      .rule_store
+      .global_rules()
+      .iter()
+      .filter(|r| r.rule().is_definition_rule())
+      .cloned()
+      .collect_vec();
+    if definition_rules.is_empty() {
+      return;
+    }
+    let mut parser = piranha_args.language().parser();
+    let mut rule_store = self.rule_store.clone();
+    // The other files of the code base referring to any defined name (these are never rewritten)
+    let other_files = if piranha_args.code_snippet().is_empty() {
+      rule_store
+        .get_relevant_files(
+          piranha_args.path_to_codebase(),
+          &Vec::new(),
+          &Vec::new(),
+          false,
+          !*piranha_args.no_ignore(),
+        )
+        .into_iter()
+        .filter(|(path, content)| {
+          !self.relevant_files.contains_key(path)
+            && definition_rules
+              .iter()
+              .any(|r| content.contains(r.rule().defined_name().as_str()))
+        })
+        .sorted_by(|(a, _), (b, _)| a.cmp(b))
+        .map(|(_, content)| {
+          let tree = parser
+            .parse(&content, None)
+            .expect("Could not parse the file!");
+          (tree, content)
+        })
+        .collect_vec()
+    } else {
+      vec![]
+    };
+    loop {
+      let mut deleted = false;
+      for rule in &definition_rules {
+        let name = rule.rule().defined_name();
+        // The definitions (i.e. the matches of the rule) in each file, except the ones whose processing timed out (or
+        // whose rewrites were rolled back)
+        let definitions: HashMap<PathBuf, Vec<Range>> = self
+          .relevant_files
+          .iter()
+          .filter(|(_, scu)| !scu.is_rolled_back())
+          .map(|(path, scu)| {
+            let ranges = scu
+              .get_matches(rule, &mut rule_store, scu.root_node(), true)
+              .iter()
+              .map(|m| m.range())
+              .collect_vec();
+            (path.clone(), ranges)
+          })
+          .filter(|(_, ranges)| !ranges.is_empty())
+          .collect();
+        if definitions.is_empty() {
+          continue;
+        }
+        let references = self
+          .relevant_files
+          .iter()
+          .map(|(path, scu)| {
+            let excluded = definitions
+              .get(path)
+              .map_or(&[][..], |ranges| ranges.as_slice());
+            count_references(&scu.root_node(), scu.code(), name, excluded)
+          })
+          .chain(
+            other_files
+              .iter()
+              .map(|(tree, content)| count_references(&tree.root_node(), content, name, &[])),
+          )
+          .sum::<usize>();
+        debug!("The definition of {name} is referred {references} time(s)");
+        if references > 0 {
+          continue;
+        }
+        for path in definitions.keys().sorted() {
+          let scu = self.relevant_files.get_mut(path).unwrap();
+          if scu.skip_reason().is_some() {
+            continue;
+          }
+          let rewrites_count = scu.rewrites().len();
+          scu.apply_rules(&mut rule_store, &[rule.clone()], &mut parser, None);
+          deleted |= scu.rewrites().len() > rewrites_count;
         }
       }
-      // If no new `global_rules` were added, break.
-      if self.rule_store.global_rules().len() == current_rules.len() {
+      if !deleted {
         break;
       }
     }
-    // Delete the temp dir inside which the input code snippet was copied
-    if let Some(t) = temp_dir {
-      _ = t.close();
-    } else {
-      let source_code_units = self.get_updated_files();
+  }
 
-      for scu in source_code_units.iter() {
-        scu.persist();
+  /// Cleans up the packages (i.e. the directories) of the rewritten files with the package cleanup of the language
+  /// (see `package_cleanup`), e.g. deletes the Go functions that are not referred in their package anymore (with
+  /// `delete_unreachable`). All the files of each package are considered, since these may declare (or refer to) the
+  /// cleaned up code, while the ones read from the disk are only retained if rewritten (or reported).
+  fn perform_package_cleanup(&mut self) {
+    let piranha_args = self.piranha_arguments.clone();
+    let package_cleanup = match piranha_args.language().package_cleanup() {
+      Some(package_cleanup)
+        if *piranha_args.delete_unreachable()
+          || *piranha_args.delete_unselected_implementations() =>
+      {
+        package_cleanup
+      }
+      _ => return,
+    };
+    let mut parser = piranha_args.language().parser();
+    let package_paths = self
+      .relevant_files
+      .iter()
+      .filter(|(_, scu)| !scu.rewrites().is_empty())
+      .map(|(path, _)| get_package(path))
+      .unique()
+      .sorted()
+      .collect_vec();
+    let mut relevant_paths = HashSet::new();
+    let mut packages = vec![];
+    for package in package_paths {
+      let package_relevant_paths: HashSet<PathBuf> = self
+        .relevant_files
+        .keys()
+        .filter(|path| get_package(path) == package)
+        .cloned()
+        .collect();
+      let mut files = package_relevant_paths
+        .iter()
+        .filter_map(|path| self.relevant_files.remove(path))
+        .collect_vec();
+      files.extend(self.read_package_files(&package, &package_relevant_paths, &mut parser));
+      files.sort_by(|a, b| a.path().cmp(b.path()));
+      relevant_paths.extend(package_relevant_paths);
+      packages.push((package, files));
+    }
+    package_cleanup(&mut packages, &piranha_args, &mut parser);
+    for scu in packages.into_iter().flat_map(|(_, files)| files) {
+      if relevant_paths.contains(scu.path())
+        || !scu.rewrites().is_empty()
+        || !scu.unselected_implementations().is_empty()
+      {
+        self.relevant_files.insert(scu.path().to_path_buf(), scu);
+      }
+    }
+  }
+
+  /// Returns the other files of the `package` (i.e. the ones directly under its directory, except the
+  /// `relevant_paths`), read from the disk. There are none when cleaning up a code snippet.
+  fn read_package_files(
+    &self, package: &Path, relevant_paths: &HashSet<PathBuf>, parser: &mut Parser,
+  ) -> Vec<SourceCodeUnit> {
+    let piranha_args = &self.piranha_arguments;
+    if !piranha_args.code_snippet().is_empty() {
+      return vec![];
+    }
+    std::fs::read_dir(package)
+      .into_iter()
+      .flatten()
+      .filter_map(|e| e.ok())
+      .map(|e| e.path())
+      .filter(|path| {
+        path.is_file() && !relevant_paths.contains(path) && piranha_args.language().can_parse(path)
+      })
+      .filter_map(|path| read_file(&path).ok().map(|content| (path, content)))
+      .map(|(path, content)| {
+        SourceCodeUnit::new(
+          parser,
+          content,
+          &piranha_args.input_substitutions(),
+          path.as_path(),
+          piranha_args,
+        )
+      })
+      .collect()
+  }
+
+  /// Records the rewrite that running Piranha again would still apply to each rewritten file (if any), i.e. the
+  /// global and package rules are not at a fixpoint (with `verify_fixpoint`). The rewrites are persisted regardless.
+  fn record_fixpoint_violations(&mut self) {
+    if !*self.piranha_arguments.verify_fixpoint() {
+      return;
+    }
+    let mut rule_store = self.rule_store.clone();
+    // The definitions that are still referred would not be deleted either by a second run
+    let global_rules = rule_store
+      .global_rules()
+      .iter()
+      .filter(|r| !r.rule().is_definition_rule())
+      .cloned()
+      .collect_vec();
+    let mut violations = vec![];
+    for (path, source_code_unit) in self
+      .relevant_files
+      .iter()
+      .sorted_by(|(a, _), (b, _)| a.cmp(b))
+    {
+      if source_code_unit.rewrites().is_empty() || source_code_unit.skip_reason().is_some() {
+        continue;
+      }
+      let mut pending_edit =
+        source_code_unit.get_pending_edit(&global_rules, &mut rule_store, &None);
+      for (scope_query, rule) in
+        rule_store.get_package_rules_for(path, source_code_unit.build_constraint())
+      {
+        if pending_edit.is_some() {
+          break;
+        }
+        if source_code_unit.is_in_scope(&scope_query, &mut rule_store) {
+          pending_edit =
+            source_code_unit.get_pending_edit(&[rule], &mut rule_store, &Some(scope_query));
+        }
+      }
+      if let Some(edit) = pending_edit {
+        let rewriting_rules = source_code_unit
+          .rewrites()
+          .iter()
+          .map(|e| e.matched_rule())
+          .unique()
+          .join(", ");
+        let violation = format!(
+          "The rule `{}` still rewrites `{}` (rewritten by {})",
+          edit.matched_rule(),
+          edit.p_match().matched_string(),
+          rewriting_rules
+        );
+        warn!(
+          "Piranha did not reach a fixpoint for {:?} : {}",
+          path, violation
+        );
+        violations.push((path.clone(), violation));
+      }
+    }
+    for (path, violation) in violations {
+      if let Some(source_code_unit) = self.relevant_files.get_mut(&path) {
+        source_code_unit.set_fixpoint_violation(Some(violation));
       }
     }
   }
@@ -191,20 +876,20 @@ impl Piranha {
     }
   }
 
-  /// Write the input code snippet into a temp directory.
-  /// Returns: A temporary directory containing the created input code snippet as a file
-  /// This function panics if it finds that neither `code_snippet` nor `path_to_configuration` are provided  
-  fn write_code_snippet_to_temp(&self) -> TempDir {
-    let temp_dir = TempDir::new_in(".", "tmp").unwrap();
-    let temp_dir_path = temp_dir.path();
-    let sample_file = temp_dir_path.join(format!(
-      "sample.{}",
-      self.piranha_arguments.language().extension()
-    ));
-    let mut file = File::create(sample_file).unwrap();
-    file
-      .write_all(self.piranha_arguments.code_snippet().as_bytes())
-      .unwrap();
-    temp_dir
+  /// Returns the files (and their content) that may be affected by the rules.
+  /// The input code snippet (if provided) is returned as `sample.<extension>`, without touching the file system.
+  fn get_relevant_files(&self) -> HashMap<PathBuf, String> {
+    let piranha_args = &self.piranha_arguments;
+    if !piranha_args.code_snippet().is_empty() {
+      let path = PathBuf::from(format!("sample.{}", piranha_args.language().extension()));
+      return HashMap::from([(path, piranha_args.code_snippet().to_string())]);
+    }
+    self.rule_store.get_relevant_files(
+      piranha_args.path_to_codebase(),
+      piranha_args.include(),
+      piranha_args.exclude(),
+      !*piranha_args.no_prefilter(),
+      !*piranha_args.no_ignore(),
+    )
   }
 }
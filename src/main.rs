@@ -12,30 +12,233 @@
 */
 
 //! Defines the entry-point for Piranha.
-use std::{fs, time::Instant};
+use std::{fs, panic, process, time::Instant};
 
 use log::{debug, info};
 use polyglot_piranha::{
-  execute_piranha, models::piranha_arguments::PiranhaArguments,
+  execute_piranha_with_stats,
+  models::piranha_arguments::{GraphArguments, PiranhaArguments, PiranhaSubcommand},
+  models::piranha_metrics::{get_metrics, PiranhaMetrics},
   models::piranha_output::PiranhaOutputSummary,
+  models::piranha_report::get_report,
 };
 
+/// The exit code when any file was changed (or would be changed, in `dry_run`)
+const EXIT_CODE_CHANGED: i32 = 1;
+/// The exit code when any usage of the stale flags is left in the code (with `fail_on_unresolved`)
+const EXIT_CODE_UNRESOLVED: i32 = 2;
+/// The exit code when Piranha fails (e.g. an invalid configuration, or a file that cannot be written)
+const EXIT_CODE_ERROR: i32 = 3;
+/// The exit code when the rewrites of any file were rolled back, as they introduced syntax errors (with `strict`)
+const EXIT_CODE_INVALID_REWRITE: i32 = 4;
+/// The exit code when running Piranha again would still rewrite any file (with `verify_fixpoint`)
+const EXIT_CODE_FIXPOINT_VIOLATION: i32 = 5;
+
 fn main() {
   let now = Instant::now();
   env_logger::init();
 
-  info!("Executing Polyglot Piranha");
+  // Report the errors (i.e. the panics, of any thread) with a distinct exit code, rather than the default one
+  let default_hook = panic::take_hook();
+  panic::set_hook(Box::new(move |info| {
+    default_hook(info);
+    process::exit(EXIT_CODE_ERROR);
+  }));
 
   let args = PiranhaArguments::from_cli();
 
+  // `piranha graph ...` exports the rule graph instead of running Piranha
+  if let Some(PiranhaSubcommand::Graph(graph_arguments)) = args.subcommand() {
+    export_rule_graph(graph_arguments);
+    return;
+  }
+
+  info!("Executing Polyglot Piranha");
+
   debug!("Piranha Arguments are \n{:#?}", args);
-  let piranha_output_summaries = execute_piranha(&args);
+  let (piranha_output_summaries, stats) = execute_piranha_with_stats(&args);
+
+  if args.output_format() == "json" {
+    print_output_summary(&piranha_output_summaries);
+  } else if *args.dry_run() && args.diff_output().is_none() {
+    print_diffs(&piranha_output_summaries);
+  }
+
+  // Signal if any file was changed (or would be changed, in `dry_run`), e.g. to block a commit in a pre-commit hook
+  let has_changed_files = piranha_output_summaries
+    .iter()
+    .any(|summary| summary.is_changed());
+
+  // With `fail_on_unresolved`, fail (with a distinct status) if any usage of the stale flags is left
+  let has_unresolved_usages = *args.fail_on_unresolved()
+    && piranha_output_summaries
+      .iter()
+      .any(|summary| !summary.unresolved_usages().is_empty());
+  if has_unresolved_usages {
+    print_unresolved_usages(&piranha_output_summaries);
+  }
+
+  // With `strict`, fail (with a distinct status) if the rewrites of any file were rolled back
+  let has_invalid_rewrites = *args.strict()
+    && piranha_output_summaries
+      .iter()
+      .any(|summary| summary.invalid_rewrite().is_some());
+  if has_invalid_rewrites {
+    print_invalid_rewrites(&piranha_output_summaries);
+  }
+
+  // With `verify_fixpoint`, fail (with a distinct status) if running Piranha again would still rewrite any file
+  let has_fixpoint_violations = piranha_output_summaries
+    .iter()
+    .any(|summary| summary.fixpoint_violation().is_some());
+  if has_fixpoint_violations {
+    print_fixpoint_violations(&piranha_output_summaries);
+  }
+
+  if let Some(path) = args.diff_output() {
+    write_diff_output(&piranha_output_summaries, path);
+  }
+
+  if let (Some(report_format), Some(path)) = (args.report_format(), args.path_to_report()) {
+    write_report(&piranha_output_summaries, &args, report_format, path);
+  }
+
+  let metrics = get_metrics(&piranha_output_summaries, &args);
+  if let Some(path) = args.metrics_out() {
+    write_metrics(&metrics, path);
+  }
 
   if let Some(path) = args.path_to_output_summary() {
     write_output_summary(piranha_output_summaries, path);
   }
 
   info!("Time elapsed - {:?}", now.elapsed().as_secs());
+  info!("Piranha : {stats}");
+  info!("Piranha metrics : {metrics}");
+
+  if has_invalid_rewrites {
+    process::exit(EXIT_CODE_INVALID_REWRITE);
+  }
+  if has_fixpoint_violations {
+    process::exit(EXIT_CODE_FIXPOINT_VIOLATION);
+  }
+  if has_unresolved_usages {
+    process::exit(EXIT_CODE_UNRESOLVED);
+  }
+  if has_changed_files {
+    process::exit(EXIT_CODE_CHANGED);
+  }
+}
+
+/// Writes the rule graph as Graphviz DOT to `output` (or prints it to stdout).
+fn export_rule_graph(args: &GraphArguments) {
+  let dot = args.get_rule_graph_dot();
+  match args.output() {
+    Some(path) => {
+      if fs::write(path, dot).is_err() {
+        panic!("Could not write the rule graph to the file - {path}");
+      }
+    }
+    None => print!("{dot}"),
+  }
+}
+
+/// Prints the unified diffs of the files that would be changed (in `dry_run`) to stdout.
+fn print_diffs(piranha_output_summaries: &[PiranhaOutputSummary]) {
+  for summary in piranha_output_summaries {
+    print!("{}", summary.diff());
+  }
+}
+
+/// Prints the usages of the stale flags left in the code (i.e. `file:line`, the enclosing function and the snippet of
+/// each usage) to stderr.
+fn print_unresolved_usages(piranha_output_summaries: &[PiranhaOutputSummary]) {
+  eprintln!("Unresolved usages of the stale flags :");
+  for usage in piranha_output_summaries
+    .iter()
+    .flat_map(|summary| summary.unresolved_usages())
+  {
+    eprintln!(
+      "{}:{} ({}, in {}) : {}",
+      usage.path(),
+      usage.line(),
+      usage.flag(),
+      usage
+        .enclosing_function()
+        .as_deref()
+        .unwrap_or("<top level>"),
+      usage.snippet()
+    );
+  }
+}
+
+/// Prints the rewrites that introduced syntax errors (i.e. the file, the offending rule and the rewritten snippet),
+/// because of which the rewrites of their files were rolled back, to stderr.
+fn print_invalid_rewrites(piranha_output_summaries: &[PiranhaOutputSummary]) {
+  eprintln!("Rewrites producing syntax errors (rolled back) :");
+  for invalid_rewrite in piranha_output_summaries
+    .iter()
+    .filter_map(|summary| summary.invalid_rewrite().as_ref())
+  {
+    eprintln!(
+      "{} ({}) : {} -> {}",
+      invalid_rewrite.path(),
+      invalid_rewrite.rule(),
+      invalid_rewrite.original_snippet(),
+      invalid_rewrite.replacement_snippet()
+    );
+  }
+}
+
+/// Prints the rewrites that running Piranha again would still apply (i.e. the file, the pending rule and the code it
+/// matches) to stderr.
+fn print_fixpoint_violations(piranha_output_summaries: &[PiranhaOutputSummary]) {
+  eprintln!("Piranha did not reach a fixpoint, i.e. running it again would rewrite the code :");
+  for summary in piranha_output_summaries {
+    if let Some(violation) = summary.fixpoint_violation() {
+      eprintln!("{} : {}", summary.path(), violation);
+    }
+  }
+}
+
+/// Prints the output summaries (as Json) to stdout.
+fn print_output_summary(piranha_output_summaries: &[PiranhaOutputSummary]) {
+  match serde_json::to_string_pretty(piranha_output_summaries) {
+    Ok(contents) => println!("{contents}"),
+    Err(e) => panic!("Could not serialize the output summary - {e}"),
+  }
+}
+
+/// Writes the report (in `report_format`) of every rewrite, deleted file and match to `path_to_report`.
+fn write_report(
+  piranha_output_summaries: &[PiranhaOutputSummary], args: &PiranhaArguments, report_format: &str,
+  path_to_report: &String,
+) {
+  let report = get_report(piranha_output_summaries, args, report_format);
+  if fs::write(path_to_report, report).is_err() {
+    panic!("Could not write the report to the file - {path_to_report}");
+  }
+}
+
+/// Writes the diffs of all the files that would be changed (in `dry_run`) as a single patch to `path_to_patch`.
+fn write_diff_output(piranha_output_summaries: &[PiranhaOutputSummary], path_to_patch: &String) {
+  let patch: String = piranha_output_summaries
+    .iter()
+    .map(|summary| summary.diff().as_str())
+    .collect();
+  if fs::write(path_to_patch, patch).is_err() {
+    panic!("Could not write the diff output to the file - {path_to_patch}");
+  }
+}
+
+/// Writes the metrics of the run (in total, per file and per flag) to a Json file named `path_to_metrics`.
+fn write_metrics(metrics: &PiranhaMetrics, path_to_metrics: &String) {
+  if let Ok(contents) = serde_json::to_string_pretty(metrics) {
+    if fs::write(path_to_metrics, contents).is_ok() {
+      return;
+    }
+  }
+  panic!("Could not write the metrics to the file - {path_to_metrics}");
 }
 
 /// Writes the output summaries to a Json file named `path_to_output_summaries` .
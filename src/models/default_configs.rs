@@ -25,12 +25,127 @@ pub const KOTLIN: &str = "kt";
 pub const GO: &str = "go";
 pub const PYTHON: &str = "py";
 pub const SWIFT: &str = "swift";
+pub const RUST: &str = "rs";
+pub const PHP: &str = "php";
+pub const RUBY: &str = "rb";
 pub const TYPESCRIPT: &str = "ts";
 pub const TSX: &str = "tsx";
 pub const THRIFT: &str = "thrift";
 pub const STRINGS: &str = "strings";
 pub const TS_SCHEME: &str = "scm"; // We support scheme files that contain tree-sitter query
 
+/// The output format printing the serialized output summaries (as json) to stdout.
+pub const JSON_OUTPUT_FORMAT: &str = "json";
+/// The default output format, i.e. the output summaries are only written to `path_to_output_summary`.
+pub const SUMMARY_OUTPUT_FORMAT: &str = "summary";
+
+/// The report format listing every rewrite, deleted file and match (as json).
+pub const JSON_REPORT_FORMAT: &str = "json";
+/// The report format listing every rewrite, deleted file and match as a SARIF (2.1.0) log.
+pub const SARIF_REPORT_FORMAT: &str = "sarif";
+
+/// The default treatment, i.e. the flag checks are replaced with the `treated` value and the code is cleaned up.
+pub const CLEANUP_TREATMENT: &str = "cleanup";
+/// The treatment only flipping the default value of the flag API calls to the `treated` value (see `treatment`).
+pub const FLIP_TREATMENT: &str = "flip";
+
+/// Built-in rules in this group may drop sub-expressions with side effects (e.g. `foo() && false` -> `false`).
+/// They are only loaded when `aggressive_simplification` is enabled.
+pub const SIDE_EFFECT_UNSAFE: &str = "side_effect_unsafe";
+
+/// Built-in rules in this group delete the tests exercising the stale flag (e.g. `func TestXxx(t *testing.T)` in Go).
+/// They are only loaded when `cleanup_tests` is enabled.
+pub const TEST_CLEANUP: &str = "test_cleanup";
+
+/// Built-in rules in this group rewrite `if c { return x } else { rest }` into `if c { return x }` followed by `rest`.
+/// They are only loaded when `flatten_else` is enabled.
+pub const FLATTEN_ELSE: &str = "flatten_else";
+
+/// Built-in rules in this group rewrite `if c { return true } else { return false }` (or `if c { return true }` followed
+/// by `return false`) into `return c`. They are only loaded when `simplify_boolean_return` is enabled.
+pub const SIMPLIFY_BOOLEAN_RETURN: &str = "simplify_boolean_return";
+
+/// Built-in rules in this group delete a statement identical to the previous one, when it is deemed free of side effects
+/// (i.e. a call to one of the `pure_functions`, or the assignment of a literal). They are only loaded when
+/// `dedupe_statements` is enabled.
+pub const DEDUPE_STATEMENTS: &str = "dedupe_statements";
+
+/// The substitution holding the alternation of the functions deemed free of side effects (e.g. `log\.Debug`), whose
+/// duplicate calls are deleted with `dedupe_statements`
+pub const PURE_FUNCTIONS: &str = "pure_functions";
+
+/// Built-in rules in this group flip the default value of the flag API calls to the `treated` value, without cleaning
+/// up the code. They are only loaded (and the other rules are dropped) with the `flip` treatment.
+pub const FLIP_DEFAULT: &str = "flip_default";
+
+/// Built-in rules in this group delete the functions emptied by the cleanup, once these are not referred anymore.
+/// They are only loaded when `delete_empty_functions` is enabled.
+pub const DELETE_EMPTY_FUNCTIONS: &str = "delete_empty_functions";
+
+/// Built-in rules in this group replace the flag calls with their default value argument
+/// (e.g. `false` in `exp.BoolValueCtx(ctx, "new_checkout", false)`). They are only loaded when `use_default_as_treatment` is enabled.
+pub const DEFAULT_AS_TREATMENT: &str = "default_as_treatment";
+
+/// Built-in rules in this group replace the flag calls with the `treated` (or `string_treated`) substitution.
+/// They are dropped when `use_default_as_treatment` is enabled.
+pub const TREATED_AS_TREATMENT: &str = "treated_as_treatment";
+
+/// The (pseudo) rule of the rewrites deleting the Go functions that are not referred in their package anymore,
+/// once all the rules have been applied (see `delete_unreachable`).
+pub const DELETE_UNREACHABLE_FUNCTION: &str = "delete_unreachable_function";
+
+/// The (pseudo) rule of the rewrites deleting the Go struct types (along with their methods and constructors) that
+/// are not selected anymore once the flag is cleaned up (see `delete_unselected_implementations`).
+pub const DELETE_UNSELECTED_IMPLEMENTATION: &str = "delete_unselected_implementation";
+
+/// The (pseudo) rule of the rewrites deleting the Go files that do not declare anything (e.g. a `doc.go`), left in
+/// a package whose other files were all deleted by the cleanup.
+pub const DELETE_EMPTY_PACKAGE_FILE: &str = "delete_empty_package_file";
+
+/// The (pseudo) rule reported for the syntax errors introduced by the formatting of the rewritten files (i.e. `normalize_go_layout`,
+/// `format_output` or the `formatter_command`), rather than by a rewrite.
+pub const FORMAT_OUTPUT: &str = "format_output";
+
+/// The substitution for the name of the stale flag, required by the rules in the `TEST_CLEANUP` group.
+pub const STALE_FLAG_NAME: &str = "stale_flag_name";
+
+/// The substitution for the value of the stale flag (i.e. `true` when the feature is shipped), set from the `flag_states`.
+pub const TREATED: &str = "treated";
+
+/// The hole of the built-in rules for the name of a variable (e.g. seeded from the stale flag), whose references left
+/// in the code once all the rules have been applied are reported as unresolved usages.
+pub(crate) const VARIABLE_NAME: &str = "variable_name";
+
+/// The ignore files honored (along with the `.gitignore` files) while walking the code base, unless `no_ignore` is set.
+pub const PIRANHA_IGNORE_FILE: &str = ".piranhaignore";
+
+/// The reason reported for the usages found in a generated Go file (i.e. `// Code generated ... DO NOT EDIT.`),
+/// which is not rewritten unless `include_generated` is set.
+pub const GENERATED_CODE_SKIP_REASON: &str = "usages in generated code — regenerate source";
+/// The reason reported for the usages found in a Go file under a `vendor` directory,
+/// which is not rewritten unless `include_vendor` is set.
+pub const VENDORED_CODE_SKIP_REASON: &str = "usages in vendored code — update the dependency";
+/// The reason reported for the usages of the package rules (e.g. the calls of a function whose parameter is deleted) found
+/// in a file of the package that is out of the scope of `include` and `exclude`, which is not rewritten.
+pub const OUT_OF_SCOPE_SKIP_REASON: &str =
+  "usages out of the include/exclude scope — clean up separately";
+/// The reason reported for the usages found in a Go file starting with `//piranha:keep-file`, which is not rewritten.
+pub const KEPT_FILE_SKIP_REASON: &str = "usages in a file kept with //piranha:keep-file";
+/// The reason reported for the (suppressed) usages overlapping a Go statement or declaration annotated with
+/// `//piranha:keep`, which are not rewritten.
+pub const KEPT_NODE_SKIP_REASON: &str = "usage kept with //piranha:keep";
+/// The reason reported for the (skipped) usages that are not entirely within the `line_range` of the code snippet,
+/// which are not rewritten.
+pub const OUT_OF_RANGE_SKIP_REASON: &str = "usage out of the requested line range";
+/// The reason reported for a file whose processing exceeded the `per_file_timeout`, which is left unmodified.
+pub const TIMEOUT_SKIP_REASON: &str = "processing timed out — clean up separately";
+/// The reason reported for a file whose rewrites introduced syntax errors, which are rolled back (i.e. the file is left
+/// unmodified).
+pub const INVALID_REWRITE_SKIP_REASON: &str = "rewrites produced syntax errors — rolled back";
+/// The reason reported for a file where the cleanup of a rewritten function did not reach a fixpoint within the
+/// `max_fixpoint_iterations`, whose rewrites are rolled back (i.e. the file is left unmodified).
+pub const FIXPOINT_EXCEEDED_SKIP_REASON: &str = "cleanup did not reach a fixpoint — rolled back";
+
 #[cfg(test)]
 //FIXME: Remove this  hack by not passing PiranhaArguments to SourceCodeUnit
 pub(crate) const UNUSED_CODE_PATH: &str = "/dev/null";
@@ -47,6 +162,18 @@ pub fn default_substitutions() -> Vec<(String, String)> {
   vec![]
 }
 
+pub fn default_flags_file() -> Option<String> {
+  None
+}
+
+pub(crate) fn default_flags() -> Vec<HashMap<String, String>> {
+  vec![]
+}
+
+pub fn default_flag_states() -> Vec<(String, bool)> {
+  vec![]
+}
+
 pub fn default_delete_file_if_empty() -> bool {
   true
 }
@@ -59,6 +186,14 @@ pub fn default_cleanup_comments() -> bool {
   false
 }
 
+pub fn default_flag_comment_pattern() -> Option<String> {
+  None
+}
+
+pub fn default_preserve_leading_comments() -> bool {
+  false
+}
+
 pub fn default_global_tag_prefix() -> String {
   "GLOBAL_TAG.".to_string()
 }
@@ -67,6 +202,52 @@ pub fn default_dry_run() -> bool {
   false
 }
 
+pub fn default_diff_output() -> Option<String> {
+  None
+}
+
+pub fn default_no_prefilter() -> bool {
+  false
+}
+
+pub fn default_no_ignore() -> bool {
+  false
+}
+
+pub fn default_include_vendor() -> bool {
+  false
+}
+
+pub fn default_include_generated() -> bool {
+  false
+}
+
+/// Defaults to the number of available cores
+pub fn default_thread_count() -> usize {
+  std::thread::available_parallelism().map_or(1, |n| n.get())
+}
+
+/// The cascading edits within a function rarely need more than a few iterations to reach the fixpoint
+pub fn default_max_fixpoint_iterations() -> usize {
+  10
+}
+
+pub(crate) fn default_per_file_timeout() -> Option<f64> {
+  None
+}
+
+pub fn default_report_format() -> Option<String> {
+  None
+}
+
+pub fn default_path_to_report() -> Option<String> {
+  None
+}
+
+pub fn default_metrics_out() -> Option<String> {
+  None
+}
+
 pub fn default_path_to_codebase() -> String {
   String::new()
 }
@@ -75,6 +256,10 @@ pub fn default_code_snippet() -> String {
   String::new()
 }
 
+pub fn default_line_range() -> Option<(usize, usize)> {
+  None
+}
+
 pub fn default_include() -> Vec<Pattern> {
   Vec::new()
 }
@@ -91,6 +276,10 @@ pub fn default_path_to_output_summaries() -> Option<String> {
   None
 }
 
+pub fn default_output_format() -> String {
+  SUMMARY_OUTPUT_FORMAT.to_string()
+}
+
 pub fn default_piranha_language() -> PiranhaLanguage {
   PiranhaLanguage::default()
 }
@@ -183,6 +372,90 @@ pub(crate) fn default_is_seed_rule() -> bool {
   true
 }
 
+pub(crate) fn default_is_rvalue() -> bool {
+  false
+}
+
+pub(crate) fn default_defined_name() -> String {
+  String::new()
+}
+
+pub(crate) fn default_unwraps_block() -> bool {
+  false
+}
+
 pub(crate) fn default_allow_dirty_ast() -> bool {
   false
 }
+
+pub(crate) fn default_aggressive_simplification() -> bool {
+  false
+}
+
+pub(crate) fn default_cleanup_imports() -> bool {
+  false
+}
+
+pub(crate) fn default_normalize_go_layout() -> bool {
+  true
+}
+
+pub(crate) fn default_format_output() -> bool {
+  false
+}
+
+pub(crate) fn default_formatter_command() -> Option<String> {
+  None
+}
+
+pub(crate) fn default_cleanup_tests() -> bool {
+  false
+}
+
+pub(crate) fn default_flatten_else() -> bool {
+  false
+}
+
+pub(crate) fn default_simplify_boolean_return() -> bool {
+  false
+}
+
+pub(crate) fn default_dedupe_statements() -> bool {
+  false
+}
+
+pub(crate) fn default_treatment() -> String {
+  CLEANUP_TREATMENT.to_string()
+}
+
+pub(crate) fn default_treated_value() -> Option<bool> {
+  None
+}
+
+pub(crate) fn default_delete_unreachable() -> bool {
+  false
+}
+
+pub(crate) fn default_delete_unselected_implementations() -> bool {
+  false
+}
+
+pub(crate) fn default_delete_empty_functions() -> bool {
+  false
+}
+
+pub(crate) fn default_fail_on_unresolved() -> bool {
+  false
+}
+
+pub(crate) fn default_strict() -> bool {
+  false
+}
+
+pub(crate) fn default_verify_fixpoint() -> bool {
+  false
+}
+
+pub(crate) fn default_use_default_as_treatment() -> bool {
+  false
+}
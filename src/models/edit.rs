@@ -11,7 +11,7 @@ Copyright (c) 2023 Uber Technologies, Inc.
  limitations under the License.
 */
 
-use std::fmt;
+use std::{collections::HashMap, fmt};
 
 use colored::Colorize;
 use getset::{Getters, MutGetters};
@@ -24,8 +24,10 @@ use super::{
 };
 use crate::utilities::{
   gen_py_str_methods,
+  go_keep_directives::overlaps_kept_range,
+  instantiate_replace,
+  parentheses::parenthesize_replacement,
   tree_sitter_utilities::{get_context, get_node_for_range},
-  Instantiate,
 };
 use pyo3::{prelude::pyclass, pymethods};
 
@@ -45,27 +47,52 @@ pub(crate) struct Edit {
   #[pyo3(get)]
   #[get = "pub"]
   matched_rule: String,
+  // The lines of the original code encompassing the match
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  original_snippet: String,
+  // The lines encompassing the match, after the edit is applied
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  replacement_snippet: String,
+  // The substitutions of the rule used for creating this match-replace (e.g. the stale flag name)
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  substitutions: HashMap<String, String>,
+  // The stale flag (of the flags manifest) whose cleanup this match-replace is part of, if any
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  flag: Option<String>,
 }
 
 gen_py_str_methods!(Edit);
 
 impl Edit {
   pub(crate) fn new(
-    p_match: Match, replacement_string: String, matched_rule: String, code: &str,
+    p_match: Match, replacement_string: String, matched_rule: String,
+    substitutions: HashMap<String, String>, code: &str,
   ) -> Self {
     let mut edit = Self {
       p_match,
       replacement_string,
       matched_rule,
+      original_snippet: String::new(),
+      replacement_snippet: String::new(),
+      substitutions,
+      flag: None,
     };
     if edit.is_delete() {
       edit.p_match_mut().expand_to_associated_matches(code);
     }
+    edit.set_snippets(code);
     edit
   }
   #[cfg(test)]
   pub(crate) fn delete_range(code: &str, replacement_range: Range) -> Self {
-    use std::collections::HashMap;
     Self {
       p_match: Match::new(
         code[replacement_range.start_byte..replacement_range.end_byte].to_string(),
@@ -74,9 +101,30 @@ impl Edit {
       ),
       replacement_string: String::new(),
       matched_rule: "Delete Range".to_string(),
+      original_snippet: String::new(),
+      replacement_snippet: String::new(),
+      substitutions: HashMap::new(),
+      flag: None,
     }
   }
 
+  /// Sets the original and the replacement snippets, i.e. the lines encompassing the match
+  /// before and after the edit is applied.
+  fn set_snippets(&mut self, code: &str) {
+    let range = self.p_match().range();
+    let snippet_start = code[..range.start_byte].rfind('\n').map_or(0, |i| i + 1);
+    let snippet_end = code[range.end_byte..]
+      .find('\n')
+      .map_or(code.len(), |i| range.end_byte + i);
+    self.original_snippet = code[snippet_start..snippet_end].to_string();
+    self.replacement_snippet = [
+      &code[snippet_start..range.start_byte],
+      self.replacement_string.as_str(),
+      &code[range.end_byte..snippet_end],
+    ]
+    .concat();
+  }
+
   pub(crate) fn is_delete(&self) -> bool {
     self.replacement_string.trim().is_empty()
   }
@@ -108,6 +156,38 @@ impl SourceCodeUnit {
   pub(crate) fn get_edit_for_context(
     &self, previous_edit_start: usize, previous_edit_end: usize, rules_store: &mut RuleStore,
     rules: &Vec<InstantiatedRule>,
+  ) -> Option<Edit> {
+    self.find_edit_for_context(
+      previous_edit_start,
+      previous_edit_end,
+      rules_store,
+      rules,
+      true,
+    )
+  }
+
+  /// Gets the first edit of the `rules` for the context of the previous edit, that is skipped as it is not entirely
+  /// within the line range (i.e. the edit `get_edit_for_context` would return without line range).
+  pub(crate) fn get_out_of_range_edit_for_context(
+    &self, previous_edit_start: usize, previous_edit_end: usize, rules_store: &mut RuleStore,
+    rules: &Vec<InstantiatedRule>,
+  ) -> Option<Edit> {
+    if self.piranha_arguments().line_range().is_none() {
+      return None;
+    }
+    self.find_edit_for_context(
+      previous_edit_start,
+      previous_edit_end,
+      rules_store,
+      rules,
+      false,
+    )
+  }
+
+  // Finds the first edit of the `rules` for the context of the previous edit, that is (or is not) within the line range.
+  fn find_edit_for_context(
+    &self, previous_edit_start: usize, previous_edit_end: usize, rules_store: &mut RuleStore,
+    rules: &Vec<InstantiatedRule>, within_line_range: bool,
   ) -> Option<Edit> {
     let number_of_ancestors_in_parent_scope = *self
       .piranha_arguments()
@@ -127,7 +207,10 @@ impl SourceCodeUnit {
     };
     for rule in rules {
       for ancestor in &context() {
-        if let Some(edit) = self.get_edit(rule, rules_store, *ancestor, false) {
+        if let Some(edit) = self
+          .get_edits(rule, rules_store, *ancestor, false)
+          .find(|edit| self.is_within_line_range(edit.p_match().range()) == within_line_range)
+        {
           return Some(edit);
         }
       }
@@ -135,25 +218,62 @@ impl SourceCodeUnit {
     None
   }
 
-  /// Gets the first match for the rule in `self`
+  /// Gets the first match for the rule in `self`, that does not overlap a node kept with `//piranha:keep`, and whose
+  /// edit is within the line range (if any)
   pub(crate) fn get_edit(
     &self, rule: &InstantiatedRule, rule_store: &mut RuleStore, node: Node, recursive: bool,
   ) -> Option<Edit> {
-    // Get all matches for the query in the given scope `node`.
+    self
+      .get_edits(rule, rule_store, node, recursive)
+      .find(|edit| self.is_within_line_range(edit.p_match().range()))
+  }
 
-    return self
+  /// Gets the edits for the matches of the rule in `self` (lazily), that do not overlap a node kept with `//piranha:keep`
+  pub(crate) fn get_edits<'a>(
+    &'a self, rule: &'a InstantiatedRule, rule_store: &mut RuleStore, node: Node, recursive: bool,
+  ) -> impl Iterator<Item = Edit> + 'a {
+    // Get all matches for the query in the given scope `node`.
+    let kept_ranges = self.kept_ranges();
+    self
       .get_matches(rule, rule_store, node, recursive)
-      .first()
-      .map(|p_match| {
-        let replacement_string = rule.replace().instantiate(p_match.matches());
-        let edit = Edit::new(
-          p_match.clone(),
+      .into_iter()
+      .filter(move |p_match| !overlaps_kept_range(p_match.range(), &kept_ranges))
+      .map(move |p_match| {
+        let replacement_string = self.parenthesize(
+          &p_match,
+          instantiate_replace(&rule.replace(), p_match.matches()),
+        );
+        let mut edit = Edit::new(
+          p_match,
           replacement_string,
           rule.name(),
+          rule.substitutions().clone(),
           self.code(),
         );
+        edit.flag = rule.flag().clone();
         trace!("Rewrite found : {:#?}", edit);
         edit
-      });
+      })
+  }
+
+  /// Encloses the replacement of the matched operand in parentheses, when the enclosing expression binds tighter than
+  /// the replacement (see `parenthesize_replacement`), w.r.t. the precedence of the operators of the language (if known).
+  fn parenthesize(&self, p_match: &Match, replacement: String) -> String {
+    let precedences = match self.piranha_arguments().language().precedences() {
+      Some(precedences) => precedences,
+      None => return replacement,
+    };
+    let range = p_match.range();
+    let node = get_node_for_range(self.root_node(), range.start_byte, range.end_byte);
+    if node.start_byte() != range.start_byte || node.end_byte() != range.end_byte {
+      return replacement;
+    }
+    parenthesize_replacement(
+      node,
+      self.code(),
+      &replacement,
+      precedences,
+      &mut self.piranha_arguments().language().parser(),
+    )
   }
 }
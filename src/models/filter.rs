@@ -40,6 +40,9 @@ use super::default_configs::{
   default_enclosing_node, default_not_contains_queries, default_not_enclosing_node,
 };
 
+const AT_LEAST_ABOVE_AT_MOST: &str =
+  "Invalid Filter Argument. `at_least` should be less than or equal to `at_most` !!!";
+
 #[derive(Deserialize, Debug, Clone, Hash, PartialEq, Eq, Getters, Builder)]
 #[pyclass]
 #[builder(build_fn(name = "create"))]
@@ -78,7 +81,7 @@ pub struct Filter {
   #[serde(default = "default_contains_query")]
   #[pyo3(get)]
   contains: CGPattern,
-  /// Least number of matches we should find for the contains query
+  /// Least number of matches we should find for the contains query (1 by default, or `at_most` if lower)
   #[builder(default = "default_contains_at_least()")]
   #[get = "pub"]
   #[serde(default = "default_contains_at_least")]
@@ -115,7 +118,8 @@ impl Filter {
     contains: Option<String>, at_least: Option<u32>, at_most: Option<u32>,
     child_count: Option<u32>, sibling_count: Option<u32>,
   ) -> Self {
-    FilterBuilder::default()
+    let mut filter_builder = FilterBuilder::default();
+    filter_builder
       .enclosing_node(CGPattern::new(enclosing_node.unwrap_or_default()))
       .outermost_enclosing_node(CGPattern::new(outermost_enclosing_node.unwrap_or_default()))
       .not_enclosing_node(CGPattern::new(not_enclosing_node.unwrap_or_default()))
@@ -126,9 +130,15 @@ impl Filter {
           .map(|x| CGPattern::new(x.to_string()))
           .collect_vec(),
       )
-      .contains(CGPattern::new(contains.unwrap_or_default()))
-      .at_least(at_least.unwrap_or(default_contains_at_least()))
-      .at_most(at_most.unwrap_or(default_contains_at_most()))
+      .contains(CGPattern::new(contains.unwrap_or_default()));
+    // Only the explicit bounds are set, such that the default `at_least` can be lowered to `at_most`
+    if let Some(at_least) = at_least {
+      filter_builder.at_least(at_least);
+    }
+    if let Some(at_most) = at_most {
+      filter_builder.at_most(at_most);
+    }
+    filter_builder
       .child_count(child_count.unwrap_or(default_child_count()))
       .sibling_count(sibling_count.unwrap_or(default_sibling_count()))
       .build()
@@ -138,21 +148,9 @@ impl Filter {
 
 impl Validator for Filter {
   fn validate(&self) -> Result<(), String> {
-    // Only allow users to set either contains or not_contains, but not both
-    if *self.contains() != default_contains_query()
-      && *self.not_contains() != default_not_contains_queries()
-    {
-      return Err(
-        "Invalid Filter Argument. `contains` and `not_contains` cannot be set at the same time !!! Please use two filters instead."
-          .to_string(),
-      );
-    }
-
-    if self.at_least > self.at_most {
-      return Err(
-        "Invalid Filter Argument. `at_least` should be less than or equal to `at_most` !!!"
-          .to_string(),
-      );
+    // The default `at_least` is lowered to `at_most` (e.g. `at_most = 0` acts as a `not_contains`)
+    if self.at_least != default_contains_at_least() && self.at_least > self.at_most {
+      return Err(AT_LEAST_ABOVE_AT_MOST.to_string());
     }
 
     // If the user set `at_least` or `at_most`, then the contains query cannot be empty
@@ -212,6 +210,12 @@ impl FilterBuilder {
   }
 
   fn _validate(&self) -> Result<Filter, String> {
+    // An explicit `at_least` is never lowered to `at_most` (even if it is the default one)
+    if let (Some(at_least), Some(at_most)) = (self.at_least, self.at_most) {
+      if at_least > at_most {
+        return Err(AT_LEAST_ABOVE_AT_MOST.to_string());
+      }
+    }
     let _filter: Filter = self.create().unwrap();
     _filter.validate().map(|_| _filter)
   }
@@ -307,6 +311,8 @@ impl SourceCodeUnit {
     rule_store: &mut RuleStore,
   ) -> bool {
     let mut updated_substitutions = self.piranha_arguments().input_substitutions();
+    // The filters may refer to the holes of the rule too (e.g. the name of the deleted variable)
+    updated_substitutions.extend(rule.substitutions().clone());
     updated_substitutions.extend(substitutions.clone());
     rule
       .filters()
@@ -325,8 +331,16 @@ impl SourceCodeUnit {
   /// The function identifies the `enclosing_node` by traversing the ancestors of the `node`. Within this node:
   /// (i) if `not_contains` is provided, it ensures no sub-tree matches any of these queries,
   /// (ii) if `contains` is provided, it ensures the number sub-trees matching `contains` fall within the specified range.
+  /// Both are checked when both are provided (e.g. a method that calls the flag API twice, but has no `return null`).
   ///
   /// If these conditions hold, the function returns true, indicating the `node` meets the `filter`'s criteria.
+  ///
+  /// The filter is checked against the current tree (i.e. after all the previous edits of the source code unit),
+  /// e.g. the switch enclosing a nested flag check once the `if` statement of the outer flag is collapsed.
+  ///
+  /// Note that `not_enclosing_node` is checked first, against the ancestors of `node` itself (not of the `enclosing_node`).
+  /// Therefore, if both are provided, `not_enclosing_node` takes precedence: the `node` is rejected whenever any of its
+  /// ancestors matches it, even if that ancestor is outside the `enclosing_node`.
   fn _check(
     &self, filter: Filter, node: Node, rule_store: &mut RuleStore,
     substitutions: &HashMap<String, String>,
@@ -416,7 +430,7 @@ impl SourceCodeUnit {
 
     while let Some(parent) = current_node.parent() {
       if let Some(p_match) =
-        get_match_for_query(&parent, self.code(), rule_store.query(ts_query), false)
+        get_match_for_query(&parent, self.code(), &rule_store.query(ts_query), false)
       {
         let matched_ancestor = get_node_for_range(
           self.root_node(),
@@ -450,7 +464,7 @@ impl SourceCodeUnit {
       None,
       None,
     );
-    let at_least = filter.at_least as usize;
+    let at_least = filter.at_least.min(filter.at_most) as usize;
     let at_most = filter.at_most as usize;
     // Validate if the count of matches falls within the expected range
     at_least <= matches.len() && matches.len() <= at_most
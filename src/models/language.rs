@@ -11,23 +11,35 @@
  limitations under the License.
 */
 
-use std::str::FromStr;
+use std::{
+  fs,
+  path::{Path, PathBuf},
+  str::FromStr,
+};
 
 use getset::Getters;
 use serde_derive::Deserialize;
 use tree_sitter::{Parser, Query};
 
-use crate::utilities::parse_toml;
+use crate::utilities::{go_package_cleanup::perform_go_package_cleanup, parse_toml};
 
 use super::{
   default_configs::{
-    default_language, GO, JAVA, KOTLIN, PYTHON, STRINGS, SWIFT, THRIFT, TSX, TS_SCHEME, TYPESCRIPT,
+    default_language, GO, JAVA, KOTLIN, PHP, PYTHON, RUBY, RUST, STRINGS, SWIFT, THRIFT, TSX,
+    TS_SCHEME, TYPESCRIPT,
   },
   outgoing_edges::Edges,
+  piranha_arguments::PiranhaArguments,
   rule::Rules,
   scopes::{ScopeConfig, ScopeGenerator},
+  source_code_unit::SourceCodeUnit,
 };
 
+/// Cleans up the files of each package (i.e. of each directory, along with its path) rewritten by Piranha, once no
+/// more rules apply (see `package_cleanup`).
+pub(crate) type PackageCleanup =
+  fn(&mut [(PathBuf, Vec<SourceCodeUnit>)], &PiranhaArguments, &mut Parser);
+
 #[derive(Debug, Clone, Getters, PartialEq)]
 pub struct PiranhaLanguage {
   /// The extension of the language FIXME: - https://github.com/uber/piranha/issues/365
@@ -51,6 +63,56 @@ pub struct PiranhaLanguage {
   /// The node kinds to be considered when searching for comments
   #[get = "pub"]
   comment_nodes: Vec<String>,
+  /// The fields (as `(kind, field)` pairs) of the assignments and the declarations, that are assigned (or declared),
+  /// e.g. `("assignment_statement", "left")`. These are used to determine if a node is in r-value position.
+  #[get = "pub"]
+  assignment_targets: Vec<(String, String)>,
+  /// The precedence of the expressions (if known for the language), used to delete the parentheses that became
+  /// redundant once the enclosed expression has been simplified
+  #[get = "pub(crate)"]
+  precedences: Option<Precedences>,
+  /// The built-in rules (or groups) re-applied to each rewritten function until none of them changes it anymore, since
+  /// the edits may cascade, e.g. the deletion of a branch leaving a variable unused
+  #[get = "pub(crate)"]
+  fixpoint_rules: Vec<String>,
+}
+
+/// The operator metadata of a language, w.r.t. which the parentheses enclosing an expression are redundant
+/// (see `get_redundant_parentheses`). The contexts are `(kind, field)` pairs, where an empty field matches any child.
+#[derive(Debug, Clone, Getters, PartialEq, Default)]
+pub(crate) struct Precedences {
+  /// The precedence of the operators of the `binary_expression`s (the higher, the tighter it binds),
+  /// e.g. `("&&", 2)`. All the binary operators are left-associative.
+  #[get = "pub(crate)"]
+  binary_operators: Vec<(String, u8)>,
+  /// The kinds of the primary expressions (e.g. the identifiers, the literals and the calls), which bind tighter
+  /// than any operator
+  #[get = "pub(crate)"]
+  primary_kinds: Vec<String>,
+  /// The contexts in which the expression is not the operand of another one (e.g. a returned expression, or an argument)
+  #[get = "pub(crate)"]
+  free_contexts: Vec<(String, String)>,
+  /// The contexts in which the expression is the operand of a primary expression (e.g. the operand of a selector),
+  /// i.e. a primary expression is expected
+  #[get = "pub(crate)"]
+  primary_contexts: Vec<(String, String)>,
+}
+
+impl Precedences {
+  fn new(
+    binary_operators: &[(&[&str], u8)], primary_kinds: &[&str], free_contexts: &[(&str, &str)],
+    primary_contexts: &[(&str, &str)],
+  ) -> Self {
+    Precedences {
+      binary_operators: binary_operators
+        .iter()
+        .flat_map(|(operators, precedence)| operators.iter().map(|o| (o.to_string(), *precedence)))
+        .collect(),
+      primary_kinds: primary_kinds.iter().map(|k| k.to_string()).collect(),
+      free_contexts: to_assignment_targets(free_contexts),
+      primary_contexts: to_assignment_targets(primary_contexts),
+    }
+  }
 }
 
 #[derive(Deserialize, Debug, Clone, PartialEq, Default)]
@@ -60,12 +122,48 @@ pub enum SupportedLanguage {
   Kotlin,
   Go,
   Swift,
+  Rust,
+  Php,
+  Ruby,
   Ts,
   Tsx,
   Python,
   Thrift,
   Strings,
   TsScheme,
+  /// A language whose grammar is loaded at runtime (see `CustomLanguageConfig`)
+  Custom,
+}
+
+/// The config of a custom language (i.e. a `.toml` file), whose tree-sitter grammar is compiled as a dynamic library
+/// and loaded at runtime. Besides the grammar, it specifies the node kinds that are hardcoded for the built-in
+/// languages. There are no built-in rules for a custom language.
+#[derive(Deserialize, Debug, Clone, Default)]
+struct CustomLanguageConfig {
+  /// The extension of the files of the language, e.g. `flags`
+  extension: String,
+  /// Path to the grammar, compiled as a dynamic library (relative to the config file)
+  grammar: String,
+  /// The function of the grammar returning the language (`tree_sitter_<extension>` by default)
+  #[serde(default)]
+  symbol: Option<String>,
+  /// The node kinds of the comments (see `comment_nodes` of `PiranhaLanguage`)
+  #[serde(default)]
+  comment_nodes: Vec<String>,
+  /// The assigned fields of the assignments and the declarations (see `assignment_targets` of `PiranhaLanguage`)
+  #[serde(default)]
+  assignment_targets: Vec<(String, String)>,
+  /// The scopes (e.g. `Method` and `Class`) of the edges, as in the `scope_config.toml` of the built-in languages
+  #[serde(default)]
+  scopes: Vec<ScopeGenerator>,
+}
+
+/// Converts the `(kind, field)` pairs to the `assignment_targets` of a language
+fn to_assignment_targets(pairs: &[(&str, &str)]) -> Vec<(String, String)> {
+  pairs
+    .iter()
+    .map(|(kind, field)| (kind.to_string(), field.to_string()))
+    .collect()
 }
 
 impl PiranhaLanguage {
@@ -89,13 +187,22 @@ impl PiranhaLanguage {
     parser
   }
 
-  pub(crate) fn can_parse(&self, de: &jwalk::DirEntry<((), ())>) -> bool {
-    de.path()
+  pub(crate) fn can_parse(&self, path: &Path) -> bool {
+    path
       .extension()
       .and_then(|e| e.to_str().filter(|x| x.eq(&self.extension())))
       .is_some()
   }
 
+  /// Returns the cleanup of the rewritten packages of the language, if any (i.e. the deletion of the unselected
+  /// implementations and of the unreachable functions of Go).
+  pub(crate) fn package_cleanup(&self) -> Option<PackageCleanup> {
+    match self.supported_language {
+      SupportedLanguage::Go => Some(perform_go_package_cleanup),
+      _ => None,
+    }
+  }
+
   #[cfg(test)]
   pub(crate) fn set_scopes(&mut self, scopes: Vec<ScopeGenerator>) {
     self.scopes = scopes;
@@ -110,14 +217,15 @@ impl Default for PiranhaLanguage {
 
 impl From<&str> for PiranhaLanguage {
   fn from(language: &str) -> Self {
-    PiranhaLanguage::from_str(language).unwrap()
+    PiranhaLanguage::from_str(language).unwrap_or_else(|e| panic!("{e}"))
   }
 }
 
 impl std::str::FromStr for PiranhaLanguage {
-  type Err = &'static str;
+  type Err = String;
   /// This method is leveraged by `clap` to parse the command line
-  /// argument into PiranhaLanguage
+  /// argument into PiranhaLanguage.
+  /// The `language` is either a built-in language (e.g. `go`), or the path to the config (`.toml`) of a custom one
   fn from_str(language: &str) -> Result<Self, Self::Err> {
     match language {
       JAVA => {
@@ -135,6 +243,53 @@ impl std::str::FromStr for PiranhaLanguage {
           .scopes()
           .to_vec(),
           comment_nodes: vec!["line_comment".to_string(), "block_comment".to_string()],
+          assignment_targets: to_assignment_targets(&[
+            ("assignment_expression", "left"),
+            ("variable_declarator", "name"),
+            ("formal_parameter", "name"),
+            ("enhanced_for_statement", "name"),
+          ]),
+          precedences: Some(Precedences::new(
+            &[
+              (&["||"], 1),
+              (&["&&"], 2),
+              (&["|"], 3),
+              (&["^"], 4),
+              (&["&"], 5),
+              (&["==", "!="], 6),
+              (&["<", "<=", ">", ">="], 7),
+              (&["<<", ">>", ">>>"], 8),
+              (&["+", "-"], 9),
+              (&["*", "/", "%"], 10),
+            ],
+            &[
+              "identifier",
+              "field_access",
+              "method_invocation",
+              "array_access",
+              "this",
+              "true",
+              "false",
+              "null_literal",
+              "decimal_integer_literal",
+              "string_literal",
+              "character_literal",
+              "parenthesized_expression",
+            ],
+            &[
+              ("return_statement", ""),
+              ("argument_list", ""),
+              ("variable_declarator", "value"),
+              ("assignment_expression", "right"),
+              ("parenthesized_expression", ""),
+            ],
+            &[
+              ("field_access", "object"),
+              ("method_invocation", "object"),
+              ("array_access", "array"),
+            ],
+          )),
+          fixpoint_rules: vec![],
         })
       }
       GO => {
@@ -150,6 +305,62 @@ impl std::str::FromStr for PiranhaLanguage {
             .scopes()
             .to_vec(),
           comment_nodes: vec!["comment".to_string()],
+          assignment_targets: to_assignment_targets(&[
+            ("assignment_statement", "left"),
+            ("short_var_declaration", "left"),
+            ("var_spec", "name"),
+            ("const_spec", "name"),
+            ("parameter_declaration", "name"),
+            ("range_clause", "left"),
+          ]),
+          precedences: Some(Precedences::new(
+            &[
+              (&["||"], 1),
+              (&["&&"], 2),
+              (&["==", "!=", "<", "<=", ">", ">="], 3),
+              (&["+", "-", "|", "^"], 4),
+              (&["*", "/", "%", "<<", ">>", "&", "&^"], 5),
+            ],
+            &[
+              "identifier",
+              "selector_expression",
+              "call_expression",
+              "index_expression",
+              "true",
+              "false",
+              "nil",
+              "int_literal",
+              "float_literal",
+              "interpreted_string_literal",
+              "raw_string_literal",
+              "parenthesized_expression",
+            ],
+            &[
+              ("expression_list", ""),
+              ("argument_list", ""),
+              ("literal_element", ""),
+              ("if_statement", "condition"),
+              ("for_clause", "condition"),
+              ("expression_switch_statement", "value"),
+              ("parenthesized_expression", ""),
+            ],
+            &[
+              ("selector_expression", "operand"),
+              ("call_expression", "function"),
+              ("index_expression", "operand"),
+            ],
+          )),
+          fixpoint_rules: [
+            "boolean_expression_simplify",
+            "unused_variable_cleanup",
+            "delete_statement_after_return",
+            "delete_statement_after_loop_jump",
+            "simplify_boolean_return",
+            "dedupe_statements",
+          ]
+          .iter()
+          .map(|r| r.to_string())
+          .collect(),
         })
       }
       KOTLIN => {
@@ -165,6 +376,9 @@ impl std::str::FromStr for PiranhaLanguage {
             .scopes()
             .to_vec(),
           comment_nodes: vec!["comment".to_string(), "line_comment".to_string()],
+          assignment_targets: vec![],
+          precedences: None,
+          fixpoint_rules: vec![],
         })
       }
       PYTHON => Ok(PiranhaLanguage {
@@ -175,6 +389,15 @@ impl std::str::FromStr for PiranhaLanguage {
         edges: None,
         scopes: vec![],
         comment_nodes: vec![],
+        assignment_targets: to_assignment_targets(&[
+          ("assignment", "left"),
+          ("augmented_assignment", "left"),
+          ("for_statement", "left"),
+          ("named_expression", "name"),
+          ("default_parameter", "name"),
+        ]),
+        precedences: None,
+        fixpoint_rules: vec![],
       }),
       SWIFT => {
         let rules: Rules = parse_toml(include_str!("../cleanup_rules/swift/rules.toml"));
@@ -189,28 +412,103 @@ impl std::str::FromStr for PiranhaLanguage {
           .scopes()
           .to_vec(),
           comment_nodes: vec!["comment".to_string(), "multiline_comment".to_string()],
+          assignment_targets: vec![],
+          precedences: None,
+          fixpoint_rules: vec![],
           rules: Some(rules),
           edges: Some(edges),
         })
       }
-      TYPESCRIPT => Ok(PiranhaLanguage {
-        extension: language.to_string(),
-        supported_language: SupportedLanguage::Ts,
-        language: tree_sitter_typescript::language_typescript(),
-        rules: None,
-        edges: None,
-        scopes: vec![],
-        comment_nodes: vec![],
-      }),
-      TSX => Ok(PiranhaLanguage {
-        extension: language.to_string(),
-        supported_language: SupportedLanguage::Tsx,
-        language: tree_sitter_typescript::language_tsx(),
-        rules: None,
-        edges: None,
-        scopes: vec![],
-        comment_nodes: vec![],
-      }),
+      RUST => {
+        let rules: Rules = parse_toml(include_str!("../cleanup_rules/rust/rules.toml"));
+        let edges: Edges = parse_toml(include_str!("../cleanup_rules/rust/edges.toml"));
+        Ok(PiranhaLanguage {
+          extension: language.to_string(),
+          supported_language: SupportedLanguage::Rust,
+          language: tree_sitter_rust::language(),
+          rules: Some(rules),
+          edges: Some(edges),
+          scopes: parse_toml::<ScopeConfig>(include_str!(
+            "../cleanup_rules/rust/scope_config.toml"
+          ))
+          .scopes()
+          .to_vec(),
+          comment_nodes: vec!["line_comment".to_string(), "block_comment".to_string()],
+          assignment_targets: vec![],
+          precedences: None,
+          fixpoint_rules: vec![],
+        })
+      }
+      PHP => {
+        let rules: Rules = parse_toml(include_str!("../cleanup_rules/php/rules.toml"));
+        let edges: Edges = parse_toml(include_str!("../cleanup_rules/php/edges.toml"));
+        Ok(PiranhaLanguage {
+          extension: language.to_string(),
+          supported_language: SupportedLanguage::Php,
+          language: tree_sitter_php::language(),
+          rules: Some(rules),
+          edges: Some(edges),
+          scopes: parse_toml::<ScopeConfig>(include_str!("../cleanup_rules/php/scope_config.toml"))
+            .scopes()
+            .to_vec(),
+          comment_nodes: vec!["comment".to_string()],
+          assignment_targets: vec![],
+          precedences: None,
+          fixpoint_rules: vec![],
+        })
+      }
+      RUBY => {
+        let rules: Rules = parse_toml(include_str!("../cleanup_rules/ruby/rules.toml"));
+        let edges: Edges = parse_toml(include_str!("../cleanup_rules/ruby/edges.toml"));
+        Ok(PiranhaLanguage {
+          extension: language.to_string(),
+          supported_language: SupportedLanguage::Ruby,
+          language: tree_sitter_ruby::language(),
+          rules: Some(rules),
+          edges: Some(edges),
+          scopes: parse_toml::<ScopeConfig>(include_str!(
+            "../cleanup_rules/ruby/scope_config.toml"
+          ))
+          .scopes()
+          .to_vec(),
+          comment_nodes: vec!["comment".to_string()],
+          assignment_targets: vec![],
+          precedences: None,
+          fixpoint_rules: vec![],
+        })
+      }
+      TYPESCRIPT => {
+        let rules: Rules = parse_toml(include_str!("../cleanup_rules/ts/rules.toml"));
+        let edges: Edges = parse_toml(include_str!("../cleanup_rules/ts/edges.toml"));
+        Ok(PiranhaLanguage {
+          extension: language.to_string(),
+          supported_language: SupportedLanguage::Ts,
+          language: tree_sitter_typescript::language_typescript(),
+          rules: Some(rules),
+          edges: Some(edges),
+          scopes: vec![],
+          comment_nodes: vec![],
+          assignment_targets: vec![],
+          precedences: None,
+          fixpoint_rules: vec![],
+        })
+      }
+      TSX => {
+        let rules: Rules = parse_toml(include_str!("../cleanup_rules/ts/rules.toml"));
+        let edges: Edges = parse_toml(include_str!("../cleanup_rules/ts/edges.toml"));
+        Ok(PiranhaLanguage {
+          extension: language.to_string(),
+          supported_language: SupportedLanguage::Tsx,
+          language: tree_sitter_typescript::language_tsx(),
+          rules: Some(rules),
+          edges: Some(edges),
+          scopes: vec![],
+          comment_nodes: vec![],
+          assignment_targets: vec![],
+          precedences: None,
+          fixpoint_rules: vec![],
+        })
+      }
       THRIFT => Ok(PiranhaLanguage {
         extension: language.to_string(),
         supported_language: SupportedLanguage::Thrift,
@@ -219,6 +517,9 @@ impl std::str::FromStr for PiranhaLanguage {
         edges: None,
         scopes: vec![],
         comment_nodes: vec![],
+        assignment_targets: vec![],
+        precedences: None,
+        fixpoint_rules: vec![],
       }),
       STRINGS => Ok(PiranhaLanguage {
         extension: language.to_string(),
@@ -228,6 +529,9 @@ impl std::str::FromStr for PiranhaLanguage {
         edges: None,
         scopes: vec![],
         comment_nodes: vec![],
+        assignment_targets: vec![],
+        precedences: None,
+        fixpoint_rules: vec![],
       }),
       TS_SCHEME => Ok(PiranhaLanguage {
         extension: language.to_string(),
@@ -237,8 +541,86 @@ impl std::str::FromStr for PiranhaLanguage {
         edges: None,
         scopes: vec![],
         comment_nodes: vec![],
+        assignment_targets: vec![],
+        precedences: None,
+        fixpoint_rules: vec![],
       }),
-      _ => Err("Language not supported"),
+      _ if language.ends_with(".toml") => load_custom_language(Path::new(language)),
+      _ => Err("Language not supported".to_string()),
     }
   }
 }
+
+/// The languages of the command line (besides the custom ones)
+const CLI_LANGUAGES: [&str; 10] = [
+  JAVA, SWIFT, PYTHON, KOTLIN, GO, RUST, PHP, RUBY, TSX, TYPESCRIPT,
+];
+
+/// Parses the language of the command line, i.e. a built-in language or the path to the config (`.toml`) of a custom
+/// language.
+pub(crate) fn parse_language(language: &str) -> Result<PiranhaLanguage, String> {
+  if !CLI_LANGUAGES.contains(&language) && !language.ends_with(".toml") {
+    return Err(format!(
+      "possible values: {}, or the path to the config (`.toml`) of a custom language",
+      CLI_LANGUAGES.join(", ")
+    ));
+  }
+  language.parse()
+}
+
+/// Loads the custom language whose config (see `CustomLanguageConfig`) is the file at `path`.
+fn load_custom_language(path: &Path) -> Result<PiranhaLanguage, String> {
+  let content = fs::read_to_string(path)
+    .map_err(|e| format!("Could not read the language config {path:?} - {e}"))?;
+  let config: CustomLanguageConfig = toml::from_str(&content)
+    .map_err(|e| format!("Could not parse the language config {path:?} - {e}"))?;
+  let grammar = path.parent().unwrap_or(Path::new("")).join(&config.grammar);
+  if grammar.extension().map_or(false, |e| e == "wasm") {
+    return Err(format!(
+      "Could not load the grammar {grammar:?} - the wasm grammars are not supported, please compile the grammar as a dynamic library"
+    ));
+  }
+  let symbol = config
+    .symbol
+    .clone()
+    .unwrap_or_else(|| format!("tree_sitter_{}", config.extension));
+  let language = load_grammar(&grammar, &symbol)?;
+  // Fails if the grammar was generated for an incompatible version of tree-sitter
+  Parser::new()
+    .set_language(language)
+    .map_err(|e| format!("Could not load the grammar {grammar:?} - {e}"))?;
+  Ok(PiranhaLanguage {
+    extension: config.extension,
+    supported_language: SupportedLanguage::Custom,
+    language,
+    rules: None,
+    edges: None,
+    scopes: config.scopes,
+    comment_nodes: config.comment_nodes,
+    assignment_targets: config.assignment_targets,
+    precedences: None,
+    fixpoint_rules: vec![],
+  })
+}
+
+/// Returns the language of the grammar compiled as the dynamic library at `path`, i.e. the result of its function
+/// `symbol`. The library is never unloaded, since the language (and the trees parsed with it) refer to its tables.
+fn load_grammar(path: &Path, symbol: &str) -> Result<tree_sitter::Language, String> {
+  // SAFETY: The library is expected to be a tree-sitter grammar, whose `symbol` is a `const TSLanguage *(void)`
+  // function (like the one of the built-in grammars)
+  unsafe {
+    let library = libloading::Library::new(path)
+      .map_err(|e| format!("Could not load the grammar {path:?} - {e}"))?;
+    let language_fn: libloading::Symbol<unsafe extern "C" fn() -> tree_sitter::Language> =
+      library.get(symbol.as_bytes()).map_err(|e| {
+        format!("Could not find the function `{symbol}` of the grammar {path:?} - {e}")
+      })?;
+    let language = language_fn();
+    std::mem::forget(library);
+    Ok(language)
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/language_test.rs"]
+mod language_test;
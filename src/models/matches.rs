@@ -11,25 +11,35 @@ Copyright (c) 2023 Uber Technologies, Inc.
  limitations under the License.
 */
 
-use std::collections::HashMap;
+use std::{collections::HashMap, time::Instant};
 
 use getset::{Getters, MutGetters};
 use itertools::Itertools;
 use log::trace;
 use pyo3::prelude::{pyclass, pymethods};
+use regex::Regex;
 use serde_derive::{Deserialize, Serialize};
 use tree_sitter::Node;
 
 use crate::utilities::{
   gen_py_str_methods,
+  go_declarations::is_unwrapping_colliding,
   tree_sitter_utilities::{get_all_matches_for_query, get_node_for_range},
 };
 
 use super::{
-  piranha_arguments::PiranhaArguments, rule::InstantiatedRule, rule_store::RuleStore,
-  source_code_unit::SourceCodeUnit,
+  language::SupportedLanguage, piranha_arguments::PiranhaArguments, rule::InstantiatedRule,
+  rule_store::RuleStore, source_code_unit::SourceCodeUnit,
 };
 
+/// The nodes grouping the targets of an assignment (or a declaration), e.g. `a, b` in `a, b = f()`
+const ASSIGNMENT_TARGET_LISTS: [&str; 4] = [
+  "expression_list",
+  "pattern_list",
+  "tuple_pattern",
+  "list_pattern",
+];
+
 #[derive(Serialize, Debug, Clone, Getters, MutGetters, Deserialize)]
 #[pyclass]
 pub(crate) struct Match {
@@ -54,6 +64,14 @@ pub(crate) struct Match {
   #[get_mut]
   #[serde(skip)]
   associated_comments: Vec<Range>,
+  // Captures the range between the match and its next sibling, to which the leading comments are re-attached
+  // (see `preserve_leading_comments`)
+  #[get]
+  #[serde(skip)]
+  associated_gap: Option<Range>,
+  // Range of the match in the original content of the file (i.e. before any edit was applied)
+  #[serde(skip)]
+  original_range: Option<Range>,
 }
 gen_py_str_methods!(Match);
 
@@ -67,6 +85,8 @@ impl Match {
       matches,
       associated_comma: None,
       associated_comments: Vec::new(),
+      associated_gap: None,
+      original_range: None,
     }
   }
   ///
@@ -77,6 +97,7 @@ impl Match {
     let associated_ranges = [
       self.associated_comma().iter().collect_vec(),
       self.associated_comments().iter().collect_vec(),
+      self.associated_gap().iter().collect_vec(),
     ]
     .concat()
     .iter()
@@ -105,18 +126,17 @@ impl Match {
 
   /// Get the edit's replacement range.
   pub(crate) fn range(&self) -> tree_sitter::Range {
-    tree_sitter::Range {
-      start_byte: self.range.start_byte,
-      end_byte: self.range.end_byte,
-      start_point: tree_sitter::Point {
-        row: self.range.start_point.row,
-        column: self.range.start_point.column,
-      },
-      end_point: tree_sitter::Point {
-        row: self.range.end_point.row,
-        column: self.range.end_point.column,
-      },
-    }
+    tree_sitter::Range::from(self.range)
+  }
+
+  /// Get the range of the match in the original content of the file (if it was recorded),
+  /// else the range of the match.
+  pub(crate) fn original_range(&self) -> tree_sitter::Range {
+    tree_sitter::Range::from(self.original_range.unwrap_or(self.range))
+  }
+
+  pub(crate) fn set_original_range(&mut self, original_range: tree_sitter::Range) {
+    self.original_range = Some(Range::from(original_range));
   }
 
   // Populates the leading and trailing comma and comment ranges for the match.
@@ -125,6 +145,155 @@ impl Match {
   ) {
     self.get_associated_elements(node, code, piranha_arguments, true);
     self.get_associated_elements(node, code, piranha_arguments, false);
+    if *piranha_arguments.preserve_leading_comments() {
+      self.reattach_leading_comments(node, piranha_arguments);
+    }
+    // The flag comments are deleted regardless
+    self.get_associated_flag_comments(node, code, piranha_arguments);
+  }
+
+  /// Re-attaches the comments immediately preceding the node (i.e. the consecutive comments right above it, without
+  /// any blank line in between) to its next surviving sibling, such that they document this sibling once the node is
+  /// deleted. The node is deleted up to this sibling (i.e. along with the comment on its last line, and the blank lines
+  /// following it).
+  /// When the node is the last one of its block, there is nothing left to document, and the comments are deleted
+  /// along with the node.
+  fn reattach_leading_comments(&mut self, node: &Node, piranha_arguments: &PiranhaArguments) {
+    let comment_nodes = piranha_arguments.language().comment_nodes();
+    let is_comment = |n: &Node| comment_nodes.contains(&n.kind().to_string());
+    // A deleted comment does not take the adjacent comments along
+    if is_comment(node) {
+      return;
+    }
+
+    // The comments right above the node (which may be a child of the enclosing nodes starting with it)
+    let mut comments = vec![];
+    let mut current_node = *node;
+    loop {
+      match current_node.prev_sibling() {
+        Some(sibling) => {
+          let is_adjacent = sibling.end_position().row + 1 == current_node.start_position().row;
+          // A comment on the same line as the previous node refers to this previous node
+          let is_trailing = sibling.prev_sibling().map_or(false, |n| {
+            n.end_position().row == sibling.start_position().row
+          });
+          if !is_adjacent || is_trailing || !is_comment(&sibling) {
+            break;
+          }
+          comments.push(Range::from(sibling.range()));
+          current_node = sibling;
+        }
+        None => match current_node.parent() {
+          Some(parent) if parent.start_byte() == current_node.start_byte() => current_node = parent,
+          _ => break,
+        },
+      }
+    }
+    if comments.is_empty() {
+      return;
+    }
+
+    // The next sibling of the node (which may be a child of the enclosing nodes ending with it), skipping the
+    // comments on its last line
+    let mut current_node = *node;
+    while current_node.next_sibling().is_none() {
+      match current_node.parent() {
+        Some(parent) if parent.end_byte() == current_node.end_byte() => current_node = parent,
+        _ => break,
+      }
+    }
+    let mut next_sibling = current_node.next_named_sibling();
+    while let Some(sibling) =
+      next_sibling.filter(|n| is_comment(n) && n.start_position().row == node.end_position().row)
+    {
+      next_sibling = sibling.next_named_sibling();
+    }
+
+    match next_sibling {
+      Some(sibling) => {
+        // The comments are retained, even if they would be deleted along with the node otherwise (`cleanup_comments`)
+        self.associated_comments.retain(|c| !comments.contains(c));
+        self.associated_gap = Some(Range::from(tree_sitter::Range {
+          start_byte: node.end_byte(),
+          end_byte: sibling.start_byte(),
+          start_point: node.end_position(),
+          end_point: sibling.start_position(),
+        }));
+      }
+      None => {
+        for comment in comments {
+          if !self.associated_comments.contains(&comment) {
+            self.associated_comments.push(comment);
+          }
+        }
+      }
+    }
+  }
+
+  /// Gets the comments matching the `flag_comment_pattern` (e.g. `// FLAG: new_checkout`) that are immediately
+  /// adjacent to the node, i.e. the consecutive comments right above it (without any blank line in between),
+  /// and the comment on its last line.
+  /// The comments above the node are only considered up to the first one that does not match (e.g. a doc comment).
+  fn get_associated_flag_comments(
+    &mut self, node: &Node, code: &String, piranha_arguments: &PiranhaArguments,
+  ) {
+    let pattern = match piranha_arguments.flag_comment_pattern() {
+      // The pattern was validated when building the arguments
+      Some(pattern) => Regex::new(pattern).unwrap(),
+      None => return,
+    };
+    let comment_nodes = piranha_arguments.language().comment_nodes();
+    // A deleted comment does not take the adjacent comments along
+    if comment_nodes.contains(&node.kind().to_string()) {
+      return;
+    }
+    let is_flag_comment = |n: &Node| {
+      comment_nodes.contains(&n.kind().to_string())
+        && pattern.is_match(n.utf8_text(code.as_bytes()).unwrap())
+    };
+
+    let mut comments = vec![];
+    // The comments right above the node (which may be a child of the enclosing nodes starting with it)
+    let mut current_node = *node;
+    loop {
+      match current_node.prev_sibling() {
+        Some(sibling) => {
+          let is_adjacent = sibling.end_position().row + 1 >= current_node.start_position().row;
+          // A comment on the same line as the previous node refers to this previous node
+          let is_trailing = sibling.prev_sibling().map_or(false, |n| {
+            n.end_position().row == sibling.start_position().row
+          });
+          if !is_adjacent || is_trailing || !is_flag_comment(&sibling) {
+            break;
+          }
+          comments.push(sibling.range());
+          current_node = sibling;
+        }
+        None => match current_node.parent() {
+          Some(parent) if parent.start_byte() == current_node.start_byte() => current_node = parent,
+          _ => break,
+        },
+      }
+    }
+    // The comment on the last line of the node (which may be a child of the enclosing nodes ending with it)
+    let mut current_node = *node;
+    while current_node.next_sibling().is_none() {
+      match current_node.parent() {
+        Some(parent) if parent.end_byte() == current_node.end_byte() => current_node = parent,
+        _ => break,
+      }
+    }
+    if let Some(sibling) = current_node.next_sibling() {
+      if sibling.start_position().row == node.end_position().row && is_flag_comment(&sibling) {
+        comments.push(sibling.range());
+      }
+    }
+
+    for comment in comments.into_iter().map(Range::from) {
+      if !self.associated_comments.contains(&comment) {
+        self.associated_comments.push(comment);
+      }
+    }
   }
 
   /// Get the associated elements for the match.
@@ -188,6 +357,10 @@ impl Match {
     if !self.is_comment(comment.kind().to_string(), piranha_arguments) {
       return false;
     }
+    // A deleted comment does not take the adjacent comments along
+    if self.is_comment(deleted_node.kind().to_string(), piranha_arguments) {
+      return false;
+    }
     // If trailing, check if the comment is on the same line as the deleted node
     // i.e. where the deleted node ends or starts
     let is_on_same_line = comment.range().start_point.row == deleted_node.range().end_point.row
@@ -201,6 +374,13 @@ impl Match {
     if is_on_same_line {
       return true;
     }
+    // (Go) The comment preceding a deleted statement, that is immediately followed by another statement,
+    // likely describes both statements. Therefore, it is retained (i.e. re-anchored to the surviving statement).
+    if *piranha_arguments.language().supported_language() == SupportedLanguage::Go
+      && self.is_followed_by_statement(deleted_node, piranha_arguments)
+    {
+      return false;
+    }
     // Check if the previous node does not overlap with the comment
     if let Some(previous_node) = comment.prev_sibling() {
       if self.overlaps(comment, &previous_node) {
@@ -219,6 +399,22 @@ impl Match {
     true
   }
 
+  /// Checks if the given statement is immediately followed (i.e. on the next line) by another statement
+  fn is_followed_by_statement(
+    &self, statement: &Node, piranha_arguments: &PiranhaArguments,
+  ) -> bool {
+    if statement
+      .parent()
+      .map_or(true, |p| p.kind() != "statement_list")
+    {
+      return false;
+    }
+    statement.next_named_sibling().map_or(false, |n| {
+      !self.is_comment(n.kind().to_string(), piranha_arguments)
+        && n.start_position().row == statement.end_position().row + 1
+    })
+  }
+
   /// Checks if the given node_1 overlaps with the given node_2
   fn overlaps(&self, node_1: &Node, node_2: &Node) -> bool {
     (node_1.start_position().row < node_2.start_position().row
@@ -261,6 +457,22 @@ impl From<tree_sitter::Range> for Range {
     }
   }
 }
+impl From<Range> for tree_sitter::Range {
+  fn from(range: Range) -> Self {
+    Self {
+      start_byte: range.start_byte,
+      end_byte: range.end_byte,
+      start_point: tree_sitter::Point {
+        row: range.start_point.row,
+        column: range.start_point.column,
+      },
+      end_point: tree_sitter::Point {
+        row: range.end_point.row,
+        column: range.end_point.column,
+      },
+    }
+  }
+}
 gen_py_str_methods!(Range);
 
 /// A range of positions in a multi-line text document, both in terms of bytes and of
@@ -291,14 +503,16 @@ impl SourceCodeUnit {
       } else {
         (rule.replace_node(), rule.replace_idx())
       };
+    let start = Instant::now();
     let mut all_query_matches = get_all_matches_for_query(
       &node,
       self.code().to_string(),
-      rule_store.query(&rule.query()),
+      &rule_store.rule_query(rule),
       recursive,
       replace_node_tag,
       replace_node_idx,
     );
+    let number_of_matches = all_query_matches.len();
 
     // Applies the filter and returns the first element
     for p_match in all_query_matches.iter_mut() {
@@ -307,13 +521,50 @@ impl SourceCodeUnit {
         p_match.range().start_byte,
         p_match.range().end_byte,
       );
+      if *rule.rule().is_rvalue() && !self.is_rvalue(&matched_node) {
+        continue;
+      }
+      if *rule.rule().unwraps_block()
+        && *self.piranha_arguments().language().supported_language() == SupportedLanguage::Go
+        && is_unwrapping_colliding(matched_node, self.code())
+      {
+        continue;
+      }
       if self.is_satisfied(matched_node, rule, p_match.matches(), rule_store) {
         p_match.populate_associated_elements(&matched_node, self.code(), self.piranha_arguments());
         trace!("Found match {:#?}", p_match);
         output.push(p_match.clone());
       }
     }
+    self
+      .stats_mut()
+      .record_matches(&rule.name(), number_of_matches, start.elapsed());
     trace!("Matches found {}", output.len());
     output
   }
+
+  /// Checks if the `node` is in r-value position, i.e. it is neither the target of an assignment nor the name of
+  /// a declaration (as specified by the `assignment_targets` of the language), e.g. `flag` but not `x` in `x = flag`.
+  /// The lists of targets (e.g. `a, b := ...` in Go) are walked through, while any other parent (e.g. the index
+  /// expression `a[i] = ...`) makes the node a read.
+  fn is_rvalue(&self, node: &Node) -> bool {
+    let assignment_targets = self.piranha_arguments().language().assignment_targets();
+    let mut current_node = *node;
+    while let Some(parent) = current_node.parent() {
+      let is_target = assignment_targets.iter().any(|(kind, field)| {
+        parent.kind() == kind
+          && parent
+            .children_by_field_name(field, &mut parent.walk())
+            .any(|child| child.id() == current_node.id())
+      });
+      if is_target {
+        return false;
+      }
+      if !ASSIGNMENT_TARGET_LISTS.contains(&parent.kind()) {
+        return true;
+      }
+      current_node = parent;
+    }
+    true
+  }
 }
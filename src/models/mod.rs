@@ -19,7 +19,9 @@ pub(crate) mod language;
 pub(crate) mod matches;
 pub(crate) mod outgoing_edges;
 pub mod piranha_arguments;
+pub mod piranha_metrics;
 pub mod piranha_output;
+pub mod piranha_report;
 pub(crate) mod rule;
 pub(crate) mod rule_graph;
 pub(crate) mod rule_store;
@@ -13,20 +13,49 @@ Copyright (c) 2023 Uber Technologies, Inc.
 
 use super::{
   default_configs::{
-    default_allow_dirty_ast, default_cleanup_comments, default_cleanup_comments_buffer,
-    default_code_snippet, default_delete_consecutive_new_lines, default_delete_file_if_empty,
-    default_dry_run, default_exclude, default_global_tag_prefix, default_include,
-    default_number_of_ancestors_in_parent_scope, default_path_to_codebase,
-    default_path_to_configurations, default_path_to_output_summaries, default_piranha_language,
-    default_rule_graph, default_substitutions, GO, JAVA, KOTLIN, PYTHON, SWIFT, TSX, TYPESCRIPT,
+    default_aggressive_simplification, default_allow_dirty_ast, default_cleanup_comments,
+    default_cleanup_comments_buffer, default_cleanup_imports, default_cleanup_tests,
+    default_code_snippet, default_dedupe_statements, default_delete_consecutive_new_lines,
+    default_delete_empty_functions, default_delete_file_if_empty, default_delete_unreachable,
+    default_delete_unselected_implementations, default_diff_output, default_dry_run,
+    default_exclude, default_fail_on_unresolved, default_flag_comment_pattern, default_flag_states,
+    default_flags, default_flags_file, default_flatten_else, default_format_output,
+    default_formatter_command, default_global_tag_prefix, default_include,
+    default_include_generated, default_include_vendor, default_line_range,
+    default_max_fixpoint_iterations, default_metrics_out, default_no_ignore, default_no_prefilter,
+    default_normalize_go_layout, default_number_of_ancestors_in_parent_scope,
+    default_output_format, default_path_to_codebase, default_path_to_configurations,
+    default_path_to_output_summaries, default_path_to_report, default_per_file_timeout,
+    default_piranha_language, default_preserve_leading_comments, default_report_format,
+    default_rule_graph, default_simplify_boolean_return, default_strict, default_substitutions,
+    default_thread_count, default_treated_value, default_treatment,
+    default_use_default_as_treatment, default_verify_fixpoint, CLEANUP_TREATMENT,
+    DEDUPE_STATEMENTS, DEFAULT_AS_TREATMENT, DELETE_EMPTY_FUNCTIONS, FIXPOINT_EXCEEDED_SKIP_REASON,
+    FLATTEN_ELSE, FLIP_DEFAULT, FLIP_TREATMENT, GENERATED_CODE_SKIP_REASON, GO,
+    INVALID_REWRITE_SKIP_REASON, JAVA, JSON_OUTPUT_FORMAT, JSON_REPORT_FORMAT,
+    KEPT_FILE_SKIP_REASON, KOTLIN, OUT_OF_SCOPE_SKIP_REASON, PHP, PURE_FUNCTIONS, PYTHON, RUBY,
+    RUST, SARIF_REPORT_FORMAT, SIDE_EFFECT_UNSAFE, SIMPLIFY_BOOLEAN_RETURN, STALE_FLAG_NAME,
+    SUMMARY_OUTPUT_FORMAT, SWIFT, TEST_CLEANUP, TIMEOUT_SKIP_REASON, TREATED, TREATED_AS_TREATMENT,
+    TSX, TYPESCRIPT, VENDORED_CODE_SKIP_REASON,
   },
-  language::PiranhaLanguage,
+  edit::Edit,
+  language::{parse_language, PiranhaLanguage, SupportedLanguage},
+  matches::Match,
+  outgoing_edges::OutgoingEdges,
+  rule::Rule,
   rule_graph::{read_user_config_files, RuleGraph, RuleGraphBuilder},
   source_code_unit::SourceCodeUnit,
 };
-use crate::utilities::{parse_glob_pattern, parse_key_val};
+use crate::utilities::{
+  go_keep_directives::{is_directive, KEEP_FILE_DIRECTIVE},
+  is_included,
+  output_formatter::{normalize_whitespace, run_formatter_command},
+  parentheses::get_redundant_parentheses,
+  parse_flag_state, parse_glob_pattern, parse_key_val, read_file,
+};
 use clap::builder::TypedValueParser;
-use clap::Parser;
+use clap::{Args, Parser, Subcommand};
+use colored::Colorize;
 use derive_builder::Builder;
 use getset::{CopyGetters, Getters};
 use glob::Pattern;
@@ -34,31 +63,45 @@ use itertools::Itertools;
 use log::{info, warn};
 use pyo3::{
   prelude::{pyclass, pymethods},
-  types::PyDict,
+  types::{PyDict, PyList},
+  FromPyObject,
 };
 use regex::Regex;
 
-use std::collections::HashMap;
+use std::{
+  collections::{HashMap, HashSet},
+  iter::once,
+  path::{Path, PathBuf},
+  time::Instant,
+};
 
 /// A refactoring tool that eliminates dead code related to stale feature flags
 #[derive(Clone, Getters, CopyGetters, Debug, Parser, Builder)]
-#[clap(name = "Piranha")]
+#[clap(
+  name = "Piranha",
+  subcommand_negates_reqs = true,
+  args_conflicts_with_subcommands = true
+)]
 #[pyclass]
 #[builder(build_fn(name = "create"))]
 pub struct PiranhaArguments {
   /// Path to source code folder or file
+  // The (hidden) default only applies along with a subcommand, which does not require it
   #[get = "pub"]
   #[builder(default = "default_path_to_codebase()")]
-  #[clap(short = 'c', long, required = true)]
+  #[clap(short = 'c', long, required = true, default_value_t = default_path_to_codebase(), hide_default_value = true)]
   path_to_codebase: String,
 
-  /// Paths to include (as glob patterns)
+  /// Paths to include (as glob patterns, also matched against the path under the code base), e.g. `**/*.go` or
+  /// `services/checkout/...` (i.e. a package and all its subpackages). All the paths are included by default
   #[get = "pub"]
   #[builder(default = "default_include()")]
   #[clap(long, value_parser = parse_glob_pattern, num_args = 0.., required=false)]
   include: Vec<Pattern>,
 
-  /// Paths to exclude (as glob patterns)
+  /// Paths to exclude (as glob patterns, also matched against the path under the code base), e.g. `**/vendor/**`.
+  /// The exclusion takes precedence over the inclusion, and the usages of the package rules in the excluded files of
+  /// a package are only reported
   #[get = "pub"]
   #[builder(default = "default_exclude()")]
   #[clap(long, value_parser = parse_glob_pattern, num_args = 0.., required=false)]
@@ -70,16 +113,47 @@ pub struct PiranhaArguments {
   #[clap(short = 't', long, default_value_t = default_code_snippet())]
   code_snippet: String,
 
+  // The (1-based and inclusive) range of lines of the `code_snippet` to clean up, e.g. the lines selected in an editor.
+  // The matches whose edit is not entirely within these lines are skipped (see `SourceCodeUnit::is_within_line_range`)
+  #[get = "pub"]
+  #[builder(default = "default_line_range()")]
+  #[clap(skip)]
+  line_range: Option<(usize, usize)>,
+
   /// These substitutions instantiate the initial set of rules.
   /// Usage : -s stale_flag_name=SOME_FLAG -s namespace=SOME_NS1
   #[builder(default = "default_substitutions()")]
   #[clap(short = 's', value_parser = parse_key_val)]
   substitutions: Vec<(String, String)>,
 
+  /// Path to a JSON manifest of the stale flags to clean up in a single run, i.e. an array of the substitutions
+  /// of each flag, identified by its `stale_flag_name`
+  /// (e.g. `[{"stale_flag_name": "SOME_FLAG", "treated": "true", "treatment_group": "enabled"}]`).
+  /// The seed rules are instantiated for each flag, while the `-s` substitutions are shared by all the flags
+  #[get = "pub"]
+  #[builder(default = "default_flags_file()")]
+  #[clap(long)]
+  flags_file: Option<String>,
+
+  /// Whether each stale flag is treated as enabled (`true`) or disabled (`false`), i.e. its `treated` substitution.
+  /// The flags of the `flags_file` are updated, while the other flags are cleaned up too (as if they were listed in it).
+  /// This way, the same configuration drives both the cleanup shipping the feature and the one killing it.
+  /// Usage : --flag-state new_checkout=false
+  #[get = "pub"]
+  #[builder(default = "default_flag_states()")]
+  #[clap(long = "flag-state", value_parser = parse_flag_state)]
+  flag_states: Vec<(String, bool)>,
+
+  // The substitutions of each flag of the `flags_file` (read upon `build`)
+  #[builder(default = "default_flags()")]
+  #[clap(skip)]
+  flags: Vec<HashMap<String, String>>,
+
   /// Directory containing the configuration files -  `rules.toml` and  `edges.toml` (optional)
+  // The (hidden) default only applies along with a subcommand, which does not require it
   #[get = "pub"]
   #[builder(default = "default_path_to_configurations()")]
-  #[clap(short = 'f', long)]
+  #[clap(short = 'f', long, required = true, default_value_t = default_path_to_configurations(), hide_default_value = true)]
   path_to_configurations: String,
 
   /// Path to output summary json file
@@ -87,11 +161,38 @@ pub struct PiranhaArguments {
   #[builder(default = "default_path_to_output_summaries()")]
   #[clap(short = 'j', long)]
   path_to_output_summary: Option<String>,
-  /// The target language
+
+  /// The output format. `json` prints the output summaries (with the original and replacement snippets of each edit) to stdout
+  #[get = "pub"]
+  #[builder(default = "default_output_format()")]
+  #[clap(long, default_value_t = default_output_format(), value_parser = clap::builder::PossibleValuesParser::new([SUMMARY_OUTPUT_FORMAT, JSON_OUTPUT_FORMAT]))]
+  output_format: String,
+
+  /// The format of the report listing every rewrite, deleted file and match (along with its rule and substitutions)
+  #[get = "pub"]
+  #[builder(default = "default_report_format()")]
+  #[clap(long, value_parser = clap::builder::PossibleValuesParser::new([JSON_REPORT_FORMAT, SARIF_REPORT_FORMAT]))]
+  report_format: Option<String>,
+
+  /// Path to the file where the report is written (requires `report_format`)
+  #[get = "pub"]
+  #[builder(default = "default_path_to_report()")]
+  #[clap(long)]
+  path_to_report: Option<String>,
+
+  /// Path to the file where the metrics of the run (e.g. the lines removed and the functions deleted, in total, per
+  /// file and per flag) are written as Json
+  #[get = "pub"]
+  #[builder(default = "default_metrics_out()")]
+  #[clap(long)]
+  metrics_out: Option<String>,
+
+  /// The target language (java, swift, py, kt, go, rs, php, rb, tsx or ts), or the path to the config (`.toml`) of a
+  /// custom language, whose tree-sitter grammar is loaded at runtime
+  // The (hidden) default only applies along with a subcommand, which does not require it
   #[get = "pub"]
   #[builder(default = "default_piranha_language()")]
-  #[clap(short = 'l', value_parser = clap::builder::PossibleValuesParser::new([JAVA, SWIFT, PYTHON, KOTLIN, GO, TSX, TYPESCRIPT])
-  .map(|s| s.parse::<PiranhaLanguage>().unwrap()))]
+  #[clap(short = 'l', required = true, value_parser = parse_language, default_value = JAVA, hide_default_value = true)]
   language: PiranhaLanguage,
 
   /// User option that determines whether an empty file will be deleted
@@ -129,12 +230,60 @@ pub struct PiranhaArguments {
   #[clap(long, default_value_t = default_cleanup_comments())]
   cleanup_comments: bool,
 
-  /// Disables in-place rewriting of code
+  /// Deletes the comments matching this regex (e.g. `^// FLAG: new_checkout$`) that are immediately adjacent to
+  /// a deleted node (i.e. on the lines right above it, or on its last line), regardless of `cleanup_comments`
+  #[get = "pub"]
+  #[builder(default = "default_flag_comment_pattern()")]
+  #[clap(long)]
+  flag_comment_pattern: Option<String>,
+
+  /// Re-attaches the comment immediately preceding a deleted node (i.e. on the lines right above it) to its next
+  /// surviving sibling, rather than deleting it (or leaving it orphaned), regardless of `cleanup_comments`.
+  /// The comment is deleted along with the node when the node is the last one of its block
+  #[get = "pub"]
+  #[builder(default = "default_preserve_leading_comments()")]
+  #[clap(long, default_value_t = default_preserve_leading_comments())]
+  preserve_leading_comments: bool,
+
+  /// Disables in-place rewriting of code. The output summaries contain the unified diff of each file instead
+  /// (the CLI prints these diffs)
   #[get = "pub"]
   #[builder(default = "default_dry_run()")]
   #[clap(long, default_value_t = false)]
   dry_run: bool,
 
+  /// Path to the file where the (`git apply` compatible) patch of all the diffs is written (requires `dry_run`)
+  #[get = "pub"]
+  #[builder(default = "default_diff_output()")]
+  #[clap(long)]
+  diff_output: Option<String>,
+
+  /// Exits with 2 if any usage of the stale flags is left in the code once all the rules have been applied
+  /// (i.e. a string literal of the flag name, or a reference to a variable seeded from it), e.g. to block the deletion
+  /// of the flag in CI until these unresolved usages are accounted for.
+  /// Requires the `stale_flag_name` substitution (or a flags manifest)
+  #[get = "pub"]
+  #[builder(default = "default_fail_on_unresolved()")]
+  #[clap(long, default_value_t = default_fail_on_unresolved())]
+  fail_on_unresolved: bool,
+
+  /// Exits with 4 if the rewrites of any file were rolled back, as they introduced syntax errors (i.e. `ERROR` or
+  /// `MISSING` nodes that were not in the original code). Such files are left unmodified and reported (along with the
+  /// offending rule) in the output summary regardless, without failing the whole run
+  #[get = "pub"]
+  #[builder(default = "default_strict()")]
+  #[clap(long, default_value_t = default_strict())]
+  strict: bool,
+
+  /// Checks that the cleanup is a fixpoint, i.e. that running Piranha again would not rewrite the code (at the cost
+  /// of an extra pass of the global and package rules over the rewritten files). The files that would still be
+  /// rewritten are reported (along with the pending rewrite) in the output summary, and Piranha exits with 5.
+  /// Their rewrites are persisted regardless
+  #[get = "pub"]
+  #[builder(default = "default_verify_fixpoint()")]
+  #[clap(long, default_value_t = default_verify_fixpoint())]
+  verify_fixpoint: bool,
+
   // A graph that captures the flow amongst the rules
   #[get = "pub"]
   #[builder(default = "default_rule_graph()")]
@@ -146,6 +295,187 @@ pub struct PiranhaArguments {
   #[builder(default = "default_allow_dirty_ast()")]
   #[clap(long, default_value_t = default_allow_dirty_ast())]
   allow_dirty_ast: bool,
+
+  /// Simplifies boolean expressions even when it drops operands that may have side effects
+  /// (e.g. `foo() && false` -> `false`). By default such operands are preserved.
+  #[get = "pub"]
+  #[builder(default = "default_aggressive_simplification()")]
+  #[clap(long, default_value_t = default_aggressive_simplification())]
+  aggressive_simplification: bool,
+
+  /// Deletes the imports that are not referred anymore (i.e. whose package was referred before the rewrite), once all
+  /// the rules have been applied (Go only).
+  /// Blank (`_`) and dot (`.`) imports are never deleted.
+  #[get = "pub"]
+  #[builder(default = "default_cleanup_imports()")]
+  #[clap(long, default_value_t = default_cleanup_imports())]
+  cleanup_imports: bool,
+
+  /// Normalizes the layout of the rewritten Go files, once all the rules have been applied (Go only), i.e. re-indents
+  /// them with tabs, collapses the consecutive blank lines, deletes the trailing whitespace and sorts the import specs.
+  /// Unlike `gofmt`, the alignment within the lines (e.g. of the field types of a struct) is left as is, hence `gofmt`
+  /// itself is to be run as the `formatter_command`. The files that are not rewritten are left as is.
+  #[get = "pub"]
+  #[builder(default = "default_normalize_go_layout()")]
+  #[clap(long, default_value_t = default_normalize_go_layout(), action = clap::ArgAction::Set)]
+  normalize_go_layout: bool,
+
+  /// Normalizes the whitespace of the rewritten files, once all the rules have been applied (any language), i.e.
+  /// collapses the consecutive blank lines into a single one and deletes the trailing whitespace.
+  /// The multi-line tokens (e.g. the text blocks or the raw string literals) and the files that are not rewritten are left as is.
+  #[get = "pub"]
+  #[builder(default = "default_format_output()")]
+  #[clap(long, default_value_t = default_format_output())]
+  format_output: bool,
+
+  /// The formatter run on the rewritten files after the built-in normalization (requires `format_output`),
+  /// i.e. a command (and its arguments, separated by whitespace) reading the code from the standard input and
+  /// writing the formatted code to the standard output (e.g. `gofmt` or `black -q -`).
+  /// The code is left as is if the command fails.
+  #[get = "pub"]
+  #[builder(default = "default_formatter_command()")]
+  #[clap(long)]
+  formatter_command: Option<String>,
+
+  /// Deletes the tests exercising the stale flag (Go only), i.e. the test functions and the rows of the test tables
+  /// referring to it. Requires the `stale_flag_name` substitution.
+  #[get = "pub"]
+  #[builder(default = "default_cleanup_tests()")]
+  #[clap(long, default_value_t = default_cleanup_tests())]
+  cleanup_tests: bool,
+
+  /// Flattens the `else` branch of the `if` statements whose consequence ends with a terminating statement
+  /// (e.g. `return`), once a flag guard is collapsed (Go and Java), i.e. `if c { return x } else { rest }` -> `if c { return x } rest`
+  #[get = "pub"]
+  #[builder(default = "default_flatten_else()")]
+  #[clap(long, default_value_t = default_flatten_else())]
+  flatten_else: bool,
+
+  /// Reduces the `if` statements returning a boolean literal in both branches to a single `return`, once a flag guard
+  /// is collapsed (Go, Java and TypeScript), i.e. `if c { return true } else { return false }` -> `return c`, or
+  /// `if c { return false } return true` -> `return !c`
+  #[get = "pub"]
+  #[builder(default = "default_simplify_boolean_return()")]
+  #[clap(long, default_value_t = default_simplify_boolean_return())]
+  simplify_boolean_return: bool,
+
+  /// Deletes a statement identical to the previous one (e.g. the logging line of both branches of a collapsed `if`),
+  /// when it is deemed free of side effects, i.e. a call to one of the `pure_functions` (an alternation of functions,
+  /// given as a substitution) without nested call, or the assignment of a literal or a variable (Go and Java). This
+  /// relies on the `pure_functions` being actually free of side effects (or idempotent), hence it is disabled by default
+  #[get = "pub"]
+  #[builder(default = "default_dedupe_statements()")]
+  #[clap(long, default_value_t = default_dedupe_statements())]
+  dedupe_statements: bool,
+
+  /// Deletes the unexported Go functions and methods that are not referred in their package anymore, once all the
+  /// rules have been applied (Go only), until a fixpoint is reached (i.e. the functions only called by the deleted ones
+  /// are deleted too). The functions referred as values (e.g. stored in a variable or a map, or method values) and the
+  /// ones that were not referred before the cleanup are retained.
+  #[get = "pub"]
+  #[builder(default = "default_delete_unreachable()")]
+  #[clap(long, default_value_t = default_delete_unreachable())]
+  delete_unreachable: bool,
+
+  /// Deletes the Go struct types that are not selected anymore once the flag is cleaned up (e.g. `legacyFlow` once
+  /// `if enabled { flow = newFlow{} } else { flow = legacyFlow{} }` is), along with their methods and constructors,
+  /// when nothing else refers to them (Go only). The types referred from another package are retained and reported.
+  #[get = "pub"]
+  #[builder(default = "default_delete_unselected_implementations()")]
+  #[clap(long, default_value_t = default_delete_unselected_implementations())]
+  delete_unselected_implementations: bool,
+
+  /// Deletes the unexported Go functions and methods whose body is emptied by the cleanup (Go only), once these are not
+  /// referred anywhere in the code base. The functions that were empty before the cleanup (e.g. the no-op methods
+  /// implementing an interface) are retained.
+  #[get = "pub"]
+  #[builder(default = "default_delete_empty_functions()")]
+  #[clap(long, default_value_t = default_delete_empty_functions())]
+  delete_empty_functions: bool,
+
+  /// Replaces the flag calls with their default value argument (i.e. the argument following the flag name,
+  /// e.g. `false` in `exp.BoolValueCtx(ctx, "new_checkout", false)`), rather than with the `treated` substitution
+  #[get = "pub"]
+  #[builder(default = "default_use_default_as_treatment()")]
+  #[clap(long, default_value_t = default_use_default_as_treatment())]
+  use_default_as_treatment: bool,
+
+  /// The treatment of the stale flags. `cleanup` replaces the flag checks with the `treated` value and cleans up the code,
+  /// while `flip` only rewrites the flag API calls (Go only), such that their default value is the `treated` value
+  /// (e.g. `exp.BoolValue("x")` -> `exp.BoolValueWithDefault("x", true)`), without deleting any branch
+  #[get = "pub"]
+  #[builder(default = "default_treatment()")]
+  #[clap(long, default_value_t = default_treatment(), value_parser = clap::builder::PossibleValuesParser::new([CLEANUP_TREATMENT, FLIP_TREATMENT]))]
+  treatment: String,
+
+  /// The value the stale flags are treated as (i.e. overrides the `treated` substitution), e.g. `--treated-value true`
+  #[get = "pub"]
+  #[builder(default = "default_treated_value()")]
+  #[clap(long)]
+  treated_value: Option<bool>,
+
+  /// Disables the pre-filter, i.e. the files that do not contain any of the substitutions (e.g. the stale flag name)
+  /// of the current rules are parsed and analyzed too (for debugging)
+  #[get = "pub"]
+  #[builder(default = "default_no_prefilter()")]
+  #[clap(long, default_value_t = default_no_prefilter())]
+  no_prefilter: bool,
+
+  /// Disables the `.gitignore` (and `.piranhaignore`) files honored while walking the code base (including the nested
+  /// ones), i.e. the ignored files (e.g. under `node_modules` or the build output) are analyzed (and rewritten) too
+  #[get = "pub"]
+  #[builder(default = "default_no_ignore()")]
+  #[clap(long, default_value_t = default_no_ignore())]
+  no_ignore: bool,
+
+  /// Rewrites the Go files under `vendor` directories too. By default, only their usages are reported
+  #[get = "pub"]
+  #[builder(default = "default_include_vendor()")]
+  #[clap(long, default_value_t = default_include_vendor())]
+  include_vendor: bool,
+
+  /// Rewrites the generated Go files (i.e. with a `// Code generated ... DO NOT EDIT.` comment before the package clause) too.
+  /// By default, only their usages are reported
+  #[get = "pub"]
+  #[builder(default = "default_include_generated()")]
+  #[clap(long, default_value_t = default_include_generated())]
+  include_generated: bool,
+
+  /// The number of threads used to process the files of the code base (defaults to the number of available cores)
+  #[get = "pub"]
+  #[builder(default = "default_thread_count()")]
+  #[clap(long, visible_alias = "threads", default_value_t = default_thread_count())]
+  thread_count: usize,
+
+  /// The maximum number of times the cleanup rules (e.g. the boolean simplifications, or the deletion of the unused
+  /// variables) are re-applied to a rewritten function until none of them changes it anymore (Go only).
+  /// The rewrites of the file are rolled back when the function still changes after that many iterations.
+  #[get = "pub"]
+  #[builder(default = "default_max_fixpoint_iterations()")]
+  #[clap(long, default_value_t = default_max_fixpoint_iterations())]
+  max_fixpoint_iterations: usize,
+
+  /// The maximum time (in seconds) spent processing a single file, e.g. `--per-file-timeout 30`. A file exceeding it
+  /// (e.g. a generated file with enormous expressions) is left unmodified, and reported with a timeout in the output
+  /// summary, rather than failing the whole run. There is no timeout by default
+  #[get = "pub"]
+  #[builder(default = "default_per_file_timeout()")]
+  #[clap(long)]
+  per_file_timeout: Option<f64>,
+
+  /// The subcommand run instead of the cleanup (e.g. `graph`), which takes its own arguments
+  #[get = "pub"]
+  #[builder(default)]
+  #[clap(subcommand)]
+  subcommand: Option<PiranhaSubcommand>,
+}
+
+/// The subcommands of the CLI, i.e. the tooling run instead of the cleanup.
+#[derive(Clone, Debug, Subcommand)]
+pub enum PiranhaSubcommand {
+  /// Exports the rule graph (i.e. the built-in rules of the language merged with the user defined rules) as Graphviz
+  /// DOT, e.g. `piranha graph -l java -f ./configurations --output graph.dot`
+  Graph(GraphArguments),
 }
 
 impl Default for PiranhaArguments {
@@ -163,7 +493,11 @@ impl PiranhaArguments {
   /// * substitutions : Substitutions to instantiate the initial set of feature flag rules
   /// * path_to_configuration: Path to the directory that contains - `piranha_arguments.toml`, `rules.toml` and optionally `edges.toml`
   /// * rule_graph: the graph constructed via the RuleGraph DSL
+  /// * rules: Rules (i.e. `Rule` objects, or dicts with the keys of the `rules.toml` entries) added to the `rule_graph`
+  /// * edges: Edges (i.e. `OutgoingEdges` objects, or dicts with the keys of the `edges.toml` entries) added to the `rule_graph`
   /// * path_to_codebase: Path to the root of the code base that Piranha will update
+  /// * include: Paths to include (as glob patterns), e.g. `**/*.go` or `services/checkout/...`
+  /// * exclude: Paths to exclude (as glob patterns), e.g. `**/vendor/**`, which takes precedence over `include`
   /// * code_snippet: Input code snippet to transform
   /// * dry_run (bool) : Disables in-place rewriting of code
   /// * cleanup_comments (bool) : Enables deletion of associated comments
@@ -174,6 +508,32 @@ impl PiranhaArguments {
   /// * delete_file_if_empty (bool): User option that determines whether an empty file will be deleted
   /// * path_to_output_summary : Path to the file where the Piranha output summary should be persisted
   /// * allow_dirty_ast : Allows syntax errors in the input source code
+  /// * aggressive_simplification : Simplifies boolean expressions even if it drops operands with side effects
+  /// * cleanup_imports : Deletes the imports that are not referred anymore (Go only)
+  /// * normalize_go_layout : Normalizes the indentation, blank lines and imports of the rewritten files (Go only)
+  /// * format_output (bool): Collapses the consecutive blank lines and deletes the trailing whitespace of the rewritten files
+  /// * formatter_command (str): The command formatting the rewritten files (from stdin to stdout), after `format_output`
+  /// * cleanup_tests : Deletes the tests exercising the stale flag (Go only)
+  /// * flatten_else : Flattens the `else` branch of the `if` statements ending with a terminating statement (Go and Java)
+  /// * simplify_boolean_return (bool): Reduces the `if` statements returning `true` or `false` to a single `return` (Go, Java and TypeScript)
+  /// * dedupe_statements (bool): Deletes the statements identical to the previous one, when free of side effects (Go and Java)
+  /// * delete_unreachable (bool): Deletes the unexported functions that are not referred in their package anymore (Go only)
+  /// * delete_unselected_implementations (bool): Deletes the struct types not selected anymore, along with their methods and constructors (Go only)
+  /// * delete_empty_functions (bool): Deletes the unexported functions emptied by the cleanup, once these are not referred anymore (Go only)
+  /// * use_default_as_treatment (bool): Replaces the flag calls with their default value argument, rather than with `treated`
+  /// * treatment (str): `cleanup` (default) cleans up the code, while `flip` only flips the default value of the flag API calls (Go only)
+  /// * treated_value (bool): The value the stale flags are treated as (overrides the `treated` substitution)
+  /// * no_prefilter (bool): Disables the pre-filter of the files that do not contain any of the substitutions of the rules
+  /// * no_ignore (bool): Analyzes the files ignored by the `.gitignore` (and `.piranhaignore`) files too
+  /// * include_vendor (bool): Rewrites the Go files under `vendor` directories too
+  /// * include_generated (bool): Rewrites the generated Go files too
+  /// * thread_count (usize): The number of threads used to process the files (defaults to the number of available cores)
+  /// * max_fixpoint_iterations (usize): The maximum number of times the cleanup rules are re-applied to a rewritten function (Go only)
+  /// * per_file_timeout (float): The maximum time (in seconds) spent processing a single file, which is left unmodified when exceeded
+  /// * flags_file (str): Path to a JSON manifest of the stale flags to clean up in a single run (i.e. the substitutions of each flag)
+  /// * flag_states (dict): Whether each stale flag is treated as enabled or disabled (i.e. its `treated` substitution)
+  /// * flag_comment_pattern (str): Deletes the comments matching this regex, that are immediately adjacent to a deleted node
+  /// * preserve_leading_comments (bool): Re-attaches the comment preceding a deleted node to its next surviving sibling
   /// Returns PiranhaArgument.
   #[new]
   fn py_new(
@@ -184,7 +544,18 @@ impl PiranhaArguments {
     cleanup_comments_buffer: Option<i32>, number_of_ancestors_in_parent_scope: Option<u8>,
     delete_consecutive_new_lines: Option<bool>, global_tag_prefix: Option<String>,
     delete_file_if_empty: Option<bool>, path_to_output_summary: Option<String>,
-    allow_dirty_ast: Option<bool>,
+    allow_dirty_ast: Option<bool>, aggressive_simplification: Option<bool>,
+    cleanup_imports: Option<bool>, normalize_go_layout: Option<bool>, cleanup_tests: Option<bool>,
+    flatten_else: Option<bool>, no_prefilter: Option<bool>, include_vendor: Option<bool>,
+    include_generated: Option<bool>, thread_count: Option<usize>, flags_file: Option<String>,
+    flag_comment_pattern: Option<String>, format_output: Option<bool>,
+    formatter_command: Option<String>, use_default_as_treatment: Option<bool>,
+    delete_unreachable: Option<bool>, delete_empty_functions: Option<bool>,
+    flag_states: Option<&PyDict>, no_ignore: Option<bool>, max_fixpoint_iterations: Option<usize>,
+    preserve_leading_comments: Option<bool>, delete_unselected_implementations: Option<bool>,
+    simplify_boolean_return: Option<bool>, treatment: Option<String>, treated_value: Option<bool>,
+    dedupe_statements: Option<bool>, per_file_timeout: Option<f64>, rules: Option<&PyList>,
+    edges: Option<&PyList>,
   ) -> Self {
     let subs = substitutions.map_or(vec![], |s| {
       s.iter()
@@ -192,21 +563,44 @@ impl PiranhaArguments {
         .collect_vec()
     });
 
+    let states = flag_states.map_or(vec![], |s| {
+      s.iter()
+        .map(|(k, v)| {
+          let state = v
+            .extract::<bool>()
+            .unwrap_or_else(|_| panic!("The state of the flag `{k}` should be a boolean"));
+          (k.to_string(), state)
+        })
+        .collect_vec()
+    });
+
     let rg = rule_graph.unwrap_or_else(|| RuleGraphBuilder::default().build());
+    let rules = extract_rule_graph_entries::<Rule>(rules, "rules");
+    let edges = extract_rule_graph_entries::<OutgoingEdges>(edges, "edges");
+    let rg = if rules.is_empty() && edges.is_empty() {
+      rg
+    } else {
+      rg.merge(
+        &RuleGraphBuilder::default()
+          .rules(rules)
+          .edges(edges)
+          .build(),
+      )
+    };
     PiranhaArgumentsBuilder::default()
       .path_to_codebase(path_to_codebase.unwrap_or_else(default_path_to_codebase))
       .include(
         include
           .unwrap_or_default()
           .iter()
-          .map(|x| Pattern::new(x).unwrap())
+          .map(|x| parse_glob_pattern(x).unwrap())
           .collect_vec(),
       )
       .exclude(
         exclude
           .unwrap_or_default()
           .iter()
-          .map(|x| Pattern::new(x).unwrap())
+          .map(|x| parse_glob_pattern(x).unwrap())
           .collect_vec(),
       )
       .path_to_configurations(path_to_configurations.unwrap_or_else(default_path_to_configurations))
@@ -230,23 +624,109 @@ impl PiranhaArguments {
       .delete_file_if_empty(delete_file_if_empty.unwrap_or_else(default_delete_file_if_empty))
       .path_to_output_summary(path_to_output_summary)
       .allow_dirty_ast(allow_dirty_ast.unwrap_or_else(default_allow_dirty_ast))
+      .aggressive_simplification(
+        aggressive_simplification.unwrap_or_else(default_aggressive_simplification),
+      )
+      .cleanup_imports(cleanup_imports.unwrap_or_else(default_cleanup_imports))
+      .normalize_go_layout(normalize_go_layout.unwrap_or_else(default_normalize_go_layout))
+      .cleanup_tests(cleanup_tests.unwrap_or_else(default_cleanup_tests))
+      .flatten_else(flatten_else.unwrap_or_else(default_flatten_else))
+      .simplify_boolean_return(
+        simplify_boolean_return.unwrap_or_else(default_simplify_boolean_return),
+      )
+      .dedupe_statements(dedupe_statements.unwrap_or_else(default_dedupe_statements))
+      .no_prefilter(no_prefilter.unwrap_or_else(default_no_prefilter))
+      .no_ignore(no_ignore.unwrap_or_else(default_no_ignore))
+      .include_vendor(include_vendor.unwrap_or_else(default_include_vendor))
+      .include_generated(include_generated.unwrap_or_else(default_include_generated))
+      .thread_count(thread_count.unwrap_or_else(default_thread_count))
+      .max_fixpoint_iterations(
+        max_fixpoint_iterations.unwrap_or_else(default_max_fixpoint_iterations),
+      )
+      .per_file_timeout(per_file_timeout)
+      .flags_file(flags_file)
+      .flag_states(states)
+      .flag_comment_pattern(flag_comment_pattern)
+      .preserve_leading_comments(
+        preserve_leading_comments.unwrap_or_else(default_preserve_leading_comments),
+      )
+      .format_output(format_output.unwrap_or_else(default_format_output))
+      .formatter_command(formatter_command)
+      .use_default_as_treatment(
+        use_default_as_treatment.unwrap_or_else(default_use_default_as_treatment),
+      )
+      .treatment(treatment.unwrap_or_else(default_treatment))
+      .treated_value(treated_value)
+      .delete_unreachable(delete_unreachable.unwrap_or_else(default_delete_unreachable))
+      .delete_unselected_implementations(
+        delete_unselected_implementations.unwrap_or_else(default_delete_unselected_implementations),
+      )
+      .delete_empty_functions(delete_empty_functions.unwrap_or_else(default_delete_empty_functions))
       .build()
   }
 }
 
+/// Extracts the `rules` (or `edges`) passed through the Python bindings, each of which is either an object (i.e. a
+/// `Rule` or `OutgoingEdges`) or a dict with the keys of the entries of `rules.toml` (or `edges.toml`), e.g.
+/// `{"name": "delete_flag", "query": "...", "replace_node": "call", "replace": "", "groups": ["cleanup"]}`.
+fn extract_rule_graph_entries<'a, T>(entries: Option<&'a PyList>, kind: &str) -> Vec<T>
+where
+  T: FromPyObject<'a> + serde::de::DeserializeOwned,
+{
+  entries.map_or(vec![], |entries| {
+    entries
+      .iter()
+      .map(|entry| {
+        if let Ok(value) = entry.extract::<T>() {
+          return value;
+        }
+        // The dicts are deserialized like the entries of the TOML configurations
+        let json = entry
+          .downcast::<PyDict>()
+          .ok()
+          .and_then(|dict| {
+            let dumps = dict.py().import("json").ok()?.getattr("dumps").ok()?;
+            dumps.call1((dict,)).ok()?.extract::<String>().ok()
+          })
+          .unwrap_or_else(|| {
+            panic!("Each of the `{kind}` should be an object or a dict (with lists rather than sets), got `{entry}`")
+          });
+        serde_json::from_str(&json)
+          .unwrap_or_else(|e| panic!("Could not read the entry `{json}` of the `{kind}` : {e}"))
+      })
+      .collect_vec()
+  })
+}
+
 impl PiranhaArguments {
   pub fn get_language(&self) -> String {
     self.language.extension().to_string()
   }
 
+  /// Parses the command line arguments, and builds (and validates) the Piranha arguments from them. Along with a
+  /// subcommand, these are returned as parsed instead (the subcommand takes its own arguments).
   pub fn from_cli() -> Self {
     let p = PiranhaArguments::parse();
+    if p.subcommand().is_some() {
+      return p;
+    }
+    Self::from_parsed_cli(p)
+  }
+
+  /// Builds (and validates) the Piranha arguments from the parsed command line arguments.
+  fn from_parsed_cli(p: PiranhaArguments) -> Self {
     PiranhaArgumentsBuilder::default()
       .path_to_codebase(p.path_to_codebase().to_string())
+      .include(p.include().clone())
+      .exclude(p.exclude().clone())
       .substitutions(p.substitutions.clone())
       .language(p.language().clone())
       .path_to_configurations(p.path_to_configurations().to_string())
       .path_to_output_summary(p.path_to_output_summary().clone())
+      .output_format(p.output_format().to_string())
+      .report_format(p.report_format().clone())
+      .path_to_report(p.path_to_report().clone())
+      .metrics_out(p.metrics_out().clone())
       .delete_file_if_empty(*p.delete_file_if_empty())
       .delete_consecutive_new_lines(*p.delete_consecutive_new_lines())
       .global_tag_prefix(p.global_tag_prefix().to_string())
@@ -254,12 +734,136 @@ impl PiranhaArguments {
       .cleanup_comments_buffer(*p.cleanup_comments_buffer())
       .cleanup_comments(*p.cleanup_comments())
       .dry_run(*p.dry_run())
+      .diff_output(p.diff_output().clone())
+      .fail_on_unresolved(*p.fail_on_unresolved())
+      .strict(*p.strict())
+      .verify_fixpoint(*p.verify_fixpoint())
+      .aggressive_simplification(*p.aggressive_simplification())
+      .cleanup_imports(*p.cleanup_imports())
+      .normalize_go_layout(*p.normalize_go_layout())
+      .cleanup_tests(*p.cleanup_tests())
+      .flatten_else(*p.flatten_else())
+      .simplify_boolean_return(*p.simplify_boolean_return())
+      .dedupe_statements(*p.dedupe_statements())
+      .delete_unreachable(*p.delete_unreachable())
+      .delete_unselected_implementations(*p.delete_unselected_implementations())
+      .delete_empty_functions(*p.delete_empty_functions())
+      .use_default_as_treatment(*p.use_default_as_treatment())
+      .treatment(p.treatment().to_string())
+      .treated_value(*p.treated_value())
+      .no_prefilter(*p.no_prefilter())
+      .no_ignore(*p.no_ignore())
+      .include_vendor(*p.include_vendor())
+      .include_generated(*p.include_generated())
+      .thread_count(*p.thread_count())
+      .max_fixpoint_iterations(*p.max_fixpoint_iterations())
+      .per_file_timeout(*p.per_file_timeout())
+      .flags_file(p.flags_file().clone())
+      .flag_states(p.flag_states().clone())
+      .flag_comment_pattern(p.flag_comment_pattern().clone())
+      .preserve_leading_comments(*p.preserve_leading_comments())
+      .format_output(*p.format_output())
+      .formatter_command(p.formatter_command().clone())
       .build()
   }
 
   pub(crate) fn input_substitutions(&self) -> HashMap<String, String> {
     self.substitutions.iter().cloned().collect()
   }
+
+  /// Returns the substitutions of each flag of the flags manifest (merged with the input substitutions),
+  /// along with its name (i.e. its `stale_flag_name`).
+  /// Without flags manifest, it returns the input substitutions (for no flag in particular).
+  pub(crate) fn flag_substitutions(&self) -> Vec<(Option<String>, HashMap<String, String>)> {
+    if self.flags.is_empty() {
+      return vec![(None, self.input_substitutions())];
+    }
+    self
+      .flags
+      .iter()
+      .map(|flag| {
+        let mut substitutions = self.input_substitutions();
+        substitutions.extend(flag.clone());
+        (flag.get(STALE_FLAG_NAME).cloned(), substitutions)
+      })
+      .collect()
+  }
+
+  /// Checks if the file at `path` is in the scope of `include` and `exclude` (see `is_included`).
+  pub(crate) fn is_included(&self, path: &Path) -> bool {
+    is_included(
+      path,
+      Path::new(self.path_to_codebase()),
+      self.include(),
+      self.exclude(),
+    )
+  }
+
+  /// Returns the substitutions of the given flag of the flags manifest.
+  pub(crate) fn get_flag_substitutions(&self, flag: &str) -> HashMap<String, String> {
+    self
+      .flags
+      .iter()
+      .find(|f| f.get(STALE_FLAG_NAME).map(String::as_str) == Some(flag))
+      .cloned()
+      .unwrap_or_default()
+  }
+
+  /// Reads the substitutions of each flag of the flags manifest (if any), where the `treated` substitution of
+  /// the flags of the `flag_states` is set from their state (the flags missing from the manifest are appended).
+  fn read_flags(&self) -> Result<Vec<HashMap<String, String>>, String> {
+    let mut flags = match self.flags_file() {
+      Some(flags_file) => self.read_flags_file(flags_file)?,
+      None => vec![],
+    };
+    for (name, state) in self.flag_states() {
+      match flags
+        .iter_mut()
+        .find(|f| f.get(STALE_FLAG_NAME) == Some(name))
+      {
+        Some(flag) => {
+          flag.insert(TREATED.to_string(), state.to_string());
+        }
+        None => flags.push(HashMap::from([
+          (STALE_FLAG_NAME.to_string(), name.to_string()),
+          (TREATED.to_string(), state.to_string()),
+        ])),
+      }
+    }
+    Ok(flags)
+  }
+
+  /// Reads the substitutions of each flag of the flags manifest.
+  /// Each flag should specify its `stale_flag_name`, which should be unique.
+  fn read_flags_file(&self, flags_file: &str) -> Result<Vec<HashMap<String, String>>, String> {
+    let flags: Vec<HashMap<String, String>> = read_file(&PathBuf::from(flags_file))
+      .and_then(|content| serde_json::from_str(&content).map_err(|e| e.to_string()))
+      .map_err(|e| {
+        format!("Invalid Piranha arguments. Could not read the flags manifest {flags_file} : {e}")
+      })?;
+    if flags.is_empty() {
+      return Err(format!(
+        "Invalid Piranha arguments. The flags manifest {flags_file} does not list any flag."
+      ));
+    }
+    let mut names = HashSet::new();
+    for flag in &flags {
+      match flag.get(STALE_FLAG_NAME) {
+        Some(name) if names.insert(name) => {}
+        Some(name) => {
+          return Err(format!(
+            "Invalid Piranha arguments. The flag `{name}` is listed twice in the flags manifest {flags_file}."
+          ))
+        }
+        None => {
+          return Err(format!(
+            "Invalid Piranha arguments. Please specify the `{STALE_FLAG_NAME}` of each flag in the flags manifest {flags_file}."
+          ))
+        }
+      }
+    }
+    Ok(flags)
+  }
 }
 
 impl PiranhaArgumentsBuilder {
@@ -274,6 +878,21 @@ impl PiranhaArgumentsBuilder {
 
     let mut _arg = self.create().unwrap();
 
+    // The `treated_value` overrides the `treated` substitution
+    if let Some(treated_value) = *_arg.treated_value() {
+      let mut substitutions = _arg.substitutions.clone();
+      substitutions.retain(|(key, _)| key != TREATED);
+      substitutions.push((TREATED.to_string(), treated_value.to_string()));
+      _arg = PiranhaArguments {
+        substitutions,
+        .._arg
+      };
+    }
+
+    // The flags manifest was validated above
+    let flags = _arg.read_flags().unwrap();
+    _arg = PiranhaArguments { flags, .._arg };
+
     let rule_graph = get_rule_graph(&_arg);
     _arg = PiranhaArguments { rule_graph, .._arg };
     #[rustfmt::skip]
@@ -298,36 +917,260 @@ impl PiranhaArgumentsBuilder {
       );
     }
 
+    if let Some((first_line, last_line)) = _arg.line_range() {
+      if _arg.code_snippet().is_empty() {
+        return Err(
+          "Invalid Piranha arguments. Please specify the `code_snippet` when specifying the `line_range`."
+            .to_string(),
+        );
+      }
+      if *first_line == 0 || first_line > last_line {
+        return Err(format!(
+          "Invalid Piranha arguments. The `line_range` ({first_line}, {last_line}) should start at line 1 or later, and end at its first line or later."
+        ));
+      }
+    }
+
+    if _arg.diff_output().is_some() && !*_arg.dry_run() {
+      return Err(
+        "Invalid Piranha arguments. Please enable `dry_run` when specifying the `diff_output`."
+          .to_string(),
+      );
+    }
+
+    if _arg.report_format().is_some() != _arg.path_to_report().is_some() {
+      return Err(
+        "Invalid Piranha arguments. Please specify both the `report_format` and the `path_to_report` (or neither)."
+          .to_string(),
+      );
+    }
+
+    if *_arg.thread_count() == 0 {
+      return Err(
+        "Invalid Piranha arguments. The `thread_count` should be at least 1.".to_string(),
+      );
+    }
+
+    if *_arg.max_fixpoint_iterations() == 0 {
+      return Err(
+        "Invalid Piranha arguments. The `max_fixpoint_iterations` should be at least 1."
+          .to_string(),
+      );
+    }
+
+    if _arg
+      .per_file_timeout()
+      .map_or(false, |t| t.is_nan() || t <= 0.0)
+    {
+      return Err(
+        "Invalid Piranha arguments. The `per_file_timeout` should be a positive number of seconds."
+          .to_string(),
+      );
+    }
+
+    if let Some(Err(e)) = _arg.flag_comment_pattern().as_ref().map(|p| Regex::new(p)) {
+      return Err(format!(
+        "Invalid Piranha arguments. The `flag_comment_pattern` is not a valid regex : {e}"
+      ));
+    }
+
+    if _arg.formatter_command().is_some() && !*_arg.format_output() {
+      return Err(
+        "Invalid Piranha arguments. Please enable `format_output` when specifying the `formatter_command`."
+          .to_string(),
+      );
+    }
+
+    // Each flag of the flags manifest specifies its `stale_flag_name`
+    let flags = _arg.read_flags()?;
+    if *_arg.cleanup_tests()
+      && flags.is_empty()
+      && !_arg.input_substitutions().contains_key(STALE_FLAG_NAME)
+    {
+      return Err(format!(
+        "Invalid Piranha arguments. Please specify the `{STALE_FLAG_NAME}` substitution when `cleanup_tests` is enabled."
+      ));
+    }
+
+    if _arg.treatment() == FLIP_TREATMENT
+      && _arg.treated_value().is_none()
+      && !_arg.input_substitutions().contains_key(TREATED)
+      && (flags.is_empty() || flags.iter().any(|flag| !flag.contains_key(TREATED)))
+    {
+      return Err(format!(
+        "Invalid Piranha arguments. Please specify the `treated_value` (or the `{TREATED}` substitution) with the `{FLIP_TREATMENT}` treatment."
+      ));
+    }
+
+    if *_arg.fail_on_unresolved()
+      && flags.is_empty()
+      && !_arg.input_substitutions().contains_key(STALE_FLAG_NAME)
+    {
+      return Err(format!(
+        "Invalid Piranha arguments. Please specify the `{STALE_FLAG_NAME}` substitution when `fail_on_unresolved` is enabled."
+      ));
+    }
+
     Ok(true)
   }
 }
 
+/// The arguments of the `graph` subcommand, which exports the rule graph (i.e. the built-in rules of the language merged
+/// with the user defined rules) as Graphviz DOT.
+/// Usage : piranha graph -l java -f ./configurations --output graph.dot
+#[derive(Clone, Getters, Debug, Args)]
+pub struct GraphArguments {
+  /// Path to the output DOT file (the graph is printed to stdout if not specified)
+  #[get = "pub"]
+  #[clap(short = 'o', long)]
+  output: Option<String>,
+
+  /// These substitutions instantiate the built-in seed rules (the ones whose holes are not all substituted are dropped).
+  /// Usage : -s stale_flag_name=SOME_FLAG -s namespace=SOME_NS1
+  #[clap(short = 's', value_parser = parse_key_val)]
+  substitutions: Vec<(String, String)>,
+
+  /// Directory containing the configuration files -  `rules.toml` and  `edges.toml` (optional)
+  #[clap(short = 'f', long, default_value_t = default_path_to_configurations())]
+  path_to_configurations: String,
+
+  /// The target language
+  #[clap(short = 'l', value_parser = clap::builder::PossibleValuesParser::new([JAVA, SWIFT, PYTHON, KOTLIN, GO, RUST, PHP, RUBY, TSX, TYPESCRIPT])
+  .map(|s| s.parse::<PiranhaLanguage>().unwrap()))]
+  language: PiranhaLanguage,
+
+  /// Includes the built-in rules that may drop operands with side effects
+  #[clap(long, default_value_t = default_aggressive_simplification())]
+  aggressive_simplification: bool,
+
+  /// Includes the built-in rules that delete the tests exercising the stale flag (Go only)
+  #[clap(long, default_value_t = default_cleanup_tests())]
+  cleanup_tests: bool,
+
+  /// Includes the built-in rules that flatten the `else` branches (Go and Java)
+  #[clap(long, default_value_t = default_flatten_else())]
+  flatten_else: bool,
+
+  /// Includes the built-in rules that reduce the `if` statements returning a boolean literal to a single `return`
+  #[clap(long, default_value_t = default_simplify_boolean_return())]
+  simplify_boolean_return: bool,
+
+  /// Includes the built-in rules that delete the duplicate statements free of side effects (Go and Java)
+  #[clap(long, default_value_t = default_dedupe_statements())]
+  dedupe_statements: bool,
+
+  /// Includes the built-in rules that replace the flag calls with their default value (rather than with `treated`)
+  #[clap(long, default_value_t = default_use_default_as_treatment())]
+  use_default_as_treatment: bool,
+}
+
+impl GraphArguments {
+  /// Loads the rule graph (like Piranha does) and serializes it to DOT.
+  pub fn get_rule_graph_dot(&self) -> String {
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .substitutions(self.substitutions.clone())
+      .path_to_configurations(self.path_to_configurations.to_string())
+      .language(self.language.clone())
+      .aggressive_simplification(self.aggressive_simplification)
+      .cleanup_tests(self.cleanup_tests)
+      .flatten_else(self.flatten_else)
+      .simplify_boolean_return(self.simplify_boolean_return)
+      .dedupe_statements(self.dedupe_statements)
+      .use_default_as_treatment(self.use_default_as_treatment)
+      .create()
+      .unwrap();
+    get_rule_graph(&piranha_arguments).to_dot()
+  }
+}
+
 /// Gets rule graph for PiranhaArguments
 ///   * Loads the language specific graphs
+///   * Drops the built-in rules that may remove side effects (unless `aggressive_simplification` is set)
+///   * Drops the built-in rules that delete tests (unless `cleanup_tests` is set)
+///   * Drops the built-in rules that flatten the `else` branches (unless `flatten_else` is set)
+///   * Drops the built-in rules that simplify the boolean returns (unless `simplify_boolean_return` is set)
+///   * Drops the built-in rules that delete the duplicate statements (unless `dedupe_statements` is set), and the ones
+///     deleting the duplicate calls (unless the `pure_functions` are substituted, for any flag)
+///   * Drops the built-in rules that delete the emptied functions (unless `delete_empty_functions` is set)
+///   * Drops the built-in rules that replace the flag calls with their default value (unless `use_default_as_treatment`
+///     is set), or with `treated` (otherwise)
+///   * Drops the built-in rules that flip the default value of the flag API calls (unless the `treatment` is `flip`),
+///     or all the other rules (otherwise)
+///   * Drops the built-in seed rules (i.e. rule templates) whose holes are not all substituted (for any flag)
 ///   * Merges these with the user defined graphs
+///   * Validates the structure of the merged graph (e.g. edges referring to undefined rules)
 /// Returns this merged graph
 fn get_rule_graph(_arg: &PiranhaArguments) -> RuleGraph {
   // Get the built-in rule -graph for the language
   let piranha_language = _arg.language();
+  let flag_substitutions = _arg.flag_substitutions();
+
+  let rules = piranha_language
+    .rules()
+    .clone()
+    .unwrap_or_default()
+    .rules
+    .into_iter()
+    .filter(|r| *_arg.aggressive_simplification() || !r.groups().contains(SIDE_EFFECT_UNSAFE))
+    .filter(|r| *_arg.cleanup_tests() || !r.groups().contains(TEST_CLEANUP))
+    .filter(|r| *_arg.flatten_else() || !r.groups().contains(FLATTEN_ELSE))
+    .filter(|r| *_arg.simplify_boolean_return() || !r.groups().contains(SIMPLIFY_BOOLEAN_RETURN))
+    .filter(|r| *_arg.dedupe_statements() || !r.groups().contains(DEDUPE_STATEMENTS))
+    .filter(|r| {
+      !r.holes().contains(PURE_FUNCTIONS)
+        || flag_substitutions
+          .iter()
+          .any(|(_, substitutions)| substitutions.contains_key(PURE_FUNCTIONS))
+    })
+    .filter(|r| *_arg.delete_empty_functions() || !r.groups().contains(DELETE_EMPTY_FUNCTIONS))
+    .filter(|r| *_arg.use_default_as_treatment() || !r.groups().contains(DEFAULT_AS_TREATMENT))
+    .filter(|r| !*_arg.use_default_as_treatment() || !r.groups().contains(TREATED_AS_TREATMENT))
+    .filter(|r| (_arg.treatment() == FLIP_TREATMENT) == r.groups().contains(FLIP_DEFAULT))
+    .filter(|r| {
+      !*r.is_seed_rule()
+        || flag_substitutions
+          .iter()
+          .any(|(_, substitutions)| r.holes().iter().all(|h| substitutions.contains_key(h)))
+    })
+    .collect_vec();
 
   let built_in_rules = RuleGraphBuilder::default()
     .edges(piranha_language.edges().clone().unwrap_or_default().edges)
-    .rules(piranha_language.rules().clone().unwrap_or_default().rules)
+    .rules(rules)
     .build();
 
+  // With the `flip` treatment, only the flag API calls are rewritten, hence the user defined rules (describing the
+  // cleanup) are ignored
+  if _arg.treatment() == FLIP_TREATMENT {
+    return built_in_rules;
+  }
+
   // TODO: Move to `PiranhaArgumentBuilder`'s _validate - https://github.com/uber/piranha/issues/387
   // Get the user-defined rule graph (if any) via the Python/Rust API
   let mut user_defined_rules: RuleGraph = _arg.rule_graph().clone();
-  // In the scenario when rules/edges are passed as toml files
+  // In the scenario when rules/edges are passed as toml files (along with the ones passed via the Python API, if any)
   if !_arg.path_to_configurations().is_empty() {
-    user_defined_rules = read_user_config_files(_arg.path_to_configurations())
+    user_defined_rules =
+      read_user_config_files(_arg.path_to_configurations()).merge(&user_defined_rules)
   }
 
   if user_defined_rules.graph().is_empty() {
     warn!("NO RULES PROVIDED. Please provide rules via the RuleGraph API or as toml files");
   }
 
-  built_in_rules.merge(&user_defined_rules)
+  let rule_graph = built_in_rules.merge(&user_defined_rules);
+  // The user defined edges may refer to the built-in rules (or groups) that were dropped above
+  let built_in_names: HashSet<String> = piranha_language
+    .rules()
+    .iter()
+    .flat_map(|rules| rules.rules.iter())
+    .flat_map(|r| once(r.name()).chain(r.groups()))
+    .cloned()
+    .collect();
+  if let Err(err) = rule_graph.validate_structure(&user_defined_rules, &built_in_names) {
+    panic!("{}", err.as_str().red());
+  }
+  rule_graph
 }
 
 #[cfg(test)]
@@ -346,14 +1189,135 @@ impl SourceCodeUnit {
   }
 
   /// Writes the current contents of `code` to the file system and deletes a file if empty.
+  /// The skipped (i.e. vendored or generated) files are never written.
   pub(crate) fn persist(&self) {
-    if *self.piranha_arguments().dry_run() {
+    if *self.piranha_arguments().dry_run() || self.skip_reason().is_some() {
       return;
     }
+    let start = Instant::now();
     if self.code().as_str().is_empty() && *self.piranha_arguments().delete_file_if_empty() {
       std::fs::remove_file(self.path()).expect("Unable to Delete file");
+    } else {
+      std::fs::write(self.path(), self.code()).expect("Unable to Write file");
+    }
+    self.stats_mut().record_write(start.elapsed());
+  }
+
+  /// Returns the reason why this source code unit is not rewritten, i.e. it is out of the scope of `include` and `exclude`
+  /// (and pulled in by a package rule), or (for Go) it is vendored (unless `include_vendor`), generated (unless
+  /// `include_generated`) or kept with `//piranha:keep-file`, or its processing exceeded the `per_file_timeout`, or its
+  /// rewrites introduced syntax errors (or did not reach a fixpoint). Only the usages of the rules are reported for such files (except the timed out
+  /// ones and the rolled back ones, which are left as is).
+  pub(crate) fn skip_reason(&self) -> Option<&'static str> {
+    if *self.timed_out() {
+      return Some(TIMEOUT_SKIP_REASON);
+    }
+    if self.invalid_rewrite().is_some() {
+      return Some(INVALID_REWRITE_SKIP_REASON);
+    }
+    if *self.fixpoint_exceeded() {
+      return Some(FIXPOINT_EXCEEDED_SKIP_REASON);
+    }
+    let piranha_arguments = self.piranha_arguments();
+    if piranha_arguments.code_snippet().is_empty() && !piranha_arguments.is_included(self.path()) {
+      return Some(OUT_OF_SCOPE_SKIP_REASON);
+    }
+    if *piranha_arguments.language().supported_language() != SupportedLanguage::Go {
+      return None;
+    }
+    if !*piranha_arguments.include_vendor() && self.is_vendored() {
+      return Some(VENDORED_CODE_SKIP_REASON);
+    }
+    if !*piranha_arguments.include_generated() && self.is_generated() {
+      return Some(GENERATED_CODE_SKIP_REASON);
+    }
+    if self.is_kept() {
+      return Some(KEPT_FILE_SKIP_REASON);
+    }
+    None
+  }
+
+  /// Checks if the file is under a `vendor` directory of the code base.
+  fn is_vendored(&self) -> bool {
+    let path_to_codebase = Path::new(self.piranha_arguments().path_to_codebase());
+    self
+      .path()
+      .strip_prefix(path_to_codebase)
+      .unwrap_or(self.path())
+      .parent()
+      .map_or(false, |dir| {
+        dir.components().any(|c| c.as_os_str() == "vendor")
+      })
+  }
+
+  /// Checks if the file is generated, i.e. a line before the package clause matches `^// Code generated .* DO NOT EDIT\.$`
+  /// (the convention of `go generate`). For instance, the `.pb.go` files generated by `protoc` have such a comment.
+  fn is_generated(&self) -> bool {
+    let regex = Regex::new(r"^// Code generated .* DO NOT EDIT\.$").unwrap();
+    self
+      .original_content()
+      .lines()
+      .take_while(|line| !line.starts_with("package "))
+      .any(|line| regex.is_match(line))
+  }
+
+  /// Checks if the file is kept, i.e. a line before the package clause is the `//piranha:keep-file` directive.
+  fn is_kept(&self) -> bool {
+    self
+      .original_content()
+      .lines()
+      .take_while(|line| !line.starts_with("package "))
+      .any(|line| is_directive(line.trim(), KEEP_FILE_DIRECTIVE))
+  }
+
+  /// Deletes the parentheses left redundant by the simplification of the enclosed expression (see
+  /// `get_redundant_parentheses`), w.r.t. the precedence of the operators of the language (if known).
+  pub(crate) fn perform_parentheses_cleanup(&mut self, parser: &mut tree_sitter::Parser) {
+    let precedences = match self.piranha_arguments().language().precedences() {
+      Some(precedences) => precedences.clone(),
+      None => return,
+    };
+    while let Some((range, replacement)) = get_redundant_parentheses(
+      self.root_node(),
+      self.code(),
+      self.original_content(),
+      &precedences,
+    ) {
+      let p_match = Match::new(
+        self.code()[range.start_byte..range.end_byte].to_string(),
+        range,
+        HashMap::new(),
+      );
+      let edit = Edit::new(
+        p_match,
+        replacement,
+        "parentheses_cleanup".to_string(),
+        HashMap::new(),
+        self.code(),
+      );
+      self.record_rewrite(&edit);
+      self.apply_edit(&edit, parser);
+    }
+  }
+
+  /// Normalizes the whitespace of the rewritten code (see `normalize_whitespace`), and runs the `formatter_command`
+  /// on it (if any), once all the rules have been applied. The code is left as is if the formatter fails.
+  pub(crate) fn perform_output_formatting(&mut self, parser: &mut tree_sitter::Parser) {
+    if !*self.piranha_arguments().format_output()
+      || self.code().is_empty()
+      || self.code() == self.original_content()
+    {
       return;
     }
-    std::fs::write(self.path(), self.code()).expect("Unable to Write file");
+    let mut formatted_code = normalize_whitespace(self.code(), self.root_node());
+    if let Some(command) = self.piranha_arguments().formatter_command() {
+      match run_formatter_command(command, &formatted_code) {
+        Ok(code) => formatted_code = code,
+        Err(e) => warn!("Could not format {:?} : {}", self.path(), e),
+      }
+    }
+    if formatted_code != *self.code() {
+      self._replace_file_contents_and_re_parse(&formatted_code, parser, false);
+    }
   }
 }
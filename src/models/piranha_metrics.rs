@@ -0,0 +1,361 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Quantitative metrics of the cleanup (e.g. the lines removed and the functions deleted), to track its impact over
+//! time. The metrics are computed from the actual changes of the files, i.e. from the line diff between their original
+//! and final content (rather than from the edits, which overlap), and from the Go declarations and `if` statements
+//! starting on the changed lines.
+//!
+//! Each changed hunk of a file is attributed to the flag of the rewrites overlapping it (the one with the most
+//! rewrites, if the rewrites of several flags overlap it), or else to the flag with the most rewrites in the file
+//! (e.g. for the blank lines deleted by the post-processing). Hence the metrics of the flags add up to the total.
+
+use std::{
+  collections::{BTreeMap, HashSet},
+  fmt,
+  ops::Range,
+  path::Path,
+};
+
+use getset::Getters;
+use itertools::Itertools;
+use serde_derive::Serialize;
+use similar::{DiffTag, TextDiff};
+use tree_sitter::{Node, Parser};
+use tree_sitter_traversal::{traverse, Order};
+
+use super::{
+  default_configs::STALE_FLAG_NAME,
+  edit::Edit,
+  language::SupportedLanguage,
+  piranha_arguments::PiranhaArguments,
+  piranha_output::{get_relative_path, PiranhaOutputSummary},
+};
+
+/// The kinds of the Go nodes counted by the metrics, when they start on a removed line (but not on an added one)
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum CountedKind {
+  IfStatement,
+  Function,
+  Type,
+  Import,
+}
+
+const COUNTED_KINDS: [CountedKind; 4] = [
+  CountedKind::IfStatement,
+  CountedKind::Function,
+  CountedKind::Type,
+  CountedKind::Import,
+];
+
+/// The metrics of the changes of a file, of a flag, or of the whole run
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Getters)]
+pub struct CleanupMetrics {
+  /// The number of lines added
+  #[get = "pub"]
+  lines_added: usize,
+  /// The number of lines removed
+  #[get = "pub"]
+  lines_removed: usize,
+  /// The net number of lines added (negative when more lines are removed than added)
+  #[get = "pub"]
+  net_lines: i64,
+  /// The number of `if` statements collapsed, i.e. replaced with one of their branches or deleted (except the ones
+  /// within the deleted functions) (Go only)
+  #[get = "pub"]
+  if_branches_collapsed: usize,
+  /// The number of functions and methods deleted (Go only)
+  #[get = "pub"]
+  functions_deleted: usize,
+  /// The number of types deleted (Go only)
+  #[get = "pub"]
+  types_deleted: usize,
+  /// The number of imports removed (Go only)
+  #[get = "pub"]
+  imports_removed: usize,
+  /// The number of files deleted (i.e. `delete_file_if_empty`)
+  #[get = "pub"]
+  files_deleted: usize,
+  /// The number of rewrites of each rule
+  #[get = "pub"]
+  rule_counts: BTreeMap<String, usize>,
+}
+
+impl CleanupMetrics {
+  /// Adds the metrics of `other` (e.g. of another file) to these
+  fn merge(&mut self, other: &CleanupMetrics) {
+    self.lines_added += other.lines_added;
+    self.lines_removed += other.lines_removed;
+    self.net_lines += other.net_lines;
+    self.if_branches_collapsed += other.if_branches_collapsed;
+    self.functions_deleted += other.functions_deleted;
+    self.types_deleted += other.types_deleted;
+    self.imports_removed += other.imports_removed;
+    self.files_deleted += other.files_deleted;
+    for (rule_name, count) in &other.rule_counts {
+      *self.rule_counts.entry(rule_name.to_string()).or_default() += count;
+    }
+  }
+
+  /// Records the number of nodes of `kind` removed (which may be negative, when more nodes are added)
+  fn record_removed(&mut self, kind: CountedKind, count: i64) {
+    let count = usize::try_from(count).unwrap_or_default();
+    match kind {
+      CountedKind::IfStatement => self.if_branches_collapsed += count,
+      CountedKind::Function => self.functions_deleted += count,
+      CountedKind::Type => self.types_deleted += count,
+      CountedKind::Import => self.imports_removed += count,
+    }
+  }
+}
+
+impl fmt::Display for CleanupMetrics {
+  fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+    write!(
+      f,
+      "+{} -{} line(s), {} if branch(es) collapsed, {} function(s) deleted, {} type(s) deleted, {} import(s) removed, {} file(s) deleted",
+      self.lines_added,
+      self.lines_removed,
+      self.if_branches_collapsed,
+      self.functions_deleted,
+      self.types_deleted,
+      self.imports_removed,
+      self.files_deleted
+    )
+  }
+}
+
+/// The metrics of a run of Piranha, in total, per file (relative to `path_to_codebase`) and per flag
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Getters)]
+pub struct PiranhaMetrics {
+  #[get = "pub"]
+  total: CleanupMetrics,
+  #[get = "pub"]
+  flags: BTreeMap<String, CleanupMetrics>,
+  #[get = "pub"]
+  files: BTreeMap<String, CleanupMetrics>,
+}
+
+impl fmt::Display for PiranhaMetrics {
+  fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+    write!(f, "{} file(s) changed, {}", self.files.len(), self.total)?;
+    for (flag, metrics) in &self.flags {
+      write!(f, "\n  {flag} : {metrics}")?;
+    }
+    // The rules rewriting the most, first
+    for (rule_name, count) in self
+      .total
+      .rule_counts
+      .iter()
+      .sorted_by(|(a_name, a), (b_name, b)| b.cmp(a).then(a_name.cmp(b_name)))
+    {
+      write!(f, "\n  # Rewrites of {rule_name} : {count}")?;
+    }
+    Ok(())
+  }
+}
+
+/// Returns the metrics of the changes of the files of the `summaries` (see the module documentation).
+/// Without flags manifest, the changes are attributed to the `stale_flag_name` of the substitutions (if any).
+pub fn get_metrics(
+  summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+) -> PiranhaMetrics {
+  let stale_flags = piranha_arguments
+    .flag_substitutions()
+    .into_iter()
+    .filter_map(|(_, substitutions)| substitutions.get(STALE_FLAG_NAME).cloned())
+    .unique()
+    .collect_vec();
+  let single_flag = (stale_flags.len() == 1).then(|| stale_flags[0].to_string());
+
+  let mut parser = piranha_arguments.language().parser();
+  let mut metrics = PiranhaMetrics::default();
+  for summary in summaries.iter().filter(|s| s.is_changed()) {
+    let mut file_metrics = CleanupMetrics::default();
+    for (flag, flag_metrics) in get_file_metrics(summary, piranha_arguments, &mut parser) {
+      file_metrics.merge(&flag_metrics);
+      if let Some(flag) = flag.or_else(|| single_flag.clone()) {
+        metrics.flags.entry(flag).or_default().merge(&flag_metrics);
+      }
+    }
+    metrics.total.merge(&file_metrics);
+    let path = get_relative_path(Path::new(summary.path()), piranha_arguments);
+    metrics.files.insert(path, file_metrics);
+  }
+  metrics
+}
+
+/// Returns the metrics of the changes of the file of the `summary`, for each flag they are attributed to.
+fn get_file_metrics(
+  summary: &PiranhaOutputSummary, piranha_arguments: &PiranhaArguments, parser: &mut Parser,
+) -> BTreeMap<Option<String>, CleanupMetrics> {
+  let original_content = summary.original_content();
+  let content = summary.content();
+  // The flag with the most rewrites in the file, to which the changes overlapping no rewrite of a flag (as well as the
+  // rewrites of no flag, e.g. of `delete_unreachable`) are attributed
+  let main_flag = summary
+    .rewrites()
+    .iter()
+    .filter_map(|e| e.flag().clone())
+    .counts()
+    .into_iter()
+    .sorted_by(|(a_flag, a), (b_flag, b)| b.cmp(a).then(a_flag.cmp(b_flag)))
+    .map(|(flag, _)| flag)
+    .next();
+
+  let mut metrics: BTreeMap<Option<String>, CleanupMetrics> = BTreeMap::new();
+  for edit in summary.rewrites() {
+    let flag = edit.flag().clone().or_else(|| main_flag.clone());
+    *metrics
+      .entry(flag)
+      .or_default()
+      .rule_counts
+      .entry(edit.matched_rule().to_string())
+      .or_default() += 1;
+  }
+
+  let is_go = *piranha_arguments.language().supported_language() == SupportedLanguage::Go;
+  let (original_nodes, nodes) = if is_go {
+    get_removable_nodes(parser, original_content, content)
+  } else {
+    (vec![], vec![])
+  };
+  let mut removed_nodes: BTreeMap<Option<String>, Vec<(CountedKind, i64)>> = BTreeMap::new();
+  for op in TextDiff::from_lines(original_content, content).ops() {
+    if op.tag() == DiffTag::Equal {
+      continue;
+    }
+    let (old_lines, new_lines) = (op.old_range(), op.new_range());
+    let flag = get_flag_of_change(summary.rewrites(), &old_lines).or_else(|| main_flag.clone());
+    let flag_metrics = metrics.entry(flag.clone()).or_default();
+    flag_metrics.lines_removed += old_lines.len();
+    flag_metrics.lines_added += new_lines.len();
+    flag_metrics.net_lines += new_lines.len() as i64 - old_lines.len() as i64;
+    let removed = removed_nodes.entry(flag).or_default();
+    removed.extend(
+      original_nodes
+        .iter()
+        .filter(|(_, row)| old_lines.contains(row))
+        .map(|(kind, _)| (*kind, 1)),
+    );
+    removed.extend(
+      nodes
+        .iter()
+        .filter(|(_, row)| new_lines.contains(row))
+        .map(|(kind, _)| (*kind, -1)),
+    );
+  }
+  for (flag, removed) in removed_nodes {
+    let flag_metrics = metrics.entry(flag).or_default();
+    for kind in COUNTED_KINDS {
+      let count = removed
+        .iter()
+        .filter(|(k, _)| *k == kind)
+        .map(|(_, c)| c)
+        .sum();
+      flag_metrics.record_removed(kind, count);
+    }
+  }
+
+  if content.is_empty() && !original_content.is_empty() && *piranha_arguments.delete_file_if_empty()
+  {
+    metrics.entry(main_flag).or_default().files_deleted += 1;
+  }
+  metrics
+}
+
+/// Returns the flag of the rewrites overlapping the `old_lines` (i.e. the 0-based lines of the original content
+/// removed by a change, or the lines around the change if it only adds lines), if any.
+fn get_flag_of_change(rewrites: &[Edit], old_lines: &Range<usize>) -> Option<String> {
+  let (first_line, last_line) = if old_lines.is_empty() {
+    (old_lines.start.saturating_sub(1), old_lines.start)
+  } else {
+    (old_lines.start, old_lines.end - 1)
+  };
+  rewrites
+    .iter()
+    .filter(|e| {
+      let range = e.p_match().original_range();
+      range.start_point.row <= last_line && range.end_point.row >= first_line
+    })
+    .filter_map(|e| e.flag().clone())
+    .counts()
+    .into_iter()
+    .sorted_by(|(a_flag, a), (b_flag, b)| b.cmp(a).then(a_flag.cmp(b_flag)))
+    .map(|(flag, _)| flag)
+    .next()
+}
+
+/// Returns the kind and the (0-based) first line of the counted nodes of the original and of the final content.
+/// The `if` statements within the functions that are not in the final content anymore are not counted, since these
+/// are deleted along with the function rather than collapsed.
+fn get_removable_nodes(
+  parser: &mut Parser, original_content: &str, content: &str,
+) -> (Vec<(CountedKind, usize)>, Vec<(CountedKind, usize)>) {
+  let original_nodes = get_counted_nodes(parser, original_content);
+  let nodes = get_counted_nodes(parser, content);
+  let functions: HashSet<&String> = nodes
+    .iter()
+    .filter(|(kind, _, _)| *kind == CountedKind::Function)
+    .filter_map(|(_, _, function)| function.as_ref())
+    .collect();
+  let keep = |(kind, row, function): (CountedKind, usize, Option<String>)| {
+    let is_deleted_with_function = kind == CountedKind::IfStatement
+      && function.as_ref().map_or(false, |f| !functions.contains(f));
+    (!is_deleted_with_function).then_some((kind, row))
+  };
+  let original_nodes = original_nodes
+    .iter()
+    .cloned()
+    .filter_map(keep)
+    .collect_vec();
+  let nodes = nodes.iter().cloned().filter_map(keep).collect_vec();
+  (original_nodes, nodes)
+}
+
+/// Returns the counted nodes of the Go `code`, along with their (0-based) first line and the signature of the
+/// function (or method) they are, or are within.
+fn get_counted_nodes(parser: &mut Parser, code: &str) -> Vec<(CountedKind, usize, Option<String>)> {
+  let tree = match parser.parse(code, None) {
+    Some(tree) => tree,
+    None => return vec![],
+  };
+  traverse(tree.walk(), Order::Pre)
+    .filter_map(|node| {
+      let kind = match node.kind() {
+        "if_statement" => CountedKind::IfStatement,
+        "function_declaration" | "method_declaration" => CountedKind::Function,
+        "type_spec" | "type_alias" => CountedKind::Type,
+        "import_spec" => CountedKind::Import,
+        _ => return None,
+      };
+      let function = std::iter::successors(Some(node), |n| n.parent())
+        .find(|n| ["function_declaration", "method_declaration"].contains(&n.kind()))
+        .map(|n| get_function_signature(n, code));
+      Some((kind, node.start_position().row, function))
+    })
+    .collect()
+}
+
+/// Returns the receiver (if any) and the name of the function (or method) declaration, e.g. `(w widget) draw`.
+fn get_function_signature(node: Node, code: &str) -> String {
+  ["receiver", "name"]
+    .iter()
+    .filter_map(|field| node.child_by_field_name(field))
+    .filter_map(|n| n.utf8_text(code.as_bytes()).ok())
+    .join(" ")
+}
+
+#[cfg(test)]
+#[path = "unit_tests/piranha_metrics_test.rs"]
+mod piranha_metrics_test;
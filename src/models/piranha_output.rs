@@ -14,10 +14,15 @@ Copyright (c) 2023 Uber Technologies, Inc.
 use getset::Getters;
 use itertools::Itertools;
 use serde_derive::{Deserialize, Serialize};
+use similar::TextDiff;
+use std::{collections::HashMap, fmt, path::Path, time::Duration};
 
 use crate::utilities::gen_py_str_methods;
 
-use super::{edit::Edit, matches::Match, source_code_unit::SourceCodeUnit};
+use super::{
+  default_configs::FLIP_DEFAULT, edit::Edit, matches::Match, piranha_arguments::PiranhaArguments,
+  source_code_unit::SourceCodeUnit,
+};
 use pyo3::{prelude::pyclass, pymethods};
 
 /// A class to represent Piranha's output
@@ -45,11 +50,385 @@ pub struct PiranhaOutputSummary {
   #[pyo3(get)]
   #[get = "pub(crate)"]
   rewrites: Vec<Edit>,
+  /// Unified diff between the original and the final content of the file (only populated for `dry_run`)
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  diff: String,
+  /// The reason why the file was not rewritten (e.g. generated Go code), in which case the `matches` are the usages of the rules
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  skip_reason: Option<String>,
+  /// The matches of the rewrite rules that were not applied, as they overlap a Go statement or declaration
+  /// annotated with `//piranha:keep`
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  suppressed_matches: Vec<(String, Match)>,
+  /// The matches of the rules that were skipped, as their edit is not entirely within the `line_range` of the code
+  /// snippet (e.g. the deletion of the block enclosing the requested lines)
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  out_of_range_matches: Vec<(String, Match)>,
+  /// The Go functions (and methods) of the file deleted by `delete_unreachable`, as they are not referred in their
+  /// package anymore
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  deleted_functions: Vec<DeletedFunction>,
+  /// The Go struct types of the file that are not selected anymore once the flag is cleaned up, deleted by
+  /// `delete_unselected_implementations` (along with their methods and constructors) unless referred from another package
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  unselected_implementations: Vec<UnselectedImplementation>,
+  /// The usages of the stale flags left in the file once all the rules have been applied (i.e. its string literals,
+  /// and the references to the variables seeded from it), to be reviewed manually before deleting the flag
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  unresolved_usages: Vec<UnresolvedUsage>,
+  /// The number of flag API calls of the file whose default value was flipped to the `treated_value` (only populated
+  /// for the `flip` treatment)
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  flipped_sites: usize,
+  /// The statistics of the processing of the file (e.g. the matches evaluated, or the time spent parsing it)
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  stats: PiranhaStats,
+  /// The rewrite that introduced syntax errors in the file, in which case all its rewrites were rolled back (i.e. the
+  /// file is left unmodified)
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  invalid_rewrite: Option<InvalidRewrite>,
+  /// The rewrite that running Piranha again would still apply to the file (with `verify_fixpoint`), i.e. the cleanup
+  /// did not reach a fixpoint. The rewrites of the file are persisted regardless
+  #[pyo3(get)]
+  #[get = "pub"]
+  #[serde(default)]
+  fixpoint_violation: Option<String>,
 }
 
 gen_py_str_methods!(PiranhaOutputSummary);
 
+/// The statistics of a run of Piranha, for a file (or for all the files), to identify the rules that match far more
+/// than they rewrite. The times are the wall-clock times of the thread processing the file, hence the times of several
+/// files (processed in parallel) add up to more than the elapsed time.
+#[derive(Serialize, Debug, Clone, Default, Deserialize, Getters, PartialEq)]
+#[pyclass]
+pub struct PiranhaStats {
+  /// The number of files processed
+  #[pyo3(get)]
+  #[get = "pub"]
+  files: usize,
+  /// The number of matches of the tree-sitter queries of the rules that were evaluated (i.e. before their filters)
+  #[pyo3(get)]
+  #[get = "pub"]
+  matches_evaluated: usize,
+  /// The number of edits applied (including the ones of the post-processing, e.g. the import cleanup)
+  #[pyo3(get)]
+  #[get = "pub"]
+  edits_applied: usize,
+  /// The number of iterations of the `fixpoint_rules` over the rewritten functions (Go only)
+  #[pyo3(get)]
+  #[get = "pub"]
+  fixpoint_iterations: usize,
+  /// The number of matches evaluated for each rule
+  #[pyo3(get)]
+  #[get = "pub"]
+  matches_evaluated_per_rule: HashMap<String, usize>,
+  /// The time spent parsing (and re-parsing, after each edit) the file, in milliseconds
+  #[pyo3(get)]
+  #[get = "pub"]
+  parse_time_ms: f64,
+  /// The time spent matching the rules (i.e. evaluating their queries and filters), in milliseconds
+  #[pyo3(get)]
+  #[get = "pub"]
+  match_time_ms: f64,
+  /// The time spent writing (or deleting) the file, in milliseconds
+  #[pyo3(get)]
+  #[get = "pub"]
+  write_time_ms: f64,
+}
+
+gen_py_str_methods!(PiranhaStats);
+
+impl PiranhaStats {
+  pub(crate) fn new() -> Self {
+    Self {
+      files: 1,
+      ..Default::default()
+    }
+  }
+
+  /// Records the `number_of_matches` evaluated for the rule `rule_name`, in `duration`
+  pub(crate) fn record_matches(
+    &mut self, rule_name: &str, number_of_matches: usize, duration: Duration,
+  ) {
+    self.matches_evaluated += number_of_matches;
+    *self
+      .matches_evaluated_per_rule
+      .entry(rule_name.to_string())
+      .or_default() += number_of_matches;
+    self.match_time_ms += get_millis(duration);
+  }
+
+  pub(crate) fn record_edit(&mut self) {
+    self.edits_applied += 1;
+  }
+
+  pub(crate) fn record_fixpoint_iteration(&mut self) {
+    self.fixpoint_iterations += 1;
+  }
+
+  pub(crate) fn record_parse(&mut self, duration: Duration) {
+    self.parse_time_ms += get_millis(duration);
+  }
+
+  pub(crate) fn record_write(&mut self, duration: Duration) {
+    self.write_time_ms += get_millis(duration);
+  }
+
+  /// Adds the statistics of `other` (e.g. of another file) to these
+  pub(crate) fn merge(&mut self, other: &PiranhaStats) {
+    self.files += other.files;
+    self.matches_evaluated += other.matches_evaluated;
+    self.edits_applied += other.edits_applied;
+    self.fixpoint_iterations += other.fixpoint_iterations;
+    for (rule_name, number_of_matches) in &other.matches_evaluated_per_rule {
+      *self
+        .matches_evaluated_per_rule
+        .entry(rule_name.to_string())
+        .or_default() += number_of_matches;
+    }
+    self.parse_time_ms += other.parse_time_ms;
+    self.match_time_ms += other.match_time_ms;
+    self.write_time_ms += other.write_time_ms;
+  }
+
+  /// Returns the rules whose matches were evaluated, along with the number of these matches, most evaluated first
+  pub fn most_evaluated_rules(&self) -> Vec<(&String, &usize)> {
+    self
+      .matches_evaluated_per_rule
+      .iter()
+      .sorted_by(|(a_name, a), (b_name, b)| b.cmp(a).then(a_name.cmp(b_name)))
+      .collect_vec()
+  }
+}
+
+impl fmt::Display for PiranhaStats {
+  fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+    write!(
+      f,
+      "{} file(s), {} match(es) evaluated, {} edit(s) applied, {} fixpoint iteration(s) (parse {:.1} ms, match {:.1} ms, write {:.1} ms)",
+      self.files,
+      self.matches_evaluated,
+      self.edits_applied,
+      self.fixpoint_iterations,
+      self.parse_time_ms,
+      self.match_time_ms,
+      self.write_time_ms
+    )
+  }
+}
+
+fn get_millis(duration: Duration) -> f64 {
+  duration.as_secs_f64() * 1000.0
+}
+
+/// A Go function (or method) deleted by `delete_unreachable`, along with the cleanup that made it unreachable
+#[derive(Serialize, Debug, Clone, Default, Deserialize, Getters, PartialEq)]
+#[pyclass]
+pub struct DeletedFunction {
+  /// Name of the function
+  #[pyo3(get)]
+  #[get = "pub"]
+  name: String,
+  /// Path to the file declaring the function
+  #[pyo3(get)]
+  #[get = "pub"]
+  path: String,
+  /// The rules whose rewrites deleted the references to the function (in the order they were applied), i.e. the rules
+  /// rewriting the code enclosing a reference, up to the one deleting it
+  #[pyo3(get)]
+  #[get = "pub"]
+  rule_chain: Vec<String>,
+  /// The deleted functions that referred to the function, if it is transitively unreachable
+  #[pyo3(get)]
+  #[get = "pub"]
+  deleted_callers: Vec<String>,
+}
+
+gen_py_str_methods!(DeletedFunction);
+
+impl DeletedFunction {
+  pub(crate) fn new(
+    name: String, path: String, rule_chain: Vec<String>, deleted_callers: Vec<String>,
+  ) -> Self {
+    Self {
+      name,
+      path,
+      rule_chain,
+      deleted_callers,
+    }
+  }
+}
+
+/// A Go struct type that is not selected anymore once the flag is cleaned up (e.g. `legacyFlow` once
+/// `if enabled { flow = newFlow{} } else { flow = legacyFlow{} }` is), which `delete_unselected_implementations` deletes
+/// along with its methods and its constructors, unless it is referred from another package
+#[derive(Serialize, Debug, Clone, Default, Deserialize, Getters, PartialEq)]
+#[pyclass]
+pub struct UnselectedImplementation {
+  /// Name of the type
+  #[pyo3(get)]
+  #[get = "pub"]
+  name: String,
+  /// Path to the file declaring the type
+  #[pyo3(get)]
+  #[get = "pub"]
+  path: String,
+  /// The methods of the type (deleted along with it)
+  #[pyo3(get)]
+  #[get = "pub"]
+  methods: Vec<String>,
+  /// The constructors of the type, i.e. the functions returning it (deleted along with it)
+  #[pyo3(get)]
+  #[get = "pub"]
+  constructors: Vec<String>,
+  /// The references to the type (or to its constructors) from other packages (as `path:line`), which prevent its
+  /// deletion
+  #[pyo3(get)]
+  #[get = "pub"]
+  external_references: Vec<String>,
+  /// The interfaces implemented by the type that are left with a single implementation once it is deleted, along with
+  /// this implementation (e.g. `("CheckoutFlow", "newFlow")`), which are not devirtualized
+  #[pyo3(get)]
+  #[get = "pub"]
+  single_implementations: Vec<(String, String)>,
+}
+
+gen_py_str_methods!(UnselectedImplementation);
+
+impl UnselectedImplementation {
+  pub(crate) fn new(
+    name: String, path: String, methods: Vec<String>, constructors: Vec<String>,
+    external_references: Vec<String>, single_implementations: Vec<(String, String)>,
+  ) -> Self {
+    Self {
+      name,
+      path,
+      methods,
+      constructors,
+      external_references,
+      single_implementations,
+    }
+  }
+
+  /// Checks if the type was deleted, i.e. it is not referred from another package
+  pub fn is_deleted(&self) -> bool {
+    self.external_references.is_empty()
+  }
+}
+
+/// A usage of a stale flag left in the code once all the rules have been applied
+#[derive(Serialize, Debug, Clone, Default, Deserialize, Getters, PartialEq)]
+#[pyclass]
+pub struct UnresolvedUsage {
+  /// Name of the stale flag (i.e. its `stale_flag_name`)
+  #[pyo3(get)]
+  #[get = "pub"]
+  flag: String,
+  /// Path to the file
+  #[pyo3(get)]
+  #[get = "pub"]
+  path: String,
+  /// The (1-based) line of the usage, in the final content of the file
+  #[pyo3(get)]
+  #[get = "pub"]
+  line: usize,
+  /// Name of the function (or method) enclosing the usage, if any
+  #[pyo3(get)]
+  #[get = "pub"]
+  enclosing_function: Option<String>,
+  /// The (trimmed) line of the usage
+  #[pyo3(get)]
+  #[get = "pub"]
+  snippet: String,
+  /// The variable seeded from the flag that the usage refers to, if it is not a string literal of the flag name
+  #[pyo3(get)]
+  #[get = "pub"]
+  variable: Option<String>,
+}
+
+gen_py_str_methods!(UnresolvedUsage);
+
+impl UnresolvedUsage {
+  pub(crate) fn new(
+    flag: String, path: String, line: usize, enclosing_function: Option<String>, snippet: String,
+    variable: Option<String>,
+  ) -> Self {
+    Self {
+      flag,
+      path,
+      line,
+      enclosing_function,
+      snippet,
+      variable,
+    }
+  }
+}
+
+/// A rewrite that introduced syntax errors (i.e. `ERROR` or `MISSING` nodes that were not in the original content), because
+/// of which all the rewrites of its file were rolled back
+#[derive(Serialize, Debug, Clone, Default, Deserialize, Getters, PartialEq)]
+#[pyclass]
+pub struct InvalidRewrite {
+  /// Path to the file
+  #[pyo3(get)]
+  #[get = "pub"]
+  path: String,
+  /// The rule whose rewrite introduced the syntax errors (or `format_output`, if these were introduced by the formatting)
+  #[pyo3(get)]
+  #[get = "pub"]
+  rule: String,
+  /// The lines of the code encompassing the rewrite, before it
+  #[pyo3(get)]
+  #[get = "pub"]
+  original_snippet: String,
+  /// The same lines, once rewritten
+  #[pyo3(get)]
+  #[get = "pub"]
+  replacement_snippet: String,
+}
+
+gen_py_str_methods!(InvalidRewrite);
+
+impl InvalidRewrite {
+  pub(crate) fn new(
+    path: String, rule: String, original_snippet: String, replacement_snippet: String,
+  ) -> Self {
+    Self {
+      path,
+      rule,
+      original_snippet,
+      replacement_snippet,
+    }
+  }
+}
+
 impl PiranhaOutputSummary {
+  /// Checks if the file was rewritten (or deleted), i.e. its content differs from the original one
+  pub fn is_changed(&self) -> bool {
+    self.content != self.original_content
+  }
+
   pub(crate) fn new(source_code_unit: &SourceCodeUnit) -> PiranhaOutputSummary {
     return PiranhaOutputSummary {
       path: String::from(source_code_unit.path().as_os_str().to_str().unwrap()),
@@ -57,6 +436,108 @@ impl PiranhaOutputSummary {
       content: source_code_unit.code().to_string(),
       matches: source_code_unit.matches().iter().cloned().collect_vec(),
       rewrites: source_code_unit.rewrites().iter().cloned().collect_vec(),
+      diff: if *source_code_unit.piranha_arguments().dry_run() {
+        get_unified_diff(source_code_unit)
+      } else {
+        String::new()
+      },
+      skip_reason: source_code_unit.skip_reason().map(String::from),
+      suppressed_matches: source_code_unit
+        .suppressed_matches()
+        .iter()
+        .cloned()
+        .collect_vec(),
+      out_of_range_matches: source_code_unit
+        .out_of_range_matches()
+        .iter()
+        .cloned()
+        .collect_vec(),
+      deleted_functions: source_code_unit.deleted_functions().clone(),
+      unselected_implementations: source_code_unit.unselected_implementations().clone(),
+      unresolved_usages: source_code_unit.unresolved_usages().clone(),
+      flipped_sites: get_flipped_sites(source_code_unit),
+      stats: source_code_unit.stats(),
+      invalid_rewrite: source_code_unit.invalid_rewrite().clone(),
+      fixpoint_violation: source_code_unit.fixpoint_violation().clone(),
     };
   }
 }
+
+/// Returns the number of flag API calls flipped in the source code unit, i.e. the rewrites of the `flip_default` seed
+/// rules (a call that is renamed to the API with a default value is only counted once, although its default value is
+/// then appended by another rule).
+fn get_flipped_sites(source_code_unit: &SourceCodeUnit) -> usize {
+  let flip_rules = source_code_unit
+    .piranha_arguments()
+    .rule_graph()
+    .rules()
+    .iter()
+    .filter(|r| *r.is_seed_rule() && r.groups().contains(FLIP_DEFAULT))
+    .map(|r| r.name())
+    .collect_vec();
+  source_code_unit
+    .rewrites()
+    .iter()
+    .filter(|e| flip_rules.contains(e.matched_rule()))
+    .count()
+}
+
+/// Returns the (`git apply` compatible) unified diff between the original and the updated content of the source code unit.
+/// The paths are relative to `path_to_codebase`, and a file that would be deleted (i.e. `delete_file_if_empty`) is
+/// reported as a deletion. Returns an empty string if the contents are the same.
+fn get_unified_diff(source_code_unit: &SourceCodeUnit) -> String {
+  let original = source_code_unit.original_content();
+  let updated = source_code_unit.code();
+  if original == updated {
+    return String::new();
+  }
+  let piranha_arguments = source_code_unit.piranha_arguments();
+  let path = get_relative_path(source_code_unit.path(), piranha_arguments);
+
+  let mut diff = format!("diff --git a/{path} b/{path}\n");
+  let mut updated_path = format!("b/{path}");
+  if updated.is_empty() && *piranha_arguments.delete_file_if_empty() {
+    diff.push_str(&format!(
+      "deleted file mode {}\n",
+      get_file_mode(source_code_unit.path())
+    ));
+    updated_path = "/dev/null".to_string();
+  }
+  diff.push_str(
+    &TextDiff::from_lines(original, updated)
+      .unified_diff()
+      .header(&format!("a/{path}"), &updated_path)
+      .to_string(),
+  );
+  diff
+}
+
+/// Returns the `path` relative to `path_to_codebase` (or to its directory, if it is a file).
+pub(crate) fn get_relative_path(path: &Path, piranha_arguments: &PiranhaArguments) -> String {
+  let path_to_codebase = Path::new(piranha_arguments.path_to_codebase());
+  let root = if path_to_codebase.is_file() {
+    path_to_codebase.parent().unwrap_or(path_to_codebase)
+  } else {
+    path_to_codebase
+  };
+  path
+    .strip_prefix(root)
+    .unwrap_or(path)
+    .to_str()
+    .unwrap()
+    .to_string()
+}
+
+/// Returns the git file mode of the file at `path`, i.e. `100755` for executables and `100644` otherwise.
+fn get_file_mode(path: &Path) -> &'static str {
+  #[cfg(unix)]
+  {
+    use std::os::unix::fs::PermissionsExt;
+    if let Ok(metadata) = std::fs::metadata(path) {
+      if metadata.permissions().mode() & 0o111 != 0 {
+        return "100755";
+      }
+    }
+  }
+  "100644"
+}
@@ -0,0 +1,277 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Structured (`json` or `sarif`) reports of the rewrites and matches performed by Piranha,
+//! to be consumed by code review tools.
+
+use std::{collections::BTreeMap, path::Path};
+
+use itertools::Itertools;
+use serde_derive::Serialize;
+use serde_json::{json, Value};
+
+use super::{
+  default_configs::{
+    JSON_REPORT_FORMAT, KEPT_NODE_SKIP_REASON, OUT_OF_RANGE_SKIP_REASON, SARIF_REPORT_FORMAT,
+  },
+  piranha_arguments::PiranhaArguments,
+  piranha_output::{get_relative_path, PiranhaOutputSummary},
+};
+
+/// The rule name reported for the files deleted by Piranha (i.e. `delete_file_if_empty`)
+const DELETE_FILE: &str = "delete_file";
+
+/// The kind of a reported result
+#[derive(Serialize, Debug, Clone, Copy, PartialEq, Eq)]
+#[serde(rename_all = "snake_case")]
+pub(crate) enum ReportResultKind {
+  /// A rewrite performed by a rule
+  Rewrite,
+  /// A file deleted because it was left empty
+  DeletedFile,
+  /// A match of a match-only rule, i.e. a site that requires a manual review
+  Match,
+  /// A usage of a rule in a file that was skipped (e.g. generated Go code), or in a node kept with `//piranha:keep`,
+  /// i.e. a site that was not rewritten
+  SkippedUsage,
+}
+
+/// A result of the report, i.e. a rewrite, a deleted file or a match.
+/// The ranges (bytes and 1-based lines) refer to the original content of the file.
+#[derive(Serialize, Debug, Clone, PartialEq)]
+pub(crate) struct ReportResult {
+  kind: ReportResultKind,
+  path: String,
+  rule: String,
+  start_byte: usize,
+  end_byte: usize,
+  start_line: usize,
+  end_line: usize,
+  original: String,
+  replacement: String,
+  substitutions: BTreeMap<String, String>,
+  /// The reason why the file (or the node) was skipped (for the `skipped_usage` results)
+  #[serde(skip_serializing_if = "Option::is_none")]
+  skip_reason: Option<String>,
+  /// The stale flag (of the flags manifest) whose cleanup performed the rewrite
+  #[serde(skip_serializing_if = "Option::is_none")]
+  flag: Option<String>,
+}
+
+/// Returns the results (in order) for the rewrites, deleted files and matches reported in the `summaries`.
+/// The matches of a skipped file (see `PiranhaOutputSummary::skip_reason`), as well as the suppressed matches
+/// (see `PiranhaOutputSummary::suppressed_matches`) and the ones out of the line range
+/// (see `PiranhaOutputSummary::out_of_range_matches`), are reported as skipped usages.
+pub(crate) fn get_report_results(
+  summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+) -> Vec<ReportResult> {
+  let mut results = vec![];
+  for summary in summaries {
+    let path = get_relative_path(Path::new(summary.path()), piranha_arguments);
+    let original_content = summary.original_content();
+    for edit in summary.rewrites() {
+      let range = edit.p_match().original_range();
+      results.push(ReportResult {
+        kind: ReportResultKind::Rewrite,
+        path: path.clone(),
+        rule: edit.matched_rule().to_string(),
+        start_byte: range.start_byte,
+        end_byte: range.end_byte,
+        start_line: range.start_point.row + 1,
+        end_line: range.end_point.row + 1,
+        original: original_content
+          .get(range.start_byte..range.end_byte)
+          .unwrap_or_default()
+          .to_string(),
+        replacement: edit.replacement_string().to_string(),
+        substitutions: edit.substitutions().clone().into_iter().collect(),
+        skip_reason: None,
+        flag: edit.flag().clone(),
+      });
+    }
+    if summary.content().is_empty()
+      && !original_content.is_empty()
+      && *piranha_arguments.delete_file_if_empty()
+    {
+      results.push(ReportResult {
+        kind: ReportResultKind::DeletedFile,
+        path: path.clone(),
+        rule: DELETE_FILE.to_string(),
+        start_byte: 0,
+        end_byte: original_content.len(),
+        start_line: 1,
+        end_line: original_content.matches('\n').count() + 1,
+        original: original_content.to_string(),
+        replacement: String::new(),
+        substitutions: BTreeMap::new(),
+        skip_reason: None,
+        flag: None,
+      });
+    }
+    let kind = if summary.skip_reason().is_some() {
+      ReportResultKind::SkippedUsage
+    } else {
+      ReportResultKind::Match
+    };
+    let matches = summary
+      .matches()
+      .iter()
+      .map(|(rule, m)| (kind, rule, m, summary.skip_reason().clone()))
+      .chain(summary.suppressed_matches().iter().map(|(rule, m)| {
+        let skip_reason = Some(KEPT_NODE_SKIP_REASON.to_string());
+        (ReportResultKind::SkippedUsage, rule, m, skip_reason)
+      }))
+      .chain(summary.out_of_range_matches().iter().map(|(rule, m)| {
+        let skip_reason = Some(OUT_OF_RANGE_SKIP_REASON.to_string());
+        (ReportResultKind::SkippedUsage, rule, m, skip_reason)
+      }));
+    for (kind, rule, m, skip_reason) in matches {
+      let range = m.original_range();
+      results.push(ReportResult {
+        kind,
+        path: path.clone(),
+        rule: rule.to_string(),
+        start_byte: range.start_byte,
+        end_byte: range.end_byte,
+        start_line: range.start_point.row + 1,
+        end_line: range.end_point.row + 1,
+        original: original_content
+          .get(range.start_byte..range.end_byte)
+          .unwrap_or_default()
+          .to_string(),
+        replacement: String::new(),
+        substitutions: m.matches().clone().into_iter().collect(),
+        skip_reason,
+        flag: None,
+      });
+    }
+  }
+  results
+}
+
+/// Returns the report of the `summaries` in the given `report_format` (i.e. `json` or `sarif`).
+pub fn get_report(
+  summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments, report_format: &str,
+) -> String {
+  let results = get_report_results(summaries, piranha_arguments);
+  let report = match report_format {
+    JSON_REPORT_FORMAT => json!(results),
+    SARIF_REPORT_FORMAT => get_sarif_report(&results),
+    _ => panic!("Unsupported report format {report_format:?}"),
+  };
+  serde_json::to_string_pretty(&report).expect("Could not serialize the report")
+}
+
+/// Returns the SARIF (2.1.0) log of the `results`.
+/// The rewrites and deleted files are reported as `fail` results (along with the fix Piranha applied),
+/// while the matches (and the usages in the skipped files) are reported as `review` results.
+fn get_sarif_report(results: &[ReportResult]) -> Value {
+  let rules = results
+    .iter()
+    .map(|r| r.rule.as_str())
+    .sorted()
+    .dedup()
+    .collect_vec();
+  let sarif_results = results
+    .iter()
+    .map(|result| {
+      let rule_index = rules.iter().position(|r| *r == result.rule).unwrap();
+      let artifact_location = json!({ "uri": result.path });
+      let region = json!({
+        "startLine": result.start_line,
+        "endLine": result.end_line,
+        "byteOffset": result.start_byte,
+        "byteLength": result.end_byte - result.start_byte,
+      });
+      let (kind, level, message) = match result.kind {
+        ReportResultKind::Rewrite => (
+          "fail",
+          "warning",
+          format!("Piranha rewrote this code (rule `{}`)", result.rule),
+        ),
+        ReportResultKind::DeletedFile => (
+          "fail",
+          "warning",
+          "Piranha deleted this file, since it was left empty".to_string(),
+        ),
+        ReportResultKind::Match => (
+          "review",
+          "none",
+          format!("Piranha matched this code (rule `{}`)", result.rule),
+        ),
+        ReportResultKind::SkippedUsage => (
+          "review",
+          "note",
+          format!(
+            "Piranha did not rewrite this code (rule `{}`) : {}",
+            result.rule,
+            result.skip_reason.as_deref().unwrap_or_default()
+          ),
+        ),
+      };
+      let mut sarif_result = json!({
+        "ruleId": result.rule,
+        "ruleIndex": rule_index,
+        "kind": kind,
+        "level": level,
+        "message": { "text": message },
+        "locations": [{
+          "physicalLocation": {
+            "artifactLocation": artifact_location,
+            "region": region,
+          }
+        }],
+        "properties": {
+          "piranhaResultKind": result.kind,
+          "substitutions": result.substitutions,
+        },
+      });
+      if [ReportResultKind::Rewrite, ReportResultKind::DeletedFile].contains(&result.kind) {
+        sarif_result["fixes"] = json!([{
+          "description": { "text": message },
+          "artifactChanges": [{
+            "artifactLocation": artifact_location,
+            "replacements": [{
+              "deletedRegion": {
+                "byteOffset": result.start_byte,
+                "byteLength": result.end_byte - result.start_byte,
+              },
+              "insertedContent": { "text": result.replacement },
+            }],
+          }],
+        }]);
+      }
+      sarif_result
+    })
+    .collect_vec();
+
+  json!({
+    "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+    "version": "2.1.0",
+    "runs": [{
+      "tool": {
+        "driver": {
+          "name": "Piranha",
+          "informationUri": "https://github.com/uber/piranha",
+          "version": env!("CARGO_PKG_VERSION"),
+          "rules": rules.iter().map(|r| json!({ "id": r })).collect_vec(),
+        }
+      },
+      "results": sarif_results,
+    }]
+  })
+}
+
+#[cfg(test)]
+#[path = "unit_tests/piranha_report_test.rs"]
+mod piranha_report_test;
@@ -19,13 +19,14 @@ use getset::Getters;
 use pyo3::prelude::{pyclass, pymethods};
 use serde_derive::Deserialize;
 
-use crate::utilities::{gen_py_str_methods, Instantiate};
+use crate::utilities::{gen_py_str_methods, instantiate_replace, validate_transforms, Instantiate};
 
 use super::{
   capture_group_patterns::CGPattern,
   default_configs::{
-    default_filters, default_groups, default_holes, default_is_seed_rule, default_query,
-    default_replace, default_replace_idx, default_replace_node, default_rule_name,
+    default_defined_name, default_filters, default_groups, default_holes, default_is_rvalue,
+    default_is_seed_rule, default_query, default_replace, default_replace_idx,
+    default_replace_node, default_rule_name, default_unwraps_block,
   },
   filter::Filter,
   Validator,
@@ -94,6 +95,31 @@ pub struct Rule {
   #[get = "pub"]
   #[pyo3(get)]
   is_seed_rule: bool,
+
+  /// Only accepts the matches in r-value position, i.e. neither the target of an assignment
+  /// nor the name of a declaration (e.g. `flag`, but not `x`, in `x = flag`)
+  #[builder(default = "default_is_rvalue()")]
+  #[serde(default = "default_is_rvalue")]
+  #[get = "pub"]
+  #[pyo3(get)]
+  is_rvalue: bool,
+
+  /// The name (e.g. `@stale_flag_name`) defined by the matches of the rule, e.g. an enum constant.
+  /// Such a (global) rule is deferred until the other rules are applied, and only deletes the definition
+  /// if the name is not referred anywhere in the code base anymore
+  #[builder(default = "default_defined_name()")]
+  #[serde(default = "default_defined_name")]
+  #[get = "pub"]
+  #[pyo3(get)]
+  defined_name: String,
+
+  /// Only accepts the matches of a block (i.e. the replace node), that can be unwrapped into the enclosing block
+  /// without any name it declares colliding with the enclosing scope (Go only)
+  #[builder(default = "default_unwraps_block()")]
+  #[serde(default = "default_unwraps_block")]
+  #[get = "pub"]
+  #[pyo3(get)]
+  unwraps_block: bool,
 }
 
 impl Rule {
@@ -106,6 +132,11 @@ impl Rule {
   pub(crate) fn is_match_only_rule(&self) -> bool {
     *self.query() != default_query() && *self.replace_node() == default_replace_node()
   }
+
+  /// Checks if a rule deletes a definition (see `defined_name`)
+  pub(crate) fn is_definition_rule(&self) -> bool {
+    *self.defined_name() != default_defined_name()
+  }
 }
 
 #[macro_export]
@@ -140,6 +171,9 @@ macro_rules! piranha_rule {
                 $(, is_seed_rule = $is_seed_rule:expr)?
                 $(, groups = [$($group_name: expr)*])?
                 $(, filters = [$($filter:tt)*])?
+                $(, is_rvalue = $is_rvalue:expr)?
+                $(, defined_name = $defined_name:expr)?
+                $(, unwraps_block = $unwraps_block:expr)?
               ) => {
     $crate::models::rule::RuleBuilder::default()
     .name($name.to_string())
@@ -150,6 +184,9 @@ macro_rules! piranha_rule {
     $(.holes(std::collections::HashSet::from([$($hole.to_string(),)*])))?
     $(.groups(std::collections::HashSet::from([$($group_name.to_string(),)*])))?
     $(.filters(std::collections::HashSet::from([$($filter)*])))?
+    $(.is_rvalue($is_rvalue))?
+    $(.defined_name($defined_name.to_string()))?
+    $(.unwraps_block($unwraps_block))?
     .build().unwrap()
   };
 }
@@ -160,7 +197,8 @@ impl Rule {
   fn py_new(
     name: String, query: Option<String>, replace: Option<String>, replace_idx: Option<u8>,
     replace_node: Option<String>, holes: Option<HashSet<String>>, groups: Option<HashSet<String>>,
-    filters: Option<HashSet<Filter>>, is_seed_rule: Option<bool>,
+    filters: Option<HashSet<Filter>>, is_seed_rule: Option<bool>, is_rvalue: Option<bool>,
+    defined_name: Option<String>, unwraps_block: Option<bool>,
   ) -> Self {
     let mut rule_builder = RuleBuilder::default();
 
@@ -197,6 +235,18 @@ impl Rule {
       rule_builder.is_seed_rule(is_seed_rule);
     }
 
+    if let Some(is_rvalue) = is_rvalue {
+      rule_builder.is_rvalue(is_rvalue);
+    }
+
+    if let Some(defined_name) = defined_name {
+      rule_builder.defined_name(defined_name);
+    }
+
+    if let Some(unwraps_block) = unwraps_block {
+      rule_builder.unwraps_block(unwraps_block);
+    }
+
     rule_builder.build().unwrap()
   }
 
@@ -208,7 +258,8 @@ impl Validator for Rule {
     let validation = self
       .query()
       .validate()
-      .and_then(|_: ()| self.filters().iter().try_for_each(|f| f.validate()));
+      .and_then(|_: ()| self.filters().iter().try_for_each(|f| f.validate()))
+      .and_then(|_: ()| validate_transforms(self.replace()));
     validation
   }
 }
@@ -221,6 +272,9 @@ pub(crate) struct InstantiatedRule {
   rule: Rule,
   #[get = "pub"]
   substitutions: HashMap<String, String>,
+  // The stale flag (of the flags manifest) whose cleanup this rule is part of, if any
+  #[get = "pub"]
+  flag: Option<String>,
 }
 
 impl InstantiatedRule {
@@ -239,9 +293,15 @@ impl InstantiatedRule {
     InstantiatedRule {
       rule: rule.instantiate(&substitutions_for_holes),
       substitutions: substitutions_for_holes,
+      flag: None,
     }
   }
 
+  /// Attributes this rule to the cleanup of the given stale flag (of the flags manifest).
+  pub(crate) fn with_flag(self, flag: Option<String>) -> Self {
+    InstantiatedRule { flag, ..self }
+  }
+
   pub fn name(&self) -> String {
     self.rule().name().to_string()
   }
@@ -278,14 +338,18 @@ impl InstantiatedRule {
 }
 
 impl Instantiate for Rule {
-  /// Create a new query from `self` by updating the `query` and `replace` based on the substitutions.
+  /// Create a new query from `self` by updating the `query`, `replace` and `defined_name` based on the substitutions.
+  /// The transforms of the tags in `replace` (e.g. `@{name|upper}`) are applied too (see `instantiate_replace`).
   /// This functions assumes that each hole in the rule can be substituted.
   /// i.e. It assumes that `substitutions_for_holes` is exhaustive and complete
   fn instantiate(&self, substitutions_for_holes: &HashMap<String, String>) -> Rule {
     let updated_rule = self.clone();
     Rule {
       query: updated_rule.query().instantiate(substitutions_for_holes),
-      replace: updated_rule.replace().instantiate(substitutions_for_holes),
+      replace: instantiate_replace(updated_rule.replace(), substitutions_for_holes),
+      defined_name: updated_rule
+        .defined_name()
+        .instantiate(substitutions_for_holes),
       ..updated_rule
     }
   }
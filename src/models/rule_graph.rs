@@ -19,7 +19,10 @@ use colored::Colorize;
 use derive_builder::Builder;
 use getset::{Getters, MutGetters};
 use itertools::Itertools;
-use std::{collections::HashMap, path::Path};
+use std::{
+  collections::{HashMap, HashSet},
+  path::Path,
+};
 
 use super::{
   default_configs::{default_edges, default_rule_graph_map, default_rules},
@@ -31,6 +34,11 @@ use pyo3::prelude::{pyclass, pymethods};
 
 pub(crate) static GLOBAL: &str = "Global";
 pub(crate) static PARENT: &str = "Parent";
+// Rules of this scope are applied to all the files of the package (i.e. in the same directory)
+// matching the scope query, which is generated from the language's `scope_config.toml`.
+pub(crate) static PACKAGE: &str = "Package";
+// The scope of the functions (and methods), in which the `fixpoint_rules` of the language are re-applied
+pub(crate) static FUNCTION_METHOD: &str = "Function-Method";
 
 #[derive(Debug, Default, Getters, MutGetters, Builder, Clone, PartialEq)]
 #[builder(build_fn(name = "create"))]
@@ -73,9 +81,62 @@ impl RuleGraph {
       .edges(edges)
       .build()
   }
+
+  /// Serializes the rule graph to Graphviz DOT (e.g. `dot -Tsvg graph.dot -o graph.svg`), for debugging.
+  /// The rules are boxes (the seed rules are filled, the dummy rules are dashed) and the groups are ellipses,
+  /// dotted-linked to their rules. The edges are the declared ones (i.e. between rules or groups), labeled with their scope.
+  pub fn to_dot(&self) -> String {
+    let mut lines = vec!["digraph RuleGraph {".to_string()];
+    for rule in self.rules() {
+      let style = match (*rule.is_seed_rule(), rule.is_dummy_rule()) {
+        (true, true) => "filled,dashed",
+        (true, false) => "filled",
+        (false, true) => "dashed",
+        (false, false) => "solid",
+      };
+      lines.push(format!(
+        "  {} [shape=box, style=\"{style}\", fillcolor=lightblue];",
+        quote_dot_id(rule.name())
+      ));
+    }
+    let groups = self
+      .rules()
+      .iter()
+      .flat_map(|r| r.groups())
+      .unique()
+      .sorted();
+    for group in groups {
+      lines.push(format!("  {} [shape=ellipse];", quote_dot_id(group)));
+      for rule in self.get_rules_for_group(group) {
+        lines.push(format!(
+          "  {} -> {} [style=dotted, dir=none];",
+          quote_dot_id(group),
+          quote_dot_id(rule)
+        ));
+      }
+    }
+    for edge in self.edges() {
+      for to in edge.get_to() {
+        lines.push(format!(
+          "  {} -> {} [label={}];",
+          quote_dot_id(edge.get_frm()),
+          quote_dot_id(to),
+          quote_dot_id(edge.get_scope())
+        ));
+      }
+    }
+    lines.push("}".to_string());
+    lines.join("\n") + "\n"
+  }
+
   gen_py_str_methods!();
 }
 
+/// Quotes `id` as a DOT identifier.
+fn quote_dot_id(id: &str) -> String {
+  format!("\"{}\"", id.replace('\\', "\\\\").replace('"', "\\\""))
+}
+
 impl RuleGraphBuilder {
   /// Build the rule graph.
   pub fn build(&self) -> RuleGraph {
@@ -159,9 +220,115 @@ impl RuleGraph {
       .build()
   }
 
+  /// Checks the structure of this (merged) rule graph w.r.t. the rules and edges of `user_defined_graph`, i.e. :
+  ///   * every endpoint of the user defined edges is a rule or a group of this graph (or one of `built_in_names`,
+  ///     i.e. a built-in rule or group that was dropped w.r.t. the Piranha arguments)
+  ///   * every user defined rule that is not a seed rule is reachable from a seed rule
+  ///   * there is no cycle of dummy rules (`get_next` would unfold it forever)
+  /// All the problems are reported together.
+  pub(crate) fn validate_structure(
+    &self, user_defined_graph: &RuleGraph, built_in_names: &HashSet<String>,
+  ) -> Result<(), String> {
+    let mut problems = vec![];
+
+    for edge in user_defined_graph.edges() {
+      for name in [edge.get_frm()].into_iter().chain(edge.get_to().iter()) {
+        if self.get_rules_for_group(name).is_empty() && !built_in_names.contains(name) {
+          problems.push(format!(
+            "The edge from `{}` to {:?} refers to `{}`, which is neither a rule nor a group",
+            edge.get_frm(),
+            edge.get_to(),
+            name
+          ));
+        }
+      }
+    }
+
+    let reachable = self.get_reachable_rules();
+    for rule in user_defined_graph.rules() {
+      if !*rule.is_seed_rule() && !reachable.contains(rule.name()) {
+        problems.push(format!(
+          "The rule `{}` is not a seed rule and is not reachable from any seed rule",
+          rule.name()
+        ));
+      }
+    }
+
+    for cycle in self.get_cycles_of_dummy_rules() {
+      problems.push(format!(
+        "The dummy rules {} form a cycle",
+        cycle.iter().map(|name| format!("`{name}`")).join(" -> ")
+      ));
+    }
+
+    if problems.is_empty() {
+      return Ok(());
+    }
+    Err(format!(
+      "Incorrect Rule Graph - {} problem(s) found :\n{}",
+      problems.len(),
+      problems.iter().map(|p| format!("  * {p}")).join("\n")
+    ))
+  }
+
+  /// Returns the names of the rules reachable from the seed rules (including the seed rules)
+  fn get_reachable_rules(&self) -> HashSet<String> {
+    let mut reachable = HashSet::new();
+    let mut stack = self
+      .rules()
+      .iter()
+      .filter(|r| *r.is_seed_rule())
+      .map(|r| r.name().to_string())
+      .collect_vec();
+    while let Some(rule_name) = stack.pop() {
+      if reachable.insert(rule_name.to_string()) {
+        stack.extend(
+          self
+            .get_neighbors(&rule_name)
+            .into_iter()
+            .map(|(_, to_rule)| to_rule),
+        );
+      }
+    }
+    reachable
+  }
+
+  /// Returns the cycles made only of dummy rules, each starting (and ending) at its smallest rule name.
+  fn get_cycles_of_dummy_rules(&self) -> Vec<Vec<String>> {
+    let dummy_rules: HashSet<&String> = self
+      .rules()
+      .iter()
+      .filter(|r| r.is_dummy_rule())
+      .map(|r| r.name())
+      .collect();
+    let mut cycles = vec![];
+    for start in dummy_rules.iter().sorted() {
+      // Only visit the rules greater than `start`, such that each cycle is reported once
+      let mut visited = HashSet::new();
+      let mut stack = vec![vec![start.to_string()]];
+      while let Some(path) = stack.pop() {
+        for (_, to_rule) in self.get_neighbors(path.last().unwrap()) {
+          if &&to_rule == start {
+            let cycle = [path.clone(), vec![to_rule]].concat();
+            if !cycles.contains(&cycle) {
+              cycles.push(cycle);
+            }
+          } else if dummy_rules.contains(&to_rule)
+            && &&to_rule > start
+            && visited.insert(to_rule.to_string())
+          {
+            stack.push([path.clone(), vec![to_rule]].concat());
+          }
+        }
+      }
+    }
+    cycles
+  }
+
   /// Get the next rules to be applied grouped by the scope in which they should be performed.
+  /// The next rules are attributed to the `flag` (of the flags manifest) of the rule that was applied.
   pub(crate) fn get_next(
-    &self, rule_name: &String, tag_matches: &HashMap<String, String>,
+    &self, rule_name: &String, tag_matches: &HashMap<String, String>, flag: &Option<String>,
   ) -> HashMap<String, Vec<InstantiatedRule>> {
     // let rule_name = rule.name();
     let mut next_rules: HashMap<String, Vec<InstantiatedRule>> = HashMap::new();
@@ -172,7 +339,7 @@ impl RuleGraph {
       if to_rule_name.is_dummy_rule() {
         // Call this method recursively on the dummy node
         for (next_next_rules_scope, next_next_rules) in
-          self.get_next(to_rule_name.name(), tag_matches)
+          self.get_next(to_rule_name.name(), tag_matches, flag)
         {
           for next_next_rule in next_next_rules {
             // Group the next rules based on the scope
@@ -183,7 +350,7 @@ impl RuleGraph {
         // Group the next rules based on the scope
         next_rules.collect(
           String::from(&scope),
-          InstantiatedRule::new(to_rule_name, tag_matches),
+          InstantiatedRule::new(to_rule_name, tag_matches).with_flag(flag.clone()),
         );
       }
     }
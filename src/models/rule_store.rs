@@ -14,32 +14,50 @@ Copyright (c) 2023 Uber Technologies, Inc.
 use std::{
   collections::HashMap,
   path::{Path, PathBuf},
+  sync::{mpsc, Arc, RwLock},
 };
 
 use colored::Colorize;
 use getset::Getters;
+use ignore::{WalkBuilder, WalkState};
 use itertools::Itertools;
-use jwalk::WalkDir;
-use log::{debug, trace};
+use log::{debug, trace, warn};
 use regex::Regex;
 use tree_sitter::Query;
 
 use crate::{
-  models::capture_group_patterns::CGPattern, models::piranha_arguments::PiranhaArguments,
-  models::scopes::ScopeQueryGenerator, utilities::read_file,
+  models::capture_group_patterns::CGPattern,
+  models::default_configs::PIRANHA_IGNORE_FILE,
+  models::piranha_arguments::PiranhaArguments,
+  models::scopes::ScopeQueryGenerator,
+  utilities::{
+    go_build_constraints::{are_compatible, BuildConstraint},
+    is_included, read_file,
+  },
 };
 
 use super::{language::PiranhaLanguage, rule::InstantiatedRule};
 use glob::Pattern;
 
 /// This maintains the state for Piranha.
-#[derive(Debug, Getters, Default)]
+/// Cloning a rule store is cheap-ish: the clones share the (thread-safe) cache of compiled queries,
+/// which allows each worker thread to process a file with its own rule store.
+#[derive(Debug, Getters, Default, Clone)]
 pub(crate) struct RuleStore {
-  // Caches the compiled tree-sitter queries.
-  rule_query_cache: HashMap<String, Query>,
+  // Caches the compiled tree-sitter queries (shared across the clones of this rule store).
+  rule_query_cache: Arc<RwLock<HashMap<String, Arc<Query>>>>,
   // Current global rules to be applied.
   #[get = "pub"]
   global_rules: Vec<InstantiatedRule>,
+  // Current package rules to be applied, along with the directory of the package, the build constraint of the file
+  // that added the rule (Go only), and the scope query that the files of the package match.
+  #[get = "pub"]
+  package_rules: Vec<(
+    PathBuf,
+    Option<BuildConstraint>,
+    CGPattern,
+    InstantiatedRule,
+  )>,
 
   #[get = "pub"]
   language: PiranhaLanguage,
@@ -52,20 +70,40 @@ impl RuleStore {
       ..Default::default()
     };
 
-    for rule in args.rule_graph().rules().clone() {
-      if *rule.is_seed_rule() {
-        rule_store.add_to_global_rules(&InstantiatedRule::new(&rule, &args.input_substitutions()));
+    // The seed rules are instantiated for each flag of the flags manifest (if any), and attributed to the flag
+    // when (at least) one of their holes is substituted by the flag.
+    let input_substitutions = args.input_substitutions();
+    for (flag, substitutions) in args.flag_substitutions() {
+      for rule in args.rule_graph().rules().clone() {
+        if !*rule.is_seed_rule()
+          || (flag.is_some() && !rule.holes().iter().all(|h| substitutions.contains_key(h)))
+        {
+          continue;
+        }
+        let is_flag_specific = rule
+          .holes()
+          .iter()
+          .any(|h| input_substitutions.get(h) != substitutions.get(h));
+        let instantiated_rule = InstantiatedRule::new(&rule, &substitutions);
+        rule_store.add_to_global_rules(&if is_flag_specific {
+          instantiated_rule.with_flag(flag.clone())
+        } else {
+          instantiated_rule
+        });
       }
     }
     trace!("Rule Store {}", format!("{rule_store:#?}"));
     rule_store
   }
 
-  /// Add a new global rule, along with grep heuristics (If it doesn't already exist)
+  /// Add a new global rule, along with grep heuristics (If it doesn't already exist, e.g. for the same flag)
   pub(crate) fn add_to_global_rules(&mut self, rule: &InstantiatedRule) {
     let r = rule.clone();
     if !self.global_rules.iter().any(|r| {
-      r.name().eq(&rule.name()) && r.replace().eq(&rule.replace()) && r.query().eq(&rule.query())
+      r.name().eq(&rule.name())
+        && r.replace().eq(&rule.replace())
+        && r.query().eq(&rule.query())
+        && r.flag().eq(rule.flag())
     }) {
       #[rustfmt::skip]
       debug!("{}", format!("Added Global Rule : {:?} - {}", r.name(), r.query().pattern()).bright_blue());
@@ -73,13 +111,100 @@ impl RuleStore {
     }
   }
 
+  /// Add a new package rule (If it doesn't already exist)
+  pub(crate) fn add_to_package_rules(
+    &mut self, directory: &Path, build_constraint: &Option<BuildConstraint>,
+    scope_query: &CGPattern, rule: &InstantiatedRule,
+  ) {
+    if !self.package_rules.iter().any(|(d, b, s, r)| {
+      d.eq(directory)
+        && b.eq(build_constraint)
+        && s.eq(scope_query)
+        && r.name().eq(&rule.name())
+        && r.replace().eq(&rule.replace())
+        && r.query().eq(&rule.query())
+    }) {
+      #[rustfmt::skip]
+      debug!("{}", format!("Added Package Rule : {:?} - {} ({:?})", rule.name(), rule.query().pattern(), directory).bright_blue());
+      self.package_rules.push((
+        directory.to_path_buf(),
+        build_constraint.clone(),
+        scope_query.clone(),
+        rule.clone(),
+      ));
+    }
+  }
+
+  /// Get the package rules applicable to the file at `path` (i.e. in the directory of the package, and not excluded by
+  /// the `build_constraint` of the file from the builds of the file that added the rule), along with the scope query
+  /// of the package.
+  pub(crate) fn get_package_rules_for(
+    &self, path: &Path, build_constraint: &Option<BuildConstraint>,
+  ) -> Vec<(CGPattern, InstantiatedRule)> {
+    self
+      .package_rules
+      .iter()
+      .filter(|(directory, b, _, _)| {
+        path.parent() == Some(directory.as_path()) && are_compatible(b, build_constraint)
+      })
+      .map(|(_, _, scope_query, rule)| (scope_query.clone(), rule.clone()))
+      .collect_vec()
+  }
+
+  /// Checks if the file at `path` belongs to a package (i.e. directory) with package rules.
+  fn has_package_rules(&self, path: &Path) -> bool {
+    self
+      .package_rules
+      .iter()
+      .any(|(directory, _, _, _)| path.parent() == Some(directory.as_path()))
+  }
+
   /// Get the compiled query for the `query_str` from the cache
   /// else compile it, add it to the cache and return it.
-  pub(crate) fn query(&mut self, query_str: &CGPattern) -> &Query {
+  /// Note that each query is compiled once per rule store (and its clones), since a rule store is specific to a language.
+  pub(crate) fn query(&self, query_str: &CGPattern) -> Arc<Query> {
+    self.get_or_compile_query(query_str, None)
+  }
+
+  /// Same as `query`, for the query of the `rule`.
+  /// Panics with the name of the rule, if its (instantiated) query cannot be compiled.
+  pub(crate) fn rule_query(&self, rule: &InstantiatedRule) -> Arc<Query> {
+    self.get_or_compile_query(&rule.query(), Some(rule.name()))
+  }
+
+  fn get_or_compile_query(&self, query_str: &CGPattern, rule_name: Option<String>) -> Arc<Query> {
+    let pattern = query_str.pattern();
+    if let Some(query) = self.rule_query_cache.read().unwrap().get(&pattern) {
+      return query.clone();
+    }
     self
       .rule_query_cache
-      .entry(query_str.pattern())
-      .or_insert_with(|| self.language.create_query(query_str.pattern()))
+      .write()
+      .unwrap()
+      .entry(pattern)
+      .or_insert_with_key(|p| {
+        let query = Query::new(*self.language.language(), p);
+        Arc::new(query.unwrap_or_else(|e| match &rule_name {
+          Some(name) => panic!("Could not parse the query for the rule {name:?} : {p:?} {e:?}"),
+          None => panic!("Could not parse the query : {p:?} {e:?}"),
+        }))
+      })
+      .clone()
+  }
+
+  /// Returns the number of queries compiled so far (i.e. the size of the cache).
+  pub(crate) fn number_of_compiled_queries(&self) -> usize {
+    self.rule_query_cache.read().unwrap().len()
+  }
+
+  /// Adds the global and package rules discovered by `other` (i.e. a clone of this rule store) to this rule store.
+  pub(crate) fn merge(&mut self, other: &RuleStore) {
+    for rule in other.global_rules() {
+      self.add_to_global_rules(rule);
+    }
+    for (directory, build_constraint, scope_query, rule) in other.package_rules() {
+      self.add_to_package_rules(directory, build_constraint, scope_query, rule);
+    }
   }
 
   // For the given scope level, get the ScopeQueryGenerator from the `scope_config.toml` file
@@ -105,6 +230,7 @@ impl RuleStore {
     let reg_x = self
       .global_rules()
       .iter()
+      .chain(self.package_rules().iter().map(|(_, _, _, r)| r))
       .flat_map(|r| r.substitutions().values())
       .sorted()
       //Remove duplicates
@@ -118,51 +244,86 @@ impl RuleStore {
     Regex::new(reg_x.as_str()).unwrap()
   }
 
-  /// Checks if any global rule has a hole
+  /// Checks if any global (or package) rule has a hole
   pub(crate) fn any_global_rules_has_holes(&self) -> bool {
-    self.global_rules().iter().any(|x| !x.holes().is_empty())
+    self
+      .global_rules()
+      .iter()
+      .chain(self.package_rules().iter().map(|(_, _, _, r)| r))
+      .any(|x| !x.holes().is_empty())
   }
 
-  /// Gets all the files from the code base that (i) have the language appropriate file extension, and (ii) contains the grep pattern.
-  /// Note that `WalkDir` traverses the directory with parallelism.
-  /// If all the global rules have no holes (i.e. we will have no grep patterns), we will try to find a match for each global rule in every file in the target.
+  /// Gets all the files from the code base that (i) have the language appropriate file extension, and (ii) contains the grep pattern
+  /// or belongs to a package with package rules (i.e. the sibling files are pulled in once a package rule is added).
+  /// Unless `respect_ignore` is disabled, the files ignored by the `.gitignore` (and `.piranhaignore`) files are skipped,
+  /// including the ones of the nested directories (and of the parents of the code base), even outside a git repository.
+  /// If all the global rules have no holes (i.e. we will have no grep patterns), or `prefilter` is disabled,
+  /// we will try to find a match for each global rule in every file in the target.
   pub(crate) fn get_relevant_files(
-    &self, path_to_codebase: &str, include: &Vec<Pattern>, exclude: &Vec<Pattern>,
+    &self, path_to_codebase: &str, include: &Vec<Pattern>, exclude: &Vec<Pattern>, prefilter: bool,
+    respect_ignore: bool,
   ) -> HashMap<PathBuf, String> {
     let _path_to_codebase = Path::new(path_to_codebase).to_path_buf();
 
     //If the path_to_codebase is a file, then execute piranha on it
     if _path_to_codebase.is_file() {
-      return HashMap::from_iter([(
-        _path_to_codebase.clone(),
-        read_file(&_path_to_codebase).unwrap(),
-      )]);
+      return read_relevant_file(&_path_to_codebase)
+        .map(|content| HashMap::from_iter([(_path_to_codebase.clone(), content)]))
+        .unwrap_or_default();
     }
 
-    let mut files: HashMap<PathBuf, String> = WalkDir::new(path_to_codebase)
-      // walk over the entire code base
-      .into_iter()
-      // ignore errors
-      .filter_map(|e| e.ok())
-      // only retain the included paths (if any)
-      .filter(|f| include.is_empty() || include.iter().any(|p| p.matches_path(&f.path())))
-      // filter out all excluded paths (if any)
-      .filter(|f| exclude.is_empty() || exclude.iter().all(|p| !p.matches_path(&f.path())))
-      // filter files with the desired extension
-      .filter(|de| self.language().can_parse(de))
-      // read the file
-      .map(|f| (f.path(), read_file(&f.path()).unwrap()))
-      .collect();
-
-    if self.any_global_rules_has_holes() {
-      let pattern = self.get_grep_heuristics();
-      files = files
-        .iter()
-        // Filter the files containing the desired regex pattern
-        .filter(|x| pattern.is_match(x.1.as_str()))
-        .map(|(x, y)| (x.clone(), y.clone()))
-        .collect();
+    let mut walker = WalkBuilder::new(path_to_codebase);
+    // The hidden files (e.g. `.git/`) are always skipped, while the ignore files are only honored with `respect_ignore`
+    // (outside a git repository as well)
+    walker
+      .hidden(true)
+      .parents(respect_ignore)
+      .ignore(respect_ignore)
+      .git_ignore(respect_ignore)
+      .git_global(respect_ignore)
+      .git_exclude(respect_ignore)
+      .require_git(false);
+    if respect_ignore {
+      walker.add_custom_ignore_filename(PIRANHA_IGNORE_FILE);
     }
+
+    let pattern =
+      (prefilter && self.any_global_rules_has_holes()).then(|| self.get_grep_heuristics());
+    // The files are read (and matched against the grep pattern) by the threads walking the code base, such that only
+    // the contents of the relevant files are retained
+    let (sender, receiver) = mpsc::channel();
+    // walk over the entire code base (except the ignored files)
+    walker.build_parallel().run(|| {
+      let sender = sender.clone();
+      let pattern = &pattern;
+      let _path_to_codebase = &_path_to_codebase;
+      Box::new(move |entry| {
+        // ignore errors
+        let path = match entry {
+          Ok(entry) => entry.into_path(),
+          Err(_) => return WalkState::Continue,
+        };
+        // only retain the paths in the scope of `include` and `exclude`, along with the files of the packages with
+        // package rules (whose usages are only reported for the paths out of scope), with the desired extension
+        if !(is_included(&path, _path_to_codebase, include, exclude)
+          || self.has_package_rules(&path))
+          || !self.language().can_parse(&path)
+        {
+          return WalkState::Continue;
+        }
+        if let Some(content) = read_relevant_file(&path) {
+          // Filter the files containing the desired regex pattern (or belonging to a package with package rules)
+          if pattern.as_ref().map_or(true, |p| {
+            p.is_match(&content) || self.has_package_rules(&path)
+          }) {
+            sender.send((path, content)).unwrap();
+          }
+        }
+        WalkState::Continue
+      })
+    });
+    drop(sender);
+    let files: HashMap<PathBuf, String> = receiver.into_iter().collect();
     debug!(
       "{}",
       format!("{} files will be analyzed.", files.len()).green()
@@ -170,3 +331,15 @@ impl RuleStore {
     files
   }
 }
+
+/// Reads the file at `path`, or skips it (with a warning) if it cannot be read.
+fn read_relevant_file(path: &PathBuf) -> Option<String> {
+  read_file(path)
+    .map_err(|e| {
+      warn!(
+        "Skipping the file {:?}, which could not be read - {}",
+        path, e
+      )
+    })
+    .ok()
+}
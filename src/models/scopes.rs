@@ -49,9 +49,10 @@ pub(crate) struct ScopeQueryGenerator {
 impl SourceCodeUnit {
   /// Generate a tree-sitter based query representing the scope of the previous edit.
   /// We generate these scope queries by matching the rules provided in `<lang>_scopes.toml`.
-  pub(crate) fn get_scope_query(
+  /// Returns `None` if no node enclosing the previous edit matches the scope.
+  pub(crate) fn find_scope_query(
     &self, scope_level: &str, start_byte: usize, end_byte: usize, rules_store: &mut RuleStore,
-  ) -> CGPattern {
+  ) -> Option<CGPattern> {
     let root_node = self.root_node();
     let mut changed_node = get_node_for_range(root_node, start_byte, end_byte);
     // Get the scope enclosing_nodes for `scope_level` from the `scope_config.toml`.
@@ -68,12 +69,12 @@ impl SourceCodeUnit {
         if let Some(p_match) = get_match_for_query(
           &changed_node,
           self.code(),
-          rules_store.query(m.enclosing_node()),
+          &rules_store.query(m.enclosing_node()),
           false,
         ) {
           // Generate the scope query for the specific context by substituting the
           // the tags with code snippets appropriately in the `generator` query.
-          return m.scope().instantiate(p_match.matches());
+          return Some(m.scope().instantiate(p_match.matches()));
         }
       }
       if let Some(parent) = changed_node.parent() {
@@ -82,7 +83,27 @@ impl SourceCodeUnit {
         break;
       }
     }
-    panic!("Could not create scope query for {scope_level:?}");
+    None
+  }
+
+  /// Same as `find_scope_query`, but panics if no node enclosing the previous edit matches the scope.
+  pub(crate) fn get_scope_query(
+    &self, scope_level: &str, start_byte: usize, end_byte: usize, rules_store: &mut RuleStore,
+  ) -> CGPattern {
+    self
+      .find_scope_query(scope_level, start_byte, end_byte, rules_store)
+      .unwrap_or_else(|| panic!("Could not create scope query for {scope_level:?}"))
+  }
+
+  /// Checks if the `scope_query` matches any node of this source code unit.
+  pub(crate) fn is_in_scope(&self, scope_query: &CGPattern, rules_store: &mut RuleStore) -> bool {
+    get_match_for_query(
+      &self.root_node(),
+      self.code(),
+      &rules_store.query(scope_query),
+      true,
+    )
+    .is_some()
   }
 }
 
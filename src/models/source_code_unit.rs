@@ -11,27 +11,43 @@ Copyright (c) 2023 Uber Technologies, Inc.
  limitations under the License.
 */
 use std::{
-  collections::{HashMap, VecDeque},
+  cell::{RefCell, RefMut},
+  collections::{HashMap, HashSet, VecDeque},
   path::{Path, PathBuf},
+  time::Instant,
 };
 
 use colored::Colorize;
 use itertools::Itertools;
-use log::{debug, error};
+use log::{debug, error, warn};
 
 use tree_sitter::{InputEdit, Node, Parser, Range, Tree};
 
 use crate::{
   models::capture_group_patterns::CGPattern,
-  models::rule_graph::{GLOBAL, PARENT},
+  models::rule_graph::{FUNCTION_METHOD, GLOBAL, PACKAGE, PARENT},
+  utilities::go_build_constraints::{get_build_constraint, BuildConstraint},
+  utilities::go_declarations::{get_declaration_fix, get_locally_declared_names},
+  utilities::go_imports::{get_referred_packages, get_unused_import},
+  utilities::go_keep_directives::{get_kept_ranges, overlaps_kept_range, KEEP_DIRECTIVE},
+  utilities::go_layout::normalize_go_layout,
   utilities::tree_sitter_utilities::{
-    get_match_for_query, get_node_for_range, get_replace_range, get_tree_sitter_edit,
-    number_of_errors,
+    get_all_matches_for_query, get_match_for_query, get_node_for_range, get_replace_range,
+    get_tree_sitter_edit, number_of_errors, position_for_offset,
   },
+  utilities::unresolved_usages::{get_enclosing_function, get_line, get_unresolved_usages},
 };
 
 use super::{
-  edit::Edit, matches::Match, piranha_arguments::PiranhaArguments, rule::InstantiatedRule,
+  default_configs::{FORMAT_OUTPUT, VARIABLE_NAME},
+  edit::Edit,
+  language::SupportedLanguage,
+  matches::Match,
+  piranha_arguments::PiranhaArguments,
+  piranha_output::{
+    DeletedFunction, InvalidRewrite, PiranhaStats, UnresolvedUsage, UnselectedImplementation,
+  },
+  rule::InstantiatedRule,
   rule_store::RuleStore,
 };
 use getset::{CopyGetters, Getters, MutGetters, Setters};
@@ -55,6 +71,10 @@ pub(crate) struct SourceCodeUnit {
   // The path to the source code.
   #[get = "pub"]
   path: PathBuf,
+  // The build constraint of the file (i.e. of its `//go:build` line and of its name, Go only), which determines
+  // the files of the directory that the package rules it adds apply to
+  #[get = "pub"]
+  build_constraint: Option<BuildConstraint>,
 
   // Rewrites applied to this source code unit
   #[get = "pub"]
@@ -64,9 +84,53 @@ pub(crate) struct SourceCodeUnit {
   #[get = "pub"]
   #[get_mut = "pub"]
   matches: Vec<(String, Match)>,
+  // Matches of the rewrite rules that were not applied, as they overlap a node kept with `//piranha:keep` (Go only)
+  #[get = "pub"]
+  suppressed_matches: Vec<(String, Match)>,
+  // Matches that were skipped, as their edit is not entirely within the `line_range` of the code snippet
+  #[get = "pub"]
+  out_of_range_matches: Vec<(String, Match)>,
+  // The functions deleted as they are not referred in their package anymore (see `delete_unreachable`, Go only)
+  #[get = "pub"]
+  #[get_mut = "pub"]
+  deleted_functions: Vec<DeletedFunction>,
+  // The struct types not selected anymore once the flag is cleaned up (see `delete_unselected_implementations`, Go only)
+  #[get = "pub"]
+  #[get_mut = "pub"]
+  unselected_implementations: Vec<UnselectedImplementation>,
+  // The usages of the stale flags left in the code once all the rules have been applied (see `record_unresolved_usages`)
+  #[get = "pub"]
+  unresolved_usages: Vec<UnresolvedUsage>,
   // Piranha Arguments passed by the user
   #[get = "pub"]
   piranha_arguments: PiranhaArguments,
+  // The edits applied to the code so far (in order), used to map the ranges back to the original content
+  applied_edits: Vec<InputEdit>,
+  // The scope queries of the functions rewritten since the `fixpoint_rules` were last applied (see `apply_fixpoint_rules`)
+  rewritten_functions: Vec<CGPattern>,
+  // The statistics of the processing of this source code unit (updated while matching, hence the interior mutability)
+  stats: RefCell<PiranhaStats>,
+  // When the processing of this source code unit started, to enforce the `per_file_timeout`
+  processing_start: Instant,
+  // Whether the processing exceeded the `per_file_timeout`, in which case the file is left unmodified
+  #[get = "pub"]
+  timed_out: bool,
+  // The number of syntax errors (i.e. `ERROR` and `MISSING` nodes) of the original content
+  original_number_of_errors: usize,
+  // The applied edit that introduced the syntax errors left in the code (if any)
+  erroneous_edit: Option<Edit>,
+  // The rewrite that introduced syntax errors, in which case all the rewrites are rolled back (i.e. the file is left
+  // unmodified)
+  #[get = "pub"]
+  invalid_rewrite: Option<InvalidRewrite>,
+  // Whether a rewritten function still changed after `max_fixpoint_iterations` iterations, in which case all the
+  // rewrites are rolled back (i.e. the file is left unmodified)
+  #[get = "pub"]
+  fixpoint_exceeded: bool,
+  // The rewrite that a second run would still apply to the rewritten code (with `verify_fixpoint`)
+  #[get = "pub"]
+  #[set = "pub(crate)"]
+  fixpoint_violation: Option<String>,
 }
 
 impl SourceCodeUnit {
@@ -74,19 +138,45 @@ impl SourceCodeUnit {
     parser: &mut Parser, code: String, substitutions: &HashMap<String, String>, path: &Path,
     piranha_arguments: &PiranhaArguments,
   ) -> Self {
+    let start = Instant::now();
     let ast = parser.parse(&code, None).expect("Could not parse code");
+    let mut stats = PiranhaStats::new();
+    stats.record_parse(start.elapsed());
+    let original_number_of_errors = number_of_errors(&ast.root_node());
+    let build_constraint =
+      if *piranha_arguments.language().supported_language() == SupportedLanguage::Go {
+        get_build_constraint(path, &code)
+      } else {
+        None
+      };
     let source_code_unit = Self {
       ast,
       original_content: code.to_string(),
       code,
       substitutions: substitutions.clone(),
       path: path.to_path_buf(),
+      build_constraint,
       rewrites: Vec::new(),
       matches: Vec::new(),
+      suppressed_matches: Vec::new(),
+      out_of_range_matches: Vec::new(),
+      deleted_functions: Vec::new(),
+      unselected_implementations: Vec::new(),
+      unresolved_usages: Vec::new(),
       piranha_arguments: piranha_arguments.clone(),
+      applied_edits: Vec::new(),
+      rewritten_functions: Vec::new(),
+      stats: RefCell::new(stats),
+      processing_start: start,
+      timed_out: false,
+      original_number_of_errors,
+      erroneous_edit: None,
+      invalid_rewrite: None,
+      fixpoint_exceeded: false,
+      fixpoint_violation: None,
     };
     // Panic if allow dirty ast is false and the tree is syntactically incorrect
-    if !piranha_arguments.allow_dirty_ast() && source_code_unit._number_of_errors() > 0 {
+    if !piranha_arguments.allow_dirty_ast() && original_number_of_errors > 0 {
       error!("{}: {}", "Syntax Error".red(), path.to_str().unwrap().red());
       _ = &source_code_unit._panic_for_syntax_error();
     }
@@ -98,18 +188,124 @@ impl SourceCodeUnit {
     self.ast.root_node()
   }
 
+  /// Returns the statistics of the processing of this source code unit so far
+  pub(crate) fn stats(&self) -> PiranhaStats {
+    self.stats.borrow().clone()
+  }
+
+  pub(crate) fn stats_mut(&self) -> RefMut<'_, PiranhaStats> {
+    self.stats.borrow_mut()
+  }
+
   /// Will apply the `rule` to all of its occurrences in the source code unit.
   fn apply_rule(
     &mut self, rule: InstantiatedRule, rules_store: &mut RuleStore, parser: &mut Parser,
     scope_query: &Option<CGPattern>,
   ) {
     loop {
-      if !self._apply_rule(rule.clone(), rules_store, parser, scope_query) {
+      if self.check_timeout(parser)
+        || !self._apply_rule(rule.clone(), rules_store, parser, scope_query)
+      {
         break;
       }
     }
   }
 
+  /// Checks if the processing of this source code unit exceeded the `per_file_timeout`, in which case it is reset to
+  /// its original content (i.e. the rewrites and the matches so far are discarded), and marked as timed out such that
+  /// it is not processed any further. The source code units rolled back otherwise are not processed any further either.
+  fn check_timeout(&mut self, parser: &mut Parser) -> bool {
+    if self.is_rolled_back() {
+      return true;
+    }
+    let timeout = match self.piranha_arguments.per_file_timeout() {
+      Some(timeout) => *timeout,
+      None => return false,
+    };
+    if self.processing_start.elapsed().as_secs_f64() <= timeout {
+      return false;
+    }
+    warn!(
+      "{}",
+      format!(
+        "The processing of {} exceeded the `per_file_timeout` ({timeout}s), it is left unmodified",
+        self.path.display()
+      )
+      .red()
+    );
+    let stats = self.stats();
+    *self = SourceCodeUnit::new(
+      parser,
+      self.original_content.clone(),
+      &self.substitutions.clone(),
+      &self.path.clone(),
+      &self.piranha_arguments.clone(),
+    );
+    self.stats = RefCell::new(stats);
+    self.timed_out = true;
+    true
+  }
+
+  /// Checks if this source code unit is left unmodified, as its processing timed out or its rewrites were rolled back
+  /// (as they introduced syntax errors, or did not reach a fixpoint).
+  pub(crate) fn is_rolled_back(&self) -> bool {
+    self.timed_out || self.invalid_rewrite.is_some() || self.fixpoint_exceeded
+  }
+
+  /// Checks that the rewrites did not introduce syntax errors, i.e. the code has no more `ERROR` or `MISSING` nodes than
+  /// the original content. Otherwise, this source code unit is reset to its original content (i.e. all its rewrites
+  /// and matches are discarded, like upon a timeout), and the edit that introduced the syntax errors is recorded as its
+  /// `invalid_rewrite`, such that it is not processed any further.
+  pub(crate) fn validate_rewrites(&mut self, parser: &mut Parser) -> bool {
+    if self.invalid_rewrite.is_some() {
+      return false;
+    }
+    if self._number_of_errors() <= self.original_number_of_errors {
+      return true;
+    }
+    // The syntax errors that were not introduced by an edit were introduced by the formatting (e.g. `formatter_command`)
+    let invalid_rewrite = match &self.erroneous_edit {
+      Some(edit) => InvalidRewrite::new(
+        self.path.display().to_string(),
+        edit.matched_rule().to_string(),
+        edit.original_snippet().to_string(),
+        edit.replacement_snippet().to_string(),
+      ),
+      None => InvalidRewrite::new(
+        self.path.display().to_string(),
+        FORMAT_OUTPUT.to_string(),
+        String::new(),
+        String::new(),
+      ),
+    };
+    warn!(
+      "{}",
+      format!(
+        "The rule `{}` produced syntactically incorrect code in {}, its rewrites are rolled back",
+        invalid_rewrite.rule(),
+        self.path.display()
+      )
+      .red()
+    );
+    self.roll_back(parser);
+    self.invalid_rewrite = Some(invalid_rewrite);
+    false
+  }
+
+  /// Resets this source code unit to its original content, i.e. all its rewrites and matches are discarded, along with
+  /// the substitutions they contributed (e.g. the global tags). The statistics of the processing are retained.
+  fn roll_back(&mut self, parser: &mut Parser) {
+    let stats = self.stats();
+    *self = SourceCodeUnit::new(
+      parser,
+      self.original_content.clone(),
+      &self.piranha_arguments.input_substitutions(),
+      &self.path.clone(),
+      &self.piranha_arguments.clone(),
+    );
+    self.stats = RefCell::new(stats);
+  }
+
   /// Applies the rule to the first match in the source code
   /// This is implements the main algorithm of piranha.
   /// Parameters:
@@ -142,8 +338,16 @@ impl SourceCodeUnit {
     // Add mappings to the substitution
     // Propagate each applied edit. The next rule will be applied relative to the application of this edit.
     if !rule.rule().is_match_only_rule() {
-      if let Some(edit) = self.get_edit(&rule, rule_store, scope_node, true) {
-        self.rewrites_mut().push(edit.clone());
+      let edit = self.get_edit(&rule, rule_store, scope_node, true);
+      for m in self.get_suppressed_matches(&rule, rule_store, scope_node) {
+        self.record_suppressed_match(rule.name(), &m);
+      }
+      for m in self.get_out_of_range_matches(&rule, rule_store, scope_node) {
+        self.record_out_of_range_match(rule.name(), &m);
+      }
+      if let Some(edit) = edit {
+        self.record_rewrite(&edit);
+        self.record_rewritten_function(&edit, rule_store);
         query_again = true;
 
         // Add all the (code_snippet, tag) mapping to the substitution table.
@@ -162,7 +366,16 @@ impl SourceCodeUnit {
     // The next edit will be applied relative to the identity edit.
     else {
       for m in self.get_matches(&rule, rule_store, scope_node, true) {
-        self.matches_mut().push((rule.name(), m.clone()));
+        // The remaining matches are discarded, once the propagation of a match timed out
+        if self.is_rolled_back() {
+          break;
+        }
+        // The matches out of the line range are neither recorded nor propagated
+        if !self.is_within_line_range(m.range()) {
+          self.record_out_of_range_match(rule.name(), &m);
+          continue;
+        }
+        self.record_match(rule.name(), &m);
 
         // In this scenario we pass the match and replace range as the range of the match `m`
         // This is equivalent to propagating an identity rule
@@ -201,16 +414,19 @@ impl SourceCodeUnit {
     let mut current_replace_range = replace_range;
 
     let mut current_rule = rule.name();
+    // The rules cascading from the cleanup of a flag (of the flags manifest) are instantiated with its substitutions
+    let current_flag = rule.flag().clone();
     let mut next_rules_stack: VecDeque<(CGPattern, InstantiatedRule)> = VecDeque::new();
     // Perform the parent edits, while queueing the Method and Class level edits.
     // let file_level_scope_names = [METHOD, CLASS];
     loop {
       debug!("Current Rule: {current_rule}");
       // Get all the (next) rules that could be after applying the current rule (`rule`).
-      let next_rules_by_scope = self
-        .piranha_arguments
-        .rule_graph()
-        .get_next(&current_rule, self.substitutions());
+      let next_rules_by_scope = self.piranha_arguments.rule_graph().get_next(
+        &current_rule,
+        &self.get_substitutions_for_flag(&current_flag),
+        &current_flag,
+      );
 
       debug!(
         "\n{}",
@@ -244,7 +460,7 @@ impl SourceCodeUnit {
         rules_store,
         &next_rules_by_scope[PARENT],
       ) {
-        self.rewrites_mut().push(edit.clone());
+        self.record_rewrite(&edit);
         debug!(
           "\n{}",
           format!(
@@ -260,6 +476,16 @@ impl SourceCodeUnit {
         // Add the (tag, code_snippet) mapping to substitution table.
         self.substitutions.extend(edit.p_match().matches().clone());
       } else {
+        // The cleanup of the parent is skipped when it would rewrite the code out of the line range
+        // (e.g. the deletion of the enclosing block)
+        if let Some(edit) = self.get_out_of_range_edit_for_context(
+          current_replace_range.start_byte,
+          current_replace_range.end_byte,
+          rules_store,
+          &next_rules_by_scope[PARENT],
+        ) {
+          self.record_out_of_range_match(edit.matched_rule().to_string(), edit.p_match());
+        }
         // No more parents found for cleanup
         break;
       }
@@ -272,6 +498,8 @@ impl SourceCodeUnit {
   }
 
   /// Adds the "Method" and "Class" scoped next rules to the queue.
+  /// The "Package" scoped rules are added to the package rules in the `rule_store` instead,
+  /// since they are applied to all the files of the package (in the next iteration).
   fn add_rules_to_stack(
     &mut self, next_rules_by_scope: &HashMap<String, Vec<InstantiatedRule>>,
     current_match_range: Range, rules_store: &mut RuleStore,
@@ -281,14 +509,27 @@ impl SourceCodeUnit {
       // Scope level is not "PArent" or "Global"
       if ![PARENT, GLOBAL].contains(&scope_level.as_str()) {
         for rule in rules {
-          let scope_query = self.get_scope_query(
+          // The previous edit may not be enclosed by the scope (e.g. a package level declaration is
+          // not enclosed by any method), in which case the rule cannot be applied.
+          let scope_query = match self.find_scope_query(
             scope_level,
             current_match_range.start_byte,
             current_match_range.end_byte,
             rules_store,
-          );
-          // Add Method and Class scoped rules to the queue
-          stack.push_front((scope_query, rule.clone()));
+          ) {
+            Some(scope_query) => scope_query,
+            None => {
+              debug!("The previous edit is not enclosed by any {scope_level} scope");
+              continue;
+            }
+          };
+          if scope_level == PACKAGE {
+            let directory = self.path().parent().unwrap_or_else(|| Path::new(""));
+            rules_store.add_to_package_rules(directory, &self.build_constraint, &scope_query, rule);
+          } else {
+            // Add Method and Class scoped rules to the queue
+            stack.push_front((scope_query, rule.clone()));
+          }
         }
       }
     }
@@ -299,7 +540,7 @@ impl SourceCodeUnit {
     // let mut scope_node = self.root_node();
     if let Some(query_str) = scope_query {
       // Apply the scope query in the source code and get the appropriate node
-      let tree_sitter_scope_query = rules_store.query(query_str);
+      let tree_sitter_scope_query = &rules_store.query(query_str);
       if let Some(p_match) = get_match_for_query(
         &self.root_node(),
         self.code(),
@@ -324,7 +565,98 @@ impl SourceCodeUnit {
     for rule in rules {
       self.apply_rule(rule.to_owned(), rules_store, parser, &scope_query)
     }
-    self.perform_delete_consecutive_new_lines();
+    self.apply_fixpoint_rules(rules_store, parser);
+    if !self.is_rolled_back() {
+      self.perform_delete_consecutive_new_lines();
+    }
+  }
+
+  /// Re-applies the `fixpoint_rules` of the language (e.g. the boolean simplifications, or the deletion of the unused
+  /// variables) to each rewritten function, until none of them changes the function anymore.
+  /// The rule graph only re-triggers the rules chained to the applied ones, so the matches enabled by a later edit
+  /// (e.g. a declaration left unused by the deletion of a branch) may be missed otherwise.
+  ///
+  /// If the function still changes after `max_fixpoint_iterations` iterations (that changed it), all the rewrites of this
+  /// source code unit are rolled back (like upon a timeout), such that it is not processed any further.
+  fn apply_fixpoint_rules(&mut self, rules_store: &mut RuleStore, parser: &mut Parser) {
+    let fixpoint_rules = self.get_fixpoint_rules();
+    if fixpoint_rules.is_empty() {
+      self.rewritten_functions.clear();
+      return;
+    }
+    let max_iterations = *self.piranha_arguments.max_fixpoint_iterations();
+    while let Some(scope_query) = self.rewritten_functions.pop() {
+      let mut iterations = 0;
+      // The function may have been deleted by the cleanup
+      while self.is_in_scope(&scope_query, rules_store) {
+        // The timeout is checked between the iterations (besides before each rule application)
+        if self.check_timeout(parser) {
+          return;
+        }
+        self.stats_mut().record_fixpoint_iteration();
+        let rewrites_count = self.rewrites.len();
+        for rule in &fixpoint_rules {
+          self.apply_rule(
+            rule.clone(),
+            rules_store,
+            parser,
+            &Some(scope_query.clone()),
+          );
+        }
+        if self.rewrites.len() == rewrites_count {
+          break;
+        }
+        iterations += 1;
+        if iterations > max_iterations {
+          #[rustfmt::skip]
+          warn!("{}", format!("The cleanup of a function of {} did not reach a fixpoint within {max_iterations} iterations, its rewrites are rolled back. Please increase `max_fixpoint_iterations`, if its edits keep cascading.", self.path.display()).red());
+          self.roll_back(parser);
+          self.fixpoint_exceeded = true;
+          return;
+        }
+      }
+      // The function is (re)recorded by its own cleanup
+      self.rewritten_functions.retain(|q| q != &scope_query);
+    }
+  }
+
+  /// Returns the `fixpoint_rules` of the language (or the rules of these groups) that are in the rule graph,
+  /// e.g. the rules with side effects are dropped unless `aggressive_simplification` is set.
+  fn get_fixpoint_rules(&self) -> Vec<InstantiatedRule> {
+    let rule_graph = self.piranha_arguments.rule_graph();
+    self
+      .piranha_arguments
+      .language()
+      .fixpoint_rules()
+      .iter()
+      .flat_map(|group| rule_graph.get_rules_for_group(group))
+      .unique()
+      .filter_map(|name| rule_graph.get_rule_named(name))
+      .map(|rule| InstantiatedRule::new(rule, &self.substitutions))
+      .collect()
+  }
+
+  /// Records the function enclosing the `edit` (if any), to which the `fixpoint_rules` are re-applied.
+  fn record_rewritten_function(&mut self, edit: &Edit, rules_store: &mut RuleStore) {
+    if self
+      .piranha_arguments
+      .language()
+      .fixpoint_rules()
+      .is_empty()
+    {
+      return;
+    }
+    let range = edit.p_match().range();
+    if let Some(scope_query) = self.find_scope_query(
+      FUNCTION_METHOD,
+      range.start_byte,
+      range.end_byte,
+      rules_store,
+    ) {
+      if !self.rewritten_functions.contains(&scope_query) {
+        self.rewritten_functions.push(scope_query);
+      }
+    }
   }
 
   /// Applies an edit to the source code unit
@@ -344,10 +676,16 @@ impl SourceCodeUnit {
     let number_of_errors = self._number_of_errors();
     self.ast.edit(&ts_edit);
     self._replace_file_contents_and_re_parse(&new_source_code, parser, true);
+    self.applied_edits.push(ts_edit);
+    self.stats_mut().record_edit();
 
-    // Panic if the number of errors increased after the edit
-    if self._number_of_errors() > number_of_errors {
-      self._panic_for_syntax_error();
+    // Track the edit that introduced the syntax errors left in the code, the rewrites are rolled back if these are still
+    // left once all the rules have been applied (see `validate_rewrites`)
+    let new_number_of_errors = self._number_of_errors();
+    if new_number_of_errors <= self.original_number_of_errors {
+      self.erroneous_edit = None;
+    } else if new_number_of_errors > number_of_errors && self.erroneous_edit.is_none() {
+      self.erroneous_edit = Some(edit.clone());
     }
     ts_edit
   }
@@ -380,13 +718,217 @@ impl SourceCodeUnit {
       None
     };
     // Create a new updated tree from the previous tree
+    let start = Instant::now();
     let new_tree = parser
       .parse(replacement_content, prev_tree)
       .expect("Could not generate new tree!");
+    self.stats_mut().record_parse(start.elapsed());
     self.ast = new_tree;
     self.code = replacement_content.to_string();
   }
 
+  /// Adds the `edit` to the rewrites (along with its range in the original content), before it is applied.
+  pub(crate) fn record_rewrite(&mut self, edit: &Edit) {
+    let mut edit = edit.clone();
+    let original_range = self.get_original_range(edit.p_match().range());
+    edit.p_match_mut().set_original_range(original_range);
+    self.rewrites.push(edit);
+  }
+
+  /// Adds the match `m` of the (match-only) rule `rule_name` to the matches (along with its range in the original content).
+  fn record_match(&mut self, rule_name: String, m: &Match) {
+    let mut m = m.clone();
+    m.set_original_range(self.get_original_range(m.range()));
+    self.matches.push((rule_name, m));
+  }
+
+  /// Adds the match `m` of the rewrite rule `rule_name` to the suppressed matches (see `get_kept_ranges`),
+  /// unless it was recorded in a previous iteration.
+  fn record_suppressed_match(&mut self, rule_name: String, m: &Match) {
+    let original_range = self.get_original_range(m.range());
+    let is_recorded = self
+      .suppressed_matches
+      .iter()
+      .any(|(name, x)| *name == rule_name && x.original_range() == original_range);
+    if !is_recorded {
+      let mut m = m.clone();
+      m.set_original_range(original_range);
+      self.suppressed_matches.push((rule_name, m));
+    }
+  }
+
+  /// Adds the match `m` of the rule `rule_name` to the matches skipped as they are out of the line range
+  /// (see `is_within_line_range`), unless it was recorded in a previous iteration.
+  fn record_out_of_range_match(&mut self, rule_name: String, m: &Match) {
+    let original_range = self.get_original_range(m.range());
+    let is_recorded = self
+      .out_of_range_matches
+      .iter()
+      .any(|(name, x)| *name == rule_name && x.original_range() == original_range);
+    if !is_recorded {
+      let mut m = m.clone();
+      m.set_original_range(original_range);
+      self.out_of_range_matches.push((rule_name, m));
+    }
+  }
+
+  /// Checks if the `range` (in the current code) is entirely within the `line_range` of the original code snippet,
+  /// i.e. it can be rewritten. A range ending at the start of a line (e.g. a deleted statement along with its new line)
+  /// ends on the previous line. Any range is within the line range when none is specified.
+  pub(crate) fn is_within_line_range(&self, range: Range) -> bool {
+    let (first_line, last_line) = match self.piranha_arguments.line_range() {
+      Some(line_range) => *line_range,
+      None => return true,
+    };
+    let original_range = self.get_original_range(range);
+    let mut last_row = original_range.end_point.row;
+    if original_range.end_point.column == 0 && original_range.end_byte > original_range.start_byte {
+      last_row -= 1;
+    }
+    first_line <= original_range.start_point.row + 1 && last_row + 1 <= last_line
+  }
+
+  /// Returns the matches of the (rewrite) `rule` within the scope, whose edit is not entirely within the line range.
+  fn get_out_of_range_matches(
+    &self, rule: &InstantiatedRule, rule_store: &mut RuleStore, scope_node: Node,
+  ) -> Vec<Match> {
+    if self.piranha_arguments.line_range().is_none() {
+      return vec![];
+    }
+    self
+      .get_edits(rule, rule_store, scope_node, true)
+      .filter(|edit| !self.is_within_line_range(edit.p_match().range()))
+      .map(|edit| edit.p_match().clone())
+      .collect()
+  }
+
+  /// Returns the ranges of the nodes kept with `//piranha:keep` (Go only), which are never rewritten.
+  pub(crate) fn kept_ranges(&self) -> Vec<Range> {
+    // The AST is only walked when the code contains a directive
+    if *self.piranha_arguments.language().supported_language() != SupportedLanguage::Go
+      || !self.code().contains(KEEP_DIRECTIVE)
+    {
+      return vec![];
+    }
+    get_kept_ranges(self.root_node(), self.code())
+  }
+
+  /// Returns the matches of the (rewrite) `rule` within the scope, that overlap a node kept with `//piranha:keep`.
+  fn get_suppressed_matches(
+    &self, rule: &InstantiatedRule, rule_store: &mut RuleStore, scope_node: Node,
+  ) -> Vec<Match> {
+    let kept_ranges = self.kept_ranges();
+    if kept_ranges.is_empty() {
+      return vec![];
+    }
+    self
+      .get_matches(rule, rule_store, scope_node, true)
+      .into_iter()
+      .filter(|m| overlaps_kept_range(m.range(), &kept_ranges))
+      .collect()
+  }
+
+  /// Records the matches of the `rules` as usages, without rewriting them (for a skipped file, see `skip_reason`).
+  /// The matches recorded in a previous iteration (over the global rules) are not recorded again.
+  pub(crate) fn record_usages(&mut self, rules: &[InstantiatedRule], rule_store: &mut RuleStore) {
+    for rule in rules.iter().filter(|r| !r.rule().is_dummy_rule()) {
+      for m in self.get_matches(rule, rule_store, self.root_node(), true) {
+        let is_recorded = self
+          .matches
+          .iter()
+          .any(|(rule_name, x)| *rule_name == rule.name() && x.range() == m.range());
+        if !is_recorded {
+          self.record_match(rule.name(), &m);
+        }
+      }
+    }
+  }
+
+  /// Records the usages of the stale `flags` left in the code (see `get_unresolved_usages`), once all the rules have
+  /// been applied. The variables seeded from a flag are the ones (i.e. the `variable_name` holes) of the rewrites of
+  /// its cleanup in this file.
+  pub(crate) fn record_unresolved_usages(&mut self, flags: &[String]) {
+    let path = self.path.to_str().unwrap_or_default().to_string();
+    let mut unresolved_usages = vec![];
+    for flag in flags {
+      // Without flags manifest, the rewrites are not attributed to the (single) flag
+      let variables = self
+        .rewrites
+        .iter()
+        .filter(|e| e.flag().as_ref().map_or(flags.len() == 1, |f| f == flag))
+        .filter_map(|e| {
+          e.p_match()
+            .matches()
+            .get(VARIABLE_NAME)
+            .or_else(|| e.substitutions().get(VARIABLE_NAME))
+        })
+        .unique()
+        .cloned()
+        .collect_vec();
+      for (node, variable) in get_unresolved_usages(self.root_node(), &self.code, flag, &variables)
+      {
+        let row = node.start_position().row;
+        unresolved_usages.push(UnresolvedUsage::new(
+          flag.to_string(),
+          path.clone(),
+          row + 1,
+          get_enclosing_function(node, &self.code),
+          get_line(&self.code, row),
+          variable,
+        ));
+      }
+    }
+    self.unresolved_usages = unresolved_usages;
+  }
+
+  /// Returns the first edit (if any) of the `rules` that would still rewrite this source code unit (within the scope),
+  /// i.e. the `rules` are not at a fixpoint (see `record_fixpoint_violations`).
+  pub(crate) fn get_pending_edit(
+    &self, rules: &[InstantiatedRule], rule_store: &mut RuleStore, scope_query: &Option<CGPattern>,
+  ) -> Option<Edit> {
+    let scope_node = self.get_scope_node(scope_query, rule_store);
+    rules
+      .iter()
+      .filter(|r| !r.rule().is_match_only_rule() && !r.rule().is_dummy_rule())
+      .find_map(|r| self.get_edit(r, rule_store, scope_node, true))
+  }
+
+  /// Maps the `range` (in the current code) back to the original content, by undoing the edits applied so far.
+  /// A position within the code inserted by an edit is mapped to the boundary of the code it replaced.
+  /// Note that the deletion of consecutive new lines (`delete_consecutive_new_lines`) is not accounted for.
+  fn get_original_range(&self, range: Range) -> Range {
+    let (mut start_byte, mut end_byte) = (range.start_byte, range.end_byte);
+    for edit in self.applied_edits.iter().rev() {
+      start_byte = get_offset_before_edit(edit, start_byte, edit.start_byte);
+      end_byte = get_offset_before_edit(edit, end_byte, edit.old_end_byte);
+    }
+    let original_content = self.original_content.as_bytes();
+    let end_byte = end_byte.max(start_byte).min(original_content.len());
+    let start_byte = start_byte.min(end_byte);
+    Range {
+      start_byte,
+      end_byte,
+      start_point: position_for_offset(original_content, start_byte),
+      end_point: position_for_offset(original_content, end_byte),
+    }
+  }
+
+  /// Returns the substitution table, overridden by the substitutions of the given flag of the flags manifest (if any),
+  /// e.g. its `treated` value (which may differ from the one of another flag).
+  fn get_substitutions_for_flag(&self, flag: &Option<String>) -> HashMap<String, String> {
+    let mut substitutions = self.substitutions().clone();
+    if let Some(flag) = flag {
+      substitutions.extend(self.piranha_arguments().get_flag_substitutions(flag));
+    }
+    substitutions
+  }
+
+  /// Adds the `substitutions` to the substitution table.
+  /// (e.g. the holes of a package rule, that was instantiated in another file of the package)
+  pub(crate) fn add_to_substitutions(&mut self, substitutions: &HashMap<String, String>) {
+    self.substitutions.extend(substitutions.clone());
+  }
+
   pub(crate) fn global_substitutions(&self) -> HashMap<String, String> {
     self
       .substitutions()
@@ -397,6 +939,183 @@ impl SourceCodeUnit {
   }
 }
 
+// Implements the post-processing of the rewritten Go code, once all the rules have been applied
+impl SourceCodeUnit {
+  /// Fixes the short variable declarations and the assignments (Go only), whose declaring occurrence has changed
+  /// because of the deleted (or split) declarations (see `get_declaration_fix`), i.e. `:=` declaring no new variable
+  /// is replaced with `=`, while `=` assigning a variable whose declaration was deleted is replaced with `:=`.
+  pub(crate) fn perform_declaration_cleanup(&mut self, parser: &mut Parser) {
+    if *self.piranha_arguments().language().supported_language() != SupportedLanguage::Go {
+      return;
+    }
+    let original_tree = parser
+      .parse(self.original_content(), None)
+      .expect("Could not parse the original content!");
+    let locally_declared_names =
+      get_locally_declared_names(original_tree.root_node(), self.original_content());
+    while let Some((range, replacement)) =
+      get_declaration_fix(self.root_node(), self.code(), &locally_declared_names)
+    {
+      let p_match = Match::new(
+        self.code()[range.start_byte..range.end_byte].to_string(),
+        range,
+        HashMap::new(),
+      );
+      let edit = Edit::new(
+        p_match,
+        replacement.to_string(),
+        "declaration_cleanup".to_string(),
+        HashMap::new(),
+        self.code(),
+      );
+      self.record_rewrite(&edit);
+      self.apply_edit(&edit, parser);
+    }
+  }
+
+  /// Deletes the imports whose package is not referred in the source code unit anymore (Go only), i.e. the ones
+  /// whose package was referred before the rewrite (see `get_unused_import`).
+  /// This is performed after all the rules have been applied, since Go fails to compile
+  /// when an imported package is not used.
+  pub(crate) fn perform_import_cleanup(&mut self, parser: &mut Parser) {
+    if !*self.piranha_arguments().cleanup_imports()
+      || *self.piranha_arguments().language().supported_language() != SupportedLanguage::Go
+    {
+      return;
+    }
+    let original_tree = parser
+      .parse(self.original_content(), None)
+      .expect("Could not parse the original content!");
+    let originally_referred_packages =
+      get_referred_packages(original_tree.root_node(), self.original_content());
+    let mut deleted_import = false;
+    while let Some(edit) = self.get_edit_for_unused_go_import(&originally_referred_packages) {
+      self.record_rewrite(&edit);
+      self.apply_edit(&edit, parser);
+      deleted_import = true;
+    }
+    if deleted_import {
+      self.perform_delete_consecutive_new_lines();
+    }
+  }
+
+  /// Normalizes the layout of the rewritten Go code (see `normalize_go_layout`), once all the rules have been applied.
+  pub(crate) fn perform_go_layout_normalization(&mut self, parser: &mut Parser) {
+    if !*self.piranha_arguments().normalize_go_layout()
+      || *self.piranha_arguments().language().supported_language() != SupportedLanguage::Go
+      || self.code() == self.original_content()
+    {
+      return;
+    }
+    let formatted_code = normalize_go_layout(self.code(), parser);
+    if formatted_code != *self.code() {
+      self._replace_file_contents_and_re_parse(&formatted_code, parser, false);
+    }
+  }
+
+  /// Deletes the contents of a Go file that does not declare anything anymore, i.e. only its package clause,
+  /// imports and comments are left (the file is then deleted upon `persist`).
+  /// Files with blank (`_`) imports are retained, since these are imported for their side effects.
+  pub(crate) fn perform_empty_go_file_cleanup(
+    &mut self, rule_store: &RuleStore, parser: &mut Parser,
+  ) {
+    if !*self.piranha_arguments().delete_file_if_empty()
+      || *self.piranha_arguments().language().supported_language() != SupportedLanguage::Go
+      || self.code().is_empty()
+      || !self.declares_nothing(rule_store)
+    {
+      return;
+    }
+    self.delete_contents("delete_empty_file", parser);
+  }
+
+  /// Checks if this Go file does not declare anything, i.e. only its package clause, imports and comments are left,
+  /// and it does not import any package for its side effects (i.e. with a blank `_` import).
+  pub(crate) fn declares_nothing(&self, rule_store: &RuleStore) -> bool {
+    let root = self.root_node();
+    let declares_nothing = root
+      .named_children(&mut root.walk())
+      .all(|n| ["package_clause", "import_declaration", "comment"].contains(&n.kind()));
+    if !declares_nothing {
+      return false;
+    }
+    let import_alias_query = rule_store.query(&CGPattern::new(
+      "(import_spec name: (_) @alias)".to_string(),
+    ));
+    !get_all_matches_for_query(
+      &root,
+      self.code().to_string(),
+      &import_alias_query,
+      true,
+      None,
+      None,
+    )
+    .iter()
+    .any(|m| m.matches()["alias"] == "_")
+  }
+
+  /// Deletes the whole contents of the file (which is then deleted upon `persist`), recording the rewrite as
+  /// performed by `rule_name`.
+  pub(crate) fn delete_contents(&mut self, rule_name: &str, parser: &mut Parser) {
+    let code = self.code().to_string();
+    let end_point = tree_sitter::Point {
+      row: code.matches('\n').count(),
+      column: code.len() - code.rfind('\n').map_or(0, |i| i + 1),
+    };
+    let range = tree_sitter::Range {
+      start_byte: 0,
+      end_byte: code.len(),
+      start_point: tree_sitter::Point { row: 0, column: 0 },
+      end_point,
+    };
+    let p_match = Match::new(code.clone(), range, HashMap::new());
+    let edit = Edit::new(
+      p_match,
+      String::new(),
+      rule_name.to_string(),
+      HashMap::new(),
+      &code,
+    );
+    self.record_rewrite(&edit);
+    self.apply_edit(&edit, parser);
+  }
+
+  /// Gets the edit that deletes the first unused import spec (see `get_unused_import`).
+  fn get_edit_for_unused_go_import(
+    &self, originally_referred_packages: &HashSet<String>,
+  ) -> Option<Edit> {
+    let (node_to_delete, package_name) =
+      get_unused_import(self.root_node(), self.code(), originally_referred_packages)?;
+    let p_match = Match::new(
+      node_to_delete
+        .utf8_text(self.code().as_bytes())
+        .unwrap()
+        .to_string(),
+      node_to_delete.range(),
+      HashMap::from([("package".to_string(), package_name)]),
+    );
+    Some(Edit::new(
+      p_match,
+      String::new(),
+      "cleanup_imports".to_string(),
+      HashMap::new(),
+      self.code(),
+    ))
+  }
+}
+
+/// Returns the `offset` (in the code after the `edit`) in the code before the `edit`.
+/// The offsets within the code inserted by the `edit` are mapped to `offset_within_edit`.
+fn get_offset_before_edit(edit: &InputEdit, offset: usize, offset_within_edit: usize) -> usize {
+  if offset <= edit.start_byte {
+    offset
+  } else if offset >= edit.new_end_byte {
+    offset - edit.new_end_byte + edit.old_end_byte
+  } else {
+    offset_within_edit
+  }
+}
+
 #[cfg(test)]
 #[path = "unit_tests/source_code_unit_test.rs"]
 mod source_code_unit_test;
@@ -0,0 +1,58 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use super::{parse_language, PiranhaLanguage};
+
+const CUSTOM_LANGUAGE_CONFIGS: &str = "test-resources/utility_tests/custom_language";
+
+fn parse_error(language: &str) -> String {
+  language.parse::<PiranhaLanguage>().unwrap_err()
+}
+
+#[test]
+fn test_parse_language_of_command_line() {
+  assert_eq!(parse_language("go").unwrap(), PiranhaLanguage::from("go"));
+  // The languages without built-in cleanup are not accepted by the command line
+  assert!(parse_language("strings")
+    .unwrap_err()
+    .contains("possible values: java, swift"));
+}
+
+#[test]
+fn test_custom_language_without_config() {
+  let error = parse_error(&format!("{CUSTOM_LANGUAGE_CONFIGS}/missing.toml"));
+  assert!(
+    error.starts_with("Could not read the language config"),
+    "{error}"
+  );
+}
+
+/// Checks that the grammar is looked up relative to the config
+#[test]
+fn test_custom_language_with_missing_grammar() {
+  let error = parse_error(&format!("{CUSTOM_LANGUAGE_CONFIGS}/missing_grammar.toml"));
+  assert!(error.starts_with("Could not load the grammar"), "{error}");
+  assert!(
+    error.contains("custom_language/libtree-sitter-missing.so"),
+    "{error}"
+  );
+}
+
+#[test]
+fn test_custom_language_with_wasm_grammar() {
+  let error = parse_error(&format!("{CUSTOM_LANGUAGE_CONFIGS}/wasm_grammar.toml"));
+  assert!(
+    error.contains("the wasm grammars are not supported"),
+    "{error}"
+  );
+}
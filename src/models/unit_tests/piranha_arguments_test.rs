@@ -11,12 +11,21 @@
  limitations under the License.
 */
 
+use std::fs;
+
+use clap::Parser;
+use glob::Pattern;
+use tempdir::TempDir;
+
 use crate::{
-  models::{default_configs::JAVA, language::PiranhaLanguage},
+  models::{
+    default_configs::{GO, JAVA},
+    language::PiranhaLanguage,
+  },
   tests::substitutions,
 };
 
-use super::PiranhaArgumentsBuilder;
+use super::{PiranhaArguments, PiranhaArgumentsBuilder, PiranhaSubcommand};
 
 #[test]
 #[should_panic(expected = "Invalid Piranha Argument. Missing `path_to_codebase` or `code_snippet`")]
@@ -41,3 +50,396 @@ fn piranha_argument_invalid_both_codebase_and_snippet() {
     .substitutions(substitutions! {"super_interface_name" => "SomeInterface"})
     .build();
 }
+
+#[test]
+fn piranha_argument_side_effect_unsafe_rules_only_when_aggressive() {
+  let rule_name = "simplify_side_effect_and_false".to_string();
+  let preserving = PiranhaArgumentsBuilder::default()
+    .code_snippet("class A { }".to_string())
+    .language(PiranhaLanguage::from(JAVA))
+    .build();
+  assert!(preserving.rule_graph().get_rule_named(&rule_name).is_none());
+
+  let aggressive = PiranhaArgumentsBuilder::default()
+    .code_snippet("class A { }".to_string())
+    .language(PiranhaLanguage::from(JAVA))
+    .aggressive_simplification(true)
+    .build();
+  assert!(aggressive.rule_graph().get_rule_named(&rule_name).is_some());
+}
+
+#[test]
+#[should_panic(
+  expected = "Invalid Piranha arguments. Please specify the `stale_flag_name` substitution when `cleanup_tests` is enabled."
+)]
+fn piranha_argument_invalid_cleanup_tests_without_stale_flag_name() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {"treated" => "true"})
+    .cleanup_tests(true)
+    .build();
+}
+
+#[test]
+#[should_panic(
+  expected = "Invalid Piranha arguments. Please enable `dry_run` when specifying the `diff_output`."
+)]
+fn piranha_argument_invalid_diff_output_without_dry_run() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .diff_output(Some("changes.patch".to_string()))
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "Invalid Piranha arguments. The `thread_count` should be at least 1.")]
+fn piranha_argument_invalid_zero_thread_count() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .thread_count(0)
+    .build();
+}
+
+#[test]
+#[should_panic(
+  expected = "Invalid Piranha arguments. The `max_fixpoint_iterations` should be at least 1."
+)]
+fn piranha_argument_invalid_max_fixpoint_iterations() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .max_fixpoint_iterations(0)
+    .build();
+}
+
+#[test]
+#[should_panic(
+  expected = "Invalid Piranha arguments. The `per_file_timeout` should be a positive number of seconds."
+)]
+fn piranha_argument_invalid_per_file_timeout() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .per_file_timeout(Some(0.0))
+    .build();
+}
+
+#[test]
+#[should_panic(
+  expected = "Invalid Piranha arguments. The `flag_comment_pattern` is not a valid regex"
+)]
+fn piranha_argument_invalid_flag_comment_pattern() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .flag_comment_pattern(Some("// FLAG: (new_checkout".to_string()))
+    .build();
+}
+
+#[test]
+#[should_panic(
+  expected = "Invalid Piranha arguments. Please enable `format_output` when specifying the `formatter_command`."
+)]
+fn piranha_argument_formatter_command_without_format_output() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .formatter_command(Some("gofmt".to_string()))
+    .build();
+}
+
+#[test]
+#[should_panic(
+  expected = "Invalid Piranha arguments. Please specify the `code_snippet` when specifying the `line_range`."
+)]
+fn piranha_argument_line_range_without_code_snippet() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/path".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .line_range(Some((1, 2)))
+    .build();
+}
+
+#[test]
+#[should_panic(
+  expected = "Invalid Piranha arguments. The `line_range` (3, 2) should start at line 1 or later"
+)]
+fn piranha_argument_invalid_line_range() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .line_range(Some((3, 2)))
+    .build();
+}
+
+/// The flag calls are replaced with their default value (rather than with `treated`) when `use_default_as_treatment` is set.
+#[test]
+fn piranha_argument_default_value_rules_only_when_use_default_as_treatment() {
+  let treated_rule = "replace_flag_function_call_with_boolean_literal".to_string();
+  let default_rule = "replace_flag_function_call_with_default_value".to_string();
+  let get_rule_graph = |use_default_as_treatment: bool| {
+    PiranhaArgumentsBuilder::default()
+      .code_snippet("package main".to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions! {
+        "stale_flag_name" => "new_checkout",
+        "treated" => "true",
+        "flag_functions" => "BoolValueCtx",
+        "flag_argument_position" => "1"
+      })
+      .use_default_as_treatment(use_default_as_treatment)
+      .build()
+      .rule_graph()
+      .clone()
+  };
+  let rule_graph = get_rule_graph(false);
+  assert!(rule_graph.get_rule_named(&treated_rule).is_some());
+  assert!(rule_graph.get_rule_named(&default_rule).is_none());
+
+  let rule_graph = get_rule_graph(true);
+  assert!(rule_graph.get_rule_named(&treated_rule).is_none());
+  assert!(rule_graph.get_rule_named(&default_rule).is_some());
+}
+
+/// Only the rules flipping the default value of the flag API calls are loaded with the `flip` treatment, where the
+/// `treated_value` overrides the `treated` substitution.
+#[test]
+fn piranha_argument_flip_default_rules_only_when_flip_treatment() {
+  let treated_rule = "replace_flag_function_call_with_boolean_literal".to_string();
+  let flip_rule = "flip_flag_function_call_default_value".to_string();
+  let get_arguments = |treatment: &str| {
+    PiranhaArgumentsBuilder::default()
+      .code_snippet("package main".to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions! {
+        "stale_flag_name" => "new_checkout",
+        "treated" => "false",
+        "flag_functions" => "BoolValueCtx",
+        "flag_argument_position" => "1"
+      })
+      .treatment(treatment.to_string())
+      .treated_value(Some(true))
+      .build()
+  };
+  let cleanup = get_arguments("cleanup");
+  assert!(cleanup.rule_graph().get_rule_named(&treated_rule).is_some());
+  assert!(cleanup.rule_graph().get_rule_named(&flip_rule).is_none());
+
+  let flip = get_arguments("flip");
+  assert!(flip.rule_graph().get_rule_named(&treated_rule).is_none());
+  assert!(flip.rule_graph().get_rule_named(&flip_rule).is_some());
+  assert_eq!(
+    flip.input_substitutions().get("treated"),
+    Some(&"true".to_string())
+  );
+}
+
+#[test]
+#[should_panic(
+  expected = "Invalid Piranha arguments. Please specify the `treated_value` (or the `treated` substitution) with the `flip` treatment."
+)]
+fn piranha_argument_invalid_flip_treatment_without_treated_value() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {"stale_flag_name" => "new_checkout"})
+    .treatment("flip".to_string())
+    .build();
+}
+
+/// The duplicate statements are only deleted with `dedupe_statements`, and the duplicate calls only when the
+/// `pure_functions` are substituted.
+#[test]
+fn piranha_argument_dedupe_rules_only_when_dedupe_statements() {
+  let assignment_rule = "delete_duplicate_assignment".to_string();
+  let call_rule = "delete_duplicate_pure_call_statement".to_string();
+  let get_rule_graph = |dedupe_statements: bool, substitutions: Vec<(String, String)>| {
+    PiranhaArgumentsBuilder::default()
+      .code_snippet("package main".to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions)
+      .dedupe_statements(dedupe_statements)
+      .build()
+      .rule_graph()
+      .clone()
+  };
+  let rule_graph = get_rule_graph(false, substitutions! {"pure_functions" => "log\\.Info"});
+  assert!(rule_graph.get_rule_named(&assignment_rule).is_none());
+  assert!(rule_graph.get_rule_named(&call_rule).is_none());
+
+  let rule_graph = get_rule_graph(true, substitutions! {"stale_flag_name" => "new_checkout"});
+  assert!(rule_graph.get_rule_named(&assignment_rule).is_some());
+  assert!(rule_graph.get_rule_named(&call_rule).is_none());
+
+  let rule_graph = get_rule_graph(true, substitutions! {"pure_functions" => "log\\.Info"});
+  assert!(rule_graph.get_rule_named(&assignment_rule).is_some());
+  assert!(rule_graph.get_rule_named(&call_rule).is_some());
+}
+
+#[test]
+fn piranha_argument_test_cleanup_rules_only_when_cleanup_tests() {
+  let rule_name = "delete_test_function_referring_stale_flag".to_string();
+  let default = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .build();
+  assert!(default.rule_graph().get_rule_named(&rule_name).is_none());
+
+  let cleanup_tests = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {"stale_flag_name" => "stale_flag"})
+    .cleanup_tests(true)
+    .build();
+  assert!(cleanup_tests
+    .rule_graph()
+    .get_rule_named(&rule_name)
+    .is_some());
+}
+
+#[test]
+fn piranha_argument_rule_templates_only_when_holes_are_substituted() {
+  let rule_name = "replace_method_chain_with_boolean_literal".to_string();
+  let missing_flag_methods = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {"stale_flag_name" => "stale_flag", "treated" => "true"})
+    .build();
+  assert!(missing_flag_methods
+    .rule_graph()
+    .get_rule_named(&rule_name)
+    .is_none());
+
+  let all_holes = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "flag_methods" => "Enabled|EnabledFor"
+    })
+    .build();
+  assert!(all_holes.rule_graph().get_rule_named(&rule_name).is_some());
+}
+
+#[test]
+#[should_panic(
+  expected = "Invalid Piranha arguments. The flag `new_checkout` is listed twice in the flags manifest"
+)]
+fn piranha_argument_invalid_flags_manifest_with_duplicate_flag() {
+  let temp_dir = TempDir::new("flags_manifest").unwrap();
+  let flags_file = temp_dir.path().join("flags.json");
+  fs::write(
+    &flags_file,
+    r#"[{"stale_flag_name": "new_checkout", "treated": "true"}, {"stale_flag_name": "new_checkout", "treated": "false"}]"#,
+  )
+  .unwrap();
+  let _ = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .flags_file(Some(flags_file.to_str().unwrap().to_string()))
+    .build();
+}
+
+#[test]
+fn piranha_argument_rule_templates_instantiated_for_each_flag() {
+  let temp_dir = TempDir::new("flags_manifest").unwrap();
+  let flags_file = temp_dir.path().join("flags.json");
+  fs::write(
+    &flags_file,
+    r#"[{"stale_flag_name": "new_checkout", "treated": "true"}, {"stale_flag_name": "legacy_cart", "treated": "false"}]"#,
+  )
+  .unwrap();
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .code_snippet("package main".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {"flag_methods" => "Enabled|EnabledFor"})
+    .flags_file(Some(flags_file.to_str().unwrap().to_string()))
+    .build();
+  // The rule template is retained, since its holes are substituted for (at least) a flag
+  assert!(piranha_arguments
+    .rule_graph()
+    .get_rule_named(&"replace_method_chain_with_boolean_literal".to_string())
+    .is_some());
+  let flag_substitutions = piranha_arguments.flag_substitutions();
+  assert_eq!(flag_substitutions.len(), 2);
+  let (flag, substitutions) = &flag_substitutions[1];
+  assert_eq!(flag.as_deref(), Some("legacy_cart"));
+  assert_eq!(substitutions["treated"], "false");
+  assert_eq!(substitutions["flag_methods"], "Enabled|EnabledFor");
+}
+
+/// The glob patterns passed to the command line are retained, e.g. to exclude the vendored code and the tests
+#[test]
+fn piranha_argument_include_exclude_from_cli() {
+  let parsed = PiranhaArguments::parse_from([
+    "polyglot_piranha",
+    "-c",
+    "test-resources/go/feature_flag/builtin_rules/include_exclude/input",
+    "-f",
+    "test-resources/go/feature_flag/builtin_rules/include_exclude/configurations",
+    "-l",
+    "go",
+    "-s",
+    "stale_flag_name=new_checkout",
+    "-s",
+    "treated=true",
+    "--include",
+    "**/*.go",
+    "--exclude",
+    "**/vendor/**",
+    "**/*_test.go",
+  ]);
+  let piranha_arguments = PiranhaArguments::from_parsed_cli(parsed);
+  assert_eq!(
+    piranha_arguments.include(),
+    &vec![Pattern::new("**/*.go").unwrap()]
+  );
+  assert_eq!(
+    piranha_arguments.exclude(),
+    &vec![
+      Pattern::new("**/vendor/**").unwrap(),
+      Pattern::new("**/*_test.go").unwrap()
+    ]
+  );
+}
+
+/// `graph` is parsed as a subcommand (taking its own arguments), rather than as the value of an argument of the cleanup
+#[test]
+fn piranha_argument_graph_subcommand_from_cli() {
+  let parsed = PiranhaArguments::parse_from([
+    "polyglot_piranha",
+    "graph",
+    "-l",
+    "go",
+    "-s",
+    "stale_flag_name=new_checkout",
+    "--output",
+    "graph.dot",
+  ]);
+  match parsed.subcommand() {
+    Some(PiranhaSubcommand::Graph(graph_arguments)) => {
+      assert_eq!(graph_arguments.output().as_deref(), Some("graph.dot"))
+    }
+    None => panic!("The graph subcommand was not parsed"),
+  }
+
+  // A code base directory named `graph`
+  let parsed = PiranhaArguments::parse_from([
+    "polyglot_piranha",
+    "-c",
+    "graph",
+    "-f",
+    "test-resources/go/feature_flag/builtin_rules/include_exclude/configurations",
+    "-l",
+    "go",
+  ]);
+  assert!(parsed.subcommand().is_none());
+  assert_eq!(parsed.path_to_codebase(), "graph");
+
+  // The arguments of the cleanup are still required without a subcommand
+  assert!(PiranhaArguments::try_parse_from(["polyglot_piranha", "-l", "go"]).is_err());
+}
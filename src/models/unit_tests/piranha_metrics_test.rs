@@ -0,0 +1,125 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::path::PathBuf;
+
+use itertools::Itertools;
+
+use crate::{
+  execute_piranha,
+  models::{
+    default_configs::GO, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
+  },
+  tests::substitutions,
+};
+
+use super::{get_metrics, CleanupMetrics};
+
+/// Checks that the `if` statements within the deleted functions are not counted as collapsed, and that the metrics of
+/// the files add up to the total.
+#[test]
+fn test_metrics_of_deleted_functions() {
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/delete_unreachable");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "new_checkout",
+      "treated_complement" => "old_checkout"
+    })
+    .delete_unreachable(true)
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  let metrics = get_metrics(&summaries, &piranha_arguments);
+
+  let total = metrics.total();
+  assert_eq!(*total.lines_added(), 1);
+  assert_eq!(*total.lines_removed(), 30);
+  assert_eq!(*total.net_lines(), -29);
+  assert_eq!(*total.if_branches_collapsed(), 1);
+  assert_eq!(*total.functions_deleted(), 4);
+  assert_eq!(*total.types_deleted(), 0);
+  assert_eq!(*total.imports_removed(), 2);
+  assert_eq!(*total.files_deleted(), 0);
+  assert_eq!(
+    total.rule_counts().values().sum::<usize>(),
+    summaries.iter().map(|s| s.rewrites().len()).sum::<usize>()
+  );
+
+  assert_eq!(
+    metrics.files().keys().collect_vec(),
+    vec!["checkout.go", "render.go"]
+  );
+  assert_eq!(*metrics.files()["render.go"].functions_deleted(), 4);
+  assert_eq!(*metrics.files()["render.go"].if_branches_collapsed(), 0);
+  assert_eq!(&sum(metrics.files().values()), total);
+  // Without `stale_flag_name` (or flags manifest), the changes are not attributed to any flag
+  assert!(metrics.flags().is_empty());
+}
+
+/// Checks that the changes of the files cleaned up for several flags (of the flags manifest) are attributed to the flags,
+/// and that the metrics of the flags add up to the total.
+#[test]
+fn test_metrics_per_flag_of_flags_manifest() {
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/flags_manifest");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "flag_methods" => "Enabled|EnabledFor"
+    })
+    .flags_file(Some(
+      _path
+        .join("configurations/flags.json")
+        .to_str()
+        .unwrap()
+        .to_string(),
+    ))
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  let metrics = get_metrics(&summaries, &piranha_arguments);
+
+  let total = metrics.total();
+  assert_eq!(*total.lines_added(), 3);
+  assert_eq!(*total.lines_removed(), 14);
+  assert_eq!(*total.if_branches_collapsed(), 3);
+  assert_eq!(*total.imports_removed(), 1);
+  assert_eq!(*total.functions_deleted(), 0);
+
+  assert_eq!(
+    metrics.flags().keys().collect_vec(),
+    vec!["legacy_cart", "new_checkout"]
+  );
+  // `beta.go` is only cleaned up for `legacy_cart`
+  let legacy_cart = &metrics.flags()["legacy_cart"];
+  assert!(*legacy_cart.lines_removed() > 0);
+  assert!(*legacy_cart.if_branches_collapsed() > 0);
+  assert!(*metrics.flags()["new_checkout"].if_branches_collapsed() > 0);
+  assert_eq!(&sum(metrics.flags().values()), total);
+}
+
+fn sum<'a>(metrics: impl Iterator<Item = &'a CleanupMetrics>) -> CleanupMetrics {
+  let mut total = CleanupMetrics::default();
+  for m in metrics {
+    total.merge(m);
+  }
+  total
+}
@@ -0,0 +1,145 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::path::PathBuf;
+
+use serde_json::Value;
+
+use crate::{
+  execute_piranha,
+  models::{
+    default_configs::{GENERATED_CODE_SKIP_REASON, GO, JAVA, SARIF_REPORT_FORMAT},
+    language::PiranhaLanguage,
+    piranha_arguments::PiranhaArgumentsBuilder,
+    rule_graph::RuleGraphBuilder,
+  },
+  piranha_rule,
+  tests::substitutions,
+};
+
+use super::{get_report, get_report_results, ReportResultKind};
+
+/// Checks that the ranges of the rewrites refer to the original content, even after the previous rewrites.
+#[test]
+fn test_report_results_refer_to_original_content() {
+  let rule = piranha_rule! {
+    name = "Append l",
+    query = "(
+  (variable_declarator value: (decimal_integer_literal) @value)
+  (#not-match? @value \"l|L\")
+  )",
+    replace_node = "value",
+    replace = "@valuel"
+  };
+  let code = "class A {\n  long a = 1;\n  long b = 22;\n}";
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .code_snippet(code.to_string())
+    .language(PiranhaLanguage::from(JAVA))
+    .rule_graph(RuleGraphBuilder::default().rules(vec![rule]).build())
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+
+  let results = get_report_results(&summaries, &piranha_arguments);
+  assert_eq!(results.len(), 2);
+  let second = &results[1];
+  assert_eq!(second.kind, ReportResultKind::Rewrite);
+  assert_eq!(second.rule, "Append l");
+  assert_eq!(second.original, "22");
+  assert_eq!(second.replacement, "22l");
+  assert_eq!(second.start_byte, code.find("22").unwrap());
+  assert_eq!(second.start_line, 3);
+}
+
+/// Checks that the SARIF report lists the deleted files separately, and that every result refers to a rule of the driver.
+#[test]
+fn test_sarif_report_with_deleted_file() {
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/init_cleanup");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    })
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+
+  let report: Value = serde_json::from_str(&get_report(
+    &summaries,
+    &piranha_arguments,
+    SARIF_REPORT_FORMAT,
+  ))
+  .unwrap();
+  assert_eq!(report["version"], "2.1.0");
+  let run = &report["runs"][0];
+  let rules = run["tool"]["driver"]["rules"].as_array().unwrap();
+  let results = run["results"].as_array().unwrap();
+  assert!(!results.is_empty());
+  for result in results {
+    let rule_index = result["ruleIndex"].as_u64().unwrap() as usize;
+    assert_eq!(rules[rule_index]["id"], result["ruleId"]);
+  }
+
+  let deleted_file = results
+    .iter()
+    .find(|r| r["properties"]["piranhaResultKind"] == "deleted_file")
+    .unwrap();
+  assert_eq!(deleted_file["kind"], "fail");
+  assert_eq!(
+    deleted_file["locations"][0]["physicalLocation"]["artifactLocation"]["uri"],
+    "register.go"
+  );
+}
+
+/// Checks that the usages in a generated file are reported (as `review` results), without any fix.
+#[test]
+fn test_sarif_report_with_skipped_usage() {
+  let rule = piranha_rule! {
+    name = "replace_is_enabled",
+    query = "((call_expression function: (_) @f) @call (#eq? @f \"isEnabled\"))",
+    replace_node = "call",
+    replace = "true"
+  };
+  let code =
+    "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage main\n\nvar a = isEnabled()\n";
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .code_snippet(code.to_string())
+    .language(PiranhaLanguage::from(GO))
+    .rule_graph(RuleGraphBuilder::default().rules(vec![rule]).build())
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries[0].content(), code);
+
+  let results = get_report_results(&summaries, &piranha_arguments);
+  assert_eq!(results.len(), 1);
+  assert_eq!(results[0].kind, ReportResultKind::SkippedUsage);
+  assert_eq!(results[0].original, "isEnabled()");
+  assert_eq!(
+    results[0].skip_reason.as_deref(),
+    Some(GENERATED_CODE_SKIP_REASON)
+  );
+
+  let report: Value = serde_json::from_str(&get_report(
+    &summaries,
+    &piranha_arguments,
+    SARIF_REPORT_FORMAT,
+  ))
+  .unwrap();
+  let result = &report["runs"][0]["results"][0];
+  assert_eq!(result["kind"], "review");
+  assert!(result.get("fixes").is_none());
+}
@@ -11,10 +11,18 @@ Copyright (c) 2023 Uber Technologies, Inc.
  limitations under the License.
 */
 
+use std::collections::HashSet;
+
 use crate::models::{
-  capture_group_patterns::CGPattern, filter::FilterBuilder, rule_graph::RuleGraphBuilder,
+  capture_group_patterns::CGPattern,
+  default_configs::JAVA,
+  filter::FilterBuilder,
+  language::PiranhaLanguage,
+  piranha_arguments::PiranhaArgumentsBuilder,
+  rule::RuleBuilder,
+  rule_graph::{RuleGraph, RuleGraphBuilder},
 };
-use crate::piranha_rule;
+use crate::{edges, piranha_rule};
 
 #[test]
 #[should_panic(
@@ -33,14 +41,16 @@ fn test_filter_bad_arg_at_most() {
 }
 
 #[test]
-#[should_panic(
-  expected = "Invalid Filter Argument. `contains` and `not_contains` cannot be set at the same time !!! Please use two filters instead."
-)]
-fn test_filter_bad_arguments_contains_not_contains() {
-  FilterBuilder::default()
+fn test_filter_contains_and_not_contains() {
+  let filter = FilterBuilder::default()
     .contains(CGPattern::new(String::from("(if_statement) @if_stmt")))
-    .not_contains(vec![CGPattern::new(String::from("(for_statement) @for"))])
+    .not_contains(vec![
+      CGPattern::new(String::from("(for_statement) @for")),
+      CGPattern::new(String::from("(while_statement) @while")),
+    ])
+    .at_least(2)
     .build();
+  assert_eq!(filter.not_contains().len(), 2);
 }
 
 #[test]
@@ -55,6 +65,28 @@ fn test_filter_bad_range() {
     .build();
 }
 
+#[test]
+#[should_panic(
+  expected = "Invalid Filter Argument. `at_least` should be less than or equal to `at_most` !!!"
+)]
+fn test_filter_explicit_default_at_least_above_at_most() {
+  FilterBuilder::default()
+    .contains(CGPattern::new(String::from("(if_statement) @if_stmt")))
+    .at_least(1)
+    .at_most(0)
+    .build();
+}
+
+#[test]
+fn test_filter_at_most_0_without_at_least() {
+  let filter = FilterBuilder::default()
+    .contains(CGPattern::new(String::from("(if_statement) @if_stmt")))
+    .at_most(0)
+    .build();
+  assert_eq!(*filter.at_least(), 1);
+  assert_eq!(*filter.at_most(), 0);
+}
+
 #[test]
 #[should_panic(expected = "Cannot parse")]
 fn test_filter_syntactically_incorrect_contains() {
@@ -118,3 +150,85 @@ fn test_filter_bad_arg_contains_n_sibling() {
     .sibling_count(2)
     .build();
 }
+
+fn get_rule_graph_with_problems() -> RuleGraph {
+  RuleGraphBuilder::default()
+    .rules(vec![
+      piranha_rule! {name = "seed", query = "(method_invocation) @m"},
+      RuleBuilder::default()
+        .name("unreachable".to_string())
+        .query(CGPattern::new("(if_statement) @i".to_string()))
+        .is_seed_rule(false)
+        .build()
+        .unwrap(),
+      piranha_rule! {name = "dummy_1"},
+      piranha_rule! {name = "dummy_2"},
+    ])
+    .edges(vec![
+      edges! {from = "seed", to = ["dummy_1" "dumy_2"], scope = "Parent"},
+      edges! {from = "dummy_1", to = ["dummy_2"], scope = "Parent"},
+      edges! {from = "dummy_2", to = ["dummy_1"], scope = "Parent"},
+    ])
+    .build()
+}
+
+#[test]
+fn test_rule_graph_validate_structure_reports_all_problems() {
+  let rule_graph = get_rule_graph_with_problems();
+  let err = rule_graph
+    .validate_structure(&rule_graph, &HashSet::new())
+    .unwrap_err();
+  assert!(err.starts_with("Incorrect Rule Graph - 3 problem(s) found"));
+  assert!(err.contains("refers to `dumy_2`, which is neither a rule nor a group"));
+  assert!(err
+    .contains("The rule `unreachable` is not a seed rule and is not reachable from any seed rule"));
+  assert!(err.contains("The dummy rules `dummy_1` -> `dummy_2` -> `dummy_1` form a cycle"));
+}
+
+#[test]
+fn test_rule_graph_validate_structure_accepts_dropped_built_in_names() {
+  let rule_graph = RuleGraphBuilder::default()
+    .rules(vec![
+      piranha_rule! {name = "seed", query = "(method_invocation) @m"},
+    ])
+    .edges(vec![
+      edges! {from = "seed", to = ["boolean_literal_cleanup"], scope = "Parent"},
+    ])
+    .build();
+  let built_in_names = HashSet::from(["boolean_literal_cleanup".to_string()]);
+  assert!(rule_graph
+    .validate_structure(&rule_graph, &built_in_names)
+    .is_ok());
+}
+
+#[test]
+#[should_panic(expected = "The rule `unreachable` is not a seed rule")]
+fn test_piranha_arguments_rejects_incorrect_rule_graph() {
+  PiranhaArgumentsBuilder::default()
+    .code_snippet("class A { }".to_string())
+    .language(PiranhaLanguage::from(JAVA))
+    .rule_graph(get_rule_graph_with_problems())
+    .build();
+}
+
+#[test]
+fn test_rule_graph_to_dot() {
+  let rule_graph = RuleGraphBuilder::default()
+    .rules(vec![
+      piranha_rule! {name = "replace_call", query = "(method_invocation) @m", groups = ["boolean_literal"]},
+      piranha_rule! {name = "cleanup"},
+    ])
+    .edges(vec![
+      edges! {from = "boolean_literal", to = ["cleanup"], scope = "Parent"},
+    ])
+    .build();
+  let expected = r#"digraph RuleGraph {
+  "replace_call" [shape=box, style="filled", fillcolor=lightblue];
+  "cleanup" [shape=box, style="filled,dashed", fillcolor=lightblue];
+  "boolean_literal" [shape=ellipse];
+  "boolean_literal" -> "replace_call" [style=dotted, dir=none];
+  "boolean_literal" -> "cleanup" [label="Parent"];
+}
+"#;
+  assert_eq!(rule_graph.to_dot(), expected);
+}
@@ -347,3 +347,36 @@ fn test_satisfies_filter_not_enclosing_node_negative() {
     |result| result,
   );
 }
+
+#[test]
+fn test_satisfies_filter_not_enclosing_node_with_enclosing_node_positive() {
+  // The `if_statement` is inside the enclosing method, hence the node is rejected
+  run_test_satisfies_filters_not_enclosing_node(
+    filter! {
+    enclosing_node = "(method_declaration) @md",
+    not_enclosing_node = "(if_statement) @if_stmt"},
+    |result| !result,
+  );
+}
+
+#[test]
+fn test_satisfies_filter_not_enclosing_node_outside_enclosing_node() {
+  // The class is outside the enclosing `if_statement`, but `not_enclosing_node` still rejects the node
+  run_test_satisfies_filters_not_enclosing_node(
+    filter! {
+    enclosing_node = "(if_statement) @if_stmt",
+    not_enclosing_node = "(class_declaration) @cd"},
+    |result| !result,
+  );
+}
+
+#[test]
+fn test_satisfies_filter_not_enclosing_node_with_contains() {
+  run_test_satisfies_filters_not_enclosing_node(
+    filter! {
+    enclosing_node = "(method_declaration) @md",
+    not_enclosing_node = "(while_statement) @while",
+    contains = "(if_statement) @if_stmt"},
+    |result| result,
+  );
+}
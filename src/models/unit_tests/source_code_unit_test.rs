@@ -16,7 +16,7 @@ use tree_sitter::{Parser, Point};
 use crate::{
   filter,
   models::{
-    default_configs::{JAVA, UNUSED_CODE_PATH},
+    default_configs::{GO, JAVA, PYTHON, UNUSED_CODE_PATH},
     filter::Filter,
     language::PiranhaLanguage,
     piranha_arguments::PiranhaArgumentsBuilder,
@@ -28,7 +28,7 @@ use crate::{
 };
 use {
   super::SourceCodeUnit,
-  crate::models::edit::Edit,
+  crate::models::{edit::Edit, matches::Match},
   std::{collections::HashMap, path::PathBuf},
   tree_sitter::Range,
 };
@@ -124,6 +124,38 @@ fn test_apply_edit_negative() {
   );
 }
 
+/// Tests the original and replacement snippets (i.e. the lines encompassing the match) of an edit.
+#[test]
+fn test_edit_snippets() {
+  let source_code = "class Test {\n  boolean isFlagTreated = isEnabled(\"flag\");\n}";
+  let matched_string = "isEnabled(\"flag\")";
+  let start_byte = source_code.find(matched_string).unwrap();
+  let end_byte = start_byte + matched_string.len();
+  // The second line starts at byte 13
+  let p_match = Match::new(
+    matched_string.to_string(),
+    range(start_byte, end_byte, 1, start_byte - 13, 1, end_byte - 13),
+    HashMap::new(),
+  );
+
+  let edit = Edit::new(
+    p_match,
+    "true".to_string(),
+    "replace_is_enabled".to_string(),
+    HashMap::new(),
+    source_code,
+  );
+
+  assert_eq!(
+    edit.original_snippet(),
+    "  boolean isFlagTreated = isEnabled(\"flag\");"
+  );
+  assert_eq!(
+    edit.replacement_snippet(),
+    "  boolean isFlagTreated = true;"
+  );
+}
+
 /// Tests for contains, at_least, and at_most
 
 fn run_test_satisfies_filters(
@@ -276,6 +308,124 @@ fn test_satisfies_filters_at_most_0_negative() {
   assert_eq!(contains_0, not_contains);
 }
 
+#[test]
+fn test_satisfies_filters_at_most_0_positive() {
+  let contains_0 = run_test_satisfies_filters(
+    filter! {
+        enclosing_node= "(method_declaration) @md",
+        contains= "(
+                    ((method_invocation name: (_) @name) @method)
+                    (#eq? @name \"hashCode\")
+                )",
+        at_most = 0
+    },
+    |result| result,
+  );
+  let not_contains = run_test_satisfies_filters(
+    filter! {
+        enclosing_node= "(method_declaration) @md",
+        not_contains= ["(
+                    ((method_invocation name: (_) @name) @method)
+                    (#eq? @name \"hashCode\")
+                )",]
+    },
+    |result| result,
+  );
+  assert_eq!(contains_0, not_contains);
+}
+
+/// Tests for several `not_contains` queries (all of which should be absent), along with `contains`
+
+#[test]
+fn test_satisfies_filters_several_not_contains_negative() {
+  // There is no `synchronized` block, but `equals` is called
+  run_test_satisfies_filters(
+    filter! {
+        enclosing_node= "(method_declaration) @md",
+        not_contains= [
+          "(synchronized_statement) @sync",
+          "(
+            ((method_invocation name: (_) @name) @method)
+            (#eq? @name \"equals\")
+          )",
+        ]
+    },
+    |result| !result,
+  );
+}
+
+#[test]
+fn test_satisfies_filters_several_not_contains_positive() {
+  run_test_satisfies_filters(
+    filter! {
+        enclosing_node= "(method_declaration) @md",
+        not_contains= [
+          "(synchronized_statement) @sync",
+          "(return_statement (null_literal)) @return_null",
+        ]
+    },
+    |result| result,
+  );
+}
+
+#[test]
+fn test_satisfies_filters_contains_and_not_contains_positive() {
+  run_test_satisfies_filters(
+    filter! {
+        enclosing_node= "(method_declaration) @md",
+        not_contains= [
+          "(synchronized_statement) @sync",
+          "(return_statement (null_literal)) @return_null",
+        ],
+        contains= "(
+                    ((method_invocation name: (_) @name) @method)
+                    (#eq? @name \"anotherFunction\")
+                )",
+        at_least = 2
+    },
+    |result| result,
+  );
+}
+
+#[test]
+fn test_satisfies_filters_contains_and_not_contains_negative() {
+  // `contains` is satisfied, but `equals` is called
+  run_test_satisfies_filters(
+    filter! {
+        enclosing_node= "(method_declaration) @md",
+        not_contains= [
+          "(synchronized_statement) @sync",
+          "(
+            ((method_invocation name: (_) @name) @method)
+            (#eq? @name \"equals\")
+          )",
+        ],
+        contains= "(
+                    ((method_invocation name: (_) @name) @method)
+                    (#eq? @name \"anotherFunction\")
+                )",
+        at_least = 2
+    },
+    |result| !result,
+  );
+  // The `not_contains` queries are absent, but `anotherFunction` is only called twice
+  run_test_satisfies_filters(
+    filter! {
+        enclosing_node= "(method_declaration) @md",
+        not_contains= [
+          "(synchronized_statement) @sync",
+          "(return_statement (null_literal)) @return_null",
+        ],
+        contains= "(
+                    ((method_invocation name: (_) @name) @method)
+                    (#eq? @name \"anotherFunction\")
+                )",
+        at_least = 3
+    },
+    |result| !result,
+  );
+}
+
 /// Tests for not contains
 #[test]
 fn test_satisfies_filters_not_contains_positive() {
@@ -699,3 +849,64 @@ fn test_satisfies_outermost_enclosing_node() {
 
   assert!(source_code_unit.is_satisfied(*node, &rule_positive, &HashMap::new(), &mut rule_store,));
 }
+
+/// Returns the identifiers (among `x`, `y` and `flag`) matched by a rule only accepting the r-values
+fn get_rvalue_identifiers(source_code: &str, language_name: &str) -> Vec<String> {
+  let rule = piranha_rule! {
+    name= "test",
+    query= "((identifier) @identifier (#match? @identifier \"^(x|y|flag)$\"))",
+    is_rvalue= true
+  };
+  let rule = InstantiatedRule::new(&rule, &HashMap::new());
+  let mut rule_store = RuleStore::default();
+  let mut parser = PiranhaLanguage::from(language_name).parser();
+  let source_code_unit =
+    SourceCodeUnit::default(source_code, &mut parser, language_name.to_string());
+  source_code_unit
+    .get_matches(&rule, &mut rule_store, source_code_unit.root_node(), true)
+    .iter()
+    .map(|m| m.matched_string().to_string())
+    .collect()
+}
+
+#[test]
+fn test_is_rvalue_go() {
+  let source_code = "package main
+
+func main() {
+\tx := flag
+\tx, y = flag, flag
+\tvar z = x
+}
+";
+  assert_eq!(
+    get_rvalue_identifiers(source_code, GO),
+    vec!["flag", "flag", "flag", "x"]
+  );
+}
+
+#[test]
+fn test_is_rvalue_java() {
+  let source_code = "class Test {
+    void foobar() {
+      x = flag;
+      boolean y = flag;
+      items[x] = y;
+    }
+  }";
+  assert_eq!(
+    get_rvalue_identifiers(source_code, JAVA),
+    vec!["flag", "flag", "x", "y"]
+  );
+}
+
+#[test]
+fn test_is_rvalue_python() {
+  let source_code = "x = flag
+x, y = flag, y
+";
+  assert_eq!(
+    get_rvalue_identifiers(source_code, PYTHON),
+    vec!["flag", "flag", "y"]
+  );
+}
@@ -12,14 +12,17 @@ Copyright (c) 2023 Uber Technologies, Inc.
 */
 
 use crate::execute_piranha;
+use crate::models::language::SupportedLanguage;
 use crate::models::piranha_arguments::PiranhaArguments;
 use crate::models::piranha_output::PiranhaOutputSummary;
+use crate::utilities::output_formatter::run_formatter_command;
 use crate::utilities::{eq_without_whitespace, read_file};
 
 use itertools::Itertools;
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::fs;
 use std::path::Path;
+use std::process::{Command, Stdio};
 use tempdir::TempDir;
 
 mod test_piranha_java;
@@ -30,6 +33,9 @@ mod test_piranha_swift;
 mod test_piranha_python;
 
 mod test_piranha_go;
+mod test_piranha_rust;
+mod test_piranha_php;
+mod test_piranha_ruby;
 mod test_piranha_ts;
 mod test_piranha_tsx;
 
@@ -107,6 +113,16 @@ fn execute_piranha_and_check_result(
 
   assert_eq!(output_summaries.len(), files_changed);
 
+  // The layout of the rewritten Go files is normalized
+  let check_go_layout = *piranha_arguments.language().supported_language() == SupportedLanguage::Go
+    && *piranha_arguments.normalize_go_layout();
+  let rewritten_files: HashSet<String> = output_summaries
+    .iter()
+    .filter(|s| !s.rewrites().is_empty())
+    .filter_map(|s| Path::new(s.path()).file_name())
+    .map(|f| f.to_string_lossy().to_string())
+    .collect();
+
   let mut all_files_match = true;
 
   let count_files = |path: &Path| {
@@ -134,6 +150,13 @@ fn execute_piranha_and_check_result(
       let expected_file_path = path_to_expected.join(file_name);
       let expected_content = read_file(&expected_file_path).unwrap();
 
+      // The expected content of the rewritten files is checked against `gofmt` itself,
+      // since the comparison ignores the whitespace
+      if check_go_layout && rewritten_files.contains(file_name.to_str().unwrap()) {
+        assert_go_layout_normalized(&cb_content);
+        assert_gofmt_clean(&expected_content);
+      }
+
       if (ignore_whitespace && eq_without_whitespace(&cb_content, &expected_content))
         || (cb_content
           .trim_end()
@@ -148,6 +171,72 @@ fn execute_piranha_and_check_result(
   }
 
   assert!(all_files_match);
+
+  // The cleanup is a fixpoint, i.e. running Piranha again on the rewritten code base does not change it
+  if !*piranha_arguments.dry_run() {
+    assert_second_run_rewrites_nothing(piranha_arguments);
+  }
+}
+
+/// Runs Piranha again on the (rewritten) code base, and checks that it rewrites nothing.
+/// The matches (e.g. of the match-only rules) are reported again, hence only the rewrites are checked.
+fn assert_second_run_rewrites_nothing(piranha_arguments: &PiranhaArguments) {
+  for summary in execute_piranha(piranha_arguments) {
+    assert!(
+      summary.rewrites().is_empty(),
+      "The second run rewrote {} :\n{}",
+      summary.path(),
+      summary.content()
+    );
+  }
+}
+
+/// Checks that the Go code has no trailing whitespace, no leading or consecutive blank lines,
+/// and is indented with tabs (outside the block comments).
+fn assert_go_layout_normalized(content: &str) {
+  if content.is_empty() {
+    return;
+  }
+  assert!(
+    !content.starts_with('\n') && content.ends_with('\n') && !content.ends_with("\n\n"),
+    "The leading and trailing blank lines are not deleted :\n{content}"
+  );
+  let mut in_block_comment = false;
+  let mut previous_line_is_blank = false;
+  for line in content.lines() {
+    assert_eq!(line.trim_end(), line, "Trailing whitespace :\n{content}");
+    assert!(
+      !(line.is_empty() && previous_line_is_blank),
+      "Consecutive blank lines :\n{content}"
+    );
+    assert!(
+      in_block_comment || !line.starts_with(' '),
+      "The line `{line}` is not indented with tabs :\n{content}"
+    );
+    in_block_comment = (in_block_comment || line.contains("/*")) && !line.contains("*/");
+    previous_line_is_blank = line.is_empty();
+  }
+}
+
+/// Checks that `gofmt` leaves the Go code as is.
+/// The check is skipped when `gofmt` is not installed.
+fn assert_gofmt_clean(content: &str) {
+  if !is_gofmt_installed() {
+    return;
+  }
+  match run_formatter_command("gofmt", content) {
+    Ok(formatted) => assert_eq!(formatted, content, "The code is not formatted by `gofmt`"),
+    Err(e) => panic!("{e}"),
+  }
+}
+
+/// Checks if `gofmt` can be run.
+fn is_gofmt_installed() -> bool {
+  Command::new("gofmt")
+    .arg("-l")
+    .stdin(Stdio::null())
+    .output()
+    .is_ok()
 }
 
 /// This macro creates a new match test case.
@@ -11,11 +11,42 @@ Copyright (c) 2023 Uber Technologies, Inc.
  limitations under the License.
 */
 
-use std::collections::HashMap;
+use std::{
+  collections::{HashMap, HashSet},
+  fs,
+  path::{Path, PathBuf},
+  time::Instant,
+};
 
-use super::{create_match_tests, create_rewrite_tests, substitutions};
+use glob::Pattern;
+use itertools::Itertools;
+use log::info;
+use tempdir::TempDir;
 
-use crate::models::default_configs::GO;
+use super::{
+  copy_folder_to_temp_dir, create_match_tests, create_rewrite_tests,
+  execute_piranha_and_check_result, substitutions,
+};
+
+use crate::{
+  edges, execute_piranha, execute_piranha_on_content, execute_piranha_with_stats,
+  models::{
+    capture_group_patterns::CGPattern,
+    default_configs::{
+      default_thread_count, DELETE_EMPTY_PACKAGE_FILE, DELETE_UNREACHABLE_FUNCTION,
+      GENERATED_CODE_SKIP_REASON, GO, INVALID_REWRITE_SKIP_REASON, KEPT_FILE_SKIP_REASON,
+      OUT_OF_SCOPE_SKIP_REASON, TIMEOUT_SKIP_REASON, VENDORED_CODE_SKIP_REASON,
+    },
+    language::PiranhaLanguage,
+    piranha_arguments::PiranhaArgumentsBuilder,
+    piranha_output::PiranhaOutputSummary,
+    rule::RuleBuilder,
+    rule_graph::RuleGraphBuilder,
+  },
+  piranha_rule,
+  utilities::{eq_without_whitespace, parse_glob_pattern},
+  Piranha,
+};
 
 create_match_tests! {
   GO,
@@ -31,14 +62,1885 @@ create_rewrite_tests! {
       "false_flag_name" => "false",
       "nil_flag_name" => "nil"
     };
+  test_builtin_boolean_literal_cleanup:  "feature_flag/builtin_rules/boolean_literal_cleanup", 1,
+    substitutions= substitutions! {
+      "true_flag_name" => "true",
+      "false_flag_name" => "false",
+      "nil_flag_name" => "nil"
+    };
+  test_builtin_for_cleanup: "feature_flag/builtin_rules/for_cleanup", 1,
+    substitutions= substitutions! {
+      "true_flag_name" => "true",
+      "false_flag_name" => "false",
+      "nil_flag_name" => "nil"
+    };
+  test_builtin_empty_block_cleanup: "feature_flag/builtin_rules/empty_block_cleanup", 1,
+    substitutions= substitutions! {
+      "true_flag_name" => "true",
+      "false_flag_name" => "false",
+      "nil_flag_name" => "nil"
+    };
+  test_builtin_nested_flags: "feature_flag/builtin_rules/nested_flags", 1,
+    substitutions= substitutions! {
+      "true_flag_name" => "true",
+      "false_flag_name" => "false",
+      "nil_flag_name" => "nil"
+    };
+  test_builtin_short_circuit_preserve_side_effects: "feature_flag/builtin_rules/short_circuit_preserve_side_effects", 1,
+    substitutions= substitutions! {
+      "true_flag_name" => "true",
+      "false_flag_name" => "false",
+      "nil_flag_name" => "nil"
+    };
+  test_builtin_short_circuit_aggressive: "feature_flag/builtin_rules/short_circuit_aggressive", 1,
+    substitutions= substitutions! {
+      "true_flag_name" => "true",
+      "false_flag_name" => "false",
+      "nil_flag_name" => "nil"
+    }, aggressive_simplification = true;
   test_builtin_statement_cleanup: "feature_flag/builtin_rules/statement_cleanup", 1,
     substitutions= substitutions! {
       "treated" => "true",
       "treated_complement" => "false"
     }, cleanup_comments = true;
+  test_builtin_declaration_cleanup: "feature_flag/builtin_rules/declaration_cleanup", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_switch_cleanup: "feature_flag/builtin_rules/switch_cleanup", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_string_switch_cleanup: "feature_flag/builtin_rules/string_switch_cleanup", 1,
+    substitutions= substitutions! {
+      "string_flag_name" => "rollout_mode",
+      "string_treated" => "on"
+    };
+  test_builtin_string_comparison_cleanup: "feature_flag/builtin_rules/string_comparison_cleanup", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "rollout_mode",
+      "string_treated" => "full",
+      "string_flag_functions" => "StrValue",
+      "flag_argument_position" => "0"
+    };
+  test_builtin_string_else_if_chain_cleanup: "feature_flag/builtin_rules/string_else_if_chain_cleanup", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "rollout_mode",
+      "string_treated" => "full",
+      "string_flag_functions" => "StrValue",
+      "flag_argument_position" => "0"
+    };
+  test_builtin_named_result_cleanup: "feature_flag/builtin_rules/named_result_cleanup", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_if_assignment_cleanup: "feature_flag/builtin_rules/if_assignment_cleanup", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_unused_imports: "feature_flag/builtin_rules/unused_imports", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_imports = true;
+  test_builtin_unused_imports_disabled: "feature_flag/builtin_rules/unused_imports_disabled", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_imports = false;
+  test_builtin_package_variable_cleanup: "feature_flag/builtin_rules/package_variable_cleanup", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_imports = true;
+  test_builtin_boolean_parameter_cleanup: "feature_flag/builtin_rules/boolean_parameter_cleanup", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_imports = true;
+  test_builtin_boolean_field_cleanup: "feature_flag/builtin_rules/boolean_field_cleanup", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_imports = true;
+  test_builtin_composite_literal_cleanup: "feature_flag/builtin_rules/composite_literal_cleanup", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_imports = true;
+  test_builtin_variable_assignment_cleanup: "feature_flag/builtin_rules/variable_assignment_cleanup", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_imports = true;
+  test_builtin_wrapper_function_cleanup: "feature_flag/builtin_rules/wrapper_function_cleanup", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_imports = true;
+  test_builtin_closure_cleanup: "feature_flag/builtin_rules/closure_cleanup", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_imports = true;
+  test_builtin_init_cleanup: "feature_flag/builtin_rules/init_cleanup", 3,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_imports = true;
+  test_builtin_method_chain_cleanup: "feature_flag/builtin_rules/method_chain_cleanup", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true",
+      "flag_methods" => "Enabled|EnabledFor"
+    };
+  test_builtin_comment_cleanup: "feature_flag/builtin_rules/comment_cleanup", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true"
+    }, cleanup_comments = true, cleanup_imports = true;
+  test_builtin_flag_comment_cleanup: "feature_flag/builtin_rules/flag_comment_cleanup", 1,
+    substitutions= substitutions! {
+      "flag_name" => "new_checkout",
+      "treated" => "false"
+    }, flag_comment_pattern = Some("^(// FLAG: new_checkout|/\\* FLAG: new_checkout \\*/)$".to_string()), cleanup_imports = true;
+  test_builtin_preserve_leading_comments: "feature_flag/builtin_rules/preserve_leading_comments", 1,
+    substitutions= substitutions! {
+      "flag_name" => "new_checkout",
+      "treated" => "false"
+    }, preserve_leading_comments = true, cleanup_imports = true;
+  test_builtin_test_cleanup: "feature_flag/builtin_rules/test_cleanup", 2,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true"
+    }, cleanup_tests = true, cleanup_imports = true;
+  test_builtin_flatten_else: "feature_flag/builtin_rules/flatten_else", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, flatten_else = true, cleanup_imports = true;
+  test_builtin_delete_unreachable: "feature_flag/builtin_rules/delete_unreachable", 2,
+    substitutions= substitutions! {
+      "treated" => "new_checkout",
+      "treated_complement" => "old_checkout"
+    }, delete_unreachable = true, cleanup_imports = true;
+  test_builtin_build_constraints: "feature_flag/builtin_rules/build_constraints", 3,
+    substitutions= substitutions! {
+      "treated" => "new_checkout",
+      "treated_complement" => "old_checkout"
+    }, delete_unreachable = true, cleanup_imports = true;
+  test_builtin_delete_unselected_implementations: "feature_flag/builtin_rules/delete_unselected_implementations", 2,
+    substitutions= substitutions! {
+      "treated" => "new_checkout",
+      "treated_complement" => "old_checkout"
+    }, delete_unselected_implementations = true, cleanup_imports = true;
+  test_builtin_delete_empty_functions: "feature_flag/builtin_rules/delete_empty_functions", 1,
+    substitutions= substitutions! {
+      "treated" => "new_checkout",
+      "treated_complement" => "old_checkout"
+    }, delete_empty_functions = true, cleanup_imports = true;
+  test_builtin_delete_empty_functions_package: "feature_flag/builtin_rules/delete_empty_functions_package", 1,
+    substitutions= substitutions! {
+      "treated" => "new_checkout",
+      "treated_complement" => "old_checkout"
+    }, delete_empty_functions = true, cleanup_imports = true;
+  test_builtin_if_initializer_cleanup: "feature_flag/builtin_rules/if_initializer_cleanup", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_import_cleanup: "feature_flag/builtin_rules/import_cleanup", 4,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_imports = true;
+  test_builtin_environment_flag_cleanup: "feature_flag/builtin_rules/environment_flag_cleanup", 2,
+    substitutions= substitutions! {
+      "env_var_name" => "ENABLE_NEW_PATH",
+      "treated" => "true"
+    }, cleanup_imports = true;
+  test_builtin_flags_manifest: "feature_flag/builtin_rules/flags_manifest", 2,
+    substitutions= substitutions! {
+      "flag_methods" => "Enabled|EnabledFor"
+    }, flags_file = Some("test-resources/go/feature_flag/builtin_rules/flags_manifest/configurations/flags.json".to_string()), cleanup_imports = true;
+  test_builtin_context_flag_cleanup: "feature_flag/builtin_rules/context_flag_cleanup/treated", 1,
+    substitutions= substitutions! {
+      "flag_functions" => "BoolValueCtx",
+      "string_flag_functions" => "StrValueCtx",
+      "flag_argument_position" => "1"
+    }, flags_file = Some("test-resources/go/feature_flag/builtin_rules/context_flag_cleanup/treated/configurations/flags.json".to_string());
+  test_builtin_context_flag_cleanup_default_as_treatment: "feature_flag/builtin_rules/context_flag_cleanup/default_as_treatment", 1,
+    substitutions= substitutions! {
+      "flag_functions" => "BoolValueCtx",
+      "string_flag_functions" => "StrValueCtx",
+      "flag_argument_position" => "1"
+    }, flags_file = Some("test-resources/go/feature_flag/builtin_rules/context_flag_cleanup/default_as_treatment/configurations/flags.json".to_string()),
+    use_default_as_treatment = true;
+  test_builtin_generic_flag_helper: "feature_flag/builtin_rules/generic_flag_helper", 1,
+    substitutions= substitutions! {
+      "generic_flag_functions" => "Flag",
+      "flag_argument_position" => "0"
+    }, flags_file = Some("test-resources/go/feature_flag/builtin_rules/generic_flag_helper/configurations/flags.json".to_string());
+  test_builtin_flag_registry: "feature_flag/builtin_rules/flag_registry", 2,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true",
+      "flag_registries" => "experiments"
+    };
+  test_builtin_flip_treatment: "feature_flag/builtin_rules/flip_treatment", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "flag_functions" => "BoolValueCtx",
+      "flag_argument_position" => "1",
+      "flag_function_without_default" => "BoolValue",
+      "flag_function_with_default" => "BoolValueWithDefault"
+    },
+    treatment = "flip".to_string(),
+    treated_value = Some(true);
+  test_builtin_mock_expectation_cleanup: "feature_flag/builtin_rules/mock_expectation_cleanup", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout"
+    }, cleanup_imports = true;
+  test_builtin_keep_directive: "feature_flag/builtin_rules/keep_directive", 2,
+    substitutions= substitutions! {
+      "treated" => "stale_flag",
+      "treated_complement" => "other_flag"
+    };
+  test_builtin_unresolved_usages: "feature_flag/builtin_rules/unresolved_usages", 2,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true",
+      "flag_methods" => "Enabled|EnabledFor"
+    }, cleanup_imports = true;
+  test_builtin_flag_name_constant_cleanup: "feature_flag/builtin_rules/flag_name_constant_cleanup", 2,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true"
+    };
+  test_builtin_multi_value_return_cleanup: "feature_flag/builtin_rules/multi_value_return_cleanup", 2,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_path",
+      "treated" => "true"
+    };
+  test_builtin_cached_getter_cleanup: "feature_flag/builtin_rules/cached_getter_cleanup", 3,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_path",
+      "treated" => "true"
+    }, cleanup_imports = true;
+  test_builtin_fixpoint_cleanup: "feature_flag/builtin_rules/fixpoint_cleanup", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true"
+    }, cleanup_imports = true;
+  test_builtin_blank_assignment_cleanup: "feature_flag/builtin_rules/blank_assignment_cleanup", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true"
+    };
+  test_builtin_simplify_boolean_return: "feature_flag/builtin_rules/simplify_boolean_return", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true"
+    }, simplify_boolean_return = true;
+  test_builtin_dedupe_statements: "feature_flag/builtin_rules/dedupe_statements", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true",
+      "pure_functions" => "log\\.Info"
+    }, dedupe_statements = true;
+  test_builtin_else_if_cleanup: "feature_flag/builtin_rules/else_if_cleanup", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true"
+    };
   test_const_same_file: "feature_flag/system_1/const_same_file", 1,
     substitutions= substitutions! {
       "stale_flag_name" => "staleFlag",
       "treated" => "false"
     };
 }
+
+/// Checks that the boolean simplifications reach a fixpoint, i.e. running Piranha on its own output is a no-op.
+#[test]
+fn test_builtin_boolean_literal_cleanup_reaches_fixpoint() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/boolean_literal_cleanup");
+  let temp_dir = copy_folder_to_temp_dir(&_path.join("input"));
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "true_flag_name" => "true",
+      "false_flag_name" => "false",
+      "nil_flag_name" => "nil"
+    })
+    .build();
+  execute_piranha_and_check_result(&piranha_arguments, &_path.join("expected"), 1, true);
+
+  // The second run should not find anything to rewrite
+  let output_summaries = execute_piranha(&piranha_arguments);
+  assert!(output_summaries.is_empty());
+  temp_dir.close().unwrap();
+}
+
+/// Checks that `verify_fixpoint` reports no violation, when the boolean simplifications reach a fixpoint.
+#[test]
+fn test_builtin_boolean_literal_cleanup_verify_fixpoint() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/boolean_literal_cleanup");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "true_flag_name" => "true",
+      "false_flag_name" => "false",
+      "nil_flag_name" => "nil"
+    })
+    .dry_run(true)
+    .verify_fixpoint(true)
+    .build();
+  let output_summaries = execute_piranha(&piranha_arguments);
+  assert!(!output_summaries.is_empty());
+  assert!(output_summaries
+    .iter()
+    .all(|summary| summary.fixpoint_violation().is_none()));
+}
+
+/// Checks that `dry_run` reaches the fixpoint without writing the files, and reports the unified diffs instead.
+#[test]
+fn test_builtin_boolean_literal_cleanup_dry_run() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/boolean_literal_cleanup");
+  let temp_dir = copy_folder_to_temp_dir(&_path.join("input"));
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "true_flag_name" => "true",
+      "false_flag_name" => "false",
+      "nil_flag_name" => "nil"
+    })
+    .dry_run(true)
+    .build();
+  let output_summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(output_summaries.len(), 1);
+
+  let summary = &output_summaries[0];
+  let file_name = PathBuf::from(summary.path());
+  let expected_content =
+    fs::read_to_string(_path.join("expected").join(file_name.file_name().unwrap())).unwrap();
+  // The file is left untouched
+  assert_eq!(
+    &fs::read_to_string(summary.path()).unwrap(),
+    summary.original_content()
+  );
+  // The final content (and the diff) reflect the fixpoint
+  assert!(eq_without_whitespace(summary.content(), &expected_content));
+  let file_name = file_name.file_name().unwrap().to_str().unwrap();
+  assert!(summary.diff().starts_with(&format!(
+    "diff --git a/{file_name} b/{file_name}\n--- a/{file_name}\n+++ b/{file_name}\n"
+  )));
+  temp_dir.close().unwrap();
+}
+
+/// Checks that the files that would be deleted are reported as deletions in the `dry_run` diffs.
+#[test]
+fn test_builtin_init_cleanup_dry_run_reports_deleted_file() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/init_cleanup");
+  let temp_dir = copy_folder_to_temp_dir(&_path.join("input"));
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    })
+    .dry_run(true)
+    .cleanup_imports(true)
+    .build();
+  let output_summaries = execute_piranha(&piranha_arguments);
+
+  let deleted_file = output_summaries
+    .iter()
+    .find(|summary| summary.path().ends_with("register.go"))
+    .unwrap();
+  assert!(temp_dir.path().join("register.go").exists());
+  assert!(deleted_file.diff().starts_with(
+    "diff --git a/register.go b/register.go\ndeleted file mode 100644\n--- a/register.go\n+++ /dev/null\n"
+  ));
+  temp_dir.close().unwrap();
+}
+
+/// Checks that the Go files left in a package whose files were all deleted (i.e. its `doc.go`) are deleted too, along
+/// with its directory, unless one of them still declares something. The deleted files (including the emptied test)
+/// are listed in the output summaries, and reported as deletions in the `dry_run` diffs.
+#[test]
+fn test_builtin_empty_package_cleanup_deletes_package_directory() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/empty_package_cleanup");
+  for dry_run in [true, false] {
+    let temp_dir = copy_folder_to_temp_dir(&_path.join("input"));
+    for package in ["legacy", "beta"] {
+      fs::create_dir(temp_dir.path().join(package)).unwrap();
+      for entry in fs::read_dir(_path.join("input").join(package)).unwrap() {
+        let path = entry.unwrap().path();
+        fs::copy(
+          &path,
+          temp_dir
+            .path()
+            .join(package)
+            .join(path.file_name().unwrap()),
+        )
+        .unwrap();
+      }
+    }
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+      .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions! {
+        "treated" => "true",
+        "treated_complement" => "false"
+      })
+      .dry_run(dry_run)
+      .build();
+    let output_summaries = execute_piranha(&piranha_arguments);
+
+    let deleted_files = output_summaries
+      .iter()
+      .filter(|summary| summary.content().is_empty())
+      .map(|summary| {
+        Path::new(summary.path())
+          .strip_prefix(temp_dir.path())
+          .unwrap()
+          .to_str()
+          .unwrap()
+          .to_string()
+      })
+      .collect_vec();
+    assert_eq!(
+      deleted_files,
+      [
+        "beta/register.go",
+        "legacy/doc.go",
+        "legacy/register.go",
+        "legacy/register_test.go"
+      ]
+    );
+    let doc = output_summaries
+      .iter()
+      .find(|summary| summary.path().ends_with("legacy/doc.go"))
+      .unwrap();
+    assert_eq!(doc.rewrites()[0].matched_rule(), DELETE_EMPTY_PACKAGE_FILE);
+    if dry_run {
+      assert!(doc.diff().starts_with(
+        "diff --git a/legacy/doc.go b/legacy/doc.go\ndeleted file mode 100644\n--- a/legacy/doc.go\n+++ /dev/null\n"
+      ));
+      assert!(temp_dir.path().join("legacy/doc.go").exists());
+    } else {
+      assert!(!temp_dir.path().join("legacy").exists());
+      assert!(temp_dir.path().join("beta/doc.go").exists());
+      assert!(!temp_dir.path().join("beta/register.go").exists());
+    }
+    temp_dir.close().unwrap();
+  }
+}
+
+/// Checks that the output summaries do not depend on the number of threads processing the files.
+#[test]
+fn test_builtin_package_variable_cleanup_is_independent_of_thread_count() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/package_variable_cleanup");
+  let output_summaries = [1, 4].map(|thread_count| {
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+      .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions! {
+        "treated" => "true",
+        "treated_complement" => "false"
+      })
+      .dry_run(true)
+      .thread_count(thread_count)
+      .cleanup_imports(true)
+      .build();
+    execute_piranha(&piranha_arguments)
+      .iter()
+      .map(|summary| (summary.path().to_string(), summary.content().to_string()))
+      .collect::<Vec<_>>()
+  });
+  assert_eq!(output_summaries[0].len(), 2);
+  // The summaries are sorted by path
+  assert!(output_summaries[0].windows(2).all(|w| w[0].0 < w[1].0));
+  assert_eq!(output_summaries[0], output_summaries[1]);
+}
+
+/// Benchmarks the number of compiled queries, i.e. each query is compiled once regardless of the number of files.
+#[test]
+fn test_builtin_import_cleanup_compiles_each_query_once() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/import_cleanup");
+  let number_of_compiled_queries = [1, 10].map(|number_of_copies| {
+    // Copy the scenario `number_of_copies` times (in different packages)
+    let temp_dir = TempDir::new_in(".", "tmp_test").unwrap();
+    for i in 0..number_of_copies {
+      let package = temp_dir.path().join(format!("package_{i}"));
+      fs::create_dir(&package).unwrap();
+      for entry in fs::read_dir(_path.join("input")).unwrap() {
+        let path = entry.unwrap().path();
+        fs::copy(&path, package.join(path.file_name().unwrap())).unwrap();
+      }
+    }
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+      .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions! {
+        "treated" => "true",
+        "treated_complement" => "false"
+      })
+      .dry_run(true)
+      .cleanup_imports(true)
+      .build();
+
+    let now = Instant::now();
+    let mut piranha = Piranha::new(&piranha_arguments);
+    piranha.perform_cleanup();
+    let number_of_compiled_queries = piranha.rule_store.number_of_compiled_queries();
+    info!(
+      "{number_of_copies} copies : {number_of_compiled_queries} queries compiled in {:?}",
+      now.elapsed()
+    );
+    assert_eq!(piranha.get_updated_files().len(), 4 * number_of_copies);
+    temp_dir.close().unwrap();
+    number_of_compiled_queries
+  });
+  assert_eq!(number_of_compiled_queries[0], number_of_compiled_queries[1]);
+}
+
+/// Benchmarks the parallel processing of the files, on the scenario replicated (in different packages) a hundred times.
+/// The output summaries (and the rules cascading across the files of a package) do not depend on the thread scheduling.
+#[test]
+fn test_builtin_package_variable_cleanup_replicated_benchmark() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/package_variable_cleanup");
+  let number_of_copies = 100;
+  let temp_dir = TempDir::new_in(".", "tmp_test").unwrap();
+  for i in 0..number_of_copies {
+    copy_dir_recursively(
+      &_path.join("input"),
+      &temp_dir.path().join(format!("copy_{i}")),
+    );
+  }
+
+  let output_summaries = [1, default_thread_count()].map(|thread_count| {
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+      .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions! {
+        "treated" => "true",
+        "treated_complement" => "false"
+      })
+      .dry_run(true)
+      .thread_count(thread_count)
+      .cleanup_imports(true)
+      .build();
+    let now = Instant::now();
+    let output_summaries = execute_piranha(&piranha_arguments)
+      .iter()
+      .map(|summary| (summary.path().to_string(), summary.content().to_string()))
+      .collect::<Vec<_>>();
+    info!("{thread_count} thread(s) : {:?}", now.elapsed());
+    output_summaries
+  });
+  assert_eq!(output_summaries[0].len(), 2 * number_of_copies);
+  assert_eq!(output_summaries[0], output_summaries[1]);
+  temp_dir.close().unwrap();
+}
+
+/// Copies the directory `src` (and its sub-directories) to `dst`.
+fn copy_dir_recursively(src: &Path, dst: &Path) {
+  fs::create_dir_all(dst).unwrap();
+  for entry in fs::read_dir(src).unwrap() {
+    let path = entry.unwrap().path();
+    let target = dst.join(path.file_name().unwrap());
+    if path.is_dir() {
+      copy_dir_recursively(&path, &target);
+    } else {
+      fs::copy(&path, &target).unwrap();
+    }
+  }
+}
+
+/// Checks that the pre-filter skips the files that do not mention the stale flag (or its APIs), unless it is disabled.
+#[test]
+fn test_builtin_method_chain_cleanup_prefilter() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/method_chain_cleanup");
+  let temp_dir = copy_folder_to_temp_dir(&_path.join("input"));
+  fs::write(
+    temp_dir.path().join("unrelated.go"),
+    "package main\n\nfunc unrelated() int {\n\treturn 1\n}\n",
+  )
+  .unwrap();
+
+  let number_of_analyzed_files = [false, true].map(|no_prefilter| {
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+      .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions! {
+        "stale_flag_name" => "new_checkout",
+        "treated" => "true",
+        "flag_methods" => "Enabled|EnabledFor"
+      })
+      .dry_run(true)
+      .no_prefilter(no_prefilter)
+      .build();
+    let mut piranha = Piranha::new(&piranha_arguments);
+    piranha.perform_cleanup();
+    assert_eq!(piranha.get_updated_files().len(), 1);
+    piranha.relevant_files.len()
+  });
+  assert_eq!(number_of_analyzed_files, [1, 2]);
+  temp_dir.close().unwrap();
+}
+
+/// Checks that the vendored and generated files are only reported (with their usages), unless they are included.
+#[test]
+fn test_builtin_method_chain_cleanup_skips_vendored_and_generated_files() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/method_chain_cleanup");
+  let temp_dir = copy_folder_to_temp_dir(&_path.join("input"));
+  let content = fs::read_to_string(temp_dir.path().join("service.go")).unwrap();
+  let vendor_dir = temp_dir.path().join("vendor/github.com/uber/checkout");
+  fs::create_dir_all(&vendor_dir).unwrap();
+  fs::write(vendor_dir.join("service.go"), &content).unwrap();
+  fs::write(
+    temp_dir.path().join("service.pb.go"),
+    format!("// Code generated by protoc-gen-go. DO NOT EDIT.\n{content}"),
+  )
+  .unwrap();
+
+  for include in [false, true] {
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+      .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions! {
+        "stale_flag_name" => "new_checkout",
+        "treated" => "true",
+        "flag_methods" => "Enabled|EnabledFor"
+      })
+      .dry_run(true)
+      .include_vendor(include)
+      .include_generated(include)
+      .build();
+    let summaries = execute_piranha(&piranha_arguments);
+    assert_eq!(summaries.len(), 3);
+    for summary in summaries {
+      let skip_reason = if include {
+        None
+      } else if summary.path().contains("/vendor/") {
+        Some(VENDORED_CODE_SKIP_REASON.to_string())
+      } else if summary.path().ends_with(".pb.go") {
+        Some(GENERATED_CODE_SKIP_REASON.to_string())
+      } else {
+        None
+      };
+      assert_eq!(summary.skip_reason(), &skip_reason, "{}", summary.path());
+      // The usages of the skipped files are reported, but they are not rewritten
+      assert_eq!(summary.rewrites().is_empty(), skip_reason.is_some());
+      assert_eq!(summary.matches().is_empty(), skip_reason.is_none());
+    }
+  }
+  temp_dir.close().unwrap();
+}
+
+/// Checks that the usages in the nodes kept with `//piranha:keep` are listed as suppressed matches (along with their
+/// line in the original file), while the file starting with `//piranha:keep-file` is skipped.
+#[test]
+fn test_builtin_keep_directive_lists_suppressed_usages() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/keep_directive");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "stale_flag",
+      "treated_complement" => "other_flag"
+    })
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries.len(), 2);
+
+  let sample = summaries
+    .iter()
+    .find(|s| s.path().ends_with("sample.go"))
+    .unwrap();
+  assert_eq!(sample.skip_reason(), &None);
+  let suppressed = sample
+    .suppressed_matches()
+    .iter()
+    .map(|(rule, m)| (rule.as_str(), m.original_range().start_point.row + 1))
+    .sorted()
+    .collect_vec();
+  assert_eq!(
+    suppressed,
+    [("true_flag", 28), ("true_flag", 34), ("true_flag", 40)]
+  );
+
+  let kept_file = summaries
+    .iter()
+    .find(|s| s.path().ends_with("kept_file.go"))
+    .unwrap();
+  assert_eq!(
+    kept_file.skip_reason(),
+    &Some(KEPT_FILE_SKIP_REASON.to_string())
+  );
+  assert!(kept_file.rewrites().is_empty());
+  assert_eq!(kept_file.matches().len(), 1);
+}
+
+/// Checks that the layout of the rewritten files is normalized (e.g. the imports are sorted),
+/// while the other files are left as is, byte for byte.
+#[test]
+fn test_builtin_go_layout_only_normalizes_rewritten_files() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/go_layout");
+  let temp_dir = copy_folder_to_temp_dir(&_path.join("input"));
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    })
+    .cleanup_imports(true)
+    .build();
+  execute_piranha_and_check_result(&piranha_arguments, &_path.join("expected"), 1, true);
+
+  let read_untouched_file = |dir: &Path| fs::read_to_string(dir.join("untouched.go")).unwrap();
+  assert_eq!(
+    read_untouched_file(temp_dir.path()),
+    read_untouched_file(&_path.join("input"))
+  );
+  temp_dir.close().unwrap();
+}
+
+/// Checks that `gofmt` run as the formatter command re-aligns the rewritten code (e.g. the keys of the
+/// composite literals), which the normalization of the layout leaves as is.
+#[test]
+fn test_builtin_composite_literal_cleanup_with_gofmt_formatter_command() {
+  super::initialize();
+  if !super::is_gofmt_installed() {
+    return;
+  }
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/composite_literal_cleanup");
+  let temp_dir = copy_folder_to_temp_dir(&_path.join("input"));
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    })
+    .cleanup_imports(true)
+    .format_output(true)
+    .formatter_command(Some("gofmt".to_string()))
+    .build();
+  execute_piranha(&piranha_arguments);
+
+  let read_server_file = |dir: &Path| fs::read_to_string(dir.join("server.go")).unwrap();
+  let server_file = read_server_file(temp_dir.path());
+  assert!(eq_without_whitespace(
+    &server_file,
+    &read_server_file(&_path.join("expected"))
+  ));
+  super::assert_gofmt_clean(&server_file);
+  temp_dir.close().unwrap();
+}
+
+/// Checks that the comparisons of the environment flag against any other value are reported, but not rewritten.
+#[test]
+fn test_builtin_environment_flag_cleanup_reports_other_values() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/environment_flag_cleanup");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "env_var_name" => "ENABLE_NEW_PATH",
+      "treated" => "true"
+    })
+    .dry_run(true)
+    .cleanup_imports(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  let mode = summaries
+    .iter()
+    .find(|s| s.path().ends_with("mode.go"))
+    .unwrap();
+  assert!(mode.rewrites().is_empty());
+  let reported = mode
+    .matches()
+    .iter()
+    .map(|(rule, m)| (rule.as_str(), m.matched_string().as_str()))
+    .collect_vec();
+  assert_eq!(
+    reported,
+    [(
+      "report_environment_flag_comparison_with_other_value",
+      "os.Getenv(\"ENABLE_NEW_PATH\") == \"beta\""
+    )]
+  );
+}
+
+/// Checks that the conditional assignments, and the assignments to the variables assigned more than once, are reported
+/// for manual review, as they cannot be resolved.
+#[test]
+fn test_builtin_variable_assignment_cleanup_reports_unresolved_assignments() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/variable_assignment_cleanup");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    })
+    .dry_run(true)
+    .cleanup_imports(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries.len(), 1);
+  let reported = summaries[0]
+    .matches()
+    .iter()
+    .filter(|(rule, _)| rule.starts_with("report_"))
+    .map(|(rule, m)| (rule.as_str(), m.matched_string().as_str()))
+    .collect_vec();
+  assert_eq!(
+    reported,
+    [
+      ("report_conditional_variable_assignment", "enabled = true"),
+      ("report_reassigned_variable_assignment", "enabled = true")
+    ]
+  );
+}
+
+/// Checks that the calls passing the pair along (rather than destructuring it) are reported, as their arity does not
+/// match once a value is resolved.
+#[test]
+fn test_builtin_multi_value_return_cleanup_reports_other_calls() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/multi_value_return_cleanup");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "stale_flag_name" => "new_path",
+      "treated" => "true"
+    })
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  let reported = summaries
+    .iter()
+    .flat_map(|s| s.matches())
+    .filter(|(rule, _)| rule.starts_with("report_"))
+    .map(|(rule, m)| (rule.as_str(), m.matched_string().as_str()))
+    .collect_vec();
+  assert_eq!(
+    reported,
+    [(
+      "report_call_to_function_returning_boolean_literal_pair",
+      "flags()"
+    )]
+  );
+}
+
+/// Checks that the references retaining the functions (and methods) returning a boolean literal are reported, e.g. a
+/// function value in another file of the package, or the method of an interface.
+#[test]
+fn test_builtin_wrapper_function_cleanup_reports_retaining_references() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/wrapper_function_cleanup");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    })
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  let reported = summaries
+    .iter()
+    .flat_map(|s| s.matches())
+    .filter(|(rule, _)| rule.starts_with("report_reference_to_"))
+    .map(|(rule, m)| (rule.as_str(), m.matched_string().as_str()))
+    .sorted()
+    .collect_vec();
+  assert_eq!(
+    reported,
+    [
+      (
+        "report_reference_to_function_returning_boolean_literal",
+        "batchingDisabled"
+      ),
+      (
+        "report_reference_to_function_returning_boolean_literal",
+        "fallbackEnabled"
+      ),
+      (
+        "report_reference_to_method_returning_boolean_literal",
+        "darkModeEnabled() bool"
+      )
+    ]
+  );
+}
+
+/// Checks that the comparisons of the string flag against a non literal value are reported, as they cannot be resolved.
+#[test]
+fn test_builtin_string_comparison_cleanup_reports_non_literal_values() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/string_comparison_cleanup");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "stale_flag_name" => "rollout_mode",
+      "string_treated" => "full",
+      "string_flag_functions" => "StrValue",
+      "flag_argument_position" => "0"
+    })
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries.len(), 1);
+  let reported = summaries[0]
+    .matches()
+    .iter()
+    .map(|(rule, m)| (rule.as_str(), m.matched_string().as_str()))
+    .collect_vec();
+  assert_eq!(
+    reported,
+    [
+      (
+        "report_string_flag_comparison_with_non_literal",
+        "exp.StrValue(\"rollout_mode\") == expected"
+      ),
+      (
+        "report_string_flag_equal_fold_with_non_literal",
+        "strings.EqualFold(exp.StrValue(\"rollout_mode\"), expected)"
+      )
+    ]
+  );
+}
+
+/// Checks that the calls to the generic flag helpers whose flag name is not a string literal are reported, as they
+/// cannot be tied to any flag.
+#[test]
+fn test_builtin_generic_flag_helper_reports_non_literal_names() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/generic_flag_helper");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true",
+      "generic_flag_functions" => "Flag",
+      "flag_argument_position" => "0"
+    })
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries.len(), 1);
+  let reported = summaries[0]
+    .matches()
+    .iter()
+    .filter(|(rule, _)| rule == "report_generic_flag_function_call_with_non_literal_name")
+    .map(|(_, m)| m.matched_string().as_str())
+    .collect_vec();
+  assert_eq!(reported, ["flags.Flag[bool](name, false)"]);
+}
+
+/// Checks that the lookups-and-calls of the flag registries whose key is not a string literal are reported, as they
+/// cannot be tied to any flag, while the ones of the other flags are left as is.
+#[test]
+fn test_builtin_flag_registry_reports_non_literal_keys() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/flag_registry");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true",
+      "flag_registries" => "experiments"
+    })
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  let reported = summaries
+    .iter()
+    .flat_map(|s| s.matches())
+    .filter(|(rule, _)| rule == "report_flag_registry_call_with_non_literal_key")
+    .map(|(_, m)| m.matched_string().as_str())
+    .collect_vec();
+  assert_eq!(reported, ["experiments[name]()"]);
+  assert!(summaries.iter().all(|s| s.unresolved_usages().is_empty()));
+}
+
+/// Checks that the rewrites are attributed to the flag (of the flags manifest) whose cleanup performed them,
+/// i.e. the rules cascading from a seed rule are instantiated with the substitutions of its flag.
+#[test]
+fn test_builtin_flags_manifest_attributes_rewrites_to_flags() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/flags_manifest");
+  let temp_dir = copy_folder_to_temp_dir(&_path.join("input"));
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "flag_methods" => "Enabled|EnabledFor"
+    })
+    .flags_file(Some(
+      _path
+        .join("configurations/flags.json")
+        .to_str()
+        .unwrap()
+        .to_string(),
+    ))
+    .dry_run(true)
+    .cleanup_imports(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries.len(), 2);
+  for summary in summaries {
+    let flags: HashSet<&str> = summary
+      .rewrites()
+      .iter()
+      .filter_map(|e| e.flag().as_deref())
+      .collect();
+    let expected_flags = if summary.path().ends_with("beta.go") {
+      HashSet::from(["legacy_cart"])
+    } else {
+      HashSet::from(["new_checkout", "legacy_cart"])
+    };
+    assert_eq!(flags, expected_flags, "{}", summary.path());
+  }
+  temp_dir.close().unwrap();
+}
+
+/// Checks that the same flags manifest (which does not specify the `treated` substitution) drives both the cleanup
+/// shipping the feature and the one killing it, depending on the state of the flag in the `flag_states`.
+#[test]
+fn test_builtin_flag_states_both_states_of_flags_manifest() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/flag_states");
+  for (state, expected) in [(true, "expected_enabled"), (false, "expected_disabled")] {
+    let temp_dir = copy_folder_to_temp_dir(&_path.join("input"));
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+      .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions! {
+        "flag_methods" => "Enabled|EnabledFor"
+      })
+      .flags_file(Some(
+        _path
+          .join("configurations/flags.json")
+          .to_str()
+          .unwrap()
+          .to_string(),
+      ))
+      .flag_states(vec![("new_checkout".to_string(), state)])
+      .cleanup_imports(true)
+      .build();
+    execute_piranha_and_check_result(&piranha_arguments, &_path.join(expected), 1, true);
+    temp_dir.close().unwrap();
+  }
+}
+
+/// Checks that only the included files that are not excluded are processed (i.e. the exclusion takes precedence over
+/// the inclusion), e.g. neither the vendored files nor the tests.
+#[test]
+fn test_builtin_include_exclude_exclusion_takes_precedence() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/include_exclude");
+  let get_paths = |include: Vec<Pattern>, exclude: Vec<Pattern>| {
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+      .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions! {
+        "stale_flag_name" => "new_checkout",
+        "treated" => "true"
+      })
+      .include(include)
+      .exclude(exclude)
+      .dry_run(true)
+      .build();
+    execute_piranha(&piranha_arguments)
+      .iter()
+      .map(|summary| {
+        Path::new(summary.path())
+          .strip_prefix(_path.join("input"))
+          .unwrap()
+          .to_str()
+          .unwrap()
+          .to_string()
+      })
+      .collect_vec()
+  };
+  assert_eq!(
+    get_paths(vec![], vec![]),
+    vec![
+      "checkout.go",
+      "checkout_test.go",
+      "vendor/github.com/uber/exp/exp.go"
+    ]
+  );
+  assert_eq!(
+    get_paths(
+      vec![Pattern::new("**/*.go").unwrap()],
+      vec![
+        Pattern::new("**/vendor/**").unwrap(),
+        Pattern::new("**/*_test.go").unwrap()
+      ]
+    ),
+    vec!["checkout.go"]
+  );
+}
+
+/// Checks that the files ignored by the `.gitignore` files (including the nested ones) and the `.piranhaignore` files are
+/// skipped, unless `no_ignore` is set.
+#[test]
+fn test_builtin_ignore_files_skip_ignored_paths() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/ignore_files");
+  let get_paths = |no_ignore: bool| {
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+      .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions! {
+        "stale_flag_name" => "new_checkout",
+        "treated" => "true"
+      })
+      .no_ignore(no_ignore)
+      .dry_run(true)
+      .build();
+    execute_piranha(&piranha_arguments)
+      .iter()
+      .map(|summary| {
+        Path::new(summary.path())
+          .strip_prefix(_path.join("input"))
+          .unwrap()
+          .to_str()
+          .unwrap()
+          .to_string()
+      })
+      .collect_vec()
+  };
+  assert_eq!(get_paths(false), vec!["checkout.go", "internal/flags.go"]);
+  assert_eq!(
+    get_paths(true),
+    vec![
+      "build/checkout.go",
+      "checkout.go",
+      "internal/flags.go",
+      "internal/generated_flags.go",
+      "third_party/exp/exp.go"
+    ]
+  );
+}
+
+/// Checks that only the packages included with the `/...` suffix are cleaned up (except the excluded paths), and that
+/// the package rules do not rewrite the excluded files of the package, whose usages are listed as out of scope instead
+/// (e.g. the declaration whose boolean parameter would be deleted).
+#[test]
+fn test_builtin_package_scope_lists_out_of_scope_usages() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/package_scope");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true"
+    })
+    .include(vec![parse_glob_pattern("services/checkout/...").unwrap()])
+    .exclude(vec![
+      parse_glob_pattern("**/internal/legacy/**").unwrap(),
+      parse_glob_pattern("**/legacy_*.go").unwrap(),
+    ])
+    .dry_run(true)
+    .cleanup_imports(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  let paths = summaries
+    .iter()
+    .map(|summary| {
+      Path::new(summary.path())
+        .strip_prefix(_path.join("input"))
+        .unwrap()
+        .to_str()
+        .unwrap()
+        .to_string()
+    })
+    .collect_vec();
+  assert_eq!(
+    paths,
+    vec![
+      "services/checkout/checkout.go",
+      "services/checkout/legacy_render.go"
+    ]
+  );
+
+  let checkout = &summaries[0];
+  assert!(checkout.skip_reason().is_none());
+  let expected = fs::read_to_string(_path.join("expected/services/checkout/checkout.go")).unwrap();
+  assert!(eq_without_whitespace(checkout.content(), &expected));
+  super::assert_go_layout_normalized(checkout.content());
+  super::assert_gofmt_clean(&expected);
+
+  let legacy_render = &summaries[1];
+  assert_eq!(
+    legacy_render.skip_reason().as_deref(),
+    Some(OUT_OF_SCOPE_SKIP_REASON)
+  );
+  assert_eq!(legacy_render.content(), legacy_render.original_content());
+  assert_eq!(
+    legacy_render
+      .matches()
+      .iter()
+      .map(|(rule, _)| rule.as_str())
+      .collect_vec(),
+    vec!["delete_boolean_parameter_of_binary_function"]
+  );
+}
+
+/// Checks that the usages of the stale flags left once all the rules have been applied are listed in the output summary
+/// of their file (along with their line, enclosing function and snippet), for a single flag and for a flags manifest.
+#[test]
+fn test_builtin_unresolved_usages_lists_left_usages() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/unresolved_usages");
+  let flags_file = _path
+    .join("configurations/flags.json")
+    .to_str()
+    .unwrap()
+    .to_string();
+  for flags_file in [None, Some(flags_file)] {
+    let is_batch = flags_file.is_some();
+    let substitutions = if is_batch {
+      substitutions! { "flag_methods" => "Enabled|EnabledFor" }
+    } else {
+      substitutions! {
+        "stale_flag_name" => "new_checkout",
+        "treated" => "true",
+        "flag_methods" => "Enabled|EnabledFor"
+      }
+    };
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+      .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(substitutions)
+      .flags_file(flags_file)
+      .dry_run(true)
+      .cleanup_imports(true)
+      .build();
+    let summaries = execute_piranha(&piranha_arguments);
+    assert_eq!(summaries.len(), 2);
+    let unresolved_usages = summaries
+      .iter()
+      .flat_map(|s| s.unresolved_usages())
+      .map(|u| {
+        (
+          u.flag().as_str(),
+          Path::new(u.path()).file_name().unwrap().to_str().unwrap(),
+          *u.line(),
+          u.enclosing_function().as_deref(),
+          u.snippet().as_str(),
+        )
+      })
+      .collect_vec();
+    let mut expected = vec![
+      (
+        "new_checkout",
+        "checkout.go",
+        26,
+        Some("Track"),
+        r#"t.Record("new_checkout", "new")"#,
+      ),
+      (
+        "new_checkout",
+        "registry.go",
+        18,
+        None,
+        r#""new_checkout": true,"#,
+      ),
+    ];
+    // The flags of the manifest are all listed
+    if is_batch {
+      expected.push((
+        "legacy_cart",
+        "registry.go",
+        19,
+        None,
+        r#""legacy_cart":  false,"#,
+      ));
+    }
+    assert_eq!(unresolved_usages, expected);
+  }
+}
+
+/// Checks that the functions deleted by `delete_unreachable` are listed in the output summary of their file, along
+/// with the rule chain that made them unreachable (i.e. the deleted callers, for the transitively unreachable ones).
+#[test]
+fn test_builtin_delete_unreachable_lists_deleted_functions() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/delete_unreachable");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "new_checkout",
+      "treated_complement" => "old_checkout"
+    })
+    .delete_unreachable(true)
+    .dry_run(true)
+    .cleanup_imports(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries.len(), 2);
+
+  let checkout = summaries
+    .iter()
+    .find(|s| s.path().ends_with("checkout.go"))
+    .unwrap();
+  assert!(checkout.deleted_functions().is_empty());
+
+  let render = summaries
+    .iter()
+    .find(|s| s.path().ends_with("render.go"))
+    .unwrap();
+  let deleted_functions = render
+    .deleted_functions()
+    .iter()
+    .map(|f| {
+      assert_eq!(f.path(), render.path());
+      (
+        f.name().as_str(),
+        f.rule_chain().iter().map(String::as_str).collect_vec(),
+        f.deleted_callers().iter().map(String::as_str).collect_vec(),
+      )
+    })
+    .collect_vec();
+  let cleanup = vec!["true_flag", "simplify_if_statement_true"];
+  assert_eq!(
+    deleted_functions,
+    vec![
+      ("renderLegacy", cleanup.clone(), vec![]),
+      (
+        "legacyHeader",
+        vec![
+          "true_flag",
+          "simplify_if_statement_true",
+          DELETE_UNREACHABLE_FUNCTION
+        ],
+        vec!["renderLegacy"]
+      ),
+      ("legacyRetry", cleanup.clone(), vec![]),
+      ("legacyDraw", cleanup, vec![]),
+    ]
+  );
+}
+
+/// Checks that the flag API calls flipped by the `flip` treatment are counted in the output summary, i.e. once per call
+/// (including the renamed ones), while the calls already defaulting to the `treated_value` are not.
+#[test]
+fn test_builtin_flip_treatment_counts_flipped_sites() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/flip_treatment");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "flag_functions" => "BoolValueCtx",
+      "flag_argument_position" => "1",
+      "flag_function_without_default" => "BoolValue",
+      "flag_function_with_default" => "BoolValueWithDefault"
+    })
+    .treatment("flip".to_string())
+    .treated_value(Some(true))
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries.len(), 1);
+  assert_eq!(*summaries[0].flipped_sites(), 3);
+  assert_eq!(summaries[0].rewrites().len(), 4);
+}
+
+/// Checks that the struct types deleted by `delete_unselected_implementations` are listed in the output summary of their
+/// file, along with their methods, their constructors and the interfaces left with a single implementation, while the
+/// ones referred from another package (here, the external tests of the package) are retained and reported.
+#[test]
+fn test_builtin_delete_unselected_implementations_lists_implementations() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/delete_unselected_implementations");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "new_checkout",
+      "treated_complement" => "old_checkout"
+    })
+    .delete_unselected_implementations(true)
+    .dry_run(true)
+    .cleanup_imports(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries.len(), 2);
+
+  let flows = summaries
+    .iter()
+    .find(|s| s.path().ends_with("flows.go"))
+    .unwrap();
+  let implementations = flows.unselected_implementations();
+  assert_eq!(implementations.len(), 2);
+
+  let legacy_flow = &implementations[0];
+  assert_eq!(legacy_flow.name(), "legacyFlow");
+  assert_eq!(legacy_flow.path(), flows.path());
+  assert!(legacy_flow.is_deleted());
+  assert_eq!(
+    legacy_flow.methods(),
+    &vec!["Run".to_string(), "retry".to_string()]
+  );
+  assert_eq!(
+    legacy_flow.constructors(),
+    &vec!["newLegacyFlow".to_string()]
+  );
+  assert_eq!(
+    legacy_flow.single_implementations(),
+    &vec![("CheckoutFlow".to_string(), "newFlow".to_string())]
+  );
+
+  let legacy_receipt = &implementations[1];
+  assert_eq!(legacy_receipt.name(), "LegacyReceipt");
+  assert!(!legacy_receipt.is_deleted());
+  assert_eq!(
+    legacy_receipt.external_references(),
+    &vec!["receipt_test.go:23".to_string()]
+  );
+  assert!(legacy_receipt.single_implementations().is_empty());
+  assert!(flows.content().contains("type LegacyReceipt struct{}"));
+  assert!(!flows.content().contains("legacyFlow"));
+}
+
+/// Checks that the cleanup of the code snippet is restricted to its line range, i.e. the usages out of the range are
+/// skipped, as well as the cleanup of the enclosing `if` statement when only its condition is within the range.
+#[test]
+fn test_execute_piranha_on_content_within_line_range() {
+  super::initialize();
+  let rule = piranha_rule! {
+    name = "stale_flag",
+    query = "(
+  (call_expression
+    function: (selector_expression field: (field_identifier) @func_id)
+    arguments: (argument_list (interpreted_string_literal) @flag)
+  ) @call_exp
+  (#eq? @func_id \"BoolValue\")
+  (#eq? @flag \"\\\"new_path\\\"\")
+  )",
+    replace_node = "call_exp",
+    replace = "true",
+    groups = ["replace_expression_with_boolean_literal"]
+  };
+  let rule_graph = RuleGraphBuilder::default().rules(vec![rule]).build();
+  let code = r#"package main
+
+import (
+	"fmt"
+
+	"github.com/uber/exp"
+)
+
+func a() {
+	if exp.BoolValue("new_path") {
+		fmt.Println("a")
+	}
+}
+
+func b() {
+	if exp.BoolValue("new_path") {
+		fmt.Println("b")
+	}
+}
+"#;
+  let get_out_of_range_matches = |summary: &PiranhaOutputSummary| {
+    summary
+      .out_of_range_matches()
+      .iter()
+      .map(|(rule, m)| (rule.to_string(), m.original_range().start_point.row + 1))
+      .collect_vec()
+  };
+
+  // The lines of `a`
+  let (content, summary) = execute_piranha_on_content(
+    code.to_string(),
+    GO.to_string(),
+    rule_graph.clone(),
+    Some((9, 13)),
+  );
+  let expected = r#"package main
+
+import (
+	"fmt"
+
+	"github.com/uber/exp"
+)
+
+func a() {
+	fmt.Println("a")
+}
+
+func b() {
+	if exp.BoolValue("new_path") {
+		fmt.Println("b")
+	}
+}
+"#;
+  assert!(eq_without_whitespace(&content, expected));
+  let summary = summary.unwrap();
+  assert_eq!(
+    get_out_of_range_matches(&summary),
+    [("stale_flag".to_string(), 16)]
+  );
+
+  // The condition of the `if` statement of `a`, whose simplification is skipped
+  let (content, summary) =
+    execute_piranha_on_content(code.to_string(), GO.to_string(), rule_graph, Some((10, 10)));
+  assert!(eq_without_whitespace(
+    &content,
+    &code.replacen(r#"exp.BoolValue("new_path")"#, "true", 1)
+  ));
+  let out_of_range_matches = get_out_of_range_matches(&summary.unwrap());
+  assert!(out_of_range_matches.contains(&("simplify_if_statement_true".to_string(), 10)));
+  assert!(out_of_range_matches.contains(&("stale_flag".to_string(), 16)));
+}
+
+/// Checks that the statistics of the files are reported in their output summaries, and that the ones of the whole run
+/// also cover the files that were neither matched nor rewritten.
+#[test]
+fn test_builtin_dedupe_statements_reports_stats() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/dedupe_statements");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true",
+      "pure_functions" => "log\\.Info"
+    })
+    .dedupe_statements(true)
+    .dry_run(true)
+    .build();
+  let (summaries, stats) = execute_piranha_with_stats(&piranha_arguments);
+  assert_eq!(summaries.len(), 1);
+  let file_stats = summaries[0].stats();
+  assert_eq!(*file_stats.files(), 1);
+  assert!(*file_stats.edits_applied() >= summaries[0].rewrites().len());
+  assert!(file_stats.matches_evaluated() >= file_stats.edits_applied());
+  assert!(*file_stats.fixpoint_iterations() > 0);
+  assert!(file_stats.matches_evaluated_per_rule()["delete_duplicate_pure_call_statement"] > 0);
+  assert!(*file_stats.parse_time_ms() > 0.0);
+  // Nothing is written in `dry_run`
+  assert_eq!(*file_stats.write_time_ms(), 0.0);
+
+  assert!(stats.files() >= file_stats.files());
+  assert!(stats.matches_evaluated() >= file_stats.matches_evaluated());
+  assert_eq!(stats.edits_applied(), file_stats.edits_applied());
+}
+
+/// Checks that the rewrites of a file that introduce syntax errors are rolled back (i.e. the file is left unmodified
+/// and the offending rule is reported), along with their contribution to the deletion of the unreachable functions,
+/// while the other files are rewritten as usual.
+#[test]
+fn test_invalid_rewrites_are_rolled_back() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("invalid_rewrite_rollback");
+  // Deleting the consequence leaves a dangling `else`
+  let invalid_rule = piranha_rule! {
+    name = "delete_legacy_consequence",
+    query = "(
+  (if_statement
+    condition: (call_expression function: (identifier) @function)
+    consequence: (block) @consequence
+  )
+  (#eq? @function \"legacyEnabled\")
+  )",
+    replace_node = "consequence",
+    replace = ""
+  };
+  let rule = piranha_rule! {
+    name = "capitalize_checkout_title",
+    query = "(
+  (interpreted_string_literal) @title
+  (#eq? @title \"\\\"checkout\\\"\")
+  )",
+    replace_node = "title",
+    replace = "\"Checkout\""
+  };
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .rule_graph(
+      RuleGraphBuilder::default()
+        .rules(vec![invalid_rule, rule])
+        .build(),
+    )
+    .delete_unreachable(true)
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries.len(), 2);
+
+  let checkout = &summaries[0];
+  assert!(checkout.path().ends_with("checkout.go"));
+  assert!(checkout.invalid_rewrite().is_none());
+  assert!(checkout.content().contains("render(\"Checkout\")"));
+  // Still referred by the rolled back file
+  assert!(checkout.content().contains("func legacyRender()"));
+  assert!(checkout.deleted_functions().is_empty());
+
+  let render = &summaries[1];
+  assert!(render.path().ends_with("render.go"));
+  assert_eq!(
+    render.skip_reason().as_deref(),
+    Some(INVALID_REWRITE_SKIP_REASON)
+  );
+  assert!(!render.is_changed());
+  assert!(render.rewrites().is_empty());
+  let invalid_rewrite = render.invalid_rewrite().as_ref().unwrap();
+  assert_eq!(invalid_rewrite.rule(), "delete_legacy_consequence");
+  assert!(invalid_rewrite
+    .original_snippet()
+    .contains("return legacyRender()"));
+  assert!(!invalid_rewrite
+    .replacement_snippet()
+    .contains("return legacyRender()"));
+}
+
+/// Checks that the global rules added by a file whose rewrites are rolled back leave no rewrite behind in the other
+/// files, even when they were added in an earlier iteration than the invalid rewrite.
+#[test]
+fn test_invalid_rewrites_roll_back_their_global_rules() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("invalid_rewrite_rollback_global_rule");
+  // Adds `rename_legacy_render` to the global rules, in the first iteration
+  let seed_rule = piranha_rule! {
+    name = "rename_legacy_enabled",
+    query = "(
+  (identifier) @name
+  (#eq? @name \"legacyEnabled\")
+  )",
+    replace_node = "name",
+    replace = "isLegacy"
+  };
+  let global_rule = RuleBuilder::default()
+    .name("rename_legacy_render".to_string())
+    .query(CGPattern::new(
+      "(
+  (identifier) @name
+  (#eq? @name \"legacyRender\")
+  )"
+      .to_string(),
+    ))
+    .replace_node("name".to_string())
+    .replace("renderLegacy".to_string())
+    .is_seed_rule(false)
+    .build()
+    .unwrap();
+  // Deleting the consequence leaves a dangling `else` (in the second iteration)
+  let invalid_rule = RuleBuilder::default()
+    .name("delete_legacy_consequence".to_string())
+    .query(CGPattern::new(
+      "(
+  (if_statement
+    condition: (call_expression function: (identifier) @function)
+    consequence: (block) @consequence
+  )
+  (#eq? @function \"isLegacy\")
+  )"
+      .to_string(),
+    ))
+    .replace_node("consequence".to_string())
+    .replace(String::new())
+    .is_seed_rule(false)
+    .build()
+    .unwrap();
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .rule_graph(
+      RuleGraphBuilder::default()
+        .rules(vec![seed_rule, global_rule, invalid_rule])
+        .edges(vec![
+          edges! {from = "rename_legacy_enabled", to = ["rename_legacy_render"], scope = "Global"},
+          edges! {from = "rename_legacy_render", to = ["delete_legacy_consequence"], scope = "File"},
+        ])
+        .build(),
+    )
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  // `checkout.go` is not rewritten by `rename_legacy_render` anymore
+  assert_eq!(summaries.len(), 1);
+
+  let render = &summaries[0];
+  assert!(render.path().ends_with("render.go"));
+  assert_eq!(
+    render.skip_reason().as_deref(),
+    Some(INVALID_REWRITE_SKIP_REASON)
+  );
+  assert!(!render.is_changed());
+  assert!(render.rewrites().is_empty());
+  assert_eq!(
+    render.invalid_rewrite().as_ref().unwrap().rule(),
+    "delete_legacy_consequence"
+  );
+}
+
+/// Checks that a file whose processing exceeds the `per_file_timeout` is left unmodified, and reported with a timeout
+/// rather than failing the run.
+#[test]
+fn test_builtin_per_file_timeout_leaves_file_unmodified() {
+  super::initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/delete_unreachable");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "new_checkout",
+      "treated_complement" => "old_checkout"
+    })
+    .delete_unreachable(true)
+    // Exceeded by the time the first rule is applied
+    .per_file_timeout(Some(1e-9))
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries.len(), 1);
+  assert!(summaries[0].path().ends_with("checkout.go"));
+  assert_eq!(
+    summaries[0].skip_reason().as_deref(),
+    Some(TIMEOUT_SKIP_REASON)
+  );
+  assert!(!summaries[0].is_changed());
+  assert!(summaries[0].rewrites().is_empty());
+  assert!(summaries[0].deleted_functions().is_empty());
+}
@@ -18,7 +18,7 @@ use super::{
   execute_piranha_and_check_result, initialize, substitutions,
 };
 use crate::{
-  edges, execute_piranha, filter,
+  edges, execute_piranha, execute_piranha_on_content, filter,
   models::{
     default_configs::JAVA, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
     rule_graph::RuleGraphBuilder,
@@ -26,7 +26,10 @@ use crate::{
   piranha_rule,
   utilities::eq_without_whitespace,
 };
-use std::{collections::HashMap, path::PathBuf};
+use std::{
+  collections::HashMap,
+  path::{Path, PathBuf},
+};
 
 create_rewrite_tests! {
   JAVA,
@@ -66,6 +69,37 @@ create_rewrite_tests! {
   test_new_line_character_used_in_string_literal:  "new_line_character_used_in_string_literal",   1;
   test_java_delete_method_invocation_argument: "delete_method_invocation_argument", 1;
   test_java_delete_method_invocation_argument_no_op: "delete_method_invocation_argument_no_op", 0;
+  test_boolean_literal_cleanup: "boolean_literal_cleanup", 2,
+    substitutions = substitutions! {
+      "stale_flag_name" => "STALE_FLAG",
+      "treated"=>  "true"
+    };
+  test_else_if_cleanup: "else_if_cleanup", 1,
+    substitutions = substitutions! {
+      "stale_flag_name" => "STALE_FLAG",
+      "treated"=>  "true"
+    };
+  test_flatten_else: "flatten_else", 1,
+    substitutions = substitutions! {
+      "stale_flag_name" => "STALE_FLAG",
+      "treated"=>  "true"
+    }, flatten_else = true;
+  test_simplify_boolean_return: "simplify_boolean_return", 1,
+    substitutions = substitutions! {
+      "stale_flag_name" => "STALE_FLAG",
+      "treated"=>  "true"
+    }, simplify_boolean_return = true;
+  test_dedupe_statements: "dedupe_statements", 1,
+    substitutions = substitutions! {
+      "stale_flag_name" => "STALE_FLAG",
+      "treated"=>  "true",
+      "pure_functions" => "log\\.info"
+    }, dedupe_statements = true;
+  test_unreferenced_definition_cleanup: "unreferenced_definition_cleanup", 3,
+    substitutions = substitutions! {
+      "stale_flag_name" => "STALE_FLAG",
+      "treated"=>  "true"
+    };
 }
 
 create_match_tests! {
@@ -86,6 +120,29 @@ create_match_tests! {
               exclude = vec![Pattern::new("*/folder_2_1/**/*").unwrap()];
 }
 
+/// Checks that the enum constant is retained, since it is still referred in the excluded file.
+#[test]
+fn test_unreferenced_definition_cleanup_retains_referred_definition() {
+  initialize();
+  let _path = PathBuf::from("test-resources")
+    .join(JAVA)
+    .join("unreferenced_definition_cleanup");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(_path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(_path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(JAVA))
+    .substitutions(substitutions! {
+      "stale_flag_name" => "STALE_FLAG",
+      "treated"=>  "true"
+    })
+    .exclude(vec![Pattern::new("*/Render.java").unwrap()])
+    .dry_run(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries.len(), 1);
+  assert!(summaries[0].path().ends_with("Checkout.java"));
+}
+
 #[test]
 #[should_panic(expected = "Could not fetch range or node for replace_node")]
 fn test_delete_method_invocation_argument_invalid() {
@@ -190,6 +247,88 @@ fn test_new_line_character_used_in_string_literal_code_snippet() {
   assert!(output_summaries[0].original_content().eq(code_snippet));
 }
 
+#[test]
+fn test_execute_piranha_on_content() {
+  initialize();
+  let rule = piranha_rule! {
+    name = "Append l",
+    query = "(
+  (variable_declarator value: (decimal_integer_literal) @value)
+  (#not-match? @value \"l|L\")
+  )",
+    replace_node = "value",
+    replace = "@valuel"
+  };
+  let rule_graph = RuleGraphBuilder::default().rules(vec![rule]).build();
+
+  let code = "class A { long a = 1; }";
+  let (content, summary) =
+    execute_piranha_on_content(code.to_string(), JAVA.to_string(), rule_graph.clone(), None);
+  assert!(eq_without_whitespace(&content, "class A { long a = 1l; }"));
+  let summary = summary.unwrap();
+  assert_eq!(summary.rewrites().len(), 1);
+  assert!(summary.original_content().eq(code));
+  // The code snippet is never written to the disk
+  assert!(!Path::new(summary.path()).exists());
+
+  // When nothing is rewritten, the input code is returned as is
+  let code = "class A { long a = 1L; }";
+  let (content, summary) =
+    execute_piranha_on_content(code.to_string(), JAVA.to_string(), rule_graph, None);
+  assert_eq!(content, code);
+  assert!(summary.is_none());
+}
+
+/// Checks that `format_output` collapses the blank lines left behind by the deleted statements and deletes the trailing
+/// whitespace of the rewritten code, while its block comments are left as is.
+#[test]
+fn test_format_output() {
+  initialize();
+  let rule = piranha_rule! {
+    name = "delete_log_statement",
+    query = "(
+  (expression_statement (method_invocation name: (_) @name) @statement)
+  (#eq? @name \"log\")
+  )",
+    replace_node = "statement",
+    replace = ""
+  };
+  let code = "class A {
+  /* Checks out the cart.  
+
+  */
+  void checkout(Cart cart) {   
+    cart.validate();
+
+    log(cart);
+
+    cart.submit();
+  }
+}
+";
+  let expected = "class A {
+  /* Checks out the cart.  
+
+  */
+  void checkout(Cart cart) {
+    cart.validate();
+
+    cart.submit();
+  }
+}
+";
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .code_snippet(code.to_string())
+    .language(PiranhaLanguage::from(JAVA))
+    .rule_graph(RuleGraphBuilder::default().rules(vec![rule]).build())
+    .format_output(true)
+    .build();
+
+  let output_summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(output_summaries.len(), 1);
+  assert_eq!(output_summaries[0].content(), expected);
+}
+
 #[test]
 fn test_user_option_do_not_delete_consecutive_lines() {
   let _path = PathBuf::from("test-resources")
@@ -414,3 +553,23 @@ fn test_incorrect_rule() {
 
   let _ = execute_piranha(&piranha_arguments);
 }
+
+/// Checks that a query that cannot be compiled (once its holes are substituted) is reported along with the name of its rule.
+#[test]
+#[should_panic(expected = "Could not parse the query for the rule \"find_class_named\"")]
+fn test_incorrect_instantiated_query_reports_rule_name() {
+  initialize();
+  let rule = piranha_rule! {
+    name = "find_class_named",
+    query = "((class_declaration name: (_) @name) (#eq? @name \"@class_name\"))",
+    holes = ["class_name"]
+  };
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .code_snippet("class A { }".to_string())
+    .language(PiranhaLanguage::from(JAVA))
+    .substitutions(substitutions! {"class_name" => "A\") (oops"})
+    .rule_graph(RuleGraphBuilder::default().rules(vec![rule]).build())
+    .build();
+
+  let _ = execute_piranha(&piranha_arguments);
+}
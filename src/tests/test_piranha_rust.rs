@@ -0,0 +1,35 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use crate::models::default_configs::RUST;
+
+use super::{create_rewrite_tests, substitutions};
+
+create_rewrite_tests! {
+  RUST,
+  test_builtin_boolean_literal_cleanup: "feature_flag/builtin_rules/boolean_literal_cleanup", 1,
+    substitutions = substitutions! {
+      "true_flag_name" => "true",
+      "false_flag_name" => "false"
+    };
+  test_builtin_statement_cleanup: "feature_flag/builtin_rules/statement_cleanup", 1,
+    substitutions = substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_try_expression_cleanup: "feature_flag/builtin_rules/try_expression_cleanup", 1,
+    substitutions = substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+}
@@ -41,6 +41,12 @@ create_rewrite_tests! {
       "stale_flag" => "one"
     },
     cleanup_comments = true, delete_file_if_empty= false;
+  test_ternary_cleanup: "ternary_cleanup", 1,
+    substitutions = substitutions! {
+      "stale_flag" => "stale_flag_one",
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
 }
 
 fn execute_piranha_with_default_swift_args(scenario: &str, substitutions: Vec<(String, String)>) {
@@ -12,7 +12,7 @@ Copyright (c) 2023 Uber Technologies, Inc.
  express or implied. See the License for the specific language governing permissions and
  limitations under the License.
 */
-use super::create_match_tests;
+use super::{create_match_tests, create_rewrite_tests, substitutions};
 
 use crate::models::default_configs::TYPESCRIPT;
 
@@ -22,3 +22,12 @@ create_match_tests! {
   test_find_fors_within_functions:"structural_find/find_fors_within_functions", HashMap::from([("find_fors_within_functions", 2)]);
   test_find_fors: "structural_find/find_fors", HashMap::from([("find_fors", 3)]);
 }
+
+create_rewrite_tests! {
+  TYPESCRIPT,
+  test_simplify_boolean_return: "simplify_boolean_return", 1,
+    substitutions = substitutions! {
+      "stale_flag_name" => "new_checkout",
+      "treated" => "true"
+    }, simplify_boolean_return = true;
+}
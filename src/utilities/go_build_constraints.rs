@@ -0,0 +1,305 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Recognizes the build constraints of the Go files, i.e.
+//! * the `//go:build` line preceding the package clause (e.g. `//go:build experiment && !race`)
+//! * the legacy `// +build` lines, when there is no `//go:build` line (e.g. `// +build linux,amd64 darwin`)
+//! * the `_GOOS`, `_GOARCH` and `_GOOS_GOARCH` suffixes of the file name (e.g. `checkout_linux.go`)
+//!
+//! The files of a directory whose constraints cannot hold in the same build (e.g. `//go:build experiment` and
+//! `//go:build !experiment`) do not belong to the same package, hence the package rules of one are not applied to the
+//! other. The constraints are only checked to be satisfiable together, by enumerating the values of their tags, which
+//! is conservative: the invalid constraints (and the ones with too many tags) are satisfiable with anything.
+
+use std::{collections::HashSet, path::Path};
+
+use itertools::Itertools;
+
+/// The known operating systems (i.e. the values of `GOOS`), which may suffix the name of a file.
+const KNOWN_OS: [&str; 17] = [
+  "aix",
+  "android",
+  "darwin",
+  "dragonfly",
+  "freebsd",
+  "hurd",
+  "illumos",
+  "ios",
+  "js",
+  "linux",
+  "nacl",
+  "netbsd",
+  "openbsd",
+  "plan9",
+  "solaris",
+  "wasip1",
+  "windows",
+];
+
+/// The operating systems that also satisfy another one, along with it (e.g. `android` satisfies `linux`).
+const IMPLYING_OS: [(&str, &str); 3] = [
+  ("android", "linux"),
+  ("illumos", "solaris"),
+  ("ios", "darwin"),
+];
+
+/// The known architectures (i.e. the values of `GOARCH`), which may suffix the name of a file.
+const KNOWN_ARCH: [&str; 21] = [
+  "386",
+  "amd64",
+  "amd64p32",
+  "arm",
+  "arm64",
+  "arm64be",
+  "armbe",
+  "loong64",
+  "mips",
+  "mips64",
+  "mips64le",
+  "mips64p32",
+  "mips64p32le",
+  "mipsle",
+  "ppc64",
+  "ppc64le",
+  "riscv64",
+  "s390x",
+  "sparc64",
+  "wasm",
+  "ppc",
+];
+
+/// The maximum number of tags whose values are enumerated, beyond which the constraints are deemed satisfiable.
+const MAX_ENUMERATED_TAGS: usize = 12;
+
+/// A build constraint, i.e. a boolean expression over the build tags.
+#[derive(Debug, Clone, PartialEq, Eq, Hash)]
+pub(crate) enum BuildConstraint {
+  Tag(String),
+  Not(Box<BuildConstraint>),
+  And(Box<BuildConstraint>, Box<BuildConstraint>),
+  Or(Box<BuildConstraint>, Box<BuildConstraint>),
+}
+
+impl BuildConstraint {
+  fn and(self, other: BuildConstraint) -> BuildConstraint {
+    BuildConstraint::And(Box::new(self), Box::new(other))
+  }
+
+  fn or(self, other: BuildConstraint) -> BuildConstraint {
+    BuildConstraint::Or(Box::new(self), Box::new(other))
+  }
+
+  /// Checks if the constraint holds when exactly the `tags` are set.
+  pub(crate) fn is_satisfied_by(&self, tags: &HashSet<&str>) -> bool {
+    match self {
+      BuildConstraint::Tag(tag) => tags.contains(tag.as_str()),
+      BuildConstraint::Not(c) => !c.is_satisfied_by(tags),
+      BuildConstraint::And(a, b) => a.is_satisfied_by(tags) && b.is_satisfied_by(tags),
+      BuildConstraint::Or(a, b) => a.is_satisfied_by(tags) || b.is_satisfied_by(tags),
+    }
+  }
+
+  /// Returns the tags of the constraint.
+  fn tags(&self) -> Vec<&str> {
+    match self {
+      BuildConstraint::Tag(tag) => vec![tag.as_str()],
+      BuildConstraint::Not(c) => c.tags(),
+      BuildConstraint::And(a, b) | BuildConstraint::Or(a, b) => {
+        a.tags().into_iter().chain(b.tags()).collect()
+      }
+    }
+  }
+}
+
+/// Returns the build constraint of the Go file at `path`, i.e. the conjunction of the constraint of its header (the
+/// `//go:build` line, or else the `// +build` lines) and of the one of its name (if any).
+pub(crate) fn get_build_constraint(path: &Path, code: &str) -> Option<BuildConstraint> {
+  let header = code
+    .lines()
+    .take_while(|line| !line.starts_with("package "))
+    .map(str::trim)
+    .collect_vec();
+  let header_constraint = match header
+    .iter()
+    .find_map(|line| line.strip_prefix("//go:build "))
+  {
+    Some(expression) => parse_expression(expression),
+    None => header
+      .iter()
+      .filter_map(|line| line.strip_prefix("// +build "))
+      .filter_map(parse_legacy_line)
+      .reduce(BuildConstraint::and),
+  };
+  match (header_constraint, get_file_name_constraint(path)) {
+    (Some(a), Some(b)) => Some(a.and(b)),
+    (a, b) => a.or(b),
+  }
+}
+
+/// Checks if the two constraints may hold in the same build, i.e. the files belong to the same package in a build.
+pub(crate) fn are_compatible(a: &Option<BuildConstraint>, b: &Option<BuildConstraint>) -> bool {
+  let (a, b) = match (a, b) {
+    (Some(a), Some(b)) => (a, b),
+    _ => return true,
+  };
+  let mut tags = a.tags().into_iter().chain(b.tags()).collect_vec();
+  // The operating systems satisfied by the ones of the constraints are set along with them
+  for (os, implied) in IMPLYING_OS {
+    if tags.contains(&os) {
+      tags.push(implied);
+    }
+  }
+  let tags = tags.into_iter().unique().collect_vec();
+  if tags.len() > MAX_ENUMERATED_TAGS {
+    return true;
+  }
+  (0..1_u32 << tags.len()).any(|bits| {
+    let set_tags: HashSet<&str> = tags
+      .iter()
+      .enumerate()
+      .filter(|(i, _)| bits & (1 << i) != 0)
+      .map(|(_, tag)| *tag)
+      .collect();
+    is_possible(&set_tags) && a.is_satisfied_by(&set_tags) && b.is_satisfied_by(&set_tags)
+  })
+}
+
+/// Checks if the tags may be set together, i.e. they do not set two operating systems (or two architectures), and
+/// the operating systems satisfying another one are set along with it.
+fn is_possible(tags: &HashSet<&str>) -> bool {
+  let os_count = tags
+    .iter()
+    .filter(|t| KNOWN_OS.contains(*t) && !IMPLYING_OS.iter().any(|(os, _)| os == *t))
+    .count();
+  let arch_count = tags.iter().filter(|t| KNOWN_ARCH.contains(*t)).count();
+  os_count <= 1
+    && arch_count <= 1
+    && IMPLYING_OS
+      .iter()
+      .all(|(os, implied)| !tags.contains(os) || tags.contains(implied))
+}
+
+/// Returns the constraint of the `_GOOS`, `_GOARCH` or `_GOOS_GOARCH` suffix of the file name (ignoring `_test`), if any.
+fn get_file_name_constraint(path: &Path) -> Option<BuildConstraint> {
+  let stem = path.file_stem()?.to_str()?;
+  let stem = stem.strip_suffix("_test").unwrap_or(stem);
+  let parts = stem.split('_').collect_vec();
+  // A file name made of the suffix only (e.g. `linux.go`) is not constrained
+  match parts[..] {
+    [.., _, os, arch] if KNOWN_OS.contains(&os) && KNOWN_ARCH.contains(&arch) => {
+      Some(BuildConstraint::Tag(os.to_string()).and(BuildConstraint::Tag(arch.to_string())))
+    }
+    [.., _, suffix] if KNOWN_OS.contains(&suffix) || KNOWN_ARCH.contains(&suffix) => {
+      Some(BuildConstraint::Tag(suffix.to_string()))
+    }
+    _ => None,
+  }
+}
+
+/// Parses a legacy `// +build` line, i.e. a disjunction (separated by spaces) of conjunctions (separated by commas) of
+/// possibly negated tags.
+fn parse_legacy_line(line: &str) -> Option<BuildConstraint> {
+  line
+    .split_whitespace()
+    .map(|option| {
+      option
+        .split(',')
+        .map(|term| match term.strip_prefix('!') {
+          Some(tag) => parse_tag(tag).map(|t| BuildConstraint::Not(Box::new(t))),
+          None => parse_tag(term),
+        })
+        .collect::<Option<Vec<_>>>()?
+        .into_iter()
+        .reduce(BuildConstraint::and)
+    })
+    .collect::<Option<Vec<_>>>()?
+    .into_iter()
+    .reduce(BuildConstraint::or)
+}
+
+fn parse_tag(tag: &str) -> Option<BuildConstraint> {
+  let is_valid = !tag.is_empty()
+    && tag
+      .chars()
+      .all(|c| c.is_alphanumeric() || c == '_' || c == '.');
+  is_valid.then(|| BuildConstraint::Tag(tag.to_string()))
+}
+
+/// Parses a `//go:build` expression (i.e. tags combined with `!`, `&&`, `||` and parentheses), or returns `None` if it
+/// is invalid.
+fn parse_expression(expression: &str) -> Option<BuildConstraint> {
+  let mut tokens = vec![];
+  let mut chars = expression.chars().peekable();
+  while let Some(c) = chars.next() {
+    match c {
+      ' ' | '\t' => continue,
+      '!' | '(' | ')' => tokens.push(c.to_string()),
+      '&' | '|' if chars.next() == Some(c) => tokens.push(format!("{c}{c}")),
+      _ if c.is_alphanumeric() || c == '_' || c == '.' => {
+        let mut tag = c.to_string();
+        while let Some(&next) = chars.peek() {
+          if !(next.is_alphanumeric() || next == '_' || next == '.') {
+            break;
+          }
+          tag.push(next);
+          chars.next();
+        }
+        tokens.push(tag);
+      }
+      _ => return None,
+    }
+  }
+  let mut position = 0;
+  let constraint = parse_or(&tokens, &mut position)?;
+  (position == tokens.len()).then_some(constraint)
+}
+
+fn parse_or(tokens: &[String], position: &mut usize) -> Option<BuildConstraint> {
+  let mut constraint = parse_and(tokens, position)?;
+  while tokens.get(*position).map(String::as_str) == Some("||") {
+    *position += 1;
+    constraint = constraint.or(parse_and(tokens, position)?);
+  }
+  Some(constraint)
+}
+
+fn parse_and(tokens: &[String], position: &mut usize) -> Option<BuildConstraint> {
+  let mut constraint = parse_not(tokens, position)?;
+  while tokens.get(*position).map(String::as_str) == Some("&&") {
+    *position += 1;
+    constraint = constraint.and(parse_not(tokens, position)?);
+  }
+  Some(constraint)
+}
+
+fn parse_not(tokens: &[String], position: &mut usize) -> Option<BuildConstraint> {
+  let token = tokens.get(*position)?;
+  *position += 1;
+  match token.as_str() {
+    "!" => Some(BuildConstraint::Not(Box::new(parse_not(tokens, position)?))),
+    "(" => {
+      let constraint = parse_or(tokens, position)?;
+      if tokens.get(*position).map(String::as_str) != Some(")") {
+        return None;
+      }
+      *position += 1;
+      Some(constraint)
+    }
+    "&&" | "||" | ")" => None,
+    tag => parse_tag(tag),
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/go_build_constraints_test.rs"]
+mod go_build_constraints_test;
@@ -0,0 +1,326 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Fixes the short variable declarations (`:=`) and the assignments (`=`) of the rewritten Go code, whose declaring
+//! occurrence has changed because of the deleted (or split) declarations. For instance,
+//! * `s, err := compute()` becomes `s, err = compute()`, once the variable it declared (e.g. `enabled` in
+//!   `s, enabled, err := compute(), true, validate()`) is split off, and `s` and `err` are already declared in its scope
+//! * `err = validate()` becomes `err := validate()`, once the declaration of `err` (e.g. `enabled, err := true, nil`)
+//!   is deleted
+//!
+//! The names are resolved w.r.t. the (lexical) scopes of Go, i.e. the package, the functions (including their parameters)
+//! and the blocks (explicit or implicit, e.g. the `if` and `for` statements, and the clauses of a `switch`).
+
+use std::collections::HashSet;
+
+use tree_sitter::{Node, Range};
+
+/// The nodes introducing a new (lexical) scope. Note that the parameters of a function are declared in the same scope
+/// as the top-level declarations of its body.
+const SCOPE_NODES: [&str; 10] = [
+  "block",
+  "if_statement",
+  "for_statement",
+  "expression_switch_statement",
+  "type_switch_statement",
+  "select_statement",
+  "expression_case",
+  "default_case",
+  "type_case",
+  "communication_case",
+];
+
+/// Returns the names of the variables declared within the functions of the code (i.e. by a short variable declaration,
+/// a `var` declaration, a `range` clause or a `select` case), regardless of their scope.
+pub(crate) fn get_locally_declared_names(root: Node, code: &str) -> HashSet<String> {
+  let mut names = HashSet::new();
+  let mut stack = vec![(root, false)];
+  while let Some((node, within_function)) = stack.pop() {
+    let within_function = within_function
+      || ["function_declaration", "method_declaration", "func_literal"].contains(&node.kind());
+    match node.kind() {
+      "short_var_declaration" => names.extend(get_declared_names(node, "left", code)),
+      "range_clause" | "receive_statement" if has_child_of_kind(node, ":=") => {
+        names.extend(get_declared_names(node, "left", code))
+      }
+      "var_spec" if within_function => names.extend(get_declared_names(node, "name", code)),
+      _ => {}
+    }
+    stack.extend(
+      node
+        .named_children(&mut node.walk())
+        .map(|c| (c, within_function)),
+    );
+  }
+  names
+}
+
+/// Returns the range of the first operator to rewrite, along with its replacement, i.e.
+/// * the `:=` of a short variable declaration, that does not declare any new (non-blank) variable in its scope, with `=`
+/// * the `=` of an assignment to (at least) a variable that is not declared in any enclosing scope, with `:=`.
+///   Only the variables in `locally_declared_names` (i.e. the ones declared within a function before the rewrite)
+///   are considered, since the others may be declared at the package level in another file.
+///   The other variables of the assignment should be declared in the same scope (`:=` would shadow them otherwise).
+pub(crate) fn get_declaration_fix(
+  root: Node, code: &str, locally_declared_names: &HashSet<String>,
+) -> Option<(Range, &'static str)> {
+  let mut walker = DeclarationWalker {
+    code,
+    locally_declared_names,
+    scopes: vec![get_package_level_names(root, code)],
+  };
+  walker.visit_children(root)
+}
+
+/// Walks the code in the order of the declarations, while maintaining the names declared in each enclosing scope.
+struct DeclarationWalker<'a> {
+  code: &'a str,
+  locally_declared_names: &'a HashSet<String>,
+  // From the package scope to the innermost one
+  scopes: Vec<HashSet<String>>,
+}
+
+impl DeclarationWalker<'_> {
+  fn visit(&mut self, node: Node) -> Option<(Range, &'static str)> {
+    match node.kind() {
+      "function_declaration" | "method_declaration" | "func_literal" => {
+        let mut scope = HashSet::new();
+        for field in ["receiver", "parameters", "result"] {
+          if let Some(parameter_list) = node.child_by_field_name(field) {
+            scope.extend(get_parameter_names(parameter_list, self.code));
+          }
+        }
+        self.scopes.push(scope);
+        let fix = node
+          .child_by_field_name("body")
+          .and_then(|body| self.visit_children(body));
+        self.scopes.pop();
+        fix
+      }
+      kind if SCOPE_NODES.contains(&kind) => {
+        self.scopes.push(HashSet::new());
+        if kind == "type_switch_statement" {
+          let alias = get_declared_names(node, "alias", self.code);
+          self.declare(alias);
+        }
+        let fix = self.visit_children(node);
+        self.scopes.pop();
+        fix
+      }
+      "short_var_declaration" => self
+        .visit_field(node, "right")
+        .or_else(|| self.get_short_var_declaration_fix(node)),
+      "assignment_statement" => self
+        .visit_field(node, "right")
+        .or_else(|| self.get_assignment_fix(node)),
+      "var_spec" | "const_spec" => self.visit_field(node, "value").or_else(|| {
+        let names = get_declared_names(node, "name", self.code);
+        self.declare(names);
+        None
+      }),
+      "type_spec" | "type_alias" => {
+        let names = get_declared_names(node, "name", self.code);
+        self.declare(names);
+        None
+      }
+      "range_clause" | "receive_statement" => self.visit_field(node, "right").or_else(|| {
+        if has_child_of_kind(node, ":=") {
+          let names = get_declared_names(node, "left", self.code);
+          self.declare(names);
+        }
+        None
+      }),
+      _ => self.visit_children(node),
+    }
+  }
+
+  fn visit_children(&mut self, node: Node) -> Option<(Range, &'static str)> {
+    node
+      .named_children(&mut node.walk())
+      .find_map(|child| self.visit(child))
+  }
+
+  fn visit_field(&mut self, node: Node, field: &str) -> Option<(Range, &'static str)> {
+    node
+      .child_by_field_name(field)
+      .and_then(|child| self.visit(child))
+  }
+
+  /// Replaces `:=` with `=`, if all the (non-blank) variables are already declared in the current scope.
+  /// Otherwise, declares them.
+  fn get_short_var_declaration_fix(&mut self, node: Node) -> Option<(Range, &'static str)> {
+    let names = get_declared_names(node, "left", self.code);
+    let current_scope = self.scopes.last()?;
+    if !names.is_empty() && names.iter().all(|n| current_scope.contains(n)) {
+      return get_child_of_kind(node, ":=").map(|op| (op.range(), "="));
+    }
+    self.declare(names);
+    None
+  }
+
+  /// Replaces `=` with `:=`, if (at least) a variable is not declared in any enclosing scope.
+  fn get_assignment_fix(&self, node: Node) -> Option<(Range, &'static str)> {
+    let operator = node.child_by_field_name("operator")?;
+    let left = node.child_by_field_name("left")?;
+    if operator.kind() != "=" {
+      return None;
+    }
+    // `:=` only accepts identifiers on the left hand side
+    let mut names = vec![];
+    for operand in left.named_children(&mut left.walk()) {
+      if operand.kind() != "identifier" {
+        return None;
+      }
+      names.push(operand.utf8_text(self.code.as_bytes()).ok()?.to_string());
+    }
+    names.retain(|n| n != "_");
+    let (undeclared, declared): (Vec<&String>, Vec<&String>) = names
+      .iter()
+      .partition(|n| !self.scopes.iter().any(|s| s.contains(*n)));
+    let current_scope = self.scopes.last()?;
+    if !undeclared.is_empty()
+      && undeclared
+        .iter()
+        .all(|n| self.locally_declared_names.contains(*n))
+      && declared.iter().all(|n| current_scope.contains(*n))
+    {
+      return Some((operator.range(), ":="));
+    }
+    None
+  }
+
+  fn declare(&mut self, names: Vec<String>) {
+    if let Some(scope) = self.scopes.last_mut() {
+      scope.extend(names);
+    }
+  }
+}
+
+/// Checks if unwrapping the block (i.e. replacing it with its statements) would make a name it declares (at its top
+/// level) collide with the enclosing scope. The check is conservative: any other occurrence of the name within the
+/// enclosing function (or file), e.g. `err` in `{ err := validate() }; return err`, is considered a collision.
+pub(crate) fn is_unwrapping_colliding(block: Node, code: &str) -> bool {
+  let mut names = HashSet::new();
+  let statements = block
+    .named_children(&mut block.walk())
+    .filter(|c| c.kind() == "statement_list")
+    .flat_map(|c| c.named_children(&mut c.walk()).collect::<Vec<_>>());
+  for statement in statements {
+    match statement.kind() {
+      "short_var_declaration" => names.extend(get_declared_names(statement, "left", code)),
+      "var_declaration" | "const_declaration" | "type_declaration" => {
+        names.extend(get_spec_names(statement, code))
+      }
+      _ => {}
+    }
+  }
+  if names.is_empty() {
+    return false;
+  }
+  let mut scope = block;
+  while let Some(parent) = scope.parent() {
+    scope = parent;
+    if ["function_declaration", "method_declaration", "func_literal"].contains(&scope.kind()) {
+      break;
+    }
+  }
+  let mut stack = vec![scope];
+  while let Some(node) = stack.pop() {
+    if node.range() == block.range() {
+      continue;
+    }
+    if ["identifier", "type_identifier"].contains(&node.kind())
+      && node
+        .utf8_text(code.as_bytes())
+        .map_or(false, |n| names.contains(n))
+    {
+      return true;
+    }
+    stack.extend(node.named_children(&mut node.walk()));
+  }
+  false
+}
+
+/// Returns the names declared at the package level (in this file), i.e. the functions, variables, constants and types.
+fn get_package_level_names(root: Node, code: &str) -> HashSet<String> {
+  let mut names = HashSet::new();
+  for declaration in root.named_children(&mut root.walk()) {
+    match declaration.kind() {
+      "function_declaration" => names.extend(get_declared_names(declaration, "name", code)),
+      "var_declaration" | "const_declaration" | "type_declaration" => {
+        names.extend(get_spec_names(declaration, code))
+      }
+      _ => {}
+    }
+  }
+  names
+}
+
+/// Returns the names of the specs of a `var`, `const` or `type` declaration (grouped or not).
+fn get_spec_names(declaration: Node, code: &str) -> Vec<String> {
+  let mut names = vec![];
+  let mut stack = vec![declaration];
+  while let Some(node) = stack.pop() {
+    if ["var_spec", "const_spec", "type_spec", "type_alias"].contains(&node.kind()) {
+      names.extend(get_declared_names(node, "name", code));
+    } else {
+      stack.extend(node.named_children(&mut node.walk()));
+    }
+  }
+  names
+}
+
+/// Returns the names of the parameters (or of the named results) of the parameter list.
+fn get_parameter_names(parameter_list: Node, code: &str) -> Vec<String> {
+  parameter_list
+    .named_children(&mut parameter_list.walk())
+    .filter(|p| ["parameter_declaration", "variadic_parameter_declaration"].contains(&p.kind()))
+    .flat_map(|p| get_declared_names(p, "name", code))
+    .collect()
+}
+
+/// Returns the (non-blank) identifiers of the given field of the node, e.g. the names on the left hand side of
+/// a short variable declaration (`left`), or the names of a `var` spec (`name`).
+fn get_declared_names(node: Node, field: &str, code: &str) -> Vec<String> {
+  let mut names = vec![];
+  for child in node.children_by_field_name(field, &mut node.walk()) {
+    let identifiers = if child.kind() == "expression_list" {
+      child.named_children(&mut child.walk()).collect()
+    } else {
+      vec![child]
+    };
+    names.extend(
+      identifiers
+        .iter()
+        .filter(|i| ["identifier", "type_identifier"].contains(&i.kind()))
+        .filter_map(|i| i.utf8_text(code.as_bytes()).ok())
+        .filter(|n| *n != "_")
+        .map(String::from),
+    );
+  }
+  names
+}
+
+fn get_child_of_kind<'a>(node: Node<'a>, kind: &str) -> Option<Node<'a>> {
+  let mut cursor = node.walk();
+  let child = node.children(&mut cursor).find(|c| c.kind() == kind);
+  child
+}
+
+fn has_child_of_kind(node: Node, kind: &str) -> bool {
+  get_child_of_kind(node, kind).is_some()
+}
+
+#[cfg(test)]
+#[path = "unit_tests/go_declarations_test.rs"]
+mod go_declarations_test;
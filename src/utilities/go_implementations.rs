@@ -0,0 +1,339 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Finds the (top-level) Go struct types, along with their methods and constructors, to delete the implementations
+//! that are not selected anymore once the flag is cleaned up (see `delete_unselected_implementations`). For instance,
+//! `legacyFlow` is deleted (along with its methods and `newLegacyFlow`), once
+//! `if exp.BoolValue("new_checkout") { return newFlow{} }; return newLegacyFlow()` resolves to `return newFlow{}`.
+//!
+//! Like the functions (see `go_unreachable_functions`), the types are resolved by name only. The methods of a type are
+//! the method declarations whose receiver has this (base) type, and its constructors are the functions returning it
+//! (e.g. `func newLegacyFlow() *legacyFlow` or `func newLegacyFlow() (*legacyFlow, error)`). A type implements an
+//! interface when it declares all its methods (by name), which is only used to report the interfaces left with a
+//! single implementation.
+
+use tree_sitter::{Node, Range};
+
+use super::go_unreachable_functions::get_doc_comment;
+
+/// A Go file, i.e. the root of its syntax tree along with its code.
+pub(crate) type GoFile<'a> = (Node<'a>, &'a str);
+
+/// A top-level declaration (i.e. a type, a method or a function) of one of the files.
+#[derive(Debug, Clone, PartialEq)]
+pub(crate) struct Declaration {
+  pub(crate) name: String,
+  /// The index of the file declaring it
+  pub(crate) file: usize,
+  /// The range of the declaration, along with its doc comment (if any)
+  pub(crate) range: Range,
+}
+
+/// A struct type, along with its methods and its constructors.
+#[derive(Debug, Clone, PartialEq)]
+pub(crate) struct Implementation {
+  pub(crate) declaration: Declaration,
+  /// The name of the package declaring the type (i.e. of the package clause of its file)
+  pub(crate) package: String,
+  pub(crate) methods: Vec<Declaration>,
+  pub(crate) constructors: Vec<Declaration>,
+}
+
+impl Implementation {
+  pub(crate) fn name(&self) -> &str {
+    &self.declaration.name
+  }
+
+  /// Returns the declarations of the type, of its methods and of its constructors.
+  pub(crate) fn declarations(&self) -> impl Iterator<Item = &Declaration> {
+    std::iter::once(&self.declaration)
+      .chain(self.methods.iter())
+      .chain(self.constructors.iter())
+  }
+
+  /// Checks if the name refers to the type or to one of its constructors.
+  fn is_named(&self, name: &str) -> bool {
+    self.name() == name || self.constructors.iter().any(|c| c.name == name)
+  }
+
+  /// Checks if the byte of the file is within one of the declarations.
+  fn contains(&self, file: usize, byte: usize) -> bool {
+    self
+      .declarations()
+      .any(|d| d.file == file && d.range.start_byte <= byte && byte < d.range.end_byte)
+  }
+}
+
+/// A reference to a type (i.e. a type identifier) or to a function (i.e. an identifier) in one of the files, or
+/// a qualified one (e.g. `checkout.LegacyReceipt` or `checkout.NewLegacyReceipt()`).
+#[derive(Debug, Clone, PartialEq)]
+pub(crate) struct Reference {
+  pub(crate) name: String,
+  /// The index of the file
+  pub(crate) file: usize,
+  pub(crate) start_byte: usize,
+  /// The (1-based) line of the reference
+  pub(crate) line: usize,
+  /// Whether the reference is qualified with the name of another package (or of any other operand, for the selectors)
+  pub(crate) is_qualified: bool,
+}
+
+impl Reference {
+  /// Checks if the (qualified) reference refers to the exported type of the implementation or to one of its exported
+  /// constructors, by name (i.e. regardless of its package).
+  pub(crate) fn refers_to(&self, implementation: &Implementation) -> bool {
+    self.is_qualified && is_exported(&self.name) && implementation.is_named(&self.name)
+  }
+}
+
+/// Returns the name of the package of the file (i.e. of its package clause).
+pub(crate) fn get_package_name(root: Node, code: &str) -> Option<String> {
+  root
+    .named_children(&mut root.walk())
+    .find(|n| n.kind() == "package_clause")
+    .and_then(|n| n.named_child(0))
+    .and_then(|n| n.utf8_text(code.as_bytes()).ok())
+    .map(String::from)
+}
+
+/// Returns the struct types declared (on their own) in the files, along with their methods and constructors in any of
+/// the files of the same package.
+pub(crate) fn get_implementations(files: &[GoFile]) -> Vec<Implementation> {
+  let packages: Vec<Option<String>> = files
+    .iter()
+    .map(|(root, code)| get_package_name(*root, code))
+    .collect();
+  let mut implementations = vec![];
+  for (i, (root, code)) in files.iter().enumerate() {
+    let package = match &packages[i] {
+      Some(package) => package,
+      None => continue,
+    };
+    for declaration in root.named_children(&mut root.walk()) {
+      let type_specs: Vec<Node> = declaration
+        .named_children(&mut declaration.walk())
+        .filter(|n| n.kind() == "type_spec")
+        .collect();
+      // The type declarations grouping several types are retained as a whole
+      if declaration.kind() != "type_declaration" || type_specs.len() != 1 {
+        continue;
+      }
+      let is_struct = type_specs[0]
+        .child_by_field_name("type")
+        .map_or(false, |t| t.kind() == "struct_type");
+      if let (true, Some(name)) = (is_struct, get_name(type_specs[0], code)) {
+        implementations.push(Implementation {
+          declaration: Declaration {
+            name,
+            file: i,
+            range: get_range(declaration),
+          },
+          package: package.to_string(),
+          methods: vec![],
+          constructors: vec![],
+        });
+      }
+    }
+  }
+  for (i, (root, code)) in files.iter().enumerate() {
+    for declaration in root.named_children(&mut root.walk()) {
+      let (type_name, is_method) = match declaration.kind() {
+        "method_declaration" => (get_receiver_type(declaration, code), true),
+        "function_declaration" => (get_result_type(declaration, code), false),
+        _ => continue,
+      };
+      let implementation = implementations.iter_mut().find(|m| {
+        Some(m.name()) == type_name.as_deref() && Some(&m.package) == packages[i].as_ref()
+      });
+      if let (Some(implementation), Some(name)) = (implementation, get_name(declaration, code)) {
+        let declaration = Declaration {
+          name,
+          file: i,
+          range: get_range(declaration),
+        };
+        if is_method {
+          implementation.methods.push(declaration);
+        } else {
+          implementation.constructors.push(declaration);
+        }
+      }
+    }
+  }
+  implementations
+}
+
+/// Returns the references to the types and to the functions in the files, i.e. all the type identifiers and the
+/// identifiers except the names of the declarations, along with the selected fields of the selectors whose operand is
+/// an identifier (as qualified references).
+pub(crate) fn get_references(files: &[GoFile]) -> Vec<Reference> {
+  let mut references = vec![];
+  for (i, (root, code)) in files.iter().enumerate() {
+    let mut stack = vec![*root];
+    while let Some(node) = stack.pop() {
+      stack.extend(node.named_children(&mut node.walk()));
+      let parent = match node.parent() {
+        Some(parent) => parent,
+        None => continue,
+      };
+      let is_qualified = match (node.kind(), parent.kind()) {
+        ("type_identifier", "type_spec") | ("identifier", "function_declaration")
+          if parent.child_by_field_name("name") == Some(node) =>
+        {
+          continue
+        }
+        ("type_identifier", "qualified_type") => true,
+        ("type_identifier", _) | ("identifier", _) => false,
+        ("field_identifier", "selector_expression")
+          if parent
+            .child_by_field_name("operand")
+            .map_or(false, |o| o.kind() == "identifier") =>
+        {
+          true
+        }
+        _ => continue,
+      };
+      if let Ok(name) = node.utf8_text(code.as_bytes()) {
+        references.push(Reference {
+          name: name.to_string(),
+          file: i,
+          start_byte: node.start_byte(),
+          line: node.start_position().row + 1,
+          is_qualified,
+        });
+      }
+    }
+  }
+  references.sort_by_key(|r| (r.file, r.start_byte));
+  references
+}
+
+/// Checks if the implementation is referred (outside of its own declarations) by the unqualified references of the
+/// files of its package, given the name of the package of each file.
+pub(crate) fn is_referred(
+  implementation: &Implementation, references: &[Reference], packages: &[Option<String>],
+) -> bool {
+  references.iter().any(|r| {
+    !r.is_qualified
+      && packages[r.file].as_ref() == Some(&implementation.package)
+      && implementation.is_named(&r.name)
+      && !implementation.contains(r.file, r.start_byte)
+  })
+}
+
+/// Returns the qualified references to the exported type (or constructors) of the implementation from the files of
+/// another package, given the name of the package of each file.
+pub(crate) fn get_external_references<'a>(
+  implementation: &Implementation, references: &'a [Reference], packages: &[Option<String>],
+) -> Vec<&'a Reference> {
+  references
+    .iter()
+    .filter(|r| {
+      packages[r.file].as_ref() != Some(&implementation.package) && r.refers_to(implementation)
+    })
+    .collect()
+}
+
+/// Returns the interfaces declared in the files, along with the names of their methods. The interfaces embedding
+/// another one (or a type constraint) are skipped, since their method set is not known.
+pub(crate) fn get_interfaces(files: &[GoFile]) -> Vec<(String, Vec<String>)> {
+  let mut interfaces = vec![];
+  for (root, code) in files {
+    let mut stack = vec![*root];
+    while let Some(node) = stack.pop() {
+      stack.extend(node.named_children(&mut node.walk()));
+      let interface_type = match node.child_by_field_name("type") {
+        Some(t) if node.kind() == "type_spec" && t.kind() == "interface_type" => t,
+        _ => continue,
+      };
+      let elements: Vec<Node> = interface_type
+        .named_children(&mut interface_type.walk())
+        .filter(|n| n.kind() != "comment")
+        .collect();
+      if elements.is_empty()
+        || elements
+          .iter()
+          .any(|n| !["method_spec", "method_elem"].contains(&n.kind()))
+      {
+        continue;
+      }
+      let methods = elements.iter().filter_map(|n| get_name(*n, code)).collect();
+      if let Some(name) = get_name(node, code) {
+        interfaces.push((name, methods));
+      }
+    }
+  }
+  interfaces
+}
+
+/// Checks if the implementation declares all the `methods` (of an interface).
+pub(crate) fn implements(implementation: &Implementation, methods: &[String]) -> bool {
+  methods
+    .iter()
+    .all(|m| implementation.methods.iter().any(|d| &d.name == m))
+}
+
+/// Checks if the name is exported, i.e. starts with an upper case letter.
+fn is_exported(name: &str) -> bool {
+  name.chars().next().map_or(false, char::is_uppercase)
+}
+
+/// Returns the name of the declaration (i.e. its `name` field).
+fn get_name(declaration: Node, code: &str) -> Option<String> {
+  declaration
+    .child_by_field_name("name")?
+    .utf8_text(code.as_bytes())
+    .ok()
+    .map(String::from)
+}
+
+/// Returns the range of the declaration, along with its doc comment (if any).
+fn get_range(declaration: Node) -> Range {
+  let start = get_doc_comment(declaration).unwrap_or(declaration);
+  Range {
+    start_byte: start.start_byte(),
+    end_byte: declaration.end_byte(),
+    start_point: start.start_position(),
+    end_point: declaration.end_position(),
+  }
+}
+
+/// Returns the (base) type of the receiver of the method, e.g. `legacyFlow` for `func (f *legacyFlow) Run()`.
+fn get_receiver_type(method: Node, code: &str) -> Option<String> {
+  let receiver = method.child_by_field_name("receiver")?;
+  let parameter = receiver.named_child(0)?;
+  get_base_type(parameter.child_by_field_name("type")?, code)
+}
+
+/// Returns the (base) type returned by the function, i.e. its result or the first one of its results, e.g.
+/// `legacyFlow` for `func newLegacyFlow() (*legacyFlow, error)`.
+fn get_result_type(function: Node, code: &str) -> Option<String> {
+  let result = function.child_by_field_name("result")?;
+  if result.kind() != "parameter_list" {
+    return get_base_type(result, code);
+  }
+  get_base_type(result.named_child(0)?.child_by_field_name("type")?, code)
+}
+
+/// Returns the name of the type, or of the type it points to (or instantiates), e.g. `legacyFlow` for `*legacyFlow`.
+fn get_base_type(node: Node, code: &str) -> Option<String> {
+  match node.kind() {
+    "type_identifier" => node.utf8_text(code.as_bytes()).ok().map(String::from),
+    "pointer_type" => get_base_type(node.named_child(0)?, code),
+    "generic_type" => get_base_type(node.child_by_field_name("type")?, code),
+    _ => None,
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/go_implementations_test.rs"]
+mod go_implementations_test;
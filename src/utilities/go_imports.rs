@@ -0,0 +1,121 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Finds the imports of the rewritten Go code that are not used anymore (Go fails to compile otherwise).
+//!
+//! The package name of an import without alias is guessed from its path (see `get_go_package_name`), which is not
+//! always right (e.g. `gopkg.in/check.v1` may declare `package check_v1`). Hence, an import is only considered unused,
+//! when its package name was referred before the rewrite and is not referred anymore. An import whose guessed name
+//! was never referred is left as is, since its package may be declared under another name.
+
+use std::collections::HashSet;
+
+use itertools::Itertools;
+use regex::Regex;
+use tree_sitter::Node;
+
+/// Returns the names of the packages referred in the code, i.e. the operands of the selector expressions
+/// (e.g. `fmt` in `fmt.Println`) and the packages of the qualified types (e.g. `http` in `http.Handler`).
+/// The operands may also be variables (e.g. `s` in `s.Start()`), which is fine, since only names are compared.
+pub(crate) fn get_referred_packages(root: Node, code: &str) -> HashSet<String> {
+  let mut packages = HashSet::new();
+  let mut stack = vec![root];
+  while let Some(node) = stack.pop() {
+    let package = match node.kind() {
+      "selector_expression" => node
+        .child_by_field_name("operand")
+        .filter(|n| n.kind() == "identifier"),
+      "qualified_type" => node.child_by_field_name("package"),
+      _ => None,
+    };
+    if let Some(package) = package {
+      packages.insert(package.utf8_text(code.as_bytes()).unwrap().to_string());
+    }
+    stack.extend(node.named_children(&mut node.walk()));
+  }
+  packages
+}
+
+/// Returns the first unused import spec of the code (along with its package name), i.e. the ones whose package
+/// is in `originally_referred_packages` (the packages referred before the rewrite), but not referred in the code.
+/// The node to delete is the whole `import` declaration (or the parenthesized list of specs), if the spec is the only
+/// one it declares. Blank (`_`) and dot (`.`) imports are never deleted.
+pub(crate) fn get_unused_import<'a>(
+  root: Node<'a>, code: &str, originally_referred_packages: &HashSet<String>,
+) -> Option<(Node<'a>, String)> {
+  let referred_packages = get_referred_packages(root, code);
+  let mut stack = vec![root];
+  while let Some(node) = stack.pop() {
+    if node.kind() != "import_spec" {
+      stack.extend(
+        node
+          .named_children(&mut node.walk())
+          .collect_vec()
+          .into_iter()
+          .rev(),
+      );
+      continue;
+    }
+    let package_name = match node.child_by_field_name("name") {
+      Some(alias) => Some(alias.utf8_text(code.as_bytes()).unwrap().to_string()),
+      None => node
+        .child_by_field_name("path")
+        .and_then(|path| get_go_package_name(path.utf8_text(code.as_bytes()).unwrap())),
+    };
+    // Retain the blank, dot and referred imports (as well as the ones whose package name cannot be inferred,
+    // or was not referred before the rewrite)
+    let package_name = match package_name {
+      Some(p)
+        if p != "_"
+          && p != "."
+          && originally_referred_packages.contains(&p)
+          && !referred_packages.contains(&p) =>
+      {
+        p
+      }
+      _ => continue,
+    };
+    let mut node_to_delete = node;
+    if let Some(parent) = node.parent() {
+      if parent.kind() == "import_declaration" {
+        node_to_delete = parent;
+      } else if parent.named_child_count() == 1 {
+        node_to_delete = parent.parent().unwrap_or(parent);
+      }
+    }
+    return Some((node_to_delete, package_name));
+  }
+  None
+}
+
+/// Infers the package name of a Go import from its path (i.e. the last element of the path).
+/// For instance, `"github.com/uber/exp"` -> `exp`, `"github.com/uber/exp/v2"` -> `exp`,
+/// `"gopkg.in/yaml.v3"` -> `yaml` and `"github.com/mattn/go-sqlite3"` -> `sqlite3`.
+/// Returns `None` if the package name cannot be inferred.
+pub(crate) fn get_go_package_name(path: &str) -> Option<String> {
+  let elements = path.trim_matches('"').split('/').collect_vec();
+  let mut name = *elements.last()?;
+  if elements.len() > 1 && Regex::new(r"^v[0-9]+$").unwrap().is_match(name) {
+    name = elements[elements.len() - 2];
+  }
+  let name = name.split('.').next()?;
+  let name = name.strip_prefix("go-").unwrap_or(name);
+  Regex::new(r"^[A-Za-z_][A-Za-z0-9_]*$")
+    .unwrap()
+    .is_match(name)
+    .then(|| name.to_string())
+}
+
+#[cfg(test)]
+#[path = "unit_tests/go_imports_test.rs"]
+mod go_imports_test;
@@ -0,0 +1,96 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Recognizes the opt-out directives of the Go code, i.e.
+//! * `//piranha:keep`, which exempts the statement (e.g. an `if` statement) or the function declaration it annotates
+//!   from the rewrites, along with its children
+//! * `//piranha:keep-file`, which exempts the whole file when it precedes the package clause
+//!
+//! A directive may be followed by an explanation, e.g. `//piranha:keep until the migration is over`.
+
+use std::collections::HashSet;
+
+use tree_sitter::{Node, Range};
+
+pub(crate) const KEEP_DIRECTIVE: &str = "//piranha:keep";
+pub(crate) const KEEP_FILE_DIRECTIVE: &str = "//piranha:keep-file";
+
+/// The declarations that can be kept (besides the statements), i.e. the nodes whose kind ends with `_statement`.
+const KEEPABLE_DECLARATIONS: [&str; 6] = [
+  "function_declaration",
+  "method_declaration",
+  "short_var_declaration",
+  "var_declaration",
+  "const_declaration",
+  "type_declaration",
+];
+
+/// Checks if the (trimmed) comment is the given directive, optionally followed by an explanation.
+pub(crate) fn is_directive(comment: &str, directive: &str) -> bool {
+  comment.strip_prefix(directive).map_or(false, |rest| {
+    rest.is_empty() || rest.starts_with(char::is_whitespace)
+  })
+}
+
+/// Returns the ranges of the nodes kept with `//piranha:keep`, i.e. the statements and the declarations
+/// * starting on the line following the directive, when the directive is on its own line
+/// * starting on the line of the directive, when the directive trails the code (e.g. `x := f() //piranha:keep`,
+///   or `if enabled { //piranha:keep`)
+pub(crate) fn get_kept_ranges(root: Node, code: &str) -> Vec<Range> {
+  // The rows of the nodes annotated by a directive
+  let mut annotated_rows = HashSet::new();
+  let mut stack = vec![root];
+  while let Some(node) = stack.pop() {
+    if node.kind() == "comment" {
+      let text = node.utf8_text(code.as_bytes()).unwrap_or_default().trim();
+      if is_directive(text, KEEP_DIRECTIVE) {
+        let row = node.start_position().row;
+        let line_start = node.start_byte() - node.start_position().column;
+        if code[line_start..node.start_byte()].trim().is_empty() {
+          annotated_rows.insert(row + 1);
+        } else {
+          annotated_rows.insert(row);
+        }
+      }
+    }
+    stack.extend(node.named_children(&mut node.walk()));
+  }
+  if annotated_rows.is_empty() {
+    return vec![];
+  }
+
+  let mut kept_ranges = vec![];
+  let mut stack = vec![root];
+  while let Some(node) = stack.pop() {
+    let is_keepable =
+      node.kind().ends_with("_statement") || KEEPABLE_DECLARATIONS.contains(&node.kind());
+    if is_keepable && annotated_rows.contains(&node.start_position().row) {
+      // The children of a kept node are kept along with it
+      kept_ranges.push(node.range());
+      continue;
+    }
+    stack.extend(node.named_children(&mut node.walk()));
+  }
+  kept_ranges
+}
+
+/// Checks if the `range` overlaps any of the kept ranges, i.e. rewriting it would change a kept node.
+pub(crate) fn overlaps_kept_range(range: Range, kept_ranges: &[Range]) -> bool {
+  kept_ranges
+    .iter()
+    .any(|k| range.start_byte < k.end_byte && k.start_byte < range.end_byte)
+}
+
+#[cfg(test)]
+#[path = "unit_tests/go_keep_directives_test.rs"]
+mod go_keep_directives_test;
@@ -0,0 +1,265 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Normalizes the layout of the rewritten Go code, i.e. it re-indents the lines (with tabs), collapses the consecutive
+//! blank lines, deletes the trailing whitespace and sorts the import specs.
+//! This is only a subset of `gofmt`: the alignment within the lines (e.g. of the field types of a struct) is left as is.
+
+use std::collections::{HashMap, HashSet};
+
+use itertools::Itertools;
+use tree_sitter::{Node, Parser};
+
+/// The nodes whose content (i.e. the children starting on a later row) is indented by one level,
+/// e.g. the statements of a `block` or the elements of a `literal_value`.
+const INDENTING_NODES: [&str; 17] = [
+  "block",
+  "literal_value",
+  "field_declaration_list",
+  "interface_type",
+  "method_spec_list",
+  "import_spec_list",
+  "const_declaration",
+  "var_declaration",
+  "var_spec_list",
+  "type_declaration",
+  "argument_list",
+  "special_argument_list",
+  "parameter_list",
+  "expression_case",
+  "default_case",
+  "type_case",
+  "communication_case",
+];
+
+/// The nodes whose children (starting on a later row) are not continuation lines,
+/// e.g. the cases of a `switch` are aligned with the `switch` keyword.
+const NON_CONTINUATION_NODES: [&str; 6] = [
+  "source_file",
+  "statement_list",
+  "labeled_statement",
+  "expression_switch_statement",
+  "type_switch_statement",
+  "select_statement",
+];
+
+/// Normalizes the layout of the given Go code :
+/// * the lines are indented with tabs, w.r.t. the nesting of their first token
+///   (the continuation lines of an expression are indented by one more level)
+/// * the consecutive blank lines are collapsed, and the leading and trailing blank lines are deleted
+/// * the trailing whitespace is deleted
+/// * the import specs are sorted within their groups (separated by blank lines),
+///   and the blank lines at the start and the end of the import blocks are deleted
+///
+/// The raw string literals are left as is. The code is returned unchanged, if it has syntax errors.
+pub(crate) fn normalize_go_layout(code: &str, parser: &mut Parser) -> String {
+  let tree = parser.parse(code, None).expect("Could not parse the code!");
+  if tree.root_node().has_error() {
+    return code.to_string();
+  }
+  let indented_code = indent_lines(code, tree.root_node());
+  let tree = parser
+    .parse(&indented_code, None)
+    .expect("Could not parse the code!");
+  let formatted_code = sort_import_specs(&indented_code, tree.root_node());
+  // Never produce a syntactically incorrect code (e.g. if a multi-line token was not recognized as such)
+  if parser
+    .parse(&formatted_code, None)
+    .map_or(true, |t| t.root_node().has_error())
+  {
+    return code.to_string();
+  }
+  formatted_code
+}
+
+/// Re-indents the lines of the code, deletes their trailing whitespace and collapses the consecutive blank lines.
+/// The lines starting within a comment or a raw string literal keep their indentation,
+/// and the lines ending within a raw string literal keep their trailing whitespace.
+fn indent_lines(code: &str, root: Node) -> String {
+  let mut lines: Vec<String> = vec![];
+  let mut line_start = 0;
+  for line in code.split('\n') {
+    let line_end = line_start + line.len();
+    let line = match get_enclosing_token_kind(root, line_end) {
+      Some("raw_string_literal") => line,
+      _ => line.trim_end(),
+    };
+    let content = line.trim_start_matches(|c: char| c == ' ' || c == '\t');
+    if get_enclosing_token_kind(root, line_start).is_some() {
+      lines.push(line.to_string());
+    } else if content.is_empty() {
+      if lines.last().map_or(false, |l| !l.is_empty()) {
+        lines.push(String::new());
+      }
+    } else {
+      let level = get_indentation_level(root, code, line_start + line.len() - content.len());
+      lines.push(format!("{}{}", "\t".repeat(level), content));
+    }
+    line_start = line_end + 1;
+  }
+  while lines.last().map_or(false, |l| l.is_empty()) {
+    lines.pop();
+  }
+  if lines.is_empty() {
+    return String::new();
+  }
+  format!("{}\n", lines.join("\n"))
+}
+
+/// Returns the kind of the (multi-line) token the given byte is strictly within, i.e. a `comment` or a `raw_string_literal`.
+fn get_enclosing_token_kind(root: Node, byte: usize) -> Option<&'static str> {
+  let mut node = root.descendant_for_byte_range(byte, byte)?;
+  loop {
+    if ["comment", "raw_string_literal"].contains(&node.kind()) {
+      return (node.start_byte() < byte && byte < node.end_byte()).then(|| node.kind());
+    }
+    node = node.parent()?;
+  }
+}
+
+/// Computes the indentation level of the line whose first token starts at the given byte.
+/// Walking up from the outermost node starting at this byte, the level is incremented for each ancestor
+/// that starts on an earlier row, and is either
+/// * an indenting node (e.g. a `block`), unless the node is its closing bracket
+/// * an expression (or statement) continued on this row, unless the node is a `block` (e.g. after a multi-line condition)
+///   or its row starts with a closing bracket (e.g. `} else if ...`)
+///
+/// As `gofmt`, the labels are indented by one level less than their statements.
+fn get_indentation_level(root: Node, code: &str, byte: usize) -> usize {
+  let mut node = match root.descendant_for_byte_range(byte, byte) {
+    Some(n) => n,
+    None => return 0,
+  };
+  let mut is_label = false;
+  while let Some(parent) = node.parent() {
+    if parent.start_byte() != byte {
+      break;
+    }
+    is_label |= parent.kind() == "labeled_statement";
+    node = parent;
+  }
+
+  let mut level = 0;
+  let mut child = node;
+  while let Some(parent) = child.parent() {
+    if child.start_position().row > parent.start_position().row {
+      if INDENTING_NODES.contains(&parent.kind()) {
+        let is_closing_bracket =
+          child.next_sibling().is_none() && ["}", ")"].contains(&child.kind());
+        if !is_closing_bracket {
+          level += 1;
+        }
+      } else if !NON_CONTINUATION_NODES.contains(&parent.kind())
+        && child.kind() != "block"
+        && !starts_after_closing_bracket(code, child)
+      {
+        level += 1;
+      }
+    }
+    child = parent;
+  }
+  if is_label {
+    level.saturating_sub(1)
+  } else {
+    level
+  }
+}
+
+/// Checks if the row of the given node starts with a closing bracket, e.g. `} else if ...` or `}, Bar{`.
+fn starts_after_closing_bracket(code: &str, node: Node) -> bool {
+  let line_start = node.start_byte() - node.start_position().column;
+  code[line_start..]
+    .trim_start()
+    .starts_with(|c: char| "})]".contains(c))
+}
+
+/// Sorts the import specs of the (parenthesized) import declarations within their groups, and deletes the duplicate specs
+/// as well as the blank lines at the start and the end of the import blocks.
+/// The groups containing (standalone) comments, or several specs on the same line, are not sorted.
+fn sort_import_specs(code: &str, root: Node) -> String {
+  let mut lines = code.split('\n').map(|l| l.to_string()).collect_vec();
+  let import_spec_lists = root
+    .named_children(&mut root.walk())
+    .filter(|n| n.kind() == "import_declaration")
+    .filter_map(|n| {
+      n.named_children(&mut n.walk())
+        .find(|c| c.kind() == "import_spec_list")
+    })
+    .collect_vec();
+
+  // The blocks are rearranged bottom-up, such that the rows of the blocks above remain valid
+  for import_spec_list in import_spec_lists.iter().rev() {
+    let open_row = import_spec_list.start_position().row;
+    let close_row = import_spec_list.end_position().row;
+    if close_row <= open_row
+      || !lines[open_row].trim_end().ends_with('(')
+      || lines[close_row].trim() != ")"
+    {
+      continue;
+    }
+
+    // The sorting key (i.e. the path and the name) of the spec on each row
+    let mut keys: HashMap<usize, (String, String)> = HashMap::new();
+    let mut unsortable_rows = HashSet::new();
+    for child in import_spec_list.named_children(&mut import_spec_list.walk()) {
+      let row = child.start_position().row;
+      if child.kind() != "import_spec" {
+        // A comment following a spec (on the same row) moves along with it
+        if !keys.contains_key(&row) {
+          unsortable_rows.insert(row);
+        }
+        continue;
+      }
+      if keys.contains_key(&row) || child.end_position().row != row {
+        unsortable_rows.insert(row);
+      }
+      let get_text = |field: &str| {
+        child
+          .child_by_field_name(field)
+          .map_or("", |n| n.utf8_text(code.as_bytes()).unwrap())
+          .trim_matches('"')
+          .to_string()
+      };
+      keys.insert(row, (get_text("path"), get_text("name")));
+    }
+
+    let mut groups: Vec<Vec<usize>> = vec![vec![]];
+    for row in open_row + 1..close_row {
+      if lines[row].trim().is_empty() {
+        groups.push(vec![]);
+      } else {
+        groups.last_mut().unwrap().push(row);
+      }
+    }
+    let mut sorted_lines: Vec<String> = vec![];
+    for mut group in groups.into_iter().filter(|g| !g.is_empty()) {
+      if group
+        .iter()
+        .all(|r| keys.contains_key(r) && !unsortable_rows.contains(r))
+      {
+        group.sort_by(|a, b| (&keys[a], &lines[*a]).cmp(&(&keys[b], &lines[*b])));
+        group.dedup_by(|a, b| lines[*a] == lines[*b]);
+      }
+      if !sorted_lines.is_empty() {
+        sorted_lines.push(String::new());
+      }
+      sorted_lines.extend(group.iter().map(|r| lines[*r].to_string()));
+    }
+    lines.splice(open_row + 1..close_row, sorted_lines);
+  }
+  lines.join("\n")
+}
+
+#[cfg(test)]
+#[path = "unit_tests/go_layout_test.rs"]
+mod go_layout_test;
@@ -0,0 +1,480 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Cleans up the Go packages (i.e. the files of each directory) rewritten by Piranha, once no more rules apply. The
+//! struct types that are not selected anymore are deleted first (see `delete_unselected_implementations`), and then
+//! the functions that are not referred anymore (see `delete_unreachable`), since the methods of a deleted type may be
+//! the only references to other functions. The analyses themselves are in `go_implementations` and
+//! `go_unreachable_functions`.
+
+use std::{
+  collections::{HashMap, HashSet},
+  path::{Path, PathBuf},
+};
+
+use ignore::WalkBuilder;
+use itertools::Itertools;
+use log::debug;
+use tree_sitter::Parser;
+
+use crate::{
+  models::{
+    default_configs::{
+      DELETE_UNREACHABLE_FUNCTION, DELETE_UNSELECTED_IMPLEMENTATION, PIRANHA_IGNORE_FILE,
+    },
+    edit::Edit,
+    matches::Match,
+    piranha_arguments::PiranhaArguments,
+    piranha_output::{get_relative_path, DeletedFunction, UnselectedImplementation},
+    source_code_unit::SourceCodeUnit,
+  },
+  utilities::{
+    go_implementations::{
+      get_external_references, get_implementations, get_interfaces, get_package_name,
+      get_references, implements, is_referred, GoFile, Reference,
+    },
+    go_keep_directives::overlaps_kept_range,
+    go_unreachable_functions::{
+      get_function_declarations, get_function_references, is_deletable, FunctionDeclaration,
+      FunctionReference,
+    },
+    read_file,
+  },
+};
+
+/// Cleans up the files of each Go package (along with its directory), i.e. deletes the unselected implementations
+/// (with `delete_unselected_implementations`) and then the unreachable functions (with `delete_unreachable`). The files
+/// of each package are sorted by path, and the excluded ones are never rewritten.
+pub(crate) fn perform_go_package_cleanup(
+  packages: &mut [(PathBuf, Vec<SourceCodeUnit>)], piranha_args: &PiranhaArguments,
+  parser: &mut Parser,
+) {
+  if *piranha_args.delete_unselected_implementations() {
+    // The other packages of the code base may only refer to the exported types (and constructors)
+    let codebase_references = get_qualified_references(piranha_args, parser);
+    for (package, files) in packages.iter_mut() {
+      let excluded_paths = get_excluded_paths(files, piranha_args);
+      let external_references = codebase_references
+        .iter()
+        .filter(|(path, _)| get_package(path) != *package)
+        .map(|(path, r)| (get_relative_path(path, piranha_args), r.clone()))
+        .collect_vec();
+      delete_unselected_implementations(files, &excluded_paths, &external_references, parser);
+    }
+  }
+  if *piranha_args.delete_unreachable() {
+    for (_, files) in packages.iter_mut() {
+      let excluded_paths = get_excluded_paths(files, piranha_args);
+      delete_unreachable_functions(files, &excluded_paths, parser);
+    }
+  }
+}
+
+/// The package of a Go file, i.e. its directory.
+pub(crate) fn get_package(path: &Path) -> PathBuf {
+  path.parent().map(Path::to_path_buf).unwrap_or_default()
+}
+
+/// Returns the paths of the `files` out of the scope of `include` and `exclude`, which are never rewritten.
+fn get_excluded_paths(
+  files: &[SourceCodeUnit], piranha_args: &PiranhaArguments,
+) -> HashSet<PathBuf> {
+  files
+    .iter()
+    .map(|scu| scu.path())
+    .filter(|path| !piranha_args.is_included(path))
+    .cloned()
+    .collect()
+}
+
+/// Returns the qualified references (e.g. `checkout.LegacyReceipt`) of all the Go files of the code base, along with
+/// their path. The hidden files are skipped, as well as the ignored ones (unless `no_ignore` is set), but not the
+/// excluded ones.
+fn get_qualified_references(
+  piranha_args: &PiranhaArguments, parser: &mut Parser,
+) -> Vec<(PathBuf, Reference)> {
+  if !piranha_args.code_snippet().is_empty() {
+    return vec![];
+  }
+  let respect_ignore = !*piranha_args.no_ignore();
+  let mut walker = WalkBuilder::new(piranha_args.path_to_codebase());
+  walker
+    .hidden(true)
+    .parents(respect_ignore)
+    .ignore(respect_ignore)
+    .git_ignore(respect_ignore)
+    .git_global(respect_ignore)
+    .git_exclude(respect_ignore)
+    .require_git(false);
+  if respect_ignore {
+    walker.add_custom_ignore_filename(PIRANHA_IGNORE_FILE);
+  }
+  let mut references = vec![];
+  let paths = walker
+    .build()
+    .filter_map(|e| e.ok())
+    .map(|e| e.into_path())
+    .filter(|path| path.is_file() && piranha_args.language().can_parse(path))
+    .sorted();
+  for path in paths {
+    let content = match read_file(&path) {
+      Ok(content) => content,
+      Err(_) => continue,
+    };
+    let tree = match parser.parse(&content, None) {
+      Some(tree) => tree,
+      None => continue,
+    };
+    let files: [GoFile; 1] = [(tree.root_node(), content.as_str())];
+    references.extend(
+      get_references(&files)
+        .into_iter()
+        .filter(|r| r.is_qualified)
+        .map(|r| (path.clone(), r)),
+    );
+  }
+  references
+}
+
+/// Deletes the functions (and methods) of the package `files` that are not referred anymore (see `is_unreachable`),
+/// one at a time until none is left, and records them (along with the rule chain that made them unreachable)
+/// in the `deleted_functions` of their file. The skipped and the `excluded_paths` files are never rewritten.
+fn delete_unreachable_functions(
+  files: &mut [SourceCodeUnit], excluded_paths: &HashSet<PathBuf>, parser: &mut Parser,
+) {
+  // The references (of each file) before the cleanup
+  let original_references = files
+    .iter()
+    .map(|scu| {
+      let tree = parser
+        .parse(scu.original_content(), None)
+        .expect("Could not parse the original content!");
+      get_function_references(tree.root_node(), scu.original_content())
+    })
+    .collect_vec();
+  // The (file and rewrite) indices of the deletions, along with the deleted functions
+  let mut deletions: Vec<(usize, usize, DeletedFunction)> = vec![];
+  loop {
+    let references = files
+      .iter()
+      .enumerate()
+      .flat_map(|(i, scu)| {
+        get_function_references(scu.root_node(), scu.code())
+          .into_iter()
+          .map(move |r| (i, r))
+      })
+      .collect_vec();
+    let unreachable = files
+      .iter()
+      .enumerate()
+      .filter(|(_, scu)| scu.skip_reason().is_none() && !excluded_paths.contains(scu.path()))
+      .find_map(|(i, scu)| {
+        let kept_ranges = scu.kept_ranges();
+        get_function_declarations(scu.root_node(), scu.code())
+          .into_iter()
+          .find(|d| {
+            !overlaps_kept_range(d.range, &kept_ranges)
+              && scu.is_within_line_range(d.range)
+              && is_unreachable(d, i, &references, &original_references)
+          })
+          .map(|d| (i, d))
+      });
+    let (i, declaration) = match unreachable {
+      Some(unreachable) => unreachable,
+      None => break,
+    };
+    let (rule_chain, deleted_callers) =
+      get_rule_chain(&declaration, files, &original_references, &deletions);
+    let scu = &mut files[i];
+    let range = declaration.range;
+    let p_match = Match::new(
+      scu.code()[range.start_byte..range.end_byte].to_string(),
+      range,
+      HashMap::new(),
+    );
+    let edit = Edit::new(
+      p_match,
+      String::new(),
+      DELETE_UNREACHABLE_FUNCTION.to_string(),
+      HashMap::new(),
+      scu.code(),
+    );
+    scu.record_rewrite(&edit);
+    scu.apply_edit(&edit, parser);
+    let deleted_function = DeletedFunction::new(
+      declaration.name.clone(),
+      scu.path().to_str().unwrap().to_string(),
+      rule_chain,
+      deleted_callers,
+    );
+    debug!("Deleted the unreachable function {:?}", deleted_function);
+    scu.deleted_functions_mut().push(deleted_function.clone());
+    deletions.push((i, scu.rewrites().len() - 1, deleted_function));
+  }
+}
+
+/// Deletes the struct types of the package `files` that are not selected anymore (see `get_unselected_implementation`),
+/// along with their methods and constructors, one at a time until none is left, and records them in the
+/// `unselected_implementations` of the file declaring the type. A type referred from another package (i.e. by the
+/// `external_references`, along with their location, or by a file of the directory declaring another package, e.g. an
+/// external test) is retained, and recorded along with these references. The skipped and the `excluded_paths` files are
+/// never rewritten.
+fn delete_unselected_implementations(
+  files: &mut [SourceCodeUnit], excluded_paths: &HashSet<PathBuf>,
+  external_references: &[(String, Reference)], parser: &mut Parser,
+) {
+  // The implementations referred (in their package) before the cleanup
+  let original_trees = files
+    .iter()
+    .map(|scu| {
+      parser
+        .parse(scu.original_content(), None)
+        .expect("Could not parse the original content!")
+    })
+    .collect_vec();
+  let originally_referred = {
+    let original_files = original_trees
+      .iter()
+      .zip(files.iter())
+      .map(|(tree, scu)| (tree.root_node(), scu.original_content().as_str()))
+      .collect_vec();
+    let packages = get_package_names(&original_files);
+    let references = get_references(&original_files);
+    get_implementations(&original_files)
+      .into_iter()
+      .filter(|i| is_referred(i, &references, &packages))
+      .map(|i| (i.package, i.declaration.name))
+      .collect::<HashSet<_>>()
+  };
+  let mut recorded: HashSet<(String, String)> = HashSet::new();
+  loop {
+    let (implementation, locations, interfaces) = {
+      let current_files = files
+        .iter()
+        .map(|scu| (scu.root_node(), scu.code().as_str()))
+        .collect_vec();
+      let packages = get_package_names(&current_files);
+      let references = get_references(&current_files);
+      let unselected = get_implementations(&current_files).into_iter().find(|i| {
+        let key = (i.package.clone(), i.name().to_string());
+        originally_referred.contains(&key)
+          && !recorded.contains(&key)
+          && !is_referred(i, &references, &packages)
+          && i.declarations().all(|d| {
+            let scu = &files[d.file];
+            scu.skip_reason().is_none()
+              && !excluded_paths.contains(scu.path())
+              && !overlaps_kept_range(d.range, &scu.kept_ranges())
+              && scu.is_within_line_range(d.range)
+          })
+      });
+      let implementation = match unselected {
+        Some(implementation) => implementation,
+        None => break,
+      };
+      let locations = get_external_references(&implementation, &references, &packages)
+        .into_iter()
+        .map(|r| {
+          let scu = &files[r.file];
+          let path = get_relative_path(scu.path(), scu.piranha_arguments());
+          format!("{}:{}", path, r.line)
+        })
+        .chain(
+          external_references
+            .iter()
+            .filter(|(_, r)| r.refers_to(&implementation))
+            .map(|(path, r)| format!("{}:{}", path, r.line)),
+        )
+        .collect_vec();
+      // The interfaces (of the package) it implemented, which may be left with a single implementation
+      let interfaces = get_interfaces(&current_files)
+        .into_iter()
+        .filter(|(_, methods)| implements(&implementation, methods))
+        .collect_vec();
+      (implementation, locations, interfaces)
+    };
+    recorded.insert((
+      implementation.package.clone(),
+      implementation.name().to_string(),
+    ));
+    let file = implementation.declaration.file;
+    let path = files[file].path().to_str().unwrap().to_string();
+    let methods = implementation
+      .methods
+      .iter()
+      .map(|d| d.name.clone())
+      .collect_vec();
+    let constructors = implementation
+      .constructors
+      .iter()
+      .map(|d| d.name.clone())
+      .collect_vec();
+    if !locations.is_empty() {
+      debug!(
+        "Kept the unselected implementation {} referred from {:?}",
+        implementation.name(),
+        locations
+      );
+      files[file]
+        .unselected_implementations_mut()
+        .push(UnselectedImplementation::new(
+          implementation.name().to_string(),
+          path,
+          methods,
+          constructors,
+          locations,
+          vec![],
+        ));
+      continue;
+    }
+    // The declarations of each file are deleted from the last one, such that the ranges of the others remain valid
+    for declaration in implementation
+      .declarations()
+      .sorted_by_key(|d| (d.file, std::cmp::Reverse(d.range.start_byte)))
+    {
+      let scu = &mut files[declaration.file];
+      let range = declaration.range;
+      let p_match = Match::new(
+        scu.code()[range.start_byte..range.end_byte].to_string(),
+        range,
+        HashMap::new(),
+      );
+      let edit = Edit::new(
+        p_match,
+        String::new(),
+        DELETE_UNSELECTED_IMPLEMENTATION.to_string(),
+        HashMap::new(),
+        scu.code(),
+      );
+      scu.record_rewrite(&edit);
+      scu.apply_edit(&edit, parser);
+    }
+    let single_implementations = {
+      let current_files = files
+        .iter()
+        .map(|scu| (scu.root_node(), scu.code().as_str()))
+        .collect_vec();
+      let remaining = get_implementations(&current_files)
+        .into_iter()
+        .filter(|i| i.package == implementation.package)
+        .collect_vec();
+      interfaces
+        .into_iter()
+        .filter_map(|(interface, methods)| {
+          let implementers = remaining
+            .iter()
+            .filter(|i| implements(i, &methods))
+            .collect_vec();
+          (implementers.len() == 1).then(|| (interface, implementers[0].name().to_string()))
+        })
+        .collect_vec()
+    };
+    let unselected_implementation = UnselectedImplementation::new(
+      implementation.name().to_string(),
+      path,
+      methods,
+      constructors,
+      vec![],
+      single_implementations,
+    );
+    debug!(
+      "Deleted the unselected implementation {:?}",
+      unselected_implementation
+    );
+    files[file]
+      .unselected_implementations_mut()
+      .push(unselected_implementation);
+  }
+}
+
+/// Returns the name of the package of each file (see `get_package_name`).
+fn get_package_names(files: &[GoFile]) -> Vec<Option<String>> {
+  files
+    .iter()
+    .map(|(root, code)| get_package_name(*root, code))
+    .collect()
+}
+
+/// Checks if the function (declared in the `i`th file) is unreachable, i.e. it is deletable (see `is_deletable`),
+/// it is not referred anymore (except by itself), and it was referred before the cleanup, though never as a value
+/// (since it may be invoked through a variable that is still alive).
+fn is_unreachable(
+  declaration: &FunctionDeclaration, i: usize, references: &[(usize, FunctionReference)],
+  original_references: &[Vec<FunctionReference>],
+) -> bool {
+  let range = declaration.range;
+  let is_referred = references.iter().any(|(j, r)| {
+    r.refers_to(declaration)
+      && (*j != i || r.start_byte < range.start_byte || r.start_byte >= range.end_byte)
+  });
+  let mut original_references = original_references
+    .iter()
+    .flatten()
+    .filter(|r| r.refers_to(declaration))
+    .peekable();
+  is_deletable(declaration)
+    && !is_referred
+    && original_references.peek().is_some()
+    && original_references.all(|r| !r.is_value)
+}
+
+/// Returns the rules whose rewrites deleted the (original) references to the function, and the deleted functions that
+/// referred to it. The rule chain of a reference is the rules of the rewrites within the first rewrite deleting it
+/// (e.g. `replace_flag_call` and then `simplify_if_statement_false`), up to this rewrite. A reference deleted along
+/// with another (unreachable) function inherits its rule chain.
+fn get_rule_chain(
+  declaration: &FunctionDeclaration, files: &[SourceCodeUnit],
+  original_references: &[Vec<FunctionReference>], deletions: &[(usize, usize, DeletedFunction)],
+) -> (Vec<String>, Vec<String>) {
+  let mut rule_chain = vec![];
+  let mut deleted_callers = vec![];
+  for (i, references) in original_references.iter().enumerate() {
+    let rewrites = files[i].rewrites();
+    for reference in references.iter().filter(|r| r.refers_to(declaration)) {
+      let offset = reference.start_byte;
+      let deleting_rewrite = rewrites.iter().position(|e| {
+        let range = e.p_match().original_range();
+        range.start_byte <= offset && offset < range.end_byte
+      });
+      let k = match deleting_rewrite {
+        Some(k) => k,
+        None => continue,
+      };
+      if let Some((_, _, caller)) = deletions.iter().find(|(j, l, _)| *j == i && *l == k) {
+        rule_chain.extend(caller.rule_chain().iter().cloned());
+        rule_chain.push(DELETE_UNREACHABLE_FUNCTION.to_string());
+        deleted_callers.push(caller.name().to_string());
+        continue;
+      }
+      let deleting_range = rewrites[k].p_match().original_range();
+      rule_chain.extend(
+        rewrites[..=k]
+          .iter()
+          .filter(|e| {
+            let range = e.p_match().original_range();
+            deleting_range.start_byte <= range.start_byte
+              && range.end_byte <= deleting_range.end_byte
+          })
+          .map(|e| e.matched_rule().to_string()),
+      );
+    }
+  }
+  (
+    rule_chain.into_iter().unique().collect(),
+    deleted_callers.into_iter().unique().collect(),
+  )
+}
+
+#[cfg(test)]
+#[path = "unit_tests/go_package_cleanup_test.rs"]
+mod go_package_cleanup_test;
@@ -0,0 +1,167 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Finds the (top-level) Go functions and methods, along with the references to them, to delete the ones that are not
+//! referred anymore in their package once the flag is cleaned up (see `delete_unreachable`). For instance,
+//! `renderLegacyCheckout` is deleted, once `if !enabled { renderLegacyCheckout() }` (its only call) is.
+//!
+//! The references are resolved by name only (i.e. regardless of the scopes and of the receiver types), which may keep
+//! a function alive, but never deletes a referred one. In particular, a method is referred by any selector (or
+//! interface method) with its name, since it may implement an interface. Similarly, the references of all the files of
+//! the package are considered regardless of their build constraints (i.e. the union of the references of every build),
+//! such that a function called by a file of another build (e.g. with `//go:build experiment`) is never deleted.
+
+use tree_sitter::{Node, Range};
+
+/// The prefixes of the functions run by `go test`, which are never deleted.
+const TEST_FUNCTION_PREFIXES: [&str; 4] = ["Test", "Benchmark", "Fuzz", "Example"];
+
+/// A top-level function or method declaration.
+#[derive(Debug, Clone, PartialEq)]
+pub(crate) struct FunctionDeclaration {
+  pub(crate) name: String,
+  pub(crate) is_method: bool,
+  /// The range of the declaration, along with its doc comment (if any)
+  pub(crate) range: Range,
+}
+
+/// A reference to a function (i.e. an identifier) or to a method (i.e. a field identifier), other than its declaration.
+#[derive(Debug, Clone, PartialEq)]
+pub(crate) struct FunctionReference {
+  pub(crate) name: String,
+  pub(crate) is_method: bool,
+  pub(crate) start_byte: usize,
+  /// Whether the function is referred as a value (e.g. stored in a variable or a map, or a method value),
+  /// rather than called
+  pub(crate) is_value: bool,
+}
+
+impl FunctionReference {
+  pub(crate) fn refers_to(&self, declaration: &FunctionDeclaration) -> bool {
+    self.name == declaration.name && self.is_method == declaration.is_method
+  }
+}
+
+/// Returns the top-level function and method declarations of the code.
+pub(crate) fn get_function_declarations(root: Node, code: &str) -> Vec<FunctionDeclaration> {
+  root
+    .named_children(&mut root.walk())
+    .filter(|n| ["function_declaration", "method_declaration"].contains(&n.kind()))
+    .filter_map(|n| {
+      let name = n
+        .child_by_field_name("name")?
+        .utf8_text(code.as_bytes())
+        .ok()?;
+      let start = get_doc_comment(n).unwrap_or(n);
+      Some(FunctionDeclaration {
+        name: name.to_string(),
+        is_method: n.kind() == "method_declaration",
+        range: Range {
+          start_byte: start.start_byte(),
+          end_byte: n.end_byte(),
+          start_point: start.start_position(),
+          end_point: n.end_position(),
+        },
+      })
+    })
+    .collect()
+}
+
+/// Returns the references to the functions and the methods in the code, i.e. all the identifiers and the field
+/// identifiers except the names of the function and method declarations.
+pub(crate) fn get_function_references(root: Node, code: &str) -> Vec<FunctionReference> {
+  let mut references = vec![];
+  let mut stack = vec![root];
+  while let Some(node) = stack.pop() {
+    stack.extend(node.named_children(&mut node.walk()));
+    let is_method = match node.kind() {
+      "identifier" => false,
+      "field_identifier" => true,
+      _ => continue,
+    };
+    let parent = node.parent();
+    if parent.map_or(false, |p| is_declaration_name(p, node)) {
+      continue;
+    }
+    // The callee of a method call is the selector (i.e. the parent of the field identifier), while the other field
+    // identifiers (e.g. the methods of an interface, or the fields of a struct) are not values
+    let is_value = match parent {
+      _ if !is_method => !is_callee(node),
+      Some(selector) if selector.kind() == "selector_expression" => !is_callee(selector),
+      _ => false,
+    };
+    if let Ok(name) = node.utf8_text(code.as_bytes()) {
+      references.push(FunctionReference {
+        name: name.to_string(),
+        is_method,
+        start_byte: node.start_byte(),
+        is_value,
+      });
+    }
+  }
+  references.sort_by_key(|r| r.start_byte);
+  references
+}
+
+/// Checks if the function (or method) may be deleted once it is not referred anymore, i.e. it is not exported, and it
+/// is neither `init`, `main` nor run by `go test`.
+pub(crate) fn is_deletable(declaration: &FunctionDeclaration) -> bool {
+  let name = declaration.name.as_str();
+  name.chars().next().map_or(false, |c| !c.is_uppercase())
+    && !["init", "main", "_"].contains(&name)
+    && !TEST_FUNCTION_PREFIXES.iter().any(|p| name.starts_with(p))
+}
+
+/// Returns the first comment of the doc comment of the declaration, i.e. of the consecutive comments right above it
+/// (without any blank line in between).
+pub(crate) fn get_doc_comment(declaration: Node) -> Option<Node> {
+  let mut doc_comment = None;
+  let mut next = declaration;
+  while let Some(comment) = next.prev_named_sibling() {
+    // The trailing comment of the previous declaration is retained
+    let is_trailing = comment.prev_named_sibling().map_or(false, |p| {
+      p.end_position().row == comment.start_position().row
+    });
+    if comment.kind() != "comment"
+      || comment.end_position().row + 1 != next.start_position().row
+      || is_trailing
+    {
+      break;
+    }
+    doc_comment = Some(comment);
+    next = comment;
+  }
+  doc_comment
+}
+
+/// Checks if the node is the name of the (function or method) declaration.
+fn is_declaration_name(declaration: Node, node: Node) -> bool {
+  ["function_declaration", "method_declaration"].contains(&declaration.kind())
+    && declaration
+      .child_by_field_name("name")
+      .map_or(false, |name| name.id() == node.id())
+}
+
+/// Checks if the node is the function of a call expression (e.g. `f` in `f(x)`, or `s.f` in `s.f(x)`).
+fn is_callee(node: Node) -> bool {
+  node.parent().map_or(false, |p| {
+    p.kind() == "call_expression"
+      && p
+        .child_by_field_name("function")
+        .map_or(false, |f| f.id() == node.id())
+  })
+}
+
+#[cfg(test)]
+#[path = "unit_tests/go_unreachable_functions_test.rs"]
+mod go_unreachable_functions_test;
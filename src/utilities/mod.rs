@@ -11,7 +11,18 @@ Copyright (c) 2023 Uber Technologies, Inc.
  limitations under the License.
 */
 
+pub(crate) mod go_build_constraints;
+pub(crate) mod go_declarations;
+pub(crate) mod go_implementations;
+pub(crate) mod go_imports;
+pub(crate) mod go_keep_directives;
+pub(crate) mod go_layout;
+pub(crate) mod go_package_cleanup;
+pub(crate) mod go_unreachable_functions;
+pub(crate) mod output_formatter;
+pub(crate) mod parentheses;
 pub(crate) mod tree_sitter_utilities;
+pub(crate) mod unresolved_usages;
 use std::collections::HashMap;
 use std::error::Error;
 use std::fs::File;
@@ -19,7 +30,7 @@ use std::fs::File;
 use std::fs::{self, DirEntry};
 use std::hash::Hash;
 use std::io::{BufReader, Read};
-use std::path::PathBuf;
+use std::path::{Path, PathBuf};
 
 // Reads a file.
 pub(crate) fn read_file(file_path: &PathBuf) -> Result<String, String> {
@@ -101,10 +112,36 @@ pub(crate) fn parse_key_val(
   Ok((s[..pos].parse()?, s[pos + 1..].parse()?))
 }
 
+/// Parses the state of a flag, e.g. `new_checkout=false`.
+pub(crate) fn parse_flag_state(
+  s: &str,
+) -> Result<(String, bool), Box<dyn Error + Send + Sync + 'static>> {
+  let (flag, state) = parse_key_val(s)?;
+  Ok((flag, state.parse()?))
+}
+
+/// Parses the glob pattern of the paths to include (or exclude). Like in the Go import paths, the `/...` suffix stands for
+/// a package and all its subpackages, e.g. `services/checkout/...` is `services/checkout/**`.
 pub(crate) fn parse_glob_pattern(
   s: &str,
 ) -> Result<Pattern, Box<dyn Error + Send + Sync + 'static>> {
-  Ok(Pattern::new(s)?)
+  let pattern = match s.strip_suffix("...") {
+    Some(package) if package.is_empty() || package.ends_with('/') => format!("{package}**"),
+    _ => s.to_string(),
+  };
+  Ok(Pattern::new(&pattern)?)
+}
+
+/// Checks if the `path` is in the scope of the `include` and `exclude` patterns, i.e. it matches any of the included
+/// ones (if any) and none of the excluded ones. The patterns are matched against the path, as well as against the path
+/// under the `path_to_codebase` (e.g. `services/checkout/**`).
+pub(crate) fn is_included(
+  path: &Path, path_to_codebase: &Path, include: &[Pattern], exclude: &[Pattern],
+) -> bool {
+  let relative_path = path.strip_prefix(path_to_codebase).ok();
+  let matches =
+    |p: &Pattern| p.matches_path(path) || relative_path.map_or(false, |r| p.matches_path(r));
+  (include.is_empty() || include.iter().any(matches)) && !exclude.iter().any(matches)
 }
 
 /// Returns the file with the given name within the given directory.
@@ -146,6 +183,7 @@ macro_rules! gen_py_str_methods {
 
 pub(crate) use gen_py_str_methods;
 use glob::Pattern;
+use regex::Regex;
 
 pub(crate) trait Instantiate {
   /// Replaces the all the occurrences of a tree-sitter specific tag with the corresponding string values
@@ -170,6 +208,158 @@ impl Instantiate for String {
   }
 }
 
+/// Instantiates the replacement string `replace` (like `Instantiate`), where a tag may be written as `@{<tag>}`, followed
+/// by a pipeline of transforms (each prefixed by `|`) that are applied to its substitution, e.g. `@{name|s/^get//|decapitalize}`.
+/// The braces delimit the tag, since `|` is an operator of most languages (e.g. `@lhs|@rhs`).
+///
+/// The supported transforms are :
+/// * `s/<regex>/<replacement>/` replaces the first match of `<regex>` (`s/<regex>/<replacement>/g` replaces all of them).
+///   `/` is escaped as `\/`, and `<replacement>` may refer to the groups of `<regex>` (e.g. `$1`).
+/// * `lower`, `upper`, `capitalize` and `decapitalize` change the case of the substitution (or of its first character).
+///
+/// The tags (and their transforms) that are not in `substitutions` are left as is, so that they can be instantiated
+/// later on (e.g. the tags of the match, once the holes are instantiated). So are the malformed transforms, which
+/// are reported upon the validation of the rule (see `validate_transforms`).
+pub(crate) fn instantiate_replace(
+  replace: &str, substitutions: &HashMap<String, String>,
+) -> String {
+  let mut output = String::new();
+  let mut rest = replace;
+  while let Some(index) = rest.find("@{") {
+    output.push_str(&rest[..index]);
+    rest = &rest[index..];
+    match parse_transformed_tag(&rest[2..]) {
+      Ok((tag, transforms, remaining)) if substitutions.contains_key(tag) => {
+        let value = transforms
+          .iter()
+          .fold(substitutions[tag].to_string(), |value, transform| {
+            transform.apply(&value)
+          });
+        output.push_str(&value);
+        rest = remaining;
+      }
+      _ => {
+        output.push_str("@{");
+        rest = &rest[2..];
+      }
+    }
+  }
+  output.push_str(rest);
+  // The tags without transforms
+  output.instantiate(substitutions)
+}
+
+/// Checks that each `@{<tag>|<transform>|...}` of the replacement string `replace` is well-formed
+/// (see `instantiate_replace`), i.e. its tag is not empty, its transforms are known and its regexes are valid.
+pub(crate) fn validate_transforms(replace: &str) -> Result<(), String> {
+  let mut rest = replace;
+  while let Some(index) = rest.find("@{") {
+    rest = &rest[index + 2..];
+    parse_transformed_tag(rest)
+      .map_err(|e| format!("Cannot parse the transforms of the replace `{replace}` - {e}"))?;
+  }
+  Ok(())
+}
+
+/// A transform of the substitution of a tag (see `instantiate_replace`)
+enum Transform {
+  // Replaces the first match of the regex (or all of them) with the replacement
+  Substitute(Regex, String, bool),
+  Lower,
+  Upper,
+  Capitalize,
+  Decapitalize,
+}
+
+impl Transform {
+  fn apply(&self, value: &str) -> String {
+    let mut characters = value.chars();
+    let first = characters.next();
+    match self {
+      Transform::Substitute(regex, replacement, true) => {
+        regex.replace_all(value, replacement.as_str()).to_string()
+      }
+      Transform::Substitute(regex, replacement, false) => {
+        regex.replace(value, replacement.as_str()).to_string()
+      }
+      Transform::Lower => value.to_lowercase(),
+      Transform::Upper => value.to_uppercase(),
+      Transform::Capitalize => first
+        .map(|c| c.to_uppercase().chain(characters).collect::<String>())
+        .unwrap_or_default(),
+      Transform::Decapitalize => first
+        .map(|c| c.to_lowercase().chain(characters).collect::<String>())
+        .unwrap_or_default(),
+    }
+  }
+}
+
+/// Parses `<tag>|<transform>|...}` (i.e. the `@{` is already consumed).
+/// Returns the tag, its transforms and the remaining input (after the closing `}`).
+fn parse_transformed_tag(input: &str) -> Result<(&str, Vec<Transform>, &str), String> {
+  let tag_length = input
+    .find(|c: char| c == '|' || c == '}')
+    .ok_or_else(|| format!("Missing `}}` in `@{{{input}`"))?;
+  let tag = &input[..tag_length];
+  if tag.is_empty()
+    || !tag
+      .chars()
+      .all(|c| c.is_alphanumeric() || c == '_' || c == '.')
+  {
+    return Err(format!("Invalid tag `{tag}` in `@{{{input}`"));
+  }
+  let mut transforms = vec![];
+  let mut rest = &input[tag_length..];
+  while let Some(pipeline) = rest.strip_prefix('|') {
+    let (transform, remaining) = parse_transform(pipeline)?;
+    transforms.push(transform);
+    rest = remaining;
+  }
+  let rest = rest
+    .strip_prefix('}')
+    .ok_or_else(|| format!("Missing `}}` in `@{{{input}`"))?;
+  Ok((tag, transforms, rest))
+}
+
+/// Parses the transform at the beginning of `input`, and returns it along with the remaining input.
+fn parse_transform(input: &str) -> Result<(Transform, &str), String> {
+  if let Some(substitution) = input.strip_prefix("s/") {
+    let incomplete = || format!("Incomplete regex transform `{input}`");
+    let (pattern, remaining) = split_at_unescaped_slash(substitution).ok_or_else(incomplete)?;
+    let (replacement, remaining) = split_at_unescaped_slash(remaining).ok_or_else(incomplete)?;
+    let regex = Regex::new(&pattern)
+      .map_err(|e| format!("Invalid regex in the transform `{input}` - {e}"))?;
+    return Ok(match remaining.strip_prefix('g') {
+      Some(remaining) => (Transform::Substitute(regex, replacement, true), remaining),
+      None => (Transform::Substitute(regex, replacement, false), remaining),
+    });
+  }
+  let name_length = input
+    .find(|c: char| c == '|' || c == '}')
+    .unwrap_or(input.len());
+  let transform = match &input[..name_length] {
+    "lower" => Transform::Lower,
+    "upper" => Transform::Upper,
+    "capitalize" => Transform::Capitalize,
+    "decapitalize" => Transform::Decapitalize,
+    name => return Err(format!("Unknown transform `{name}`")),
+  };
+  Ok((transform, &input[name_length..]))
+}
+
+/// Splits `input` at its first `/` that is not escaped (i.e. `\/`), and unescapes the first part.
+fn split_at_unescaped_slash(input: &str) -> Option<(String, &str)> {
+  let mut escaped = false;
+  for (index, c) in input.char_indices() {
+    match c {
+      '/' if !escaped => return Some((input[..index].replace("\\/", "/"), &input[index + 1..])),
+      '\\' => escaped = !escaped,
+      _ => escaped = false,
+    }
+  }
+  None
+}
+
 #[cfg(test)]
 #[path = "unit_tests/utilities_test.rs"]
 mod utilities_test;
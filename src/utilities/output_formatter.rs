@@ -0,0 +1,129 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Formats the rewritten code of any language (e.g. the stray blank lines left behind by a deleted block), i.e. it
+//! collapses the consecutive blank lines and deletes the trailing whitespace, and optionally runs an external formatter
+//! (e.g. `gofmt` or `black -q -`) on it.
+
+use std::io::Write;
+use std::process::{Command, Stdio};
+
+use tree_sitter::Node;
+
+/// Normalizes the whitespace of the given code :
+/// * the consecutive blank lines are collapsed into a single one, and the trailing blank lines are deleted
+/// * the trailing whitespace (spaces and tabs) of the lines is deleted
+///
+/// Since the kinds of the nodes differ across the languages, the multi-line tokens are recognized as the leaves
+/// (or the string nodes) spanning several lines, e.g. the text blocks, the raw string literals or the block comments.
+/// The lines within these tokens are left as is. The code is returned unchanged, if it has syntax errors.
+pub(crate) fn normalize_whitespace(code: &str, root: Node) -> String {
+  if root.has_error() {
+    return code.to_string();
+  }
+  // The normalized lines, along with whether they are blank (outside a multi-line token)
+  let mut lines: Vec<(String, bool)> = vec![];
+  let mut line_start = 0;
+  for line in code.split('\n') {
+    let line_end = line_start + line.len();
+    let line = if is_within_multiline_token(root, line_end) {
+      line.to_string()
+    } else {
+      trim_trailing_whitespace(line)
+    };
+    let is_blank =
+      line.trim_end_matches('\r').is_empty() && !is_within_multiline_token(root, line_start);
+    if !is_blank || lines.last().map_or(true, |(_, b)| !b) {
+      lines.push((line, is_blank));
+    }
+    line_start = line_end + 1;
+  }
+  // The last line is empty when the code ends with a new line (which is retained)
+  while lines.len() > 1 && lines[lines.len() - 1].1 && lines[lines.len() - 2].1 {
+    lines.pop();
+  }
+  lines
+    .into_iter()
+    .map(|(l, _)| l)
+    .collect::<Vec<_>>()
+    .join("\n")
+}
+
+/// Runs the formatter `command` (i.e. a program and its arguments, separated by whitespace) on the given code,
+/// which is written to its standard input. Returns the standard output of the command, or the reason of its failure.
+pub(crate) fn run_formatter_command(command: &str, code: &str) -> Result<String, String> {
+  let mut args = command.split_whitespace();
+  let program = args
+    .next()
+    .ok_or_else(|| "The formatter command is empty".to_string())?;
+  let mut child = Command::new(program)
+    .args(args)
+    .stdin(Stdio::piped())
+    .stdout(Stdio::piped())
+    .stderr(Stdio::piped())
+    .spawn()
+    .map_err(|e| format!("Could not run `{command}` : {e}"))?;
+  let mut stdin = child
+    .stdin
+    .take()
+    .expect("Could not open the standard input!");
+  let input = code.to_string();
+  // The code is written from another thread, such that the command never blocks on a full output pipe
+  let writer = std::thread::spawn(move || stdin.write_all(input.as_bytes()));
+  let output = child
+    .wait_with_output()
+    .map_err(|e| format!("Could not run `{command}` : {e}"))?;
+  let _ = writer.join();
+  if !output.status.success() {
+    return Err(format!(
+      "`{command}` failed ({}) : {}",
+      output.status,
+      String::from_utf8_lossy(&output.stderr).trim()
+    ));
+  }
+  String::from_utf8(output.stdout).map_err(|e| format!("`{command}` did not output UTF-8 : {e}"))
+}
+
+/// Deletes the trailing spaces and tabs of the line, while retaining its carriage return (if any).
+fn trim_trailing_whitespace(line: &str) -> String {
+  let is_whitespace = |c: char| c == ' ' || c == '\t';
+  match line.strip_suffix('\r') {
+    Some(content) => format!("{}\r", content.trim_end_matches(is_whitespace)),
+    None => line.trim_end_matches(is_whitespace).to_string(),
+  }
+}
+
+/// Checks if the given byte is strictly within a multi-line token, i.e. a leaf (or a string node) spanning several lines.
+fn is_within_multiline_token(root: Node, byte: usize) -> bool {
+  let mut node = match root.descendant_for_byte_range(byte, byte) {
+    Some(n) => n,
+    None => return false,
+  };
+  loop {
+    if (node.child_count() == 0 || node.kind().contains("string"))
+      && node.start_byte() < byte
+      && byte < node.end_byte()
+      && node.start_position().row < node.end_position().row
+    {
+      return true;
+    }
+    node = match node.parent() {
+      Some(parent) => parent,
+      None => return false,
+    };
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/output_formatter_test.rs"]
+mod output_formatter_test;
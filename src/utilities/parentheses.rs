@@ -0,0 +1,179 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Deletes the parentheses left redundant by the simplification of the enclosed expression, e.g. `return (enabled)`
+//! once `return (enabled && exp.BoolValue("new_checkout"))` is simplified. The parentheses are redundant when deleting
+//! them does not change the precedence, w.r.t. the operator metadata of the language (see `Precedences`), i.e.
+//! * the parenthesized expression is not the operand of another one (e.g. it is returned, or an argument)
+//! * the enclosed expression binds tighter than the enclosing one, e.g. `a || (b && c)`, or as tight when it is
+//!   the left operand (all the binary operators are left-associative), e.g. `(a && b) && c`
+//!
+//! Only the negations (`!`) are unwrapped within another expression, since the other unary operators may be lexed
+//! along with the preceding one (e.g. `a-(-b)`). The parentheses enclosing a block (e.g. a Go composite literal
+//! in an `if` condition) are always kept.
+//!
+//! Conversely, the replacement of an operand (e.g. `flag` replaced with `a || b` in `flag && c`) is enclosed in
+//! parentheses, unless these would be redundant w.r.t. the same metadata (see `parenthesize_replacement`).
+
+use tree_sitter::{Node, Parser, Range};
+use tree_sitter_traversal::{traverse, Order};
+
+use crate::models::language::Precedences;
+
+/// The (Go and Java) kind of the parenthesized expressions
+const PARENTHESIZED_EXPRESSION: &str = "parenthesized_expression";
+
+/// The precedence of the primary expressions, and of the negations
+const PRIMARY_PRECEDENCE: u8 = u8::MAX;
+const UNARY_PRECEDENCE: u8 = u8::MAX - 1;
+
+/// Returns the range of the first redundant parentheses (in order), along with the enclosed expression replacing them.
+/// Only the parentheses that are not in the `original_code` are considered, i.e. the ones changed by the cleanup.
+pub(crate) fn get_redundant_parentheses(
+  root: Node, code: &str, original_code: &str, precedences: &Precedences,
+) -> Option<(Range, String)> {
+  traverse(root.walk(), Order::Pre)
+    .filter(|n| n.kind() == PARENTHESIZED_EXPRESSION && n.named_child_count() == 1)
+    .filter(|n| {
+      n.utf8_text(code.as_bytes())
+        .map_or(false, |t| !original_code.contains(t) && !t.contains('{'))
+    })
+    .find(|n| is_redundant(*n, precedences))
+    .map(|n| (n.range(), get_replacement(n, code)))
+}
+
+/// Returns the `replacement` of the `node`, enclosed in parentheses when the node is the operand of an expression that
+/// binds tighter than the top-level operator of the replacement, e.g. `(a || b)` replacing `flag` in `flag && c`
+/// (rather than `a || b && c`), or `(y + 1)` replacing `x` in `x * 2`.
+/// The replacement is enclosed in parentheses (in the code), and the parentheses are kept unless they are redundant
+/// (see `is_redundant`). The replacements of a single token (e.g. a boolean literal) are returned as is.
+pub(crate) fn parenthesize_replacement(
+  node: Node, code: &str, replacement: &str, precedences: &Precedences, parser: &mut Parser,
+) -> String {
+  let is_operand = node.parent().map_or(false, |parent| {
+    ["binary_expression", "unary_expression"].contains(&parent.kind())
+      || is_in_context(node, parent, precedences.primary_contexts())
+  });
+  let is_single_token = replacement
+    .chars()
+    .all(|c| c.is_alphanumeric() || c == '_' || c == '.');
+  if !is_operand || is_single_token || replacement.trim().is_empty() {
+    return replacement.to_string();
+  }
+  let parenthesized = format!("({replacement})");
+  let code = [
+    &code[..node.start_byte()],
+    parenthesized.as_str(),
+    &code[node.end_byte()..],
+  ]
+  .concat();
+  let tree = match parser.parse(&code, None) {
+    Some(tree) => tree,
+    None => return replacement.to_string(),
+  };
+  let end_byte = node.start_byte() + parenthesized.len();
+  match tree
+    .root_node()
+    .descendant_for_byte_range(node.start_byte(), end_byte)
+  {
+    Some(n)
+      if n.kind() == PARENTHESIZED_EXPRESSION
+        && n.start_byte() == node.start_byte()
+        && n.end_byte() == end_byte
+        && n.named_child_count() == 1
+        && !is_redundant(n, precedences) =>
+    {
+      parenthesized
+    }
+    _ => replacement.to_string(),
+  }
+}
+
+/// Checks if the parentheses can be deleted without changing the precedence of the enclosed expression.
+fn is_redundant(parenthesized: Node, precedences: &Precedences) -> bool {
+  let (parent, expression) = match (parenthesized.parent(), parenthesized.named_child(0)) {
+    (Some(parent), Some(expression)) => (parent, expression),
+    _ => return false,
+  };
+  if is_in_context(parenthesized, parent, precedences.free_contexts()) {
+    return get_precedence(expression, precedences).is_some()
+      || expression.kind() == "unary_expression";
+  }
+  let precedence = match get_precedence(expression, precedences) {
+    Some(precedence) => precedence,
+    None => return false,
+  };
+  if is_in_context(parenthesized, parent, precedences.primary_contexts()) {
+    return precedence == PRIMARY_PRECEDENCE;
+  }
+  match parent.kind() {
+    "unary_expression" => precedence >= UNARY_PRECEDENCE,
+    "binary_expression" => match get_precedence(parent, precedences) {
+      Some(parent_precedence) => {
+        precedence > parent_precedence
+          || (precedence == parent_precedence
+            && parent.child_by_field_name("left") == Some(parenthesized))
+      }
+      None => false,
+    },
+    _ => false,
+  }
+}
+
+/// Returns the precedence of the expression, if it is a primary expression, a negation or a binary expression
+/// of a known operator.
+fn get_precedence(expression: Node, precedences: &Precedences) -> Option<u8> {
+  if precedences
+    .primary_kinds()
+    .contains(&expression.kind().to_string())
+  {
+    return Some(PRIMARY_PRECEDENCE);
+  }
+  let operator = expression.child_by_field_name("operator")?.kind();
+  match expression.kind() {
+    "unary_expression" if operator == "!" => Some(UNARY_PRECEDENCE),
+    "binary_expression" => precedences
+      .binary_operators()
+      .iter()
+      .find(|(o, _)| o == operator)
+      .map(|(_, precedence)| *precedence),
+    _ => None,
+  }
+}
+
+/// Checks if the node is a child of the parent matching any of the `(kind, field)` contexts.
+fn is_in_context(node: Node, parent: Node, contexts: &[(String, String)]) -> bool {
+  contexts.iter().any(|(kind, field)| {
+    parent.kind() == kind && (field.is_empty() || parent.child_by_field_name(field) == Some(node))
+  })
+}
+
+/// Returns the enclosed expression, preceded (or followed) by a space when it would be lexed along with
+/// the adjacent token otherwise (e.g. `return(enabled)`).
+fn get_replacement(parenthesized: Node, code: &str) -> String {
+  let expression = parenthesized.named_child(0).unwrap();
+  let text = &code[expression.start_byte()..expression.end_byte()];
+  let is_word = |c: Option<char>| c.map_or(false, |c| c.is_alphanumeric() || c == '_');
+  let mut replacement = text.to_string();
+  if is_word(code[..parenthesized.start_byte()].chars().last()) && is_word(text.chars().next()) {
+    replacement.insert(0, ' ');
+  }
+  if is_word(code[parenthesized.end_byte()..].chars().next()) && is_word(text.chars().last()) {
+    replacement.push(' ');
+  }
+  replacement
+}
+
+#[cfg(test)]
+#[path = "unit_tests/parentheses_test.rs"]
+mod parentheses_test;
@@ -249,7 +249,7 @@ pub(crate) fn get_tree_sitter_edit(code: String, edit: &Edit) -> (String, InputE
 }
 
 // Finds the position (col and row number) for a given offset.
-fn position_for_offset(input: &[u8], offset: usize) -> Point {
+pub(crate) fn position_for_offset(input: &[u8], offset: usize) -> Point {
   let mut result = Point { row: 0, column: 0 };
   for c in &input[0..offset] {
     if *c as char == '\n' {
@@ -338,6 +338,19 @@ pub(crate) fn number_of_errors(node: &Node) -> usize {
     .count()
 }
 
+/// Returns the number of references to `name` within the node, i.e. the leaves (e.g. identifiers) whose text is `name`,
+/// except the ones within the `excluded` ranges (e.g. the definition of the name).
+pub(crate) fn count_references(node: &Node, code: &str, name: &str, excluded: &[Range]) -> usize {
+  traverse(node.walk(), Order::Pre)
+    .filter(|n| n.child_count() == 0 && n.utf8_text(code.as_bytes()).ok() == Some(name))
+    .filter(|n| {
+      excluded
+        .iter()
+        .all(|r| n.start_byte() < r.start_byte || n.end_byte() > r.end_byte)
+    })
+    .count()
+}
+
 #[cfg(test)]
 #[path = "unit_tests/tree_sitter_utilities_test.rs"]
 mod tree_sitter_utilities_test;
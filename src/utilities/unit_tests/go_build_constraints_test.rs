@@ -0,0 +1,112 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashSet, path::Path};
+
+use super::{are_compatible, get_build_constraint, BuildConstraint};
+
+/// Returns the build constraint of the file `checkout.go` with the given header.
+fn get_header_constraint(header: &str) -> Option<BuildConstraint> {
+  get_build_constraint(
+    Path::new("checkout.go"),
+    &format!("{header}\n\npackage checkout\n"),
+  )
+}
+
+fn is_satisfied(constraint: &Option<BuildConstraint>, tags: &[&str]) -> bool {
+  let tags: HashSet<&str> = tags.iter().copied().collect();
+  constraint.as_ref().unwrap().is_satisfied_by(&tags)
+}
+
+#[test]
+fn test_get_build_constraint() {
+  let constraint = get_header_constraint("//go:build experiment && (linux || !race)");
+  assert!(is_satisfied(&constraint, &["experiment", "linux", "race"]));
+  assert!(is_satisfied(&constraint, &["experiment"]));
+  assert!(!is_satisfied(&constraint, &["experiment", "race"]));
+  assert!(!is_satisfied(&constraint, &["linux"]));
+
+  // The legacy lines are conjunctions of disjunctions (separated by spaces) of conjunctions (separated by commas)
+  let constraint = get_header_constraint("// +build linux,amd64 darwin\n// +build !race");
+  assert!(is_satisfied(&constraint, &["linux", "amd64"]));
+  assert!(is_satisfied(&constraint, &["darwin"]));
+  assert!(!is_satisfied(&constraint, &["linux"]));
+  assert!(!is_satisfied(&constraint, &["darwin", "race"]));
+
+  // The `//go:build` line takes precedence over the legacy lines
+  let constraint = get_header_constraint("//go:build experiment\n// +build !experiment");
+  assert!(is_satisfied(&constraint, &["experiment"]));
+
+  // The lines after the package clause, and the invalid expressions, are ignored
+  assert_eq!(
+    get_build_constraint(
+      Path::new("checkout.go"),
+      "package checkout\n\n//go:build experiment\n"
+    ),
+    None
+  );
+  assert_eq!(get_header_constraint("//go:build experiment &&"), None);
+  assert_eq!(get_header_constraint("//go:build (experiment"), None);
+}
+
+#[test]
+fn test_get_build_constraint_of_file_name() {
+  let code = "package checkout\n";
+  let constraint = get_build_constraint(Path::new("checkout_linux.go"), code);
+  assert!(is_satisfied(&constraint, &["linux"]));
+  assert!(!is_satisfied(&constraint, &["darwin"]));
+  let constraint = get_build_constraint(Path::new("checkout_linux_amd64_test.go"), code);
+  assert!(is_satisfied(&constraint, &["linux", "amd64"]));
+  assert!(!is_satisfied(&constraint, &["linux", "arm64"]));
+  // The name made of the suffix only is not constrained
+  assert_eq!(get_build_constraint(Path::new("linux.go"), code), None);
+  assert_eq!(
+    get_build_constraint(Path::new("checkout_experiment.go"), code),
+    None
+  );
+
+  // The constraint of the header applies along with the one of the name
+  let constraint = get_build_constraint(
+    Path::new("checkout_linux.go"),
+    "//go:build experiment\n\npackage checkout\n",
+  );
+  assert!(is_satisfied(&constraint, &["linux", "experiment"]));
+  assert!(!is_satisfied(&constraint, &["linux"]));
+}
+
+#[test]
+fn test_are_compatible() {
+  let experiment = get_header_constraint("//go:build experiment");
+  let not_experiment = get_header_constraint("//go:build !experiment");
+  assert!(!are_compatible(&experiment, &not_experiment));
+  assert!(are_compatible(&experiment, &experiment));
+  // The unconstrained files belong to every build
+  assert!(are_compatible(&experiment, &None));
+  assert!(are_compatible(&None, &not_experiment));
+
+  // A build targets a single operating system and architecture
+  let code = "package checkout\n";
+  let linux = get_build_constraint(Path::new("checkout_linux.go"), code);
+  let darwin = get_build_constraint(Path::new("checkout_darwin.go"), code);
+  let amd64 = get_build_constraint(Path::new("checkout_amd64.go"), code);
+  let arm64 = get_build_constraint(Path::new("checkout_arm64.go"), code);
+  assert!(!are_compatible(&linux, &darwin));
+  assert!(!are_compatible(&amd64, &arm64));
+  assert!(are_compatible(&linux, &amd64));
+  assert!(are_compatible(&linux, &experiment));
+  // Android builds satisfy `linux` too
+  let android = get_build_constraint(Path::new("checkout_android.go"), code);
+  assert!(are_compatible(&linux, &android));
+  assert!(!are_compatible(&android, &darwin));
+  assert!(are_compatible(&android, &experiment));
+}
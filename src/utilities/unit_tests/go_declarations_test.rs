@@ -0,0 +1,237 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashSet;
+
+use crate::models::{default_configs::GO, language::PiranhaLanguage};
+
+use super::{get_declaration_fix, get_locally_declared_names, is_unwrapping_colliding};
+
+/// Applies the declaration fixes to `code` (until none is left), where `original_code` is the code before the rewrite.
+fn fix_declarations(original_code: &str, code: &str) -> String {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let original_tree = parser.parse(original_code, None).unwrap();
+  let locally_declared_names = get_locally_declared_names(original_tree.root_node(), original_code);
+  let mut code = code.to_string();
+  loop {
+    let tree = parser.parse(&code, None).unwrap();
+    match get_declaration_fix(tree.root_node(), &code, &locally_declared_names) {
+      Some((range, replacement)) => {
+        code.replace_range(range.start_byte..range.end_byte, replacement)
+      }
+      None => return code,
+    }
+  }
+}
+
+#[test]
+fn test_get_locally_declared_names() {
+  let code = r#"package main
+
+var counter = 0
+
+func handle(items []string, ch chan string) {
+	var total int
+	s, err := compute()
+	for i, item := range items {
+		select {
+		case msg := <-ch:
+			log(i, item, msg)
+		}
+	}
+	counter = total
+}
+"#;
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let tree = parser.parse(code, None).unwrap();
+  let expected: HashSet<String> = ["total", "s", "err", "i", "item", "msg"]
+    .iter()
+    .map(|n| n.to_string())
+    .collect();
+  assert_eq!(get_locally_declared_names(tree.root_node(), code), expected);
+}
+
+/// `s, err := ...` (left behind by `s, enabled, err := ...`) declares no new variable in the function scope,
+/// while the one in the nested block shadows `s` and `err` (so it is retained).
+#[test]
+fn test_fix_redeclaration() {
+  let original_code = r#"package main
+
+func process(name string) error {
+	s, err := compute(name)
+	s, enabled, err := compute(s), true, validate(s)
+	if s != "" {
+		s, enabled, err := compute(s), true, validate(s)
+		log(s, enabled, err)
+	}
+	name, legacy := s, false
+	log(s, name, enabled, legacy)
+	return err
+}
+"#;
+  let code = r#"package main
+
+func process(name string) error {
+	s, err := compute(name)
+	s, err := compute(s), validate(s)
+	if s != "" {
+		s, err := compute(s), validate(s)
+		log(s, err)
+	}
+	name := s
+	log(s, name)
+	return err
+}
+"#;
+  let expected = r#"package main
+
+func process(name string) error {
+	s, err := compute(name)
+	s, err = compute(s), validate(s)
+	if s != "" {
+		s, err := compute(s), validate(s)
+		log(s, err)
+	}
+	name = s
+	log(s, name)
+	return err
+}
+"#;
+  assert_eq!(fix_declarations(original_code, code), expected);
+}
+
+/// `err = ...` becomes the declaration of `err`, once `enabled, err := true, nil` is deleted.
+/// Only its first occurrence is replaced, and `s` is redeclared (as it is declared in the same scope).
+#[test]
+fn test_fix_assignment_to_deleted_declaration() {
+  let original_code = r#"package main
+
+func process() error {
+	s := "str"
+	enabled, err := true, nil
+	if !enabled {
+		return nil
+	}
+	s, err = compute(), validate(s)
+	err = validate(s)
+	return err
+}
+"#;
+  let code = r#"package main
+
+func process() error {
+	s := "str"
+	s, err = compute(), validate(s)
+	err = validate(s)
+	return err
+}
+"#;
+  let expected = r#"package main
+
+func process() error {
+	s := "str"
+	s, err := compute(), validate(s)
+	err = validate(s)
+	return err
+}
+"#;
+  assert_eq!(fix_declarations(original_code, code), expected);
+}
+
+/// The assignments to the package level variables (declared in this file or not), and to the variables
+/// declared in an outer scope, are retained.
+#[test]
+fn test_fix_assignment_retains_declared_variables() {
+  let original_code = r#"package main
+
+var counter = 0
+
+func process(s string) error {
+	enabled, err := true, nil
+	if s != "" {
+		s, err = compute(), validate(s)
+	}
+	counter = 1
+	total = 2
+	log(enabled)
+	return err
+}
+
+func other() {
+	counter := 0
+	log(counter)
+}
+"#;
+  let code = r#"package main
+
+var counter = 0
+
+func process(s string) error {
+	if s != "" {
+		s, err = compute(), validate(s)
+	}
+	counter = 1
+	total = 2
+	return err
+}
+
+func other() {
+	counter := 0
+	log(counter)
+}
+"#;
+  assert_eq!(fix_declarations(original_code, code), code);
+}
+
+/// The blocks declaring `err` and `rendered` collide with the enclosing function, unlike the one declaring `msg`
+/// (or declaring nothing).
+#[test]
+fn test_is_unwrapping_colliding() {
+  let code = r#"package main
+
+func process(s string) error {
+	{
+		err := validate(s)
+		log(err)
+	}
+	{
+		var msg = "processed"
+		log(msg)
+	}
+	{
+		type rendered string
+		log(rendered(s))
+	}
+	{
+		log(s)
+	}
+	var rendered = s
+	log(rendered)
+	return err
+}
+"#;
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let tree = parser.parse(code, None).unwrap();
+  let body = tree
+    .root_node()
+    .named_child(1)
+    .and_then(|f| f.child_by_field_name("body"))
+    .unwrap();
+  let statement_list = body.named_child(0).unwrap();
+  let colliding: Vec<bool> = statement_list
+    .named_children(&mut statement_list.walk())
+    .filter(|s| s.kind() == "block")
+    .map(|b| is_unwrapping_colliding(b, code))
+    .collect();
+  assert_eq!(colliding, vec![true, false, true, false]);
+}
@@ -0,0 +1,185 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use itertools::Itertools;
+
+use crate::models::{default_configs::GO, language::PiranhaLanguage};
+
+use super::{
+  get_external_references, get_implementations, get_interfaces, get_package_name, get_references,
+  implements, is_referred,
+};
+
+const FLOWS: &str = r#"package checkout
+
+type CheckoutFlow interface {
+	Run() error
+}
+
+type newFlow struct{}
+
+func (f newFlow) Run() error {
+	return nil
+}
+
+// legacyFlow is the checkout before the redesign
+type legacyFlow struct {
+	retries int
+}
+
+func newLegacyFlow() (*legacyFlow, error) {
+	return &legacyFlow{retries: 3}, nil
+}
+
+func (f *legacyFlow) Run() error {
+	return f.retry()
+}
+
+func (f *legacyFlow) retry() error {
+	return nil
+}
+
+type (
+	first  struct{}
+	second struct{}
+)
+"#;
+
+const CHECKOUT: &str = r#"package checkout
+
+func selectFlow(enabled bool) CheckoutFlow {
+	if enabled {
+		return newFlow{}
+	}
+	flow, _ := newLegacyFlow()
+	return flow
+}
+"#;
+
+const CHECKOUT_TEST: &str = r#"package checkout_test
+
+func TestLegacy(t *testing.T) {
+	_ = checkout.LegacyReceipt{}
+	_ = checkout.NewLegacyReceipt()
+}
+"#;
+
+#[test]
+fn test_get_implementations() {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let trees = [FLOWS, CHECKOUT]
+    .iter()
+    .map(|code| parser.parse(code, None).unwrap())
+    .collect_vec();
+  let files = trees
+    .iter()
+    .zip([FLOWS, CHECKOUT])
+    .map(|(tree, code)| (tree.root_node(), code))
+    .collect_vec();
+  let implementations = get_implementations(&files);
+  let summary = implementations
+    .iter()
+    .map(|i| {
+      (
+        i.name(),
+        i.package.as_str(),
+        i.methods.iter().map(|m| m.name.as_str()).collect_vec(),
+        i.constructors.iter().map(|c| c.name.as_str()).collect_vec(),
+      )
+    })
+    .collect_vec();
+  assert_eq!(
+    summary,
+    vec![
+      ("newFlow", "checkout", vec!["Run"], vec![]),
+      (
+        "legacyFlow",
+        "checkout",
+        vec!["Run", "retry"],
+        vec!["newLegacyFlow"]
+      ),
+    ]
+  );
+  // The range of the type declaration includes its doc comment
+  let legacy_flow = &implementations[1].declaration;
+  assert!(
+    FLOWS[legacy_flow.range.start_byte..legacy_flow.range.end_byte]
+      .starts_with("// legacyFlow is the checkout before the redesign")
+  );
+
+  let references = get_references(&files);
+  let packages = vec![Some("checkout".to_string()); 2];
+  assert!(implementations
+    .iter()
+    .all(|i| is_referred(i, &references, &packages)));
+  // The references within the methods and the constructors of the type do not count
+  assert!(!is_referred(
+    &implementations[1],
+    &get_references(&files[..1]),
+    &packages
+  ));
+
+  let interfaces = get_interfaces(&files);
+  assert_eq!(
+    interfaces,
+    vec![("CheckoutFlow".to_string(), vec!["Run".to_string()])]
+  );
+  assert!(implementations
+    .iter()
+    .all(|i| implements(i, &interfaces[0].1)));
+}
+
+#[test]
+fn test_get_external_references() {
+  let code = r#"package checkout
+
+type LegacyReceipt struct{}
+
+func NewLegacyReceipt() LegacyReceipt {
+	return LegacyReceipt{}
+}
+"#;
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let trees = [code, CHECKOUT_TEST]
+    .iter()
+    .map(|code| parser.parse(code, None).unwrap())
+    .collect_vec();
+  let files = trees
+    .iter()
+    .zip([code, CHECKOUT_TEST])
+    .map(|(tree, code)| (tree.root_node(), code))
+    .collect_vec();
+  let packages = files
+    .iter()
+    .map(|(root, code)| get_package_name(*root, code))
+    .collect_vec();
+  assert_eq!(
+    packages,
+    vec![
+      Some("checkout".to_string()),
+      Some("checkout_test".to_string())
+    ]
+  );
+  let implementations = get_implementations(&files);
+  let references = get_references(&files);
+  // The references of the other package are not resolved within the package
+  assert!(!is_referred(&implementations[0], &references, &packages));
+  let external_references = get_external_references(&implementations[0], &references, &packages)
+    .iter()
+    .map(|r| (r.name.as_str(), r.file, r.line))
+    .collect_vec();
+  assert_eq!(
+    external_references,
+    vec![("LegacyReceipt", 1, 4), ("NewLegacyReceipt", 1, 5)]
+  );
+}
@@ -0,0 +1,129 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashSet;
+
+use crate::models::{default_configs::GO, language::PiranhaLanguage};
+
+use super::{get_go_package_name, get_referred_packages, get_unused_import};
+
+/// Returns the names of the packages of the unused imports of `code`, where `original_code` is the code before the rewrite.
+fn get_unused_imports(original_code: &str, code: &str) -> Vec<String> {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let original_tree = parser.parse(original_code, None).unwrap();
+  let originally_referred_packages =
+    get_referred_packages(original_tree.root_node(), original_code);
+  let mut code = code.to_string();
+  let mut unused_imports = vec![];
+  loop {
+    let tree = parser.parse(&code, None).unwrap();
+    match get_unused_import(tree.root_node(), &code, &originally_referred_packages) {
+      Some((node, package_name)) => {
+        unused_imports.push(package_name);
+        code.replace_range(node.start_byte()..node.end_byte(), "");
+      }
+      None => return unused_imports,
+    }
+  }
+}
+
+#[test]
+fn test_get_referred_packages() {
+  let code = r#"package main
+
+func handle(s *server.Server, h http.Handler) {
+	fmt.Println(s.Name)
+}
+"#;
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let tree = parser.parse(code, None).unwrap();
+  assert_eq!(
+    get_referred_packages(tree.root_node(), code),
+    HashSet::from(["server", "http", "fmt", "s"].map(String::from))
+  );
+}
+
+#[test]
+fn test_get_unused_import() {
+  let original_code = r#"package main
+
+import (
+	"fmt"
+	"github.com/uber/exp/v2"
+	yaml "gopkg.in/yaml.v3"
+	"gopkg.in/check.v1"
+)
+
+func handle() {
+	if exp.IsEnabled() {
+		fmt.Println(yaml.Marshal(check_v1.C))
+	}
+	fmt.Println()
+}
+"#;
+  let code = r#"package main
+
+import (
+	"fmt"
+	"github.com/uber/exp/v2"
+	yaml "gopkg.in/yaml.v3"
+	"gopkg.in/check.v1"
+)
+
+func handle() {
+	fmt.Println()
+}
+"#;
+  // `check_v1` is not the guessed name of `gopkg.in/check.v1` (i.e. `check`), which was never referred
+  assert_eq!(get_unused_imports(original_code, code), vec!["exp", "yaml"]);
+}
+
+#[test]
+fn test_get_unused_import_retains_the_blank_and_dot_imports() {
+  let code = r#"package main
+
+import (
+	_ "github.com/lib/pq"
+	. "github.com/onsi/gomega"
+)
+
+func handle() {}
+"#;
+  let original_code = code.replace(
+    "func handle() {}",
+    "func handle() { pq.Open(); gomega.Expect() }",
+  );
+  assert!(get_unused_imports(&original_code, code).is_empty());
+}
+
+#[test]
+fn test_get_go_package_name() {
+  assert_eq!(get_go_package_name("\"fmt\""), Some("fmt".to_string()));
+  assert_eq!(
+    get_go_package_name("\"github.com/uber/exp\""),
+    Some("exp".to_string())
+  );
+  assert_eq!(
+    get_go_package_name("\"github.com/uber/exp/v2\""),
+    Some("exp".to_string())
+  );
+  assert_eq!(
+    get_go_package_name("\"gopkg.in/yaml.v3\""),
+    Some("yaml".to_string())
+  );
+  assert_eq!(
+    get_go_package_name("\"github.com/mattn/go-sqlite3\""),
+    Some("sqlite3".to_string())
+  );
+  assert_eq!(get_go_package_name("\"github.com/uber/exp-go\""), None);
+}
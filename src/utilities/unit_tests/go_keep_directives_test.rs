@@ -0,0 +1,92 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use crate::models::{default_configs::GO, language::PiranhaLanguage};
+
+use super::{get_kept_ranges, is_directive, KEEP_DIRECTIVE, KEEP_FILE_DIRECTIVE};
+
+/// Returns the text of the kept nodes, in the order of the code.
+fn get_kept_snippets(code: &str) -> Vec<String> {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let tree = parser.parse(code, None).unwrap();
+  let mut kept_ranges = get_kept_ranges(tree.root_node(), code);
+  kept_ranges.sort_by_key(|r| r.start_byte);
+  kept_ranges
+    .iter()
+    .map(|r| code[r.start_byte..r.end_byte].to_string())
+    .collect()
+}
+
+#[test]
+fn test_is_directive() {
+  assert!(is_directive("//piranha:keep", KEEP_DIRECTIVE));
+  assert!(is_directive(
+    "//piranha:keep until the migration is over",
+    KEEP_DIRECTIVE
+  ));
+  assert!(!is_directive("//piranha:keep-file", KEEP_DIRECTIVE));
+  assert!(!is_directive("//piranha:keeper", KEEP_DIRECTIVE));
+  assert!(!is_directive("// piranha:keep", KEEP_DIRECTIVE));
+  assert!(is_directive("//piranha:keep-file", KEEP_FILE_DIRECTIVE));
+}
+
+/// The directive on its own line keeps the statement (or declaration) on the next line, while the trailing directive
+/// keeps the statement on its line. The children of a kept node (e.g. the nested `if`) are kept along with it.
+#[test]
+fn test_get_kept_ranges() {
+  let code = r#"package main
+
+//piranha:keep
+func legacy() bool {
+	return exp.BoolValue("stale_flag")
+}
+
+func checkout() {
+	//piranha:keep
+	if exp.BoolValue("stale_flag") {
+		if exp.BoolValue("stale_flag") {
+			log("nested")
+		}
+	}
+	enabled := exp.BoolValue("stale_flag") //piranha:keep
+	if exp.BoolValue("stale_flag") { //piranha:keep until the dashboards are migrated
+		log(enabled)
+	}
+	//piranha:keep
+
+	log("not kept, as the directive is not immediately above")
+	log(exp.BoolValue("stale_flag")) // piranha:keep is not a directive
+}
+"#;
+  assert_eq!(
+    get_kept_snippets(code),
+    vec![
+      "func legacy() bool {\n\treturn exp.BoolValue(\"stale_flag\")\n}",
+      "if exp.BoolValue(\"stale_flag\") {\n\t\tif exp.BoolValue(\"stale_flag\") {\n\t\t\tlog(\"nested\")\n\t\t}\n\t}",
+      "enabled := exp.BoolValue(\"stale_flag\")",
+      "if exp.BoolValue(\"stale_flag\") { //piranha:keep until the dashboards are migrated\n\t\tlog(enabled)\n\t}",
+    ]
+  );
+}
+
+#[test]
+fn test_get_kept_ranges_without_directives() {
+  let code = r#"package main
+
+// keep this function
+func checkout() {
+	log(exp.BoolValue("stale_flag"))
+}
+"#;
+  assert!(get_kept_snippets(code).is_empty());
+}
@@ -0,0 +1,169 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use crate::models::{default_configs::GO, language::PiranhaLanguage};
+
+use super::normalize_go_layout;
+
+fn format(code: &str) -> String {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  normalize_go_layout(code, &mut parser)
+}
+
+/// Checks that the lines are re-indented with tabs, e.g. the statements of an unwrapped block.
+#[test]
+fn test_normalize_go_layout_indentation() {
+  let code = r#"package main
+
+func checkout(user string, items []string) string {
+        log(user)
+        if user == "" {
+                return "anonymous"
+        }
+    switch len(items) {
+        case 0:
+        return "empty"
+    default:
+    log("items")
+    }
+    ok := user != "" &&
+    len(items) > 0
+    config := Config{
+    Name: user,
+    }
+    return fmt.Sprintf("%v %v",
+    ok, config)
+}
+"#;
+  let expected = "package main
+
+func checkout(user string, items []string) string {
+\tlog(user)
+\tif user == \"\" {
+\t\treturn \"anonymous\"
+\t}
+\tswitch len(items) {
+\tcase 0:
+\t\treturn \"empty\"
+\tdefault:
+\t\tlog(\"items\")
+\t}
+\tok := user != \"\" &&
+\t\tlen(items) > 0
+\tconfig := Config{
+\t\tName: user,
+\t}
+\treturn fmt.Sprintf(\"%v %v\",
+\t\tok, config)
+}
+";
+  assert_eq!(format(code), expected);
+}
+
+/// Checks the indentation of the labels, the `else if` clauses and the function literals.
+#[test]
+fn test_normalize_go_layout_indentation_of_labels_and_function_literals() {
+  let code = r#"package main
+
+func visit(items []string) {
+outer:
+for _, item := range items {
+if item == "" {
+continue outer
+} else if item == "stop" {
+break outer
+}
+go func() {
+log(item)
+}()
+}
+}
+"#;
+  let expected = "package main
+
+func visit(items []string) {
+outer:
+\tfor _, item := range items {
+\t\tif item == \"\" {
+\t\t\tcontinue outer
+\t\t} else if item == \"stop\" {
+\t\t\tbreak outer
+\t\t}
+\t\tgo func() {
+\t\t\tlog(item)
+\t\t}()
+\t}
+}
+";
+  assert_eq!(format(code), expected);
+}
+
+/// Checks that the blank lines are collapsed, the trailing whitespace is deleted and the import specs are sorted,
+/// while the raw string literals are left as is.
+#[test]
+fn test_normalize_go_layout_blank_lines_and_imports() {
+  let code = "
+
+package main
+
+import (
+
+\t\"strings\"
+\t\"fmt\"
+
+
+\t\"github.com/uber/exp\"
+\t\"github.com/uber/exp\"
+
+)
+
+const usage = `Usage:  
+
+
+  greeting <name>`  
+
+
+
+func greeting(name string) string {   
+\treturn strings.ToUpper(name)\t
+}
+
+
+";
+  let expected = "package main
+
+import (
+\t\"fmt\"
+\t\"strings\"
+
+\t\"github.com/uber/exp\"
+)
+
+const usage = `Usage:  
+
+
+  greeting <name>`
+
+func greeting(name string) string {
+\treturn strings.ToUpper(name)
+}
+";
+  assert_eq!(format(code), expected);
+}
+
+/// Checks that the code with syntax errors is left as is.
+#[test]
+fn test_normalize_go_layout_with_syntax_errors() {
+  let code = "package main\n\nfunc greeting(name string {\n    return name\n}\n";
+  assert_eq!(format(code), code);
+}
@@ -0,0 +1,89 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::path::{Path, PathBuf};
+
+use itertools::Itertools;
+
+use crate::{
+  models::{default_configs::GO, language::PiranhaLanguage},
+  utilities::go_unreachable_functions::{get_function_declarations, get_function_references},
+};
+
+use super::{get_package, is_unreachable};
+
+#[test]
+fn test_get_package() {
+  assert_eq!(
+    get_package(Path::new("services/checkout/checkout.go")),
+    PathBuf::from("services/checkout")
+  );
+  assert_eq!(get_package(Path::new("main.go")), PathBuf::from(""));
+}
+
+/// `renderLegacy` was called before the cleanup, and is only referred by itself now, unlike `renderNew` (still called)
+/// and `legacyHandler` (referred as a value before the cleanup, hence possibly invoked through a variable).
+#[test]
+fn test_is_unreachable() {
+  let original_code = r#"package checkout
+
+func Render(enabled bool) {
+	handler := legacyHandler
+	if enabled {
+		renderNew()
+	} else {
+		renderLegacy()
+	}
+	handler()
+}
+
+func renderNew() {}
+
+func renderLegacy() {
+	renderLegacy()
+}
+
+func legacyHandler() {}
+"#;
+  let code = r#"package checkout
+
+func Render(enabled bool) {
+	renderNew()
+}
+
+func renderNew() {}
+
+func renderLegacy() {
+	renderLegacy()
+}
+
+func legacyHandler() {}
+"#;
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let original_tree = parser.parse(original_code, None).unwrap();
+  let original_references = vec![get_function_references(
+    original_tree.root_node(),
+    original_code,
+  )];
+  let tree = parser.parse(code, None).unwrap();
+  let references = get_function_references(tree.root_node(), code)
+    .into_iter()
+    .map(|r| (0, r))
+    .collect_vec();
+  let unreachable = get_function_declarations(tree.root_node(), code)
+    .iter()
+    .filter(|d| is_unreachable(d, 0, &references, &original_references))
+    .map(|d| d.name.clone())
+    .collect_vec();
+  assert_eq!(unreachable, vec!["renderLegacy"]);
+}
@@ -0,0 +1,143 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use itertools::Itertools;
+
+use crate::models::{default_configs::GO, language::PiranhaLanguage};
+
+use super::{get_function_declarations, get_function_references, is_deletable};
+
+const CODE: &str = r#"package checkout
+
+type renderer interface {
+	draw()
+}
+
+type page struct{}
+
+func (p page) draw() {}
+
+func (p page) legacyDraw() {
+	p.draw()
+}
+
+func Render(p page) {
+	handlers := map[string]func(){"legacy": renderLegacy}
+	callback := p.legacyDraw
+	handlers["legacy"]()
+	callback()
+	renderNew()
+}
+
+func renderNew() {}
+
+func renderLegacy() {}
+"#;
+
+#[test]
+fn test_get_function_declarations() {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let tree = parser.parse(CODE, None).unwrap();
+  let declarations = get_function_declarations(tree.root_node(), CODE)
+    .iter()
+    .map(|d| (d.name.clone(), d.is_method))
+    .collect_vec();
+  assert_eq!(
+    declarations,
+    vec![
+      ("draw".to_string(), true),
+      ("legacyDraw".to_string(), true),
+      ("Render".to_string(), false),
+      ("renderNew".to_string(), false),
+      ("renderLegacy".to_string(), false),
+    ]
+  );
+}
+
+/// The function stored in the map and the method value are referred as values, unlike the calls and
+/// the method of the interface.
+#[test]
+fn test_get_function_references() {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let tree = parser.parse(CODE, None).unwrap();
+  let references = get_function_references(tree.root_node(), CODE)
+    .iter()
+    .filter(|r| ["draw", "legacyDraw", "renderNew", "renderLegacy"].contains(&r.name.as_str()))
+    .map(|r| (r.name.clone(), r.is_method, r.is_value))
+    .collect_vec();
+  assert_eq!(
+    references,
+    vec![
+      ("draw".to_string(), true, false),
+      ("draw".to_string(), true, false),
+      ("renderLegacy".to_string(), false, true),
+      ("legacyDraw".to_string(), true, true),
+      ("renderNew".to_string(), false, false),
+    ]
+  );
+}
+
+#[test]
+fn test_is_deletable() {
+  let code = r#"package checkout
+
+func init() {}
+
+func main() {}
+
+func Render() {}
+
+func renderLegacy() {}
+
+func (p page) legacyDraw() {}
+
+func TestRender(t *testing.T) {}
+
+func BenchmarkRender(b *testing.B) {}
+"#;
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let tree = parser.parse(code, None).unwrap();
+  let deletable = get_function_declarations(tree.root_node(), code)
+    .iter()
+    .filter(|d| is_deletable(d))
+    .map(|d| d.name.clone())
+    .collect_vec();
+  assert_eq!(deletable, vec!["renderLegacy", "legacyDraw"]);
+}
+
+/// The range of a declaration starts at its doc comment, but not at the trailing comment of the previous declaration.
+#[test]
+fn test_get_function_declarations_with_doc_comment() {
+  let code = r#"package checkout
+
+var enabled = true // enables the new checkout
+func renderNew() {}
+
+// renderLegacy renders the legacy page,
+// once the flag is disabled
+func renderLegacy() {}
+"#;
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let tree = parser.parse(code, None).unwrap();
+  let declarations = get_function_declarations(tree.root_node(), code)
+    .iter()
+    .map(|d| &code[d.range.start_byte..d.range.end_byte])
+    .collect_vec();
+  assert_eq!(
+    declarations,
+    vec![
+      "func renderNew() {}",
+      "// renderLegacy renders the legacy page,\n// once the flag is disabled\nfunc renderLegacy() {}"
+    ]
+  );
+}
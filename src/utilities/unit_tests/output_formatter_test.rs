@@ -0,0 +1,97 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use crate::models::{
+  default_configs::{GO, PYTHON},
+  language::PiranhaLanguage,
+};
+
+use super::{normalize_whitespace, run_formatter_command};
+
+fn normalize(code: &str, language: &str) -> String {
+  let mut parser = PiranhaLanguage::from(language).parser();
+  let tree = parser.parse(code, None).unwrap();
+  normalize_whitespace(code, tree.root_node())
+}
+
+/// Checks that the consecutive blank lines are collapsed and the trailing whitespace is deleted,
+/// while the indentation and the raw string literals are left as is.
+#[test]
+fn test_normalize_whitespace() {
+  let code = "package main
+
+const usage = `Usage:  
+
+
+  greeting <name>`  
+
+
+
+func greeting(name string) string {   
+    if name == \"\" {\t
+
+        return \"anonymous\"
+    }
+    return name
+}
+
+
+";
+  let expected = "package main
+
+const usage = `Usage:  
+
+
+  greeting <name>`
+
+func greeting(name string) string {
+    if name == \"\" {
+
+        return \"anonymous\"
+    }
+    return name
+}
+";
+  assert_eq!(normalize(code, GO), expected);
+}
+
+/// Checks that the (multi-line) strings of another language are left as is, as well as the carriage returns.
+#[test]
+fn test_normalize_whitespace_python() {
+  let code = "def greeting(name):  \r\n\r\n\r\n    usage = \"\"\"Usage:  \r\n\r\n\r\n  greeting <name>\"\"\"\r\n    return name \r\n";
+  let expected = "def greeting(name):\r\n\r\n    usage = \"\"\"Usage:  \r\n\r\n\r\n  greeting <name>\"\"\"\r\n    return name\r\n";
+  assert_eq!(normalize(code, PYTHON), expected);
+}
+
+/// Checks that the code with syntax errors is left as is.
+#[test]
+fn test_normalize_whitespace_with_syntax_errors() {
+  let code = "package main\n\n\n\nfunc greeting(name string {  \n    return name\n}\n";
+  assert_eq!(normalize(code, GO), code);
+}
+
+#[test]
+fn test_run_formatter_command() {
+  assert_eq!(
+    run_formatter_command("tr a-z A-Z", "package main\n"),
+    Ok("PACKAGE MAIN\n".to_string())
+  );
+}
+
+/// Checks that the failure of the command (or a missing command) is reported.
+#[test]
+fn test_run_formatter_command_failure() {
+  assert!(run_formatter_command("false", "package main\n").is_err());
+  assert!(run_formatter_command("a-formatter-that-does-not-exist", "package main\n").is_err());
+  assert!(run_formatter_command("  ", "package main\n").is_err());
+}
@@ -0,0 +1,214 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use crate::models::{
+  default_configs::{GO, JAVA},
+  language::PiranhaLanguage,
+};
+
+use super::{get_redundant_parentheses, parenthesize_replacement};
+
+/// Deletes the redundant parentheses of the code, until none is left (w.r.t. the `original_code`)
+fn delete_redundant_parentheses(language: &str, code: &str, original_code: &str) -> String {
+  let language = PiranhaLanguage::from(language);
+  let precedences = language.precedences().clone().unwrap();
+  let mut parser = language.parser();
+  let mut code = code.to_string();
+  loop {
+    let tree = parser.parse(&code, None).unwrap();
+    match get_redundant_parentheses(tree.root_node(), &code, original_code, &precedences) {
+      Some((range, replacement)) => {
+        code.replace_range(range.start_byte..range.end_byte, &replacement)
+      }
+      None => return code,
+    }
+  }
+}
+
+/// Replaces the (first) `flag` of the code with the `replacement`, enclosed in parentheses if needed
+fn replace_flag(language: &str, code: &str, replacement: &str) -> String {
+  let language = PiranhaLanguage::from(language);
+  let precedences = language.precedences().clone().unwrap();
+  let mut parser = language.parser();
+  let tree = parser.parse(code, None).unwrap();
+  let start_byte = code.find("flag").unwrap();
+  let node = tree
+    .root_node()
+    .descendant_for_byte_range(start_byte, start_byte + "flag".len())
+    .unwrap();
+  let replacement = parenthesize_replacement(node, code, replacement, &precedences, &mut parser);
+  [
+    &code[..node.start_byte()],
+    replacement.as_str(),
+    &code[node.end_byte()..],
+  ]
+  .concat()
+}
+
+#[test]
+fn test_get_redundant_parentheses_go() {
+  let code = r#"package checkout
+
+func render(a, b, c bool, x, y int, p *page) bool {
+	enabled := (a)
+	show((a), ((b)))
+	if (a) {
+	}
+	if (p == page{}) {
+	}
+	_ = (a || b) && c
+	_ = a || (b && c)
+	_ = (a && b) && c
+	_ = a && (b && c)
+	_ = x - (y - 1)
+	_ = (x + y) * 2
+	_ = x + (y * 2)
+	_ = x == (y & 1)
+	_ = !(a)
+	_ = !(!a)
+	_ = !(a && b)
+	_ = x-(-y)
+	_ = (*p).draw
+	_ = (p).draw
+	return(a)
+}
+"#;
+  let expected = r#"package checkout
+
+func render(a, b, c bool, x, y int, p *page) bool {
+	enabled := a
+	show(a, b)
+	if a {
+	}
+	if (p == page{}) {
+	}
+	_ = (a || b) && c
+	_ = a || b && c
+	_ = a && b && c
+	_ = a && (b && c)
+	_ = x - (y - 1)
+	_ = (x + y) * 2
+	_ = x + y * 2
+	_ = x == y & 1
+	_ = !a
+	_ = !!a
+	_ = !(a && b)
+	_ = x-(-y)
+	_ = (*p).draw
+	_ = p.draw
+	return a
+}
+"#;
+  assert_eq!(delete_redundant_parentheses(GO, code, ""), expected);
+}
+
+/// The parentheses of the original code are kept, e.g. `a || (b && c)`
+#[test]
+fn test_get_redundant_parentheses_go_kept_when_original() {
+  let code = r#"package checkout
+
+func render(a, b, c bool) bool {
+	return a || (b && c) || (a)
+}
+"#;
+  let expected = r#"package checkout
+
+func render(a, b, c bool) bool {
+	return a || (b && c) || a
+}
+"#;
+  assert_eq!(
+    delete_redundant_parentheses(GO, code, "a || (b && c)"),
+    expected
+  );
+}
+
+/// The parentheses of the `if` condition are kept, and the `+` of a string concatenation is not (re-)associated
+#[test]
+fn test_get_redundant_parentheses_java() {
+  let code = r#"class Checkout {
+  boolean render(boolean a, boolean b, int x, int y, String s) {
+    boolean enabled = (a);
+    if ((a)) {
+      show((a));
+    }
+    s = s + (x + y);
+    s = (s + x) + y;
+    x = (x & 1) == 0 ? 1 : 2;
+    x = (int) (x + y);
+    a = (a | b) && a;
+    a = !(b);
+    a = (this).enabled;
+    return (a) && (b || a);
+  }
+}
+"#;
+  let expected = r#"class Checkout {
+  boolean render(boolean a, boolean b, int x, int y, String s) {
+    boolean enabled = a;
+    if (a) {
+      show(a);
+    }
+    s = s + (x + y);
+    s = s + x + y;
+    x = (x & 1) == 0 ? 1 : 2;
+    x = (int) (x + y);
+    a = a | b && a;
+    a = !b;
+    a = this.enabled;
+    return a && (b || a);
+  }
+}
+"#;
+  assert_eq!(delete_redundant_parentheses(JAVA, code, ""), expected);
+}
+
+#[test]
+fn test_parenthesize_replacement_go() {
+  let cases = [
+    ("_ = flag && c", "a || b", "_ = (a || b) && c"),
+    ("_ = c || flag", "a && b", "_ = c || a && b"),
+    ("_ = !flag", "a && b", "_ = !(a && b)"),
+    ("_ = !flag", "a", "_ = !a"),
+    ("_ = flag && c", "true", "_ = true && c"),
+    ("_ = flag && c", "x > 0", "_ = x > 0 && c"),
+    ("_ = x > flag", "y + 1", "_ = x > y + 1"),
+    ("_ = flag * 2", "y + 1", "_ = (y + 1) * 2"),
+    ("_ = x - flag", "y - 1", "_ = x - (y - 1)"),
+    ("_ = flag - x", "y - 1", "_ = y - 1 - x"),
+    ("_ = flag == 0", "x & 1", "_ = x & 1 == 0"),
+    ("_ = flag.Enabled()", "a || b", "_ = (a || b).Enabled()"),
+    ("_ = flag", "a || b", "_ = a || b"),
+  ];
+  for (statement, replacement, expected) in cases {
+    let code = format!("package checkout\n\nfunc render() {{\n\t{statement}\n}}\n");
+    let expected = format!("package checkout\n\nfunc render() {{\n\t{expected}\n}}\n");
+    assert_eq!(replace_flag(GO, &code, replacement), expected);
+  }
+}
+
+/// The replacement of an `if` condition is not enclosed in (additional) parentheses
+#[test]
+fn test_parenthesize_replacement_java() {
+  let cases = [
+    ("a = flag && c;", "a || b", "a = (a || b) && c;"),
+    ("x = flag * 2;", "y + 1", "x = (y + 1) * 2;"),
+    ("s = s + flag;", "x + y", "s = s + (x + y);"),
+    ("if (flag) {}", "a || b", "if (a || b) {}"),
+  ];
+  for (statement, replacement, expected) in cases {
+    let code = format!("class Checkout {{\n  void render() {{\n    {statement}\n  }}\n}}\n");
+    let expected = format!("class Checkout {{\n  void render() {{\n    {expected}\n  }}\n}}\n");
+    assert_eq!(replace_flag(JAVA, &code, replacement), expected);
+  }
+}
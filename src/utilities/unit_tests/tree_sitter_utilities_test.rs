@@ -16,7 +16,10 @@ use tree_sitter::Query;
 
 use crate::{
   models::{capture_group_patterns::CGPattern, default_configs::JAVA, language::PiranhaLanguage},
-  utilities::{tree_sitter_utilities::get_all_matches_for_query, Instantiate},
+  utilities::{
+    tree_sitter_utilities::{count_references, get_all_matches_for_query},
+    Instantiate,
+  },
 };
 
 #[test]
@@ -138,3 +141,30 @@ fn test_instantiate() {
     "isFlagTreated foo bar true"
   )
 }
+
+/// The name is only referred by the identifiers outside of the excluded range (i.e. the definition),
+/// but not within the string literals or the comments.
+#[test]
+fn test_count_references() {
+  let source_code = r#"
+      enum TestEnum {
+        STALE_FLAG
+      }
+      class Test {
+        // STALE_FLAG
+        void foobar(Experiment exp) {
+          exp.isToggleEnabled(TestEnum.STALE_FLAG);
+          exp.log("STALE_FLAG is stale");
+        }
+      }
+    "#;
+  let mut parser = PiranhaLanguage::from(JAVA).parser();
+  let tree = parser.parse(source_code, None).unwrap();
+  let root = tree.root_node();
+  let definition = root.named_child(0).unwrap().range();
+  assert_eq!(count_references(&root, source_code, "STALE_FLAG", &[]), 2);
+  assert_eq!(
+    count_references(&root, source_code, "STALE_FLAG", &[definition]),
+    1
+  );
+}
@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use itertools::Itertools;
+
+use crate::models::{
+  default_configs::{GO, JAVA},
+  language::PiranhaLanguage,
+};
+
+use super::{get_enclosing_function, get_line, get_unresolved_usages};
+
+const CODE: &str = r#"package checkout
+
+var flags = map[string]bool{"new_checkout": true}
+
+func Render(p page) {
+	enabled := true
+	go func() {
+		if enabled {
+			p.draw()
+		}
+	}()
+	log.Print("new_checkout_v2")
+}
+
+func (p page) legacyDraw() {
+	exp.Register(`new_checkout`)
+}
+"#;
+
+/// The other flags (e.g. `new_checkout_v2`) are not usages, while the enclosing function of the function literal is
+/// the one declaring it.
+#[test]
+fn test_get_unresolved_usages() {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let tree = parser.parse(CODE, None).unwrap();
+  let usages = get_unresolved_usages(
+    tree.root_node(),
+    CODE,
+    "new_checkout",
+    &["enabled".to_string()],
+  )
+  .iter()
+  .map(|(n, variable)| {
+    (
+      n.start_position().row + 1,
+      get_enclosing_function(*n, CODE),
+      get_line(CODE, n.start_position().row),
+      variable.clone(),
+    )
+  })
+  .collect_vec();
+  assert_eq!(
+    usages,
+    vec![
+      (
+        3,
+        None,
+        r#"var flags = map[string]bool{"new_checkout": true}"#.to_string(),
+        None
+      ),
+      (
+        6,
+        Some("Render".to_string()),
+        "enabled := true".to_string(),
+        Some("enabled".to_string())
+      ),
+      (
+        8,
+        Some("Render".to_string()),
+        "if enabled {".to_string(),
+        Some("enabled".to_string())
+      ),
+      (
+        16,
+        Some("legacyDraw".to_string()),
+        "exp.Register(`new_checkout`)".to_string(),
+        None
+      ),
+    ]
+  );
+}
+
+/// The string literal is reported once, rather than along with its content (i.e. its `string_fragment`).
+#[test]
+fn test_get_unresolved_usages_java() {
+  let code = r#"class Checkout {
+  Checkout() {
+    experimentation.isToggleEnabled("new_checkout");
+  }
+}
+"#;
+  let mut parser = PiranhaLanguage::from(JAVA).parser();
+  let tree = parser.parse(code, None).unwrap();
+  let usages = get_unresolved_usages(tree.root_node(), code, "new_checkout", &[])
+    .iter()
+    .map(|(n, _)| {
+      (
+        n.utf8_text(code.as_bytes()).unwrap().to_string(),
+        get_enclosing_function(*n, code),
+      )
+    })
+    .collect_vec();
+  assert_eq!(
+    usages,
+    vec![(
+      r#""new_checkout""#.to_string(),
+      Some("Checkout".to_string())
+    )]
+  );
+}
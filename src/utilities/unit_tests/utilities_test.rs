@@ -13,9 +13,14 @@ Copyright (c) 2023 Uber Technologies, Inc.
 
 use crate::utilities::find_file;
 use serde_derive::Deserialize;
-use std::path::PathBuf;
+use std::{
+  collections::HashMap,
+  path::{Path, PathBuf},
+};
 
-use super::{read_file, read_toml};
+use super::{
+  instantiate_replace, is_included, parse_glob_pattern, read_file, read_toml, validate_transforms,
+};
 
 #[derive(Deserialize, Default)]
 struct TestStruct {
@@ -66,3 +71,123 @@ fn test_find_file_negative() {
   let f = find_file(&project_root, "another_sample.toml.toml");
   assert!(f.is_file());
 }
+
+#[test]
+fn test_instantiate_replace_with_transforms() {
+  let substitutions = HashMap::from([
+    ("name".to_string(), "getIsEnabled".to_string()),
+    ("names".to_string(), "flags".to_string()),
+  ]);
+  // The same tag with different transforms
+  assert_eq!(
+    instantiate_replace("@{name|s/^get//} @{name|upper}", &substitutions),
+    "IsEnabled GETISENABLED"
+  );
+  assert_eq!(
+    instantiate_replace("@{name|s/^get//|decapitalize}()", &substitutions),
+    "isEnabled()"
+  );
+  assert_eq!(
+    instantiate_replace("@{name|s/^get(\\w)/set$1/|lower}", &substitutions),
+    "setisenabled"
+  );
+  assert_eq!(
+    instantiate_replace("@{names|capitalize} @{name|s/e/E/g}", &substitutions),
+    "Flags gEtIsEnablEd"
+  );
+  // The regex may contain `|` and `}`
+  assert_eq!(
+    instantiate_replace("@{name|s/^(get|is)\\w{2}//}", &substitutions),
+    "Enabled"
+  );
+  assert_eq!(
+    instantiate_replace(
+      "@{name|s/\\//_/}",
+      &HashMap::from([("name".to_string(), "a/b".to_string())])
+    ),
+    "a_b"
+  );
+}
+
+#[test]
+fn test_instantiate_replace_without_transforms() {
+  let substitutions = HashMap::from([
+    ("lhs".to_string(), "a".to_string()),
+    ("rhs".to_string(), "b".to_string()),
+  ]);
+  assert_eq!(instantiate_replace("@lhs|@rhs", &substitutions), "a|b");
+  assert_eq!(
+    instantiate_replace("@lhs || @rhs", &substitutions),
+    "a || b"
+  );
+  // `|` is an operator outside the braces (e.g. a bitwise or in Go)
+  assert_eq!(instantiate_replace("@lhs|upper", &substitutions), "a|upper");
+  assert_eq!(
+    instantiate_replace("@{lhs}_suffix", &substitutions),
+    "a_suffix"
+  );
+  // The tags not in the substitutions are retained (with their transforms)
+  assert_eq!(
+    instantiate_replace("@{lhs|upper} @{other|lower}", &substitutions),
+    "A @{other|lower}"
+  );
+}
+
+#[test]
+fn test_validate_transforms() {
+  assert!(validate_transforms("@lhs|s/foo @{lhs|s/^get//|upper} @rhs").is_ok());
+  for (replace, error) in [
+    ("@{lhs|s/foo}", "Incomplete regex transform `s/foo}`"),
+    ("@{lhs|s/(/x/}", "Invalid regex in the transform"),
+    ("@{lhs|title}", "Unknown transform `title`"),
+    ("@{lhs|upper", "Missing `}`"),
+    ("@{|upper}", "Invalid tag ``"),
+  ] {
+    let result = validate_transforms(replace);
+    assert!(
+      result.as_ref().is_err_and(|e| e.contains(error)),
+      "{replace} : {result:?}"
+    );
+  }
+}
+
+/// The `/...` suffix stands for the package and all its subpackages, while the other patterns are parsed as is.
+#[test]
+fn test_parse_glob_pattern_package_suffix() {
+  let pattern = parse_glob_pattern("services/checkout/...").unwrap();
+  assert_eq!(pattern.as_str(), "services/checkout/**");
+  assert!(pattern.matches_path(Path::new("services/checkout/checkout.go")));
+  assert!(pattern.matches_path(Path::new("services/checkout/internal/render.go")));
+  assert!(!pattern.matches_path(Path::new("services/payments/payments.go")));
+  assert_eq!(parse_glob_pattern("...").unwrap().as_str(), "**");
+  assert_eq!(
+    parse_glob_pattern("**/vendor/**").unwrap().as_str(),
+    "**/vendor/**"
+  );
+}
+
+/// The patterns are matched against the path under the code base too, and the exclusion takes precedence.
+#[test]
+fn test_is_included() {
+  let include = vec![
+    parse_glob_pattern("services/checkout/...").unwrap(),
+    parse_glob_pattern("services/payments/...").unwrap(),
+  ];
+  let exclude = vec![parse_glob_pattern("**/internal/legacy/**").unwrap()];
+  let path_to_codebase = Path::new("/repo");
+  let included = |path: &str| is_included(Path::new(path), path_to_codebase, &include, &exclude);
+  assert!(included("/repo/services/checkout/checkout.go"));
+  assert!(included("/repo/services/payments/payments.go"));
+  assert!(!included("/repo/services/search/search.go"));
+  assert!(!included(
+    "/repo/services/checkout/internal/legacy/legacy.go"
+  ));
+  assert!(!included("/elsewhere/services/checkout/checkout.go"));
+  // All the paths are included by default
+  assert!(is_included(
+    Path::new("/repo/main.go"),
+    path_to_codebase,
+    &[],
+    &[]
+  ));
+}
@@ -0,0 +1,94 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+//! Finds the usages of a stale flag that are left in the code once all the rules have been applied, i.e. the string
+//! literals of the flag name (e.g. `"new_checkout"` in a call that no rule matched), and the references to the
+//! variables seeded from the flag that were not fully propagated (e.g. `enabled`, once `enabled := exp.Enabled("new_checkout")`
+//! is rewritten to `enabled := true`, if it is still referred). These are reported for a manual review, since the
+//! flag cannot be deleted until they are accounted for.
+//!
+//! The usages are found by name only, across languages (i.e. any string literal node and any identifier node).
+
+use tree_sitter::Node;
+use tree_sitter_traversal::{traverse, Order};
+
+/// The quotes delimiting the string literals
+const QUOTES: [char; 3] = ['"', '\'', '`'];
+
+/// The node kinds of the (named) function, method and constructor declarations, across languages
+const FUNCTION_DECLARATION_KINDS: [&str; 7] = [
+  "function_declaration",
+  "method_declaration",
+  "constructor_declaration",
+  "function_definition",
+  "method_definition",
+  "function_item",
+  "method",
+];
+
+/// Returns the usages of the `flag` left in the code (in order), i.e. its string literals and the identifiers referring
+/// to the `variables` seeded from it, along with the variable each usage refers to (if any).
+pub(crate) fn get_unresolved_usages<'a>(
+  root: Node<'a>, code: &str, flag: &str, variables: &[String],
+) -> Vec<(Node<'a>, Option<String>)> {
+  traverse(root.walk(), Order::Pre)
+    .filter_map(|n| {
+      if is_flag_literal(n, code, flag)
+        && !n.parent().map_or(false, |p| is_flag_literal(p, code, flag))
+      {
+        return Some((n, None));
+      }
+      if n.child_count() > 0 || !n.kind().contains("identifier") {
+        return None;
+      }
+      let text = n.utf8_text(code.as_bytes()).ok()?;
+      variables
+        .iter()
+        .find(|v| v.as_str() == text)
+        .map(|v| (n, Some(v.to_string())))
+    })
+    .collect()
+}
+
+/// Returns the name of the (innermost named) function, method or constructor declaring the node, if any.
+/// The anonymous functions (e.g. the Go function literals) are skipped.
+pub(crate) fn get_enclosing_function(node: Node, code: &str) -> Option<String> {
+  let mut parent = node.parent();
+  while let Some(p) = parent {
+    if FUNCTION_DECLARATION_KINDS.contains(&p.kind()) {
+      if let Some(name) = p.child_by_field_name("name") {
+        return name.utf8_text(code.as_bytes()).ok().map(String::from);
+      }
+    }
+    parent = p.parent();
+  }
+  None
+}
+
+/// Returns the (trimmed) line of the code at the 0-based `row`.
+pub(crate) fn get_line(code: &str, row: usize) -> String {
+  code.lines().nth(row).unwrap_or_default().trim().to_string()
+}
+
+/// Checks if the node is a string literal (or the content of one) whose value is the flag name.
+/// The string literals are identified by their kind, e.g. `interpreted_string_literal` (Go) or `string_fragment` (Java).
+fn is_flag_literal(node: Node, code: &str, flag: &str) -> bool {
+  node.kind().contains("string")
+    && node
+      .utf8_text(code.as_bytes())
+      .map_or(false, |t| t.trim_matches(&QUOTES[..]) == flag)
+}
+
+#[cfg(test)]
+#[path = "unit_tests/unresolved_usages_test.rs"]
+mod unresolved_usages_test;
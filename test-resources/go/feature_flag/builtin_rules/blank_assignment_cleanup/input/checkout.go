@@ -0,0 +1,54 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import (
+	"fmt"
+
+	"github.com/uber/exp"
+)
+
+func Checkout() string {
+	enabled := exp.BoolValue("new_checkout")
+	_ = enabled
+	return "checkout"
+}
+
+func Render() {
+	mode := loadMode()
+	_ = mode
+	if exp.BoolValue("new_checkout") {
+		fmt.Println("new")
+	} else {
+		fmt.Println(mode)
+	}
+}
+
+func Track() {
+	tracked := exp.BoolValue("tracking")
+	_ = tracked
+	_ = tracked
+	if !exp.BoolValue("new_checkout") {
+		fmt.Println(tracked)
+	}
+	fmt.Println("tracked")
+}
+
+func Audit() {
+	audited := exp.BoolValue("audit")
+	_ = audited
+	if exp.BoolValue("new_checkout") {
+		fmt.Println(audited)
+	}
+}
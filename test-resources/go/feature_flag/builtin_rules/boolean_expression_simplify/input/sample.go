@@ -230,3 +230,23 @@ func simplify_identity_neq_nil() {
         fmt.Println("keep")
     }
 }
+
+// simplify nested parenthesized expressions:
+// (true && something) || other -> something || other
+// (false && something) || other -> other
+// ((true)) && something -> something
+// false || f1() -> f1(), the call is kept
+func simplify_nested_parenthesized(something bool, other bool) {
+    if (exp.BoolValue("true") && something) || other {
+        fmt.Println("something or other")
+    }
+    if (exp.BoolValue("false") && something) || other {
+        fmt.Println("only other")
+    }
+    if ((exp.BoolValue("true"))) && something {
+        fmt.Println("only something")
+    }
+    if exp.BoolValue("false") || f1() {
+        fmt.Println("keep call")
+    }
+}
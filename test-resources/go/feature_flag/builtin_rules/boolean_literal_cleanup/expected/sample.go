@@ -0,0 +1,75 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+// De Morgan's law is applied when a boolean literal cancels:
+// !(a || true) -> !a && false -> false
+// !(false && f1()) -> true || !f1() -> true
+// !(f1() && true) -> !f1() || false -> !f1()
+func de_morgan_with_literal(a bool) {
+	fmt.Println("kept 1")
+	fmt.Println("kept 2")
+	if !f1() {
+		fmt.Println("negated call")
+	}
+	// does not simplify; the call may contain side-effects
+	if !(f1() || true) {
+		fmt.Println("keep as it is")
+	}
+}
+
+// De Morgan's law is not applied without a boolean literal child
+func de_morgan_without_literal(a bool, b bool) {
+	if !(a || b) {
+		fmt.Println("left alone")
+	}
+}
+
+// Double negations left over after the flag is substituted are collapsed
+// !(!a && true) -> !(!a) -> a
+// !!(!a || false) -> !!(!a) -> !a
+func double_negation(a bool) {
+	if a {
+		fmt.Println("a")
+	}
+	if !a {
+		fmt.Println("not a")
+	}
+}
+
+// The negations are pushed through the parentheses, until the condition is fully reduced
+// !(a == b && true) -> !(a == b)
+// !(!(false || a)) -> !(!a) -> a
+// !(a && (!false && b)) -> !(a && (true && b)) -> !(a && (b)) -> !(a && b)
+// !true || b -> false || b -> b
+func nested_negation(a bool, b bool) {
+	if !(a == b) {
+		fmt.Println("not equal")
+	}
+	if a {
+		fmt.Println("a")
+	}
+	if !(a && b) {
+		fmt.Println("not a and b")
+	}
+	if b {
+		fmt.Println("b")
+	}
+	// does not simplify; the call is evaluated before the literal
+	if !(f1() && false) {
+		fmt.Println("keep as it is")
+	}
+}
@@ -0,0 +1,37 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "github.com/uber/exp"
+
+// TODO: remove after new_checkout ships
+func checkout(cart []string) int {
+	// Compute the totals
+	enabled := exp.BoolValue("new_checkout")
+	total := len(cart)
+	if !enabled {
+		// The legacy flow
+		legacyCheckout(cart)
+	}
+	// Charge the customer
+	return total
+}
+
+// newCheckoutEnabled reports whether the new checkout flow is enabled.
+func newCheckoutEnabled() bool {
+	return exp.BoolValue("new_checkout")
+}
+
+// Keep the reminder about new_checkout_v2
+func legacyCheckout(cart []string) {}
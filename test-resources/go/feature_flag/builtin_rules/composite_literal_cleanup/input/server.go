@@ -0,0 +1,59 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package server
+
+import "github.com/uber/exp"
+
+type Options struct {
+	Timeout        int
+	EnableBatching bool
+}
+
+type Backend struct {
+	Name        string
+	EnableRetry bool
+}
+
+// The unkeyed composite literal retains the field
+var defaultOptions = Options{10, true}
+
+func newOptions() Options {
+	return Options{Timeout: 5, EnableBatching: exp.BoolValue("false")}
+}
+
+func newBatchOptions() []*Options {
+	return []*Options{
+		{
+			Timeout:        5,
+			EnableBatching: exp.BoolValue("false"),
+		},
+	}
+}
+
+func newBackends() []Backend {
+	return []Backend{
+		{Name: "primary", EnableRetry: exp.BoolValue("true")},
+		{
+			Name:        "secondary",
+			EnableRetry: exp.BoolValue("true"),
+		},
+	}
+}
+
+func backendsByName() map[string]*Backend {
+	return map[string]*Backend{
+		"primary": {Name: "primary", EnableRetry: exp.BoolValue("true")},
+	}
+}
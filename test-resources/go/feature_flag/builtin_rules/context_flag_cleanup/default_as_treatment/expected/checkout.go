@@ -0,0 +1,38 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import (
+	"context"
+
+	"github.com/uber/exp"
+)
+
+func Checkout(ctx context.Context, cart Cart) string {
+	return legacyCheckout(cart)
+}
+
+func Items(ctx context.Context) []string {
+	items := []string{"book"}
+	return items
+}
+
+func Banner(ctx context.Context) string {
+	return ""
+}
+
+// The other flags, and the calls passing the flag name at another position, are left as is
+func Other(ctx context.Context) bool {
+	return exp.BoolValueCtx(ctx, "other_flag", true) || exp.BoolValueCtx("new_checkout", ctx)
+}
@@ -0,0 +1,67 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/uber/exp"
+)
+
+// The declaration of `err` is deleted, so its first assignment becomes its declaration
+func assignment_after_deleted_declaration() error {
+	err := validate("str")
+	return err
+}
+
+// `s` is declared in the same scope, so it is redeclared along with `err`
+func tuple_assignment_after_deleted_declaration() error {
+	s := "str"
+	s, err := compute(), validate(s)
+	fmt.Println(s)
+	return err
+}
+
+// The redeclaration of `err` (legal because `s` is new) becomes its declaration, once the first one is deleted
+func redeclaration_after_deleted_declaration() error {
+	s, err := compute(), validate("str")
+	fmt.Println(s)
+	return err
+}
+
+// `s, err := ...` does not declare any new variable, once `enabled` is split off
+func split_tuple_redeclaration() error {
+	s, err := exp.StrValue("str")
+	s, err = compute(), validate(s)
+	fmt.Println(s)
+	return err
+}
+
+// ... unless it is in a nested block, where it shadows `s` and `err`
+func split_tuple_redeclaration_in_nested_block() error {
+	s, err := exp.StrValue("str")
+	if s != "" {
+		s, err := compute(), validate(s)
+		fmt.Println(s, err)
+	}
+	return err
+}
+
+func compute() string {
+	return "computed"
+}
+
+func validate(s string) error {
+	return nil
+}
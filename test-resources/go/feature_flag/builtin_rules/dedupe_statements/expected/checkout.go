@@ -0,0 +1,42 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+func Checkout(cart Cart) {
+	log.Info("checkout started")
+	newCheckout(cart)
+}
+
+func Retries(cart Cart) int {
+	retries := 1
+	retries = maxRetries
+	return retries
+}
+
+// The calls that are not deemed free of side effects, the ones with nested calls, and the compound assignments are
+// retained
+func Submit(cart Cart) {
+	cart.Save()
+	cart.Save()
+	log.Info("items", len(cart.Items))
+	log.Info("items", len(cart.Items))
+	cart.Count += 1
+	cart.Count += 1
+}
+
+// Not rewritten by the cleanup
+func Cancel(cart Cart) {
+	log.Info("checkout cancelled")
+	log.Info("checkout cancelled")
+}
@@ -0,0 +1,66 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import (
+	"fmt"
+	"github.com/uber/exp"
+	"log"
+)
+
+type renderer interface {
+	flush()
+}
+
+type page struct{}
+
+// flush is a no-op, implementing renderer
+func (p page) flush() {}
+
+// noop is empty before the cleanup, hence it is retained (along with its calls)
+func noop() {}
+
+// renderBanner and trackLegacy are still called, hence these are retained (along with their calls), unlike
+// legacyAudit and legacyFooter
+func (p page) renderBanner() {
+	if exp.BoolValue("old_checkout") {
+		log.Print("legacy banner")
+	}
+}
+
+func trackLegacy(user string) {
+	if exp.BoolValue("old_checkout") {
+		log.Print(user)
+	}
+}
+
+func legacyAudit() {
+	if exp.BoolValue("old_checkout") {
+		log.Print("legacy audit")
+	}
+}
+
+func (p page) legacyFooter() {
+	if exp.BoolValue("old_checkout") {
+		log.Print("legacy footer")
+	}
+}
+
+func (p page) Render(user string) {
+	p.renderBanner()
+	defer trackLegacy(user)
+	noop()
+	p.flush()
+	fmt.Println(user)
+}
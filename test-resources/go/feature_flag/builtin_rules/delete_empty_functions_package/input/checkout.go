@@ -0,0 +1,41 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import (
+	"github.com/uber/exp"
+	"log"
+)
+
+type page struct{}
+
+// renderLegacyBanner is still called from banner.go, hence it is retained
+func renderLegacyBanner() {
+	if exp.BoolValue("old_checkout") {
+		log.Print("legacy banner")
+	}
+}
+
+// flush implements flusher (declared in banner.go), hence it is retained
+func (p page) flush() {
+	if exp.BoolValue("old_checkout") {
+		log.Print("legacy flush")
+	}
+}
+
+func logLegacy() {
+	if exp.BoolValue("old_checkout") {
+		log.Print("legacy")
+	}
+}
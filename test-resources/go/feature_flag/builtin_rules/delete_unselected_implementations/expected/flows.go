@@ -0,0 +1,43 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+// CheckoutFlow runs the checkout
+type CheckoutFlow interface {
+	Run() error
+}
+
+type newFlow struct{}
+
+func (f newFlow) Run() error {
+	return nil
+}
+
+// Receipt renders the receipt of the checkout
+type Receipt interface {
+	Render() string
+}
+
+type plainReceipt struct{}
+
+func (r plainReceipt) Render() string {
+	return "plain"
+}
+
+// LegacyReceipt is still rendered by the external tests of the package
+type LegacyReceipt struct{}
+
+func (r LegacyReceipt) Render() string {
+	return "legacy"
+}
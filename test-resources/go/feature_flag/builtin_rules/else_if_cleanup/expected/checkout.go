@@ -0,0 +1,61 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import "fmt"
+
+// The head arm is dropped, the next one takes its place
+func Head(region string) {
+	if region == "eu" {
+		fmt.Println("eu")
+	} else {
+		fmt.Println("other")
+	}
+}
+
+// The middle arm is dropped, the enclosing `if` statement is followed by the next arm
+func Middle(region string) {
+	if region == "eu" {
+		fmt.Println("eu")
+	} else if region == "us" {
+		fmt.Println("us")
+	} else {
+		fmt.Println("other")
+	}
+}
+
+// The tail arm is dropped, along with the `else` of the enclosing `if` statement
+func Tail(region string) {
+	if region == "eu" {
+		fmt.Println("eu")
+	} else if region == "us" {
+		fmt.Println("us")
+	}
+}
+
+// Same as `Tail`, but for an `if` statement with an initializer
+func TailWithInitializer() {
+	if region := currentRegion(); region == "eu" {
+		fmt.Println("eu")
+	}
+}
+
+// The true arm becomes the last one, the subsequent arms are never reached
+func TrueArm(region string) {
+	if region == "eu" {
+		fmt.Println("eu")
+	} else {
+		fmt.Println("new")
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+// The `else` left empty by the cleanup is deleted
+func empty_else(user string) {
+	if user == "" {
+		fmt.Println("anonymous")
+	}
+}
+
+func empty_else_with_initializer(user string) {
+	if n := len(user); n > 10 {
+		fmt.Println("long", n)
+	}
+}
+
+// The `if` statement left empty by the cleanup is deleted, unless its condition has side effects
+func empty_error_check(user string) error {
+	err := validate(user)
+	if save(user) != nil {
+	}
+	return err
+}
+
+// The block declaring `msg` is retained, since `msg` is also declared by the enclosing function
+func colliding_block(user string) {
+	msg := "welcome"
+	{
+		msg := "welcome back"
+		fmt.Println(msg, user)
+	}
+	fmt.Println(msg)
+}
+
+// The block is unwrapped, and the `return` now ending the function is deleted
+func trailing_return(p page) {
+	banner := p.banner()
+	p.draw(banner)
+}
+
+// The `return` ending the function is retained, since it returns a value
+func trailing_return_with_result(p page) error {
+	banner := p.banner()
+	return p.draw(banner)
+}
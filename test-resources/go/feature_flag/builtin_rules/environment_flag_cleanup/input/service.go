@@ -0,0 +1,44 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func newPath() string {
+	enabled, _ := strconv.ParseBool(os.Getenv("ENABLE_NEW_PATH"))
+	if enabled {
+		return "new"
+	}
+	return "old"
+}
+
+func legacyPath() string {
+	if os.Getenv("ENABLE_NEW_PATH") == "false" {
+		return "legacy"
+	}
+	return "current"
+}
+
+func checkout() {
+	if os.Getenv("ENABLE_NEW_PATH") != "0" {
+		fmt.Println("new checkout")
+	}
+	if "1" == os.Getenv("ENABLE_NEW_PATH") {
+		fmt.Println("new cart")
+	}
+}
@@ -0,0 +1,34 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "fmt"
+
+// `legacy` is left unused once the flag variable is resolved (after the branch is deleted),
+// which does not cascade to the cleanup of the unused variables
+func checkout(mode string) bool {
+	legacy := mode == "legacy"
+	enabled := exp.BoolValue("new_checkout")
+	if !enabled {
+		fmt.Println("legacy checkout")
+	}
+	return enabled || legacy
+}
+
+// The other functions are not rewritten
+func refund(mode string) bool {
+	legacy := mode == "legacy"
+	return legacy
+}
@@ -0,0 +1,38 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+// Checkout renders the checkout page.
+func Checkout() {
+	fmt.Println("checkout")
+	fmt.Println("done")
+}
+
+// Cart renders the cart.
+func Cart() {
+	// Renders the cart items.
+	fmt.Println("cart")
+}
+
+// The comments that are not immediately adjacent to the deleted guard are retained.
+func Banner() {
+	// FLAG: new_checkout
+
+	// FLAG: new_checkout
+	fmt.Println("banner")
+}
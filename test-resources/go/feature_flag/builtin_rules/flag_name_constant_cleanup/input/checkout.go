@@ -0,0 +1,38 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import (
+	"fmt"
+
+	"github.com/uber/exp"
+)
+
+func Checkout(total int) int {
+	if exp.BoolValue(newCheckoutFlag) {
+		fmt.Println("new checkout")
+		return total * 2
+	}
+	return total
+}
+
+func Legacy(total int) int {
+	if !exp.BoolValue(legacyCheckoutFlag) {
+		return total + 1
+	}
+	if exp.BoolValue(newCacheFlag) {
+		return total
+	}
+	return total - 1
+}
@@ -0,0 +1,38 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import "github.com/uber/exp"
+
+var experiments = map[string]func() bool{
+	"new_checkout": func() bool {
+		return exp.BoolValue("new_checkout")
+	},
+	"dark_mode": func() bool { return exp.BoolValue("dark_mode") },
+}
+
+var settings = struct {
+	Name     string
+	Defaults map[string]bool
+}{
+	Name:     "checkout",
+	Defaults: map[string]bool{"dark_mode": false, "new_checkout": true},
+}
+
+var overrides = map[string]bool{}
+
+func init() {
+	overrides["new_checkout"] = exp.BoolValue("new_checkout")
+	overrides["dark_mode"] = exp.BoolValue("dark_mode")
+}
@@ -0,0 +1,103 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "github.com/uber/exp"
+
+func checkout(user string) string {
+	if exp.BoolValue("true") {
+		if user == "" {
+			return "anonymous"
+		} else {
+			log(user)
+		}
+		for _, item := range items(user) {
+			if item == "" {
+				continue
+			} else {
+				log(item)
+			}
+		}
+	}
+	return "new"
+}
+
+func panics(user string) {
+	if exp.BoolValue("false") {
+		log("legacy")
+	} else {
+		if user == "" {
+			panic("empty user")
+		} else {
+			log(user)
+		}
+	}
+}
+
+// The alternative declares a variable, which could clash with the enclosing block
+func declarations(user string) string {
+	if exp.BoolValue("true") {
+		if user == "" {
+			return "anonymous"
+		} else {
+			name := "user " + user
+			log(name)
+		}
+	}
+	return user
+}
+
+func elseIf(user string) string {
+	if exp.BoolValue("true") {
+		if user == "" {
+			return "anonymous"
+		} else if user == "admin" {
+			panic("admin")
+		} else {
+			log(user)
+		}
+	}
+	return user
+}
+
+// The variables of the initializer are scoped to the alternative too
+func initializer(user string) error {
+	if exp.BoolValue("true") {
+		if err := check(user); err != nil {
+			return err
+		} else {
+			log(user)
+		}
+	}
+	return nil
+}
+
+func notTerminating(user string) {
+	if exp.BoolValue("true") {
+		if user == "" {
+			log("anonymous")
+		} else {
+			log(user)
+		}
+	}
+}
+
+// No flag guard is collapsed in this function
+func unrelated(user string) string {
+	if user == "" {
+		return "anonymous"
+	} else {
+		return user
+	}
+}
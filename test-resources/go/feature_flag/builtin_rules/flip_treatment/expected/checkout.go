@@ -0,0 +1,54 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import (
+	"context"
+
+	"github.com/uber/exp"
+)
+
+func Checkout(ctx context.Context, cart Cart) string {
+	if exp.BoolValueCtx(ctx, "new_checkout", true) {
+		return newCheckout(cart)
+	}
+	return legacyCheckout(cart)
+}
+
+func Items(ctx context.Context) []string {
+	items := []string{"book"}
+	enabled := exp.BoolValueCtx(
+		ctx,
+		"new_checkout",
+		true,
+	)
+	if enabled {
+		items = append(items, "gift")
+	}
+	return items
+}
+
+func Banner() string {
+	if !exp.BoolValueWithDefault("new_checkout", true) {
+		return ""
+	}
+	return "Try the new checkout"
+}
+
+// The calls already defaulting to the treated value, the other flags, and the calls passing the flag name at another
+// position, are left as is
+func Other(ctx context.Context) bool {
+	return exp.BoolValueCtx(ctx, "new_checkout", true) || exp.BoolValueCtx(ctx, "other_flag", false) ||
+		exp.BoolValue("other_flag") || exp.BoolValueCtx("new_checkout", ctx)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// The loop guarded by a disabled flag is deleted
+func poll_disabled() {
+	for exp.BoolValue("false") {
+		poll()
+		time.Sleep(time.Second)
+	}
+	fmt.Println("done")
+}
+
+// The loop guarded by an enabled flag never ends, hence the statements after it are unreachable
+func poll_enabled() {
+	for exp.BoolValue("true") {
+		poll()
+		time.Sleep(time.Second)
+	}
+	fmt.Println("unreachable")
+}
+
+// The statements after the loop are kept, since the loop may break
+func poll_until_done(done func() bool) {
+	for exp.BoolValue("true") {
+		if done() {
+			break
+		}
+		poll()
+	}
+	fmt.Println("done")
+}
+
+// The flag is dropped from the condition of the three-clause loops
+func retry(n int) {
+	for i := 0; i < n && exp.BoolValue("true"); i++ {
+		poll()
+	}
+	for i := 0; exp.BoolValue("false"); i++ {
+		poll()
+	}
+	for i := 0; exp.BoolValue("true"); i++ {
+		if i > n {
+			return
+		}
+		poll()
+	}
+}
@@ -0,0 +1,40 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import "github.com/uber/flags"
+
+const defaultCheckout = false
+
+func Checkout(cart Cart) string {
+	return newCheckout(cart)
+}
+
+func Items() []string {
+	items := []string{"book"}
+	return append(items, "gift")
+}
+
+func Banner() string {
+	return "Try the new checkout"
+}
+
+func Footer() string {
+	return "New checkout"
+}
+
+// The flag names that are not string literals are reported, and the other flags are left as is
+func Other(name string) bool {
+	return flags.Flag[bool](name, false) || flags.Flag("other_flag", true)
+}
@@ -0,0 +1,53 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func if_else_true_assignment() time.Duration {
+	var timeout time.Duration = 5 * time.Second
+	return timeout
+}
+
+func if_else_false_assignment() time.Duration {
+	var timeout time.Duration = 30 * time.Second
+	return timeout
+}
+
+func if_true_assignment() string {
+	var name string = "enabled"
+	return name
+}
+
+func if_else_multiple_assignments() (time.Duration, int) {
+	var timeout time.Duration = 30 * time.Second
+	var retries int = 3
+	return timeout, retries
+}
+
+func if_else_assignment_with_call() time.Duration {
+	var timeout time.Duration
+	timeout = 5 * time.Second
+	fmt.Println("short timeout")
+	return timeout
+}
+
+func if_else_assignment_referring_variable() int {
+	var count int
+	count = count + 1
+	return count
+}
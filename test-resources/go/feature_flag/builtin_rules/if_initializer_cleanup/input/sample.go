@@ -0,0 +1,68 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "fmt"
+
+func if_init_treated() {
+    if enabled := exp.BoolValue("true"); enabled {
+        fmt.Println("treatment")
+    } else {
+        fmt.Println("control")
+    }
+}
+
+func if_init_control() {
+    if enabled := exp.BoolValue("false"); enabled {
+        fmt.Println("treatment")
+    } else {
+        fmt.Println("control")
+    }
+    fmt.Println("after")
+}
+
+func if_init_negated() string {
+    if ok := exp.BoolValue("true"); !ok {
+        return "control"
+    }
+    return "treatment"
+}
+
+func if_init_negated_control() string {
+    if ok := exp.BoolValue("false"); !ok {
+        return "control"
+    }
+    return "treatment"
+}
+
+func if_init_in_conjunction(x int) {
+    if enabled := exp.BoolValue("true"); enabled && x > 0 {
+        fmt.Println("treatment")
+    }
+}
+
+func if_init_hoists_other_variables() {
+    if enabled, s := exp.BoolValue("true"), compute(); enabled {
+        fmt.Println(s)
+    } else {
+        fmt.Println("control")
+    }
+}
+
+func if_init_unrelated() {
+    if v := compute(); v > 0 {
+        fmt.Println(v)
+    }
+}
@@ -0,0 +1,58 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupFlags(mockFlags *MockFlags) {
+	mockFlags.EXPECT().BoolValue("new_checkout").Return(true).AnyTimes()
+}
+
+func setupFlagsMock(flagsMock *FlagsMock) {
+	flagsMock.On("BoolValue", "new_checkout").Return(true)
+	flagsMock.On("BoolValue", "legacy_cart").Return(false)
+}
+
+func TestCheckout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockFlags := NewMockFlags(ctrl)
+	setupFlags(mockFlags)
+	mockFlags.EXPECT().
+		BoolValue(gomock.Any(), "new_checkout").
+		Return(true).
+		Times(1)
+	mockFlags.EXPECT().BoolValue("legacy_cart").Return(false)
+	mockFlags.EXPECT().BoolValue("new_checkout").DoAndReturn(func(name string) bool {
+		return true
+	})
+	assertCheckout(t, mockFlags)
+}
+
+func TestCart(t *testing.T) {
+	flagsMock := new(FlagsMock)
+	setupFlagsMock(flagsMock)
+	flagsMock.On("BoolValue", mock.Anything, "new_checkout").
+		Return(true).
+		Once()
+	flagsMock.On("StrValue", "checkout_variant").Return("control")
+	t.Run("cached", func(t *testing.T) {
+		flagsMock.On("BoolValue", "new_checkout").Return(false)
+		assertCart(t, flagsMock)
+	})
+}
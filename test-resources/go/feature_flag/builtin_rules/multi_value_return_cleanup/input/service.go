@@ -0,0 +1,49 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "fmt"
+
+func handle() string {
+	newPath, batching := flags()
+	if batching {
+		fmt.Println("batching")
+	}
+	if newPath {
+		return "new path"
+	}
+	return "old path"
+}
+
+func retry() {
+	newPath, _ := flags()
+	if !newPath {
+		fmt.Println("retrying on the old path")
+	}
+}
+
+// The variable is reassigned, hence the call site is left as is
+func fallback() bool {
+	newPath, _ := flags()
+	if len(fmt.Sprint()) > 0 {
+		newPath = false
+	}
+	return newPath
+}
+
+// The pair is passed along, hence the call is reported
+func forward() (bool, bool) {
+	return flags()
+}
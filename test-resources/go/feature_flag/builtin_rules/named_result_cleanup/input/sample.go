@@ -0,0 +1,69 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+func named_result_unused() (result string) {
+	if exp.BoolValue("false") {
+		result = "enabled"
+	}
+	return "disabled"
+}
+
+func named_result_with_bare_return() (result string) {
+	if exp.BoolValue("false") {
+		result = "enabled"
+	}
+	fmt.Println("done")
+	return
+}
+
+func named_error_with_bare_return() (err error) {
+	if exp.BoolValue("false") {
+		err = fmt.Errorf("failed")
+		return
+	}
+	cleanup := func() {
+		fmt.Println("cleanup")
+		return
+	}
+	cleanup()
+	return
+}
+
+func named_results_unused() (value int, err error) {
+	if exp.BoolValue("false") {
+		value = 10
+	}
+	return 0, nil
+}
+
+// `err` is still referred, so the names are retained
+func named_results_with_bare_return() (value int, err error) {
+	if exp.BoolValue("false") {
+		value = 10
+	}
+	err = validate()
+	return
+}
+
+// `result` is still referred, so the name is retained
+func named_result_still_used() (result string) {
+	result = "default"
+	if exp.BoolValue("true") {
+		result = "enabled"
+	}
+	return
+}
@@ -0,0 +1,51 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+// The outer (enabled) flag is collapsed first, then the inner (disabled) one
+func nested_flags() {
+	if exp.BoolValue("true") {
+		if exp.BoolValue("false") {
+			fmt.Println("inner")
+		} else {
+			fmt.Println("outer only")
+		}
+	} else {
+		fmt.Println("legacy")
+	}
+}
+
+func nested_flags_in_alternative() {
+	if exp.BoolValue("false") {
+		fmt.Println("legacy")
+	} else {
+		if exp.BoolValue("true") {
+			fmt.Println("enabled")
+		}
+	}
+}
+
+// The case of the inner flag is deleted w.r.t. the switch enclosing it once the outer flag is collapsed
+func nested_flag_in_switch(user string) {
+	if exp.BoolValue("true") {
+		switch {
+		case exp.BoolValue("false"):
+			fmt.Println("beta", user)
+		case user == "":
+			fmt.Println("anonymous")
+		}
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+// Each operand position of `&&` / `||` against each boolean literal
+func side_effect_free(x bool) {
+	fmt.Println(x && exp.BoolValue("true"))
+	fmt.Println(exp.BoolValue("true") && x)
+	fmt.Println(x && exp.BoolValue("false"))
+	fmt.Println(exp.BoolValue("false") && x)
+	fmt.Println(x || exp.BoolValue("true"))
+	fmt.Println(exp.BoolValue("true") || x)
+	fmt.Println(x || exp.BoolValue("false"))
+	fmt.Println(exp.BoolValue("false") || x)
+}
+
+// Same matrix, but the non-literal operand is a call that may have side effects
+func with_side_effects() {
+	fmt.Println(foo() && exp.BoolValue("true"))
+	fmt.Println(exp.BoolValue("true") && foo())
+	fmt.Println(foo() && exp.BoolValue("false"))
+	fmt.Println(exp.BoolValue("false") && foo())
+	fmt.Println(foo() || exp.BoolValue("true"))
+	fmt.Println(exp.BoolValue("true") || foo())
+	fmt.Println(foo() || exp.BoolValue("false"))
+	fmt.Println(exp.BoolValue("false") || foo())
+}
+
+// The negated expressions, where the non-literal operand is a call that may have side effects
+func negated_with_side_effects() {
+	fmt.Println(!(foo() && exp.BoolValue("false")))
+	fmt.Println(!(foo() || exp.BoolValue("true")))
+	fmt.Println(!(foo() && exp.BoolValue("true")))
+	fmt.Println(!(exp.BoolValue("false") || foo()))
+}
@@ -0,0 +1,55 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+type Mode bool
+
+func canCheckout(user User) bool {
+	if user.Verified && exp.BoolValue("new_checkout") {
+		return true
+	} else {
+		return false
+	}
+}
+
+func isLegacy(user User) bool {
+	if !exp.BoolValue("new_checkout") || user.Beta {
+		return false
+	}
+	return true
+}
+
+func needsReview(user User) bool {
+	if user.Admin || user.Trusted || !exp.BoolValue("new_checkout") {
+		return false
+	} else {
+		return true
+	}
+}
+
+// The result is not a `bool`
+func betaMode(user User) Mode {
+	if user.Beta && exp.BoolValue("new_checkout") {
+		return true
+	}
+	return false
+}
+
+// Not rewritten by the cleanup
+func isAdmin(user User) bool {
+	if user.Admin {
+		return true
+	}
+	return false
+}
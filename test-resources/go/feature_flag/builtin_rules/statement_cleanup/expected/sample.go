@@ -15,78 +15,191 @@ package main
 
 import "fmt"
 
-func a() bool {
-    return true
-}
-
 func b() string {
-    s, err := exp.StrValue("str")
-    if err != nil {
-        fmt.Println(err)
-    }
+	s, err := exp.StrValue("str")
+	if err != nil {
+		fmt.Println(err)
+	}
 
-    return s
+	return s
 }
 
 func c() string {
-    s, err := exp.StrValue("str")
-    if err != nil {
-        fmt.Println(err)
-    }
+	s, err := exp.StrValue("str")
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	fmt.Println("not enabled")
+	return "prefix_" + s
+}
+
+// `s` is only referred in the deleted branch
+func unused_after_branch_deletion() string {
+	_, _ = exp.StrValue("str")
 
-    fmt.Println("not enabled")
-    return "prefix_" + s
+	return "enabled"
+}
+
+// only `s` becomes unused, `err` is still referred
+func partially_unused_after_branch_deletion() (string, error) {
+	_, err := exp.StrValue("str")
+	return "enabled", err
+}
+
+// `prefix` has no side-effects, and is deleted
+func unused_literal_after_branch_deletion() string {
+	return "disabled"
 }
 
 func after_return1() string {
-    return "not enabled"
+	return "not enabled"
 }
 
 func after_return_nil_1() string {
-    fmt.Println("Retain")
-    return "not enabled"
+	fmt.Println("Retain")
+	return "not enabled"
 }
 
 func after_return_nil_2() string {
-    return "not enabled"
+	return "not enabled"
 }
 
 func after_return2(a bool) string {
-    if a {
-        fmt.Println("not enabled")
-        return "not enabled"
-    }
-    // delete after return needs to consider blocks
-    fmt.Println("should not be removed")
-    return "keep"
+	if a {
+		fmt.Println("not enabled")
+		return "not enabled"
+	}
+	// delete after return needs to consider blocks
+	fmt.Println("should not be removed")
+	return "keep"
 }
 
 // should remove multiple statements after return
 func after_return3() string {
-    return "not enabled"
+	return "not enabled"
 }
 
 func after_return4() string {
-    fmt.Println("before 1")
-    fmt.Println("before 2")
+	fmt.Println("before 1")
+	fmt.Println("before 2")
 
-    return "not enabled"
+	return "not enabled"
 }
 
-
 func simplify_if_statement_false_comment_demo_single_comment() {
-    fmt.Println("remain")
+	fmt.Println("remain")
 }
 
-
 func simplify_if_statement_false_comment_demo_double_comment() {
-    fmt.Println("remain")
+	fmt.Println("remain")
 }
 
 func simplify_if_statement_false_comment_demo_multiline_comment() {
-    fmt.Println("remain")
+	fmt.Println("remain")
 }
 
 func simplify_if_statement_false_comment_demo_multiline_comment_one_line() {
-    fmt.Println("remain")
+	fmt.Println("remain")
+}
+
+func continue_in_loop(n int) {
+	for i := 0; i < n; i++ {
+		continue
+	}
+}
+
+func break_in_loop(n int) {
+	for i := 0; i < n; i++ {
+		fmt.Println(i)
+		break
+	}
+	fmt.Println("after loop")
+}
+
+func labeled_jump_in_nested_loop(n int) {
+outer:
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			break outer
+		}
+		fmt.Println("after inner loop")
+		for j := 0; j < n; j++ {
+			continue outer
+		}
+		fmt.Println("after second inner loop")
+	}
+}
+
+func break_in_select(ch chan int, done chan bool) {
+	for {
+		select {
+		case <-ch:
+			break
+		case <-done:
+			return
+		}
+		fmt.Println("after select")
+	}
+}
+
+func split_tuple_declaration() {
+	s := compute()
+	fmt.Println(s)
+}
+
+func split_triple_declaration() {
+	s, err := compute(), validate()
+	fmt.Println(s, err)
+}
+
+func delete_tuple_declaration() {
+	fmt.Println("new")
+}
+
+func split_redeclaration() error {
+	s, err := exp.StrValue("str")
+	err = validate(s)
+	return err
+}
+
+func unused_cascade_after_branch_deletion(name string) string {
+	return name
+}
+
+func suppressed_unused_variable_after_branch_deletion() string {
+	debug := "verbose"
+	_ = debug
+	return "enabled"
+}
+
+func shadowed_unused_variable_after_branch_deletion() string {
+	s := "outer"
+	for i := 0; i < 2; i++ {
+		fmt.Println(i)
+	}
+	return s
+}
+
+func defer_after_return() string {
+	defer fmt.Println("registered")
+	return "enabled"
+}
+
+func label_after_return(retry bool) string {
+	if retry {
+		goto fallback
+	}
+	return "enabled"
+fallback:
+	fmt.Println("fallback")
+	return "fallback"
+}
+
+func return_in_case_clause(n int) string {
+	switch n {
+	case 1:
+		return "one"
+	}
+	return "other"
 }
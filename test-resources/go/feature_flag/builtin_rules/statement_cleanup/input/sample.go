@@ -52,6 +52,44 @@ func c() string {
     }
 }
 
+// `s` is only referred in the deleted branch
+func unused_after_branch_deletion() string {
+    enabled := exp.BoolValue("true")
+
+    s, err := exp.StrValue("str")
+    if err != nil {
+        fmt.Println(err)
+    }
+
+    if enabled {
+        return "enabled"
+    } else {
+        return "prefix_" + s
+    }
+}
+
+// only `s` becomes unused, `err` is still referred
+func partially_unused_after_branch_deletion() (string, error) {
+    enabled := exp.BoolValue("true")
+
+    s, err := exp.StrValue("str")
+    if enabled {
+        return "enabled", err
+    }
+    return s, nil
+}
+
+// `prefix` has no side-effects, and is deleted
+func unused_literal_after_branch_deletion() string {
+    enabled := exp.BoolValue("false")
+
+    prefix := "prefix_"
+    if enabled {
+        return prefix + "enabled"
+    }
+    return "disabled"
+}
+
 func after_return1() string {
     enabled := exp.BoolValue("false")
     if !enabled {
@@ -187,3 +225,171 @@ func simplify_if_statement_false_comment_demo_multiline_comment_one_line() {
         fmt.Println("to be removed 2")
     }
 }
+
+func continue_in_loop(n int) {
+    for i := 0; i < n; i++ {
+        if exp.BoolValue("true") {
+            continue
+        }
+        fmt.Println("skipped")
+    }
+}
+
+func break_in_loop(n int) {
+    for i := 0; i < n; i++ {
+        fmt.Println(i)
+        if exp.BoolValue("false") {
+            fmt.Println("keep looping")
+        } else {
+            break
+        }
+        fmt.Println("unreachable")
+    }
+    fmt.Println("after loop")
+}
+
+func labeled_jump_in_nested_loop(n int) {
+outer:
+    for i := 0; i < n; i++ {
+        for j := 0; j < n; j++ {
+            if exp.BoolValue("true") {
+                break outer
+            }
+            fmt.Println(i, j)
+        }
+        fmt.Println("after inner loop")
+        for j := 0; j < n; j++ {
+            if exp.BoolValue("true") {
+                continue outer
+            }
+            fmt.Println(j)
+        }
+        fmt.Println("after second inner loop")
+    }
+}
+
+func break_in_select(ch chan int, done chan bool) {
+    for {
+        select {
+        case <-ch:
+            if exp.BoolValue("true") {
+                break
+            }
+            fmt.Println("received")
+        case <-done:
+            return
+        }
+        fmt.Println("after select")
+    }
+}
+
+func split_tuple_declaration() {
+    enabled, s := exp.BoolValue("true"), compute()
+    if enabled {
+        fmt.Println(s)
+    }
+}
+
+func split_triple_declaration() {
+    s, enabled, err := compute(), exp.BoolValue("false"), validate()
+    if enabled {
+        return
+    }
+    fmt.Println(s, err)
+}
+
+func delete_tuple_declaration() {
+    enabled, legacy := exp.BoolValue("true"), exp.BoolValue("false")
+    if enabled && !legacy {
+        fmt.Println("new")
+    } else {
+        fmt.Println("legacy")
+    }
+}
+
+func split_redeclaration() error {
+    s, err := exp.StrValue("str")
+    enabled, err := exp.BoolValue("true"), validate(s)
+    if !enabled {
+        return nil
+    }
+    return err
+}
+
+// `greeting` is only referred in the deleted branch, and then `prefix` is not referred anymore
+func unused_cascade_after_branch_deletion(name string) string {
+    enabled := exp.BoolValue("false")
+
+    prefix := "Hello, "
+    var greeting = prefix + name
+    if enabled {
+        return greeting
+    }
+    return name
+}
+
+// `_ = debug` suppresses the error for `debug`, so both are retained
+func suppressed_unused_variable_after_branch_deletion() string {
+    enabled := exp.BoolValue("true")
+
+    debug := "verbose"
+    _ = debug
+    if enabled {
+        return "enabled"
+    }
+    return debug
+}
+
+// the shadowing `s` is not referred anymore, while the outer `s` still is
+func shadowed_unused_variable_after_branch_deletion() string {
+    enabled := exp.BoolValue("false")
+
+    s := "outer"
+    for i := 0; i < 2; i++ {
+        s := "inner"
+        if enabled {
+            return s
+        }
+        fmt.Println(i)
+    }
+    return s
+}
+
+// the deferred call after the return is never registered
+func defer_after_return() string {
+    enabled := exp.BoolValue("true")
+    defer fmt.Println("registered")
+    if enabled {
+        return "enabled"
+    }
+    defer fmt.Println("not registered")
+    go fmt.Println("not started")
+    return "disabled"
+}
+
+// the statements following the label are reachable through `goto`
+func label_after_return(retry bool) string {
+    enabled := exp.BoolValue("true")
+    if retry {
+        goto fallback
+    }
+    if enabled {
+        return "enabled"
+    }
+    fmt.Println("not reachable")
+fallback:
+    fmt.Println("fallback")
+    return "fallback"
+}
+
+func return_in_case_clause(n int) string {
+    enabled := exp.BoolValue("true")
+    switch n {
+    case 1:
+        if enabled {
+            return "one"
+        }
+        fmt.Println("not reachable")
+    }
+    return "other"
+}
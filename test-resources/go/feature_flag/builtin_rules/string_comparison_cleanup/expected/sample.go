@@ -0,0 +1,55 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func equal() {
+	fmt.Println("full")
+}
+
+func not_equal() {
+	fmt.Println("on")
+	fmt.Println("done")
+}
+
+func equal_fold() string {
+	return "full"
+}
+
+func intermediate_variable() {
+	fmt.Println("on")
+	fmt.Println("full")
+}
+
+func switch_on_intermediate_variable() {
+	fmt.Println("full")
+}
+
+// the error handling block is deleted along with the call
+func intermediate_variable_with_error() (string, error) {
+	return "full", nil
+}
+
+// the comparisons against a non literal value are reported
+func compare_with_variable(expected string) bool {
+	return "full" == expected
+}
+
+func equal_fold_with_variable(expected string) bool {
+	return strings.EqualFold("full", expected)
+}
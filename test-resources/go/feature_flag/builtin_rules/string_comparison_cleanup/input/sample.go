@@ -0,0 +1,87 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func equal() {
+	if exp.StrValue("rollout_mode") == "full" {
+		fmt.Println("full")
+	} else {
+		fmt.Println("partial")
+	}
+}
+
+func not_equal() {
+	if "off" != exp.StrValue("rollout_mode") {
+		fmt.Println("on")
+	}
+	fmt.Println("done")
+}
+
+func equal_fold() string {
+	if strings.EqualFold(exp.StrValue("rollout_mode"), "off") {
+		return "off"
+	}
+	if strings.EqualFold(exp.StrValue("rollout_mode"), "full") {
+		return "full"
+	}
+	return "partial"
+}
+
+func intermediate_variable() {
+	mode := exp.StrValue("rollout_mode")
+	if mode != "off" {
+		fmt.Println("on")
+	}
+	if mode == "beta" {
+		fmt.Println("beta")
+	}
+	fmt.Println(mode)
+}
+
+func switch_on_intermediate_variable() {
+	mode := exp.StrValue("rollout_mode")
+	switch mode {
+	case "off":
+		fmt.Println("off")
+	case "full":
+		fmt.Println("full")
+	}
+}
+
+// the error handling block is deleted along with the call
+func intermediate_variable_with_error() (string, error) {
+	mode, err := exp.StrValue("rollout_mode")
+	if err != nil {
+		fmt.Println(err)
+		return "", err
+	}
+	if mode == "full" {
+		return "full", nil
+	}
+	return mode, nil
+}
+
+// the comparisons against a non literal value are reported
+func compare_with_variable(expected string) bool {
+	return exp.StrValue("rollout_mode") == expected
+}
+
+func equal_fold_with_variable(expected string) bool {
+	return strings.EqualFold(exp.StrValue("rollout_mode"), expected)
+}
@@ -0,0 +1,48 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+// no arm is true, the final else is kept
+func noArmTrue() {
+	fmt.Println("other")
+}
+
+// the true arm replaces the remainder of the chain, the arms preceding it are only dropped when false
+func trueArm(region string) {
+	if region == "eu" {
+		fmt.Println("eu")
+	} else {
+		fmt.Println("full")
+	}
+}
+
+// the false arms interleaved with the other arms are dropped
+func interleavedArms(region string) {
+	if region == "eu" {
+		fmt.Println("eu")
+	} else if region == "us" {
+		fmt.Println("us")
+	}
+}
+
+// the chain nested in the surviving arm is resolved too
+func nestedChain(region string) {
+	if region == "eu" {
+		fmt.Println("full eu")
+	} else {
+		fmt.Println("full")
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+func switch_on_string_flag() {
+	fmt.Println("before")
+	fmt.Println("on")
+	fmt.Println("after")
+}
+
+func switch_on_string_flag_with_fallthrough() {
+	fmt.Println("on")
+	fmt.Println("legacy")
+}
+
+func switch_on_string_flag_default_taken() {
+	fmt.Println("unknown")
+}
+
+func switch_on_string_flag_leading_default() {
+	fmt.Println("on")
+}
+
+func tagless_switch_on_string_flag(x int) {
+	fmt.Println("on")
+}
+
+// the `break` would exit the enclosing loop once the switch is inlined
+func switch_with_break_in_nested_statement(x int) {
+	for {
+		switch "on" {
+		case "on":
+			if x > 5 {
+				break
+			}
+			fmt.Println("on")
+		}
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+func switch_on_string_flag() {
+	fmt.Println("before")
+	switch exp.StrValue("rollout_mode") {
+	case "off":
+		fmt.Println("off")
+	case "beta":
+		fmt.Println("beta")
+	case "on":
+		fmt.Println("on")
+		break
+	default:
+		fmt.Println("unknown")
+	}
+	fmt.Println("after")
+}
+
+func switch_on_string_flag_with_fallthrough() {
+	switch exp.StrValue("rollout_mode") {
+	case "beta":
+		fmt.Println("beta")
+	case "on":
+		fmt.Println("on")
+		fallthrough
+	case "legacy":
+		fmt.Println("legacy")
+	default:
+		fmt.Println("unknown")
+	}
+}
+
+func switch_on_string_flag_default_taken() {
+	switch exp.StrValue("rollout_mode") {
+	case "off":
+		fmt.Println("off")
+	default:
+		fmt.Println("unknown")
+		break
+	case "beta":
+		fmt.Println("beta")
+	}
+}
+
+func switch_on_string_flag_leading_default() {
+	switch exp.StrValue("rollout_mode") {
+	default:
+		fmt.Println("unknown")
+	case "on":
+		fmt.Println("on")
+	}
+}
+
+func tagless_switch_on_string_flag(x int) {
+	switch {
+	case exp.StrValue("rollout_mode") == "off":
+		fmt.Println("off")
+	case exp.StrValue("rollout_mode") == "on":
+		fmt.Println("on")
+	case x > 5:
+		fmt.Println("x > 5")
+	}
+}
+
+// the `break` would exit the enclosing loop once the switch is inlined
+func switch_with_break_in_nested_statement(x int) {
+	for {
+		switch exp.StrValue("rollout_mode") {
+		case "on":
+			if x > 5 {
+				break
+			}
+			fmt.Println("on")
+		}
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+func switch_on_flag_variable() {
+	fmt.Println("treatment")
+}
+
+func switch_on_flag_call() {
+	fmt.Println("control")
+}
+
+func tagless_switch() {
+	fmt.Println("treatment")
+}
+
+func tagless_switch_default(x int) {
+	fmt.Println("default")
+}
+
+func tagless_switch_keeps_other_cases(x int) {
+	switch {
+	case x > 5:
+		fmt.Println("x > 5")
+	default:
+		fmt.Println("default")
+	}
+}
+
+func tagless_switch_with_fallthrough(x int) {
+	fmt.Println("treatment")
+	fmt.Println("x > 5")
+}
+
+func switch_with_empty_case_taken() {
+	fmt.Println("after")
+}
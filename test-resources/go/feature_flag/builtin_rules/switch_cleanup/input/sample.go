@@ -0,0 +1,89 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+func switch_on_flag_variable() {
+    enabled := exp.BoolValue("true")
+    switch enabled {
+    case true:
+        fmt.Println("treatment")
+    case false:
+        fmt.Println("control")
+    }
+}
+
+func switch_on_flag_call() {
+    switch exp.BoolValue("false") {
+    case true:
+        fmt.Println("treatment")
+    case false:
+        fmt.Println("control")
+    }
+}
+
+func tagless_switch() {
+    enabled := exp.BoolValue("true")
+    switch {
+    case enabled:
+        fmt.Println("treatment")
+    default:
+        fmt.Println("control")
+    }
+}
+
+func tagless_switch_default(x int) {
+    enabled := exp.BoolValue("true")
+    switch {
+    case !enabled:
+        fmt.Println("control")
+    default:
+        fmt.Println("default")
+    }
+}
+
+func tagless_switch_keeps_other_cases(x int) {
+    enabled := exp.BoolValue("true")
+    switch {
+    case x > 5:
+        fmt.Println("x > 5")
+    case !enabled:
+        fmt.Println("control")
+    default:
+        fmt.Println("default")
+    }
+}
+
+func tagless_switch_with_fallthrough(x int) {
+    enabled := exp.BoolValue("true")
+    switch {
+    case enabled:
+        fmt.Println("treatment")
+        fallthrough
+    case x > 5:
+        fmt.Println("x > 5")
+    default:
+        fmt.Println("default")
+    }
+}
+
+func switch_with_empty_case_taken() {
+    switch exp.BoolValue("true") {
+    case true:
+    case false:
+        fmt.Println("control")
+    }
+    fmt.Println("after")
+}
@@ -0,0 +1,58 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package checkout
+
+import (
+	"testing"
+
+	"github.com/uber/exp"
+)
+
+var legacyPrices = []int{1, 2, 3}
+
+func TestCheckoutLegacy(t *testing.T) {
+	exp.Override("new_checkout", false)
+	if Checkout() != "old" {
+		t.Fail()
+	}
+}
+
+func TestTotalLegacy(t *testing.T) {
+	newCheckoutEnabled = false
+	for _, price := range legacyPrices {
+		if Total(price) != price+1 {
+			t.Fail()
+		}
+	}
+}
+
+// The test is retained, only its row referring to the stale flag is deleted
+func TestTotal(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  string
+		price int
+		want  int
+	}{
+		{name: "default", price: 1, want: 1},
+		{name: "legacy", flag: "new_checkout", price: 1, want: 2},
+	}
+	for _, tt := range tests {
+		exp.Override(tt.flag, true)
+		if got := Total(tt.price); got != tt.want {
+			t.Errorf("Total() = %v, want %v", got, tt.want)
+		}
+	}
+}
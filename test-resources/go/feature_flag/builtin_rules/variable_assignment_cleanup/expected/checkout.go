@@ -0,0 +1,51 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+type user struct{}
+
+func (u user) InRollout() bool {
+	return true
+}
+
+func checkout() {
+	fmt.Println("checkout")
+	fmt.Println("new checkout")
+}
+
+func render() {
+	fmt.Println("new render")
+}
+
+// The assignment is conditional, so the variable is not resolved
+func rollout(u user) {
+	var enabled bool
+	if u.InRollout() {
+		enabled = true
+	}
+	fmt.Println(enabled)
+}
+
+// The variable is assigned more than once, so it is not resolved
+func retry() {
+	var enabled bool
+	enabled = true
+	fmt.Println(enabled)
+	enabled = false
+	fmt.Println(enabled)
+}
@@ -0,0 +1,55 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "github.com/uber/exp"
+
+type Service struct {
+	name string
+}
+
+func newCheckoutEnabled() bool {
+	enabled := exp.BoolValue("true")
+	return enabled
+}
+
+func (s *Service) legacyDisabled() bool {
+	if exp.BoolValue("false") {
+		return true
+	}
+	return false
+}
+
+// `fallbackEnabled` is referred as a function value
+func fallbackEnabled() bool {
+	return exp.BoolValue("false")
+}
+
+var checks = map[string]func() bool{"fallback": fallbackEnabled}
+
+// `batchingDisabled` is referred as a function value in another file
+func batchingDisabled() bool {
+	return exp.BoolValue("false")
+}
+
+// `darkModeEnabled` is declared by an interface in another file
+func (s *Service) darkModeEnabled() bool {
+	return exp.BoolValue("true")
+}
+
+// IsBetaEnabled is exported, hence it may be called outside the codebase
+func IsBetaEnabled() bool {
+	return exp.BoolValue("true")
+}
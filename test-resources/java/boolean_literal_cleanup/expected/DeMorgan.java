@@ -0,0 +1,39 @@
+/**
+ * Copyright (c) 2023 Uber Technologies, Inc.
+ *
+ * <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * <p>http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ * express or implied. See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package com.uber.piranha;
+
+class DeMorgan {
+
+  // De Morgan's law is applied when a boolean literal cancels
+  void withLiteral(boolean a) {
+    System.out.println("kept");
+    if (!foo()) {
+      System.out.println("negated call");
+    }
+  }
+
+  // De Morgan's law is not applied without a boolean literal child
+  void withoutLiteral(boolean a, boolean b) {
+    if (!(a || b)) {
+      System.out.println("left alone");
+    }
+  }
+
+  // Double negations left over after the flag is substituted are collapsed
+  void doubleNegation(boolean a) {
+    if (a) {
+      System.out.println("a");
+    }
+  }
+}
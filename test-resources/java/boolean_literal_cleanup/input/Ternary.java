@@ -0,0 +1,37 @@
+/**
+ * Copyright (c) 2023 Uber Technologies, Inc.
+ *
+ * <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * <p>http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ * express or implied. See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package com.uber.piranha;
+
+class Ternary {
+
+    // The taken branch replaces a conditional whose condition becomes a literal
+    int inReturn(int a, int b) {
+        return exp.isToggleEnabled(STALE_FLAG) ? a : b;
+    }
+
+    int inAssignment(int a, int b) {
+        int x;
+        x = !exp.isToggleEnabled(STALE_FLAG) ? a : b;
+        return x;
+    }
+
+    void asArgument(String a, String b) {
+        System.out.println((exp.isToggleEnabled(STALE_FLAG)) ? a : b);
+    }
+
+    // The condition does not become a literal
+    int notConstant(boolean c, int a, int b) {
+        return c ? a : b;
+    }
+}
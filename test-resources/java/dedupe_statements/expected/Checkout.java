@@ -0,0 +1,45 @@
+/**
+ * Copyright (c) 2023 Uber Technologies, Inc.
+ *
+ * <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * <p>http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ * express or implied. See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package com.uber.piranha;
+
+class Checkout {
+
+  void checkout(Cart cart) {
+    log.info("checkout started");
+    newCheckout(cart);
+  }
+
+  int retries(Cart cart) {
+    int retries = 1;
+    retries = MAX_RETRIES;
+    return retries;
+  }
+
+  // The calls that are not deemed free of side effects, the ones with nested calls, and the compound assignments are
+  // retained
+  void submit(Cart cart) {
+    cart.save();
+    cart.save();
+    log.info("items", cart.items().size());
+    log.info("items", cart.items().size());
+    cart.count += 1;
+    cart.count += 1;
+  }
+
+  // Not rewritten by the cleanup
+  void cancel(Cart cart) {
+    log.info("checkout cancelled");
+    log.info("checkout cancelled");
+  }
+}
@@ -0,0 +1,44 @@
+/**
+ * Copyright (c) 2023 Uber Technologies, Inc.
+ *
+ * <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * <p>http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ * express or implied. See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package com.uber.piranha;
+
+class ElseIf {
+
+  // The head arm is dropped, the next one takes its place
+  void head(String region) {
+    if (region.equals("eu")) {
+      System.out.println("eu");
+    } else {
+      System.out.println("other");
+    }
+  }
+
+  // The middle arm is dropped, the enclosing if statement is followed by the next arm
+  void middle(String region) {
+    if (region.equals("eu")) {
+      System.out.println("eu");
+    } else {
+      System.out.println("other");
+    }
+  }
+
+  // The tail arm is dropped, along with the else of the enclosing if statement
+  void tail(String region) {
+    if (region.equals("eu")) {
+      System.out.println("eu");
+    } else if (region.equals("us")) {
+      System.out.println("us");
+    }
+  }
+}
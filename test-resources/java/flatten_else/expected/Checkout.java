@@ -0,0 +1,80 @@
+/**
+ * Copyright (c) 2023 Uber Technologies, Inc.
+ *
+ * <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * <p>http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ * express or implied. See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package com.uber.piranha;
+
+import java.util.List;
+
+class Checkout {
+
+    String checkout(String user, List<String> items) {
+        if (user == null) {
+            return "anonymous";
+        }
+        log(user);
+        for (String item : items) {
+            if (item.isEmpty()) {
+                continue;
+            }
+            log(item);
+        }
+        return "new";
+    }
+
+    void validate(String user) {
+        if (user == null) {
+            throw new IllegalArgumentException("user");
+        }
+        log(user);
+        log("validated");
+    }
+
+    // The alternative declares a local variable, which could clash with the enclosing block
+    String declarations(String user) {
+        if (user == null) {
+            return "anonymous";
+        } else {
+            String name = "user " + user;
+            log(name);
+        }
+        return user;
+    }
+
+    String elseIf(String user) {
+        if (user == null) {
+            return "anonymous";
+        } else if (user.isEmpty()) {
+            throw new IllegalArgumentException("user");
+        } else {
+            log(user);
+        }
+        return user;
+    }
+
+    void notTerminating(String user) {
+        if (user == null) {
+            log("anonymous");
+        } else {
+            log(user);
+        }
+    }
+
+    // No flag guard is collapsed in this method
+    String unrelated(String user) {
+        if (user == null) {
+            return "anonymous";
+        } else {
+            return user;
+        }
+    }
+}
@@ -0,0 +1,39 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package com.uber.input
+
+class DeMorgan {
+
+    // De Morgan's law is applied when a boolean literal cancels
+    fun withLiteral(a: Boolean) {
+        println("kept")
+        if (!foo()) {
+            println("negated call")
+        }
+    }
+
+    // De Morgan's law is not applied without a boolean literal child
+    fun withoutLiteral(a: Boolean, b: Boolean) {
+        if (!(a || b)) {
+            println("left alone")
+        }
+    }
+
+    // Double negations left over after the flag is substituted are collapsed
+    fun doubleNegation(a: Boolean) {
+        if (a) {
+            println("a")
+        }
+    }
+}
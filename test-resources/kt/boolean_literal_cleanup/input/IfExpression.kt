@@ -0,0 +1,51 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package com.uber.input
+
+class IfExpression {
+
+    // In expression position the taken branch is inlined as a bare expression
+    fun inReturn(a: Int, b: Int): Int {
+        return if (exp.isToggleEnabled(STALE_FLAG)) a else b
+    }
+
+    fun inAssignment(a: Int, b: Int): Int {
+        var x = 0
+        x = if (!exp.isToggleEnabled(STALE_FLAG)) { a } else { b }
+        return x
+    }
+
+    fun asArgument(a: String, b: String) {
+        println(if (exp.isToggleEnabled(STALE_FLAG)) a else b)
+    }
+
+    // A branch with several statements is wrapped in `run` when it is used as a value
+    fun multipleStatements(a: Int): Int {
+        val x = if (exp.isToggleEnabled(STALE_FLAG)) {
+            println("enabled")
+            a
+        } else 0
+        return x
+    }
+
+    // In statement position the statements of the taken branch are inlined
+    fun inStatement() {
+        if (exp.isToggleEnabled(STALE_FLAG)) {
+            println("enabled")
+            println("still enabled")
+        } else {
+            println("disabled")
+        }
+    }
+}
@@ -0,0 +1,53 @@
+<?php
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+function simplify_and() {
+    return abc();
+}
+
+function simplify_not() {
+    return true;
+}
+
+function simplify_or() {
+    return true;
+}
+
+function preserve_side_effect() {
+    return abc() && false;
+}
+
+function simplify_ternary() {
+    return 'new';
+}
+
+function simplify_short_ternary() {
+    return 'old';
+}
+
+function simplify_null_coalescing() {
+    return true;
+}
+
+function simplify_nested_ternary_condition($x) {
+    return $x ? 1 : 2;
+}
+
+function simplify_comparison() {
+    return true;
+}
+
+function simplify_double_negation() {
+    return true;
+}
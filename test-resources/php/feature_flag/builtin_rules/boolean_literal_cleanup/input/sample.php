@@ -0,0 +1,53 @@
+<?php
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+function simplify_and() {
+    return $exp->boolValue('true') && abc();
+}
+
+function simplify_not() {
+    return !$exp->boolValue('false');
+}
+
+function simplify_or() {
+    return $exp->boolValue('true') or abc();
+}
+
+function preserve_side_effect() {
+    return abc() && $exp->boolValue('false');
+}
+
+function simplify_ternary() {
+    return $exp->boolValue('true') ? 'new' : 'old';
+}
+
+function simplify_short_ternary() {
+    return $exp->boolValue('false') ?: 'old';
+}
+
+function simplify_null_coalescing() {
+    return $exp->boolValue('true') ?? false;
+}
+
+function simplify_nested_ternary_condition($x) {
+    return ($exp->boolValue('false') || $x) ? 1 : 2;
+}
+
+function simplify_comparison() {
+    return $exp->boolValue('true') === true;
+}
+
+function simplify_double_negation() {
+    return !!$exp->boolValue('true');
+}
@@ -0,0 +1,75 @@
+<?php
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+function if_true() {
+    echo 'new';
+}
+
+function if_false() {
+    echo 'new';
+}
+
+function if_false_without_else() {
+    echo 'before';
+    echo 'after';
+}
+
+function if_false_with_elseif($user) {
+    if ($user->isAdmin()) {
+        echo 'admin';
+    } else {
+        echo 'new';
+    }
+}
+
+function if_false_with_else_if($user) {
+    if ($user->isAdmin()) {
+        echo 'admin';
+    }
+}
+
+function elseif_false($user) {
+    if ($user->isAdmin()) {
+        echo 'admin';
+    } else {
+        echo 'new';
+    }
+}
+
+function elseif_true($user) {
+    if ($user->isAdmin()) {
+        echo 'admin';
+    } else {
+        echo 'new';
+    }
+}
+
+function ternary() {
+    $label = 'new';
+    return $label;
+}
+
+function inline_variable() {
+    return 'new';
+}
+
+function render() {
+    ?>
+    <p>New checkout</p>
+    <?php
+}
+?>
+<footer>
+<?php ?>
+</footer>
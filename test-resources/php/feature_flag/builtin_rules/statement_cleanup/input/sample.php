@@ -0,0 +1,102 @@
+<?php
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+function if_true() {
+    if ($exp->boolValue('true')) {
+        echo 'new';
+    } else {
+        echo 'old';
+    }
+}
+
+function if_false() {
+    if ($exp->boolValue('false')) {
+        echo 'old';
+    } else {
+        echo 'new';
+    }
+}
+
+function if_false_without_else() {
+    echo 'before';
+    if ($exp->boolValue('false')) {
+        echo 'old';
+    }
+    echo 'after';
+}
+
+function if_false_with_elseif($user) {
+    if ($exp->boolValue('false')) {
+        echo 'old';
+    } elseif ($user->isAdmin()) {
+        echo 'admin';
+    } else {
+        echo 'new';
+    }
+}
+
+function if_false_with_else_if($user) {
+    if ($exp->boolValue('false')) {
+        echo 'old';
+    } else if ($user->isAdmin()) {
+        echo 'admin';
+    }
+}
+
+function elseif_false($user) {
+    if ($user->isAdmin()) {
+        echo 'admin';
+    } elseif ($exp->boolValue('false')) {
+        echo 'old';
+    } else {
+        echo 'new';
+    }
+}
+
+function elseif_true($user) {
+    if ($user->isAdmin()) {
+        echo 'admin';
+    } elseif ($exp->boolValue('true')) {
+        echo 'new';
+    } elseif ($user->isGuest()) {
+        echo 'guest';
+    } else {
+        echo 'old';
+    }
+}
+
+function ternary() {
+    $label = $exp->boolValue('true') ? 'new' : 'old';
+    return $label;
+}
+
+function inline_variable() {
+    $enabled = $exp->boolValue('true');
+    if ($enabled) {
+        return 'new';
+    }
+    return 'old';
+}
+
+function render() {
+    if ($exp->boolValue('true')) {
+        ?>
+        <p>New checkout</p>
+        <?php
+    }
+}
+?>
+<footer>
+<?php if ($exp->boolValue('false')) { echo 'old'; } ?>
+</footer>
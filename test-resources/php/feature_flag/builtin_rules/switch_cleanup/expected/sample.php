@@ -0,0 +1,52 @@
+<?php
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+function pay($method) {
+    switch ($method) {
+        case 'card':
+            echo 'new card';
+            break;
+        case 'cash':
+            echo 'cash';
+            break;
+        case 'voucher':
+            $fee = 2;
+            echo $fee;
+            break;
+        default:
+            echo 'new default';
+    }
+}
+
+function pay_with_elseif($method) {
+    switch ($method) {
+        case 'card':
+        case 'credit':
+            if ($method->isExpired()) {
+                echo 'expired';
+            }
+            break;
+    }
+}
+
+// the flag is the subject of the `switch`, which is not collapsed
+function pay_with_flag() {
+    switch (true) {
+        case true:
+            echo 'new';
+            break;
+        default:
+            echo 'old';
+    }
+}
@@ -0,0 +1,63 @@
+<?php
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+function pay($method) {
+    switch ($method) {
+        case 'card':
+            if ($exp->boolValue('true')) {
+                echo 'new card';
+            } else {
+                echo 'old card';
+            }
+            break;
+        case 'cash':
+            if ($exp->boolValue('false')) {
+                echo 'old cash';
+            }
+            echo 'cash';
+            break;
+        case 'voucher':
+            $fee = $exp->boolValue('false') ? 1 : 2;
+            echo $fee;
+            break;
+        default:
+            if ($exp->boolValue('true')) {
+                echo 'new default';
+            }
+    }
+}
+
+function pay_with_elseif($method) {
+    switch ($method) {
+        case 'card':
+        case 'credit':
+            if ($exp->boolValue('false')) {
+                echo 'old card';
+            } elseif ($method->isExpired()) {
+                echo 'expired';
+            }
+            break;
+    }
+}
+
+// the flag is the subject of the `switch`, which is not collapsed
+function pay_with_flag() {
+    switch ($exp->boolValue('true')) {
+        case true:
+            echo 'new';
+            break;
+        default:
+            echo 'old';
+    }
+}
@@ -0,0 +1,41 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+fn and_or(a: bool) {
+    log(compute());
+    log(compute());
+    log(false);
+    log(false);
+    log(true);
+    log(true);
+    // does not simplify; the call may contain side-effects
+    log(compute() && false);
+    log(compute() || true);
+}
+
+// !(false) -> !false -> true
+// !!a && true -> !!a -> a
+// !(!a || false) -> !(!a) -> a
+fn negation(a: bool) {
+    log(true);
+    log(false);
+    log(a);
+    log(a);
+}
+
+fn comparison(a: bool) {
+    log(a);
+    log(!a);
+    log(!a);
+    log(a);
+}
@@ -0,0 +1,102 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+fn if_statement() {
+    println!("enabled");
+    println!("after");
+}
+
+fn if_statement_false() {
+    println!("disabled");
+}
+
+fn else_if(a: bool) {
+    if a {
+        println!("a");
+    } else {
+        println!("enabled");
+    }
+}
+
+// `if` is an expression, the value of the retained branch is retained
+fn if_expression() -> u32 {
+    let limit = 10;
+    limit * 2
+}
+
+fn tail_if_expression() -> &'static str {
+    "disabled"
+}
+
+// the condition of an `if let` is a pattern match, which is not collapsed
+fn if_let(config: Option<u32>) {
+    if let Some(limit) = config {
+        println!("{}", limit);
+    }
+    if let true = true {
+        println!("matched");
+    }
+}
+
+// the block declaring `_guard` is retained, since the guard is dropped at its end
+fn scoped_guard() {
+    {
+        let _guard = lock();
+        update();
+    }
+    println!("unlocked");
+}
+
+fn match_on_flag() {
+    println!("enabled");
+    println!("after");
+}
+
+fn match_as_value() -> u32 {
+    let retries = {
+        log("disabled");
+        1
+    };
+    retries
+}
+
+fn match_guards(value: Option<u32>) -> u32 {
+    match value {
+        Some(v) => v,
+        _ => 0,
+    }
+}
+
+fn inline_variable() {
+    println!("enabled");
+}
+
+// `enabled` may be re-assigned
+fn mutable_variable_is_retained() {
+    let mut enabled = false;
+    if should_enable() {
+        enabled = true;
+    }
+    log(enabled);
+}
+
+fn after_return() -> &'static str {
+    return "enabled";
+}
+
+fn break_in_loop(items: &[u32]) {
+    for item in items {
+        break;
+    }
+    println!("after loop");
+}
@@ -0,0 +1,136 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+fn if_statement() {
+    if exp.bool_value("true") {
+        println!("enabled");
+    } else {
+        println!("disabled");
+    }
+    println!("after");
+}
+
+fn if_statement_false() {
+    if exp.bool_value("false") {
+        println!("enabled");
+    }
+    if exp.bool_value("false") {
+        println!("enabled");
+    } else {
+        println!("disabled");
+    }
+}
+
+fn else_if(a: bool) {
+    if a {
+        println!("a");
+    } else if exp.bool_value("true") {
+        println!("enabled");
+    } else {
+        println!("disabled");
+    }
+}
+
+// `if` is an expression, the value of the retained branch is retained
+fn if_expression() -> u32 {
+    let limit = if exp.bool_value("true") { 10 } else { 20 };
+    limit * 2
+}
+
+fn tail_if_expression() -> &'static str {
+    if exp.bool_value("false") {
+        "enabled"
+    } else {
+        "disabled"
+    }
+}
+
+// the condition of an `if let` is a pattern match, which is not collapsed
+fn if_let(config: Option<u32>) {
+    if let Some(limit) = config {
+        println!("{}", limit);
+    }
+    if let true = exp.bool_value("true") {
+        println!("matched");
+    }
+}
+
+// the block declaring `_guard` is retained, since the guard is dropped at its end
+fn scoped_guard() {
+    if exp.bool_value("true") {
+        let _guard = lock();
+        update();
+    }
+    println!("unlocked");
+}
+
+fn match_on_flag() {
+    match exp.bool_value("true") {
+        true => println!("enabled"),
+        false => println!("disabled"),
+    }
+    println!("after");
+}
+
+fn match_as_value() -> u32 {
+    let retries = match exp.bool_value("false") {
+        true => 3,
+        false => {
+            log("disabled");
+            1
+        }
+    };
+    retries
+}
+
+fn match_guards(value: Option<u32>) -> u32 {
+    match value {
+        Some(v) if exp.bool_value("true") => v,
+        Some(v) if exp.bool_value("false") => v + 1,
+        _ => 0,
+    }
+}
+
+fn inline_variable() {
+    let enabled = exp.bool_value("true");
+    if enabled {
+        println!("enabled");
+    }
+}
+
+// `enabled` may be re-assigned
+fn mutable_variable_is_retained() {
+    let mut enabled = exp.bool_value("false");
+    if should_enable() {
+        enabled = true;
+    }
+    log(enabled);
+}
+
+fn after_return() -> &'static str {
+    if exp.bool_value("true") {
+        return "enabled";
+    }
+    println!("not reachable");
+    "disabled"
+}
+
+fn break_in_loop(items: &[u32]) {
+    for item in items {
+        if exp.bool_value("true") {
+            break;
+        }
+        println!("{}", item);
+    }
+    println!("after loop");
+}
@@ -0,0 +1,57 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// `check()?` may return early, hence it is retained when it is evaluated before the flag
+fn try_before_flag() -> Result<bool, Error> {
+    Ok(check()? && exp.bool_value("false"))
+}
+
+// `check()?` is never evaluated once the flag is false
+fn try_after_flag() -> Result<bool, Error> {
+    Ok(exp.bool_value("false") && check()?)
+}
+
+fn try_in_retained_branch() -> Result<u32, Error> {
+    if exp.bool_value("true") {
+        return Ok(load()?);
+    }
+    let legacy = load_legacy()?;
+    Ok(legacy)
+}
+
+fn try_in_deleted_branch() -> Result<(), Error> {
+    if exp.bool_value("false") {
+        migrate()?;
+    }
+    save()?;
+    Ok(())
+}
+
+fn match_on_flag_with_try(value: Result<u32, Error>) -> Result<u32, Error> {
+    let retries = match exp.bool_value("true") {
+        true => value?,
+        false => 1,
+    };
+    Ok(retries)
+}
+
+// the condition of an `if let` is a pattern match, which is not collapsed
+fn if_let_with_try(config: Option<u32>) -> Result<(), Error> {
+    if let Some(limit) = config {
+        if exp.bool_value("false") {
+            validate(limit)?;
+        }
+        apply(limit)?;
+    }
+    Ok(())
+}
@@ -0,0 +1,59 @@
+# Copyright (c) 2023 Uber Technologies, Inc.
+#
+# <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+# except in compliance with the License. You may obtain a copy of the License at
+# <p>http://www.apache.org/licenses/LICENSE-2.0
+#
+# <p>Unless required by applicable law or agreed to in writing, software distributed under the
+# License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+# express or implied. See the License for the specific language governing permissions and
+# limitations under the License.
+
+def simplify_and
+  abc
+end
+
+def simplify_not
+  true
+end
+
+def simplify_not_keyword
+  false
+end
+
+def simplify_or
+  true
+end
+
+# The value of `abc && true` is `abc`, hence only the truthiness of the condition is retained
+def preserve_value
+  abc && true
+end
+
+def simplify_condition(abc)
+  if abc
+    puts "on"
+  end
+end
+
+def preserve_side_effect
+  if compute(1) && false
+    puts "on"
+  end
+end
+
+def simplify_ternary
+  "new"
+end
+
+def simplify_nested_ternary_condition(x)
+  1
+end
+
+def simplify_comparison
+  true
+end
+
+def simplify_double_negation
+  true
+end
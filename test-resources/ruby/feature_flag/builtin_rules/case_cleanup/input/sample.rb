@@ -0,0 +1,52 @@
+# Copyright (c) 2023 Uber Technologies, Inc.
+#
+# <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+# except in compliance with the License. You may obtain a copy of the License at
+# <p>http://www.apache.org/licenses/LICENSE-2.0
+#
+# <p>Unless required by applicable law or agreed to in writing, software distributed under the
+# License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+# express or implied. See the License for the specific language governing permissions and
+# limitations under the License.
+
+def pay(method)
+  case method
+  when "card"
+    if exp.bool_value("true")
+      puts "new card"
+    else
+      puts "old card"
+    end
+  when "cash"
+    puts "old cash" if exp.bool_value("false")
+    puts "cash"
+  else
+    unless exp.bool_value("false")
+      return "new"
+    end
+    puts "unreachable"
+  end
+end
+
+def pay_with_elsif(method)
+  case method
+  when "card", "credit"
+    if method.expired?
+      puts "expired"
+    elsif exp.bool_value("false")
+      puts "old card"
+    else
+      puts "new card"
+    end
+  end
+end
+
+# the flag is the subject of the `case`, which is not collapsed
+def pay_with_flag
+  case exp.bool_value("true")
+  when true
+    puts "new"
+  else
+    puts "old"
+  end
+end
@@ -0,0 +1,93 @@
+# Copyright (c) 2023 Uber Technologies, Inc.
+#
+# <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+# except in compliance with the License. You may obtain a copy of the License at
+# <p>http://www.apache.org/licenses/LICENSE-2.0
+#
+# <p>Unless required by applicable law or agreed to in writing, software distributed under the
+# License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+# express or implied. See the License for the specific language governing permissions and
+# limitations under the License.
+
+def if_true
+  puts "on"
+  puts "done"
+end
+
+def if_false
+  puts "off"
+  puts "really off"
+end
+
+def if_false_without_else
+  puts "done"
+end
+
+def if_false_with_elsif(abc)
+  if abc
+    puts "abc"
+  else
+    puts "off"
+  end
+end
+
+def elsif_false(abc)
+  if abc
+    puts "abc"
+  else
+    puts "off"
+  end
+end
+
+def elsif_true(abc)
+  if abc
+    puts "abc"
+  else
+    puts "on"
+  end
+end
+
+def unless_true
+  puts "done"
+end
+
+def unless_false
+  puts "off"
+end
+
+def modifier_if_true
+  puts "on"
+  puts "done"
+end
+
+def modifier_if_false
+  puts "done"
+end
+
+def modifier_unless_true
+  puts "done"
+end
+
+def modifier_unless_false
+  return "off"
+end
+
+def modifier_with_condition(abc)
+  puts "on" if abc
+end
+
+def return_in_branch
+  return "on"
+end
+
+def inline_variable
+  puts "on"
+  puts "done"
+end
+
+# `enabled` is assigned twice, hence it is not inlined
+def reassigned_variable(abc)
+  enabled = true
+  enabled = false if abc
+  puts "on" if enabled
+end
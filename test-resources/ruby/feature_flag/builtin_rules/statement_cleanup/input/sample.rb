@@ -0,0 +1,125 @@
+# Copyright (c) 2023 Uber Technologies, Inc.
+#
+# <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+# except in compliance with the License. You may obtain a copy of the License at
+# <p>http://www.apache.org/licenses/LICENSE-2.0
+#
+# <p>Unless required by applicable law or agreed to in writing, software distributed under the
+# License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+# express or implied. See the License for the specific language governing permissions and
+# limitations under the License.
+
+def if_true
+  if exp.bool_value("true")
+    puts "on"
+  else
+    puts "off"
+  end
+  puts "done"
+end
+
+def if_false
+  if exp.bool_value("false")
+    puts "on"
+  else
+    puts "off"
+    puts "really off"
+  end
+end
+
+def if_false_without_else
+  if exp.bool_value("false")
+    puts "on"
+  end
+  puts "done"
+end
+
+def if_false_with_elsif(abc)
+  if exp.bool_value("false")
+    puts "on"
+  elsif abc
+    puts "abc"
+  else
+    puts "off"
+  end
+end
+
+def elsif_false(abc)
+  if abc
+    puts "abc"
+  elsif exp.bool_value("false")
+    puts "on"
+  else
+    puts "off"
+  end
+end
+
+def elsif_true(abc)
+  if abc
+    puts "abc"
+  elsif exp.bool_value("true")
+    puts "on"
+  else
+    puts "off"
+  end
+end
+
+def unless_true
+  unless exp.bool_value("true")
+    puts "off"
+  end
+  puts "done"
+end
+
+def unless_false
+  unless exp.bool_value("false")
+    puts "off"
+  else
+    puts "on"
+  end
+end
+
+def modifier_if_true
+  puts "on" if exp.bool_value("true")
+  puts "done"
+end
+
+def modifier_if_false
+  puts "on" if exp.bool_value("false")
+  puts "done"
+end
+
+def modifier_unless_true
+  puts "off" unless exp.bool_value("true")
+  puts "done"
+end
+
+def modifier_unless_false
+  return "off" unless exp.bool_value("false")
+  puts "unreachable"
+end
+
+def modifier_with_condition(abc)
+  puts "on" if abc && exp.bool_value("true")
+end
+
+def return_in_branch
+  if exp.bool_value("true")
+    return "on"
+  end
+  puts "unreachable"
+end
+
+def inline_variable
+  enabled = exp.bool_value("true")
+  puts "on" if enabled
+  puts "off" unless enabled
+  puts "done"
+end
+
+# `enabled` is assigned twice, hence it is not inlined
+def reassigned_variable(abc)
+  enabled = exp.bool_value("true")
+  enabled = false if abc
+  puts "on" if enabled
+end
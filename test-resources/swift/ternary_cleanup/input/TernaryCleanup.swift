@@ -0,0 +1,32 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+// 
+// <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+// except in compliance with the License. You may obtain a copy of the License at
+// <p>http://www.apache.org/licenses/LICENSE-2.0
+// 
+// <p>Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+class TernaryCleanup {
+    // The taken branch replaces a ternary whose condition becomes a literal
+    func inReturn(a: Int, b: Int) -> Int {
+        return TestEnum.stale_flag_one.isEnabled ? a : b
+    }
+
+    func inAssignment(a: Int, b: Int) -> Int {
+        var x = 0
+        x = !TestEnum.stale_flag_one.isEnabled ? a : b
+        return x
+    }
+
+    func asArgument(a: String, b: String) {
+        print(TestEnum.stale_flag_one.isEnabled ? a : b)
+    }
+
+    // The condition does not become a literal
+    func notConstant(c: Bool, a: Int, b: Int) -> Int {
+        return c ? a : b
+    }
+}
@@ -0,0 +1,33 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+function canCheckout(user: User): boolean {
+  if (user.verified && flags.isEnabled("new_checkout")) {
+    return true;
+  } else {
+    return false;
+  }
+}
+
+function isLegacy(user: User): boolean {
+  if (!flags.isEnabled("new_checkout") || user.beta) {
+    return false;
+  }
+  return true;
+}
+
+// Not rewritten by the cleanup
+function isAdmin(user: User): boolean {
+  if (user.admin) {
+    return true;
+  }
+  return false;
+}
@@ -9,7 +9,7 @@
 # express or implied. See the License for the specific language governing permissions and
 # limitations under the License.
 from pathlib import Path
-from polyglot_piranha import Filter, execute_piranha, PiranhaArguments, PiranhaOutputSummary, Rule, RuleGraph, OutgoingEdges
+from polyglot_piranha import Filter, execute_piranha, execute_piranha_on_content, PiranhaArguments, PiranhaOutputSummary, Rule, RuleGraph, OutgoingEdges
 from os.path import join, basename
 from os import listdir
 import re
@@ -177,6 +177,109 @@ def test_delete_unused_field():
     )
 
 
+def test_rules_as_dicts():
+    # Same as `test_delete_unused_field`, with the rule (and its filter) passed as a dict
+    delete_unused_field = {
+        "name": "delete_unused_field",
+        "query": """(
+        ((field_declaration
+            declarator: (_) @id_name) @decl)
+        (#match? @decl "^private")
+        )
+        """,
+        "replace_node": "decl",
+        "replace": "",
+        "filters": [
+            {
+                "enclosing_node": "(class_declaration ) @c_cd",
+                "contains": """(
+                    (identifier) @name
+                    (#eq? @name "@id_name")
+                )""",
+                "at_most": 1,
+            }
+        ],
+    }
+
+    args = PiranhaArguments(
+        path_to_codebase= "test-resources/java/delete_unused_field/input",
+        language="java",
+        rules=[delete_unused_field],
+        dry_run=True,
+    )
+
+    output_summaries = execute_piranha(args)
+    assert is_as_expected(
+        "test-resources/java/delete_unused_field/", output_summaries
+    )
+
+
+def test_rules_and_edges_as_objects_and_dicts():
+    rename_field = Rule(
+        name="rename_field",
+        query="((variable_declarator name: (identifier) @name) @decl)",
+        replace_node="name",
+        replace="renamed",
+    )
+
+    # The edge (passed as a dict) refers to a rule that is not passed
+    with pytest.raises(BaseException, match="refers to `missing_rule`"):
+        PiranhaArguments(
+            path_to_codebase= "test-resources/java/delete_unused_field/input",
+            language="java",
+            rules=[rename_field],
+            edges=[{"from": "rename_field", "to": ["missing_rule"], "scope": "Parent"}],
+            dry_run=True,
+        )
+
+    with pytest.raises(BaseException, match="should be an object or a dict"):
+        PiranhaArguments(language="java", rules=["rename_field"])
+
+
+def test_execute_piranha_on_content():
+    append_l = Rule(
+        name="append_l",
+        query="""(
+        (variable_declarator value: (decimal_integer_literal) @value)
+        (#not-match? @value "l|L")
+        )""",
+        replace_node="value",
+        replace="@valuel",
+    )
+    rule_graph = RuleGraph(rules=[append_l], edges=[])
+
+    content, output_summary = execute_piranha_on_content(
+        "class A { long a = 1; }", "java", rule_graph
+    )
+    assert content == "class A { long a = 1l; }"
+    assert len(output_summary.rewrites) == 1
+
+    # Only the second line is cleaned up
+    content, output_summary = execute_piranha_on_content(
+        "class A {\n  long a = 1;\n  long b = 2;\n}", "java", rule_graph, (2, 2)
+    )
+    assert content == "class A {\n  long a = 1l;\n  long b = 2;\n}"
+    assert [(rule, m.range.start_point.row) for rule, m in output_summary.out_of_range_matches] == [("append_l", 2)]
+
+
+def test_flag_states():
+    scenario = "test-resources/go/feature_flag/builtin_rules/flag_states"
+    for state, expected in [(True, "expected_enabled"), (False, "expected_disabled")]:
+        args = PiranhaArguments(
+            path_to_configurations=join(scenario, "configurations"),
+            language="go",
+            substitutions={"flag_methods": "Enabled|EnabledFor"},
+            path_to_codebase=join(scenario, "input"),
+            flags_file=join(scenario, "configurations", "flags.json"),
+            flag_states={"new_checkout": state},
+            cleanup_imports=True,
+            dry_run=True,
+        )
+        output_summaries = execute_piranha(args)
+        assert len(output_summaries) == 1
+        assert is_as_expected(scenario, output_summaries, expected)
+
+
 def test_incorrect_import():
     delete_unused_field = Rule (
         name= "delete_unused_field",
@@ -194,8 +297,8 @@ def test_incorrect_import():
             edges = []
             )
 
-def is_as_expected(path_to_scenario, output_summary):
-    expected_output = join(path_to_scenario, "expected")
+def is_as_expected(path_to_scenario, output_summary, expected="expected"):
+    expected_output = join(path_to_scenario, expected)
     input_dir = join(path_to_scenario, "input")
     for file_name in listdir(expected_output):
         with open(join(expected_output, file_name), "r") as f: